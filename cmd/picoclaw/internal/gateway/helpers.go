@@ -13,6 +13,7 @@ import (
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/api"
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
@@ -49,7 +50,10 @@ func gatewayCmd(debug bool) error {
 	}
 
 	msgBus := bus.NewMessageBus()
-	agentLoop := agent.NewAgentLoop(cfg, msgBus, provider)
+	agentLoop, err := agent.NewAgentLoop(cfg, msgBus, provider)
+	if err != nil {
+		return fmt.Errorf("error creating agent: %w", err)
+	}
 
 	// Print agent startup info
 	fmt.Println("\n📦 Agent Status:")
@@ -189,6 +193,15 @@ func gatewayCmd(debug bool) error {
 	}
 
 	healthServer := health.NewServer(cfg.Gateway.Host, cfg.Gateway.Port)
+	healthServer.RegisterDetail("rate_limits", func() any { return providers.RateLimitStatuses() })
+	if cfg.Gateway.API.Enabled {
+		if len(cfg.Gateway.API.Tokens) == 0 {
+			fmt.Println("⚠ Warning: gateway.api is enabled but has no tokens; API disabled")
+		} else {
+			healthServer.Handle("/v1/", api.NewHandler(agentLoop, cfg.Gateway.API.Tokens))
+			fmt.Printf("✓ OpenAI-compatible API available at http://%s:%d/v1\n", cfg.Gateway.Host, cfg.Gateway.Port)
+		}
+	}
 	go func() {
 		if err := healthServer.Start(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.ErrorCF("health", "Health server error", map[string]any{"error": err.Error()})
@@ -203,9 +216,6 @@ func gatewayCmd(debug bool) error {
 	<-sigChan
 
 	fmt.Println("\nShutting down...")
-	if cp, ok := provider.(providers.StatefulProvider); ok {
-		cp.Close()
-	}
 	cancel()
 	healthServer.Stop(context.Background())
 	deviceService.Stop()
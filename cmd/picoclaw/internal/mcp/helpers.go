@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
+)
+
+func mcpServeCmd(httpMode bool, agentID string) error {
+	cfg, err := internal.LoadConfig()
+	if err != nil {
+		return fmt.Errorf("error loading config: %w", err)
+	}
+
+	serveCfg := cfg.Tools.MCP.Serve
+	if agentID == "" {
+		agentID = serveCfg.Agent
+	}
+	registry, err := agent.NewToolServerRegistry(cfg, agentID)
+	if err != nil {
+		return err
+	}
+
+	server := mcp.NewServer(registry, mcp.ServerOptions{
+		Name:           "picoclaw",
+		Version:        internal.GetVersion(),
+		Allow:          serveCfg.Tools,
+		Token:          serveCfg.Token,
+		AllowedOrigins: serveCfg.AllowedOrigins,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if !httpMode {
+		// stdout carries the protocol; logs go to stderr.
+		return server.ServeStdio(ctx, os.Stdin, os.Stdout)
+	}
+
+	addr := net.JoinHostPort(serveCfg.Host, strconv.Itoa(serveCfg.Port))
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", server)
+	httpServer := &http.Server{Addr: addr, Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		httpServer.Shutdown(context.Background())
+	}()
+
+	logger.InfoCF("mcp", "Serving MCP over HTTP", map[string]any{
+		"url": fmt.Sprintf("http://%s/mcp", addr),
+	})
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package mcp
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func newServeCommand() *cobra.Command {
+	var (
+		httpMode bool
+		agentID  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Publish picoclaw tools as an MCP server",
+		Long: `Publish an agent's local tools (filesystem, exec, academic search,
+i2c/spi) as an MCP server. By default the server speaks MCP over stdio, so
+other assistants and IDEs can launch it as a subprocess. With --http it
+listens on tools.mcp.serve.host:port instead.`,
+		Args: cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return mcpServeCmd(httpMode, agentID)
+		},
+	}
+
+	cmd.Flags().BoolVar(&httpMode, "http", false, "Serve streamable HTTP instead of stdio")
+	cmd.Flags().StringVarP(&agentID, "agent", "a", "", "Agent whose tools to serve (default: tools.mcp.serve.agent)")
+
+	return cmd
+}
@@ -0,0 +1,26 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewServeSubcommand(t *testing.T) {
+	cmd := newServeCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "serve", cmd.Use)
+	assert.Equal(t, "Publish picoclaw tools as an MCP server", cmd.Short)
+
+	assert.Nil(t, cmd.Run)
+	assert.NotNil(t, cmd.RunE)
+
+	assert.False(t, cmd.HasSubCommands())
+
+	assert.True(t, cmd.HasFlags())
+	assert.NotNil(t, cmd.Flags().Lookup("http"))
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+}
@@ -0,0 +1,231 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+const timeFormat = "2006-01-02 15:04"
+
+// ollamaServer is an Ollama server and the model_list entries it serves.
+type ollamaServer struct {
+	host    string
+	client  *providers.OllamaProvider
+	entries []config.ModelConfig
+}
+
+// ollamaServers returns the Ollama servers of cfg's model_list, or only the
+// one at host when it is set. Without ollama entries, the local server is
+// used.
+func ollamaServers(cfg *config.Config, host string) []*ollamaServer {
+	var servers []*ollamaServer
+	byHost := map[string]*ollamaServer{}
+	add := func(apiBase, apiKey, proxy string) *ollamaServer {
+		apiBase = providers.OllamaAPIBase(apiBase)
+		if s, ok := byHost[apiBase]; ok {
+			return s
+		}
+		s := &ollamaServer{
+			host:   apiBase,
+			client: providers.NewOllamaProvider(apiKey, apiBase, proxy, 0, providers.OllamaSettings{}),
+		}
+		byHost[apiBase] = s
+		servers = append(servers, s)
+		return s
+	}
+
+	if host != "" {
+		add(host, "", "")
+	}
+	for _, mc := range cfg.ModelList {
+		if protocol, _ := providers.ExtractProtocol(mc.Model); protocol != "ollama" {
+			continue
+		}
+		apiBase := providers.OllamaAPIBase(mc.APIBase)
+		if host != "" && apiBase != providers.OllamaAPIBase(host) {
+			continue
+		}
+		s := add(apiBase, mc.APIKey, mc.Proxy)
+		s.entries = append(s.entries, mc)
+	}
+	if len(servers) == 0 {
+		add("", "", "")
+	}
+	return servers
+}
+
+// modelNames returns the model_name of each entry of s by the tagged model
+// it uses.
+func (s *ollamaServer) modelNames() map[string][]string {
+	names := map[string][]string{}
+	for _, mc := range s.entries {
+		_, id := providers.ExtractProtocol(mc.Model)
+		tag := providers.OllamaModelTag(id)
+		names[tag] = append(names[tag], mc.ModelName)
+	}
+	return names
+}
+
+func modelsListCmd(ctx context.Context, w io.Writer, servers []*ollamaServer) error {
+	var failed int
+	for i, s := range servers {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		local, err := s.client.ListModels(ctx)
+		if err != nil {
+			fmt.Fprintf(w, "Ollama at %s: %v\n", s.host, err)
+			failed++
+			continue
+		}
+		writeModels(w, s, local)
+	}
+	if failed == len(servers) {
+		return fmt.Errorf("no Ollama server could be reached")
+	}
+	return nil
+}
+
+// writeModels prints the models of server s, followed by the models its
+// entries use that it does not have yet.
+func writeModels(w io.Writer, s *ollamaServer, local []providers.OllamaModel) {
+	names := s.modelNames()
+	fmt.Fprintf(w, "Ollama at %s:\n", s.host)
+	if len(local) == 0 && len(names) == 0 {
+		fmt.Fprintln(w, "No models.")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSIZE\tPARAMS\tQUANT\tMODIFIED\tMODEL_NAME")
+	pulled := map[string]bool{}
+	for _, m := range local {
+		tag := providers.OllamaModelTag(m.Name)
+		pulled[tag] = true
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", m.Name, formatSize(m.Size),
+			orDash(m.Details.ParameterSize), orDash(m.Details.QuantizationLevel),
+			m.ModifiedAt.Local().Format(timeFormat), orDash(strings.Join(names[tag], ", ")))
+	}
+
+	var missing []string
+	for tag := range names {
+		if !pulled[tag] {
+			missing = append(missing, tag)
+		}
+	}
+	sort.Strings(missing)
+	for _, tag := range missing {
+		fmt.Fprintf(tw, "%s\t-\t-\t-\tnot pulled\t%s\n", tag, strings.Join(names[tag], ", "))
+	}
+	tw.Flush()
+}
+
+// modelsPullCmd pulls model to the server whose entries use it, or the first
+// server. Without a model, it pulls every model the entries of each server
+// use that the server does not have.
+func modelsPullCmd(ctx context.Context, w io.Writer, servers []*ollamaServer, model string) error {
+	if model != "" {
+		target := servers[0]
+		for _, s := range servers {
+			if _, ok := s.modelNames()[providers.OllamaModelTag(model)]; ok {
+				target = s
+				break
+			}
+		}
+		return pull(ctx, w, target, model)
+	}
+
+	var pulled int
+	for _, s := range servers {
+		local, err := s.client.ListModels(ctx)
+		if err != nil {
+			return fmt.Errorf("listing models of %s: %w", s.host, err)
+		}
+		have := map[string]bool{}
+		for _, m := range local {
+			have[providers.OllamaModelTag(m.Name)] = true
+		}
+		var missing []string
+		for tag := range s.modelNames() {
+			if !have[tag] {
+				missing = append(missing, tag)
+			}
+		}
+		sort.Strings(missing)
+		for _, tag := range missing {
+			if err := pull(ctx, w, s, tag); err != nil {
+				return err
+			}
+			pulled++
+		}
+	}
+	if pulled == 0 {
+		fmt.Fprintln(w, "All models in model_list are pulled.")
+	}
+	return nil
+}
+
+func pull(ctx context.Context, w io.Writer, s *ollamaServer, model string) error {
+	fmt.Fprintf(w, "Pulling %s to %s\n", model, s.host)
+	printer := &progressPrinter{w: w}
+	err := s.client.Pull(ctx, model, printer.print)
+	printer.done()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "✓ Pulled %s\n", model)
+	return nil
+}
+
+// progressPrinter prints pull progress, rewriting the line of a download in
+// place.
+type progressPrinter struct {
+	w       io.Writer
+	inPlace bool
+}
+
+func (p *progressPrinter) print(progress providers.OllamaPullProgress) {
+	if progress.Total > 0 {
+		fmt.Fprintf(p.w, "\r  %s %3d%% (%s / %s)", progress.Status,
+			progress.Completed*100/progress.Total, formatSize(progress.Completed), formatSize(progress.Total))
+		p.inPlace = true
+		return
+	}
+	p.done()
+	fmt.Fprintf(p.w, "  %s\n", progress.Status)
+}
+
+// done ends a line rewritten in place.
+func (p *progressPrinter) done() {
+	if p.inPlace {
+		fmt.Fprintln(p.w)
+		p.inPlace = false
+	}
+}
+
+func formatSize(bytes int64) string {
+	const unit = 1000
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "kMGTPE"[exp])
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
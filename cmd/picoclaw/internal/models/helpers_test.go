@@ -0,0 +1,112 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// newOllamaServer serves /api/tags with models and records pulled models.
+func newOllamaServer(t *testing.T, models ...string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var pulled []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			var entries []string
+			for _, m := range models {
+				entries = append(entries, fmt.Sprintf(
+					`{"name":%q,"size":4661224676,"details":{"parameter_size":"8B","quantization_level":"Q4_K_M"}}`, m))
+			}
+			fmt.Fprintf(w, `{"models":[%s]}`, strings.Join(entries, ","))
+		case "/api/pull":
+			var req struct{ Model string }
+			json.NewDecoder(r.Body).Decode(&req)
+			pulled = append(pulled, req.Model)
+			fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+			fmt.Fprintln(w, `{"status":"downloading","total":2000000000,"completed":1000000000}`)
+			fmt.Fprintln(w, `{"status":"success"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &pulled
+}
+
+func TestOllamaServers(t *testing.T) {
+	cfg := &config.Config{ModelList: []config.ModelConfig{
+		{ModelName: "llama", Model: "ollama/llama3.1", APIBase: "http://box:11434/v1"},
+		{ModelName: "qwen", Model: "ollama/qwen3:4b", APIBase: "http://box:11434"},
+		{ModelName: "local", Model: "ollama/phi4"},
+		{ModelName: "gpt", Model: "openai/gpt-4o"},
+	}}
+
+	servers := ollamaServers(cfg, "")
+	require.Len(t, servers, 2)
+	assert.Equal(t, "http://box:11434", servers[0].host)
+	assert.Len(t, servers[0].entries, 2)
+	assert.Equal(t, "http://localhost:11434", servers[1].host)
+
+	servers = ollamaServers(cfg, "http://box:11434/")
+	require.Len(t, servers, 1)
+	assert.Len(t, servers[0].entries, 2)
+
+	servers = ollamaServers(&config.Config{}, "")
+	require.Len(t, servers, 1)
+	assert.Equal(t, "http://localhost:11434", servers[0].host)
+}
+
+func TestModelsListCmd(t *testing.T) {
+	server, _ := newOllamaServer(t, "llama3.1:latest", "nomic-embed-text:latest")
+	cfg := &config.Config{ModelList: []config.ModelConfig{
+		{ModelName: "llama", Model: "ollama/llama3.1", APIBase: server.URL},
+		{ModelName: "qwen", Model: "ollama/qwen3:4b", APIBase: server.URL},
+	}}
+
+	var out bytes.Buffer
+	require.NoError(t, modelsListCmd(context.Background(), &out, ollamaServers(cfg, "")))
+	list := out.String()
+	assert.Contains(t, list, "Ollama at "+server.URL+":")
+	assert.Regexp(t, `llama3\.1:latest +4\.7 GB +8B +Q4_K_M +\S+ \S+ +llama`, list)
+	assert.Regexp(t, `nomic-embed-text:latest +4\.7 GB .* -\n`, list)
+	assert.Regexp(t, `qwen3:4b +- +- +- +not pulled +qwen`, list)
+
+	server.Close()
+	assert.Error(t, modelsListCmd(context.Background(), &out, ollamaServers(cfg, "")))
+}
+
+func TestModelsPullCmd(t *testing.T) {
+	server, pulled := newOllamaServer(t, "llama3.1:latest")
+	cfg := &config.Config{ModelList: []config.ModelConfig{
+		{ModelName: "llama", Model: "ollama/llama3.1", APIBase: server.URL},
+		{ModelName: "qwen", Model: "ollama/qwen3:4b", APIBase: server.URL},
+	}}
+	servers := ollamaServers(cfg, "")
+
+	var out bytes.Buffer
+	require.NoError(t, modelsPullCmd(context.Background(), &out, servers, ""))
+	assert.Equal(t, []string{"qwen3:4b"}, *pulled)
+	assert.Contains(t, out.String(), "Pulling qwen3:4b to "+server.URL)
+	assert.Contains(t, out.String(), "\r  downloading  50% (1.0 GB / 2.0 GB)\n  success\n")
+	assert.Contains(t, out.String(), "✓ Pulled qwen3:4b")
+
+	require.NoError(t, modelsPullCmd(context.Background(), &out, servers, "phi4"))
+	assert.Equal(t, []string{"qwen3:4b", "phi4"}, *pulled)
+}
+
+func TestFormatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 kB", formatSize(1500))
+	assert.Equal(t, "4.7 GB", formatSize(4661224676))
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+)
+
+func newListCommand() *cobra.Command {
+	var host string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the models of the Ollama servers in model_list",
+		Args:  cobra.NoArgs,
+		Example: `picoclaw models list
+picoclaw models list --host http://192.168.1.20:11434`,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			return modelsListCmd(cmd.Context(), os.Stdout, ollamaServers(cfg, host))
+		},
+	}
+
+	cmd.Flags().StringVar(&host, "host", "", "Ollama server to list instead of those in model_list")
+
+	return cmd
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+)
+
+func newPullCommand() *cobra.Command {
+	var host string
+
+	cmd := &cobra.Command{
+		Use:   "pull [model]",
+		Short: "Pull a model, or every missing model in model_list",
+		Args:  cobra.MaximumNArgs(1),
+		Example: `picoclaw models pull
+picoclaw models pull qwen3:4b
+picoclaw models pull llama3.2 --host http://192.168.1.20:11434`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			model := ""
+			if len(args) > 0 {
+				model = args[0]
+			}
+			return modelsPullCmd(cmd.Context(), os.Stdout, ollamaServers(cfg, host), model)
+		},
+	}
+
+	cmd.Flags().StringVar(&host, "host", "", "Ollama server to pull to instead of those in model_list")
+
+	return cmd
+}
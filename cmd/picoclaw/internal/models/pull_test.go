@@ -0,0 +1,21 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPullSubcommand(t *testing.T) {
+	cmd := newPullCommand()
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "pull [model]", cmd.Use)
+	assert.Equal(t, "Pull a model, or every missing model in model_list", cmd.Short)
+
+	assert.NoError(t, cmd.Args(cmd, nil))
+	assert.NoError(t, cmd.Args(cmd, []string{"qwen3:4b"}))
+	assert.Error(t, cmd.Args(cmd, []string{"a", "b"}))
+}
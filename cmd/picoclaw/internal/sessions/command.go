@@ -0,0 +1,51 @@
+package sessions
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+)
+
+func NewSessionsCommand() *cobra.Command {
+	var set *sessionSet
+
+	cmd := &cobra.Command{
+		Use:   "sessions",
+		Short: "Manage conversation sessions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return cmd.Help()
+		},
+		// Open the session stores of all configured agents once for whichever
+		// subcommand runs.
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			set, err = openSessions(cfg)
+			return err
+		},
+		PersistentPostRunE: func(_ *cobra.Command, _ []string) error {
+			if set == nil {
+				return nil
+			}
+			return set.Close()
+		},
+	}
+
+	setFn := func() *sessionSet { return set }
+
+	cmd.AddCommand(
+		newListCommand(setFn),
+		newShowCommand(setFn),
+		newExportCommand(setFn),
+		newImportCommand(setFn),
+		newPruneCommand(setFn),
+		newDeleteCommand(setFn),
+	)
+
+	return cmd
+}
@@ -0,0 +1,38 @@
+package sessions
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newDeleteCommand(setFn func() *sessionSet) *cobra.Command {
+	var (
+		peer    string
+		channel string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete a session, or all sessions with a peer",
+		Args:  cobra.MaximumNArgs(1),
+		Example: `picoclaw sessions delete agent:main:telegram:direct:123456
+picoclaw sessions delete --peer 123456 --channel telegram`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			switch {
+			case len(args) == 1 && peer != "":
+				return fmt.Errorf("pass either a session key or --peer, not both")
+			case len(args) == 1:
+				return sessionsDeleteCmd(setFn(), args[0], "", "")
+			case peer != "":
+				return sessionsDeleteCmd(setFn(), "", channel, peer)
+			}
+			return fmt.Errorf("pass a session key or --peer")
+		},
+	}
+
+	cmd.Flags().StringVar(&peer, "peer", "", "Delete all sessions with this peer ID")
+	cmd.Flags().StringVar(&channel, "channel", "", "With --peer, only delete sessions on this channel")
+
+	return cmd
+}
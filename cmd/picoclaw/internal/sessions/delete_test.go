@@ -0,0 +1,21 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeleteSubcommand(t *testing.T) {
+	cmd := newDeleteCommand(func() *sessionSet { return nil })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "delete", cmd.Use)
+	assert.Equal(t, "Delete a session, or all sessions with a peer", cmd.Short)
+
+	assert.True(t, cmd.HasFlags())
+	assert.NotNil(t, cmd.Flags().Lookup("peer"))
+	assert.NotNil(t, cmd.Flags().Lookup("channel"))
+}
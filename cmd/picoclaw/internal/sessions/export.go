@@ -0,0 +1,26 @@
+package sessions
+
+import "github.com/spf13/cobra"
+
+func newExportCommand(setFn func() *sessionSet) *cobra.Command {
+	var (
+		format string
+		output string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export a session as Markdown or JSONL",
+		Args:  cobra.ExactArgs(1),
+		Example: `picoclaw sessions export agent:main:main
+picoclaw sessions export agent:main:main --format jsonl -o main.jsonl`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return sessionsExportCmd(setFn(), args[0], format, output)
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "markdown", "Export format: markdown or jsonl")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Write to this file instead of stdout")
+
+	return cmd
+}
@@ -0,0 +1,21 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewExportSubcommand(t *testing.T) {
+	cmd := newExportCommand(func() *sessionSet { return nil })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "export", cmd.Use)
+	assert.Equal(t, "Export a session as Markdown or JSONL", cmd.Short)
+
+	assert.True(t, cmd.HasFlags())
+	assert.NotNil(t, cmd.Flags().Lookup("format"))
+	assert.NotNil(t, cmd.Flags().Lookup("output"))
+}
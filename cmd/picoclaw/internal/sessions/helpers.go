@@ -0,0 +1,502 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+const timeFormat = "2006-01-02 15:04"
+
+// agentSessions is the session store of one configured agent.
+type agentSessions struct {
+	id      string
+	dir     string
+	manager *session.SessionManager
+}
+
+// sessionSet holds the session stores of all configured agents. Agents that
+// share a workspace share one store; its sessions are attributed by the
+// agent ID in their key.
+type sessionSet struct {
+	agents []*agentSessions
+}
+
+// openSessions opens the session store of every agent in cfg, or of the
+// implicit main agent when none are listed.
+func openSessions(cfg *config.Config) (*sessionSet, error) {
+	agentCfgs := []*config.AgentConfig{nil}
+	if len(cfg.Agents.List) > 0 {
+		agentCfgs = agentCfgs[:0]
+		for i := range cfg.Agents.List {
+			agentCfgs = append(agentCfgs, &cfg.Agents.List[i])
+		}
+	}
+
+	set := &sessionSet{}
+	managers := make(map[string]*session.SessionManager)
+	for _, ac := range agentCfgs {
+		id := routing.DefaultAgentID
+		if ac != nil {
+			id = routing.NormalizeAgentID(ac.ID)
+		}
+		dir := agent.SessionsDir(ac, &cfg.Agents.Defaults)
+		sm, ok := managers[dir]
+		if !ok {
+			store, err := session.OpenStore(cfg.Session.Store, dir)
+			if err != nil {
+				set.Close()
+				return nil, fmt.Errorf("open sessions of agent %q: %w", id, err)
+			}
+			sm = session.NewSessionManagerWithStore(store, 0)
+			managers[dir] = sm
+		}
+		set.agents = append(set.agents, &agentSessions{id: id, dir: dir, manager: sm})
+	}
+	return set, nil
+}
+
+// Close closes the stores.
+func (s *sessionSet) Close() error {
+	closed := make(map[string]bool)
+	var errs []error
+	for _, a := range s.agents {
+		if closed[a.dir] {
+			continue
+		}
+		closed[a.dir] = true
+		errs = append(errs, a.manager.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// agent returns the agent with the given ID, or nil.
+func (s *sessionSet) agent(id string) *agentSessions {
+	id = routing.NormalizeAgentID(id)
+	for _, a := range s.agents {
+		if a.id == id {
+			return a
+		}
+	}
+	return nil
+}
+
+// owner returns the agent a session key in a's store belongs to: the agent
+// named in the key if it shares the store, otherwise the first agent using
+// the store.
+func (s *sessionSet) owner(a *agentSessions, key string) *agentSessions {
+	if parsed := routing.ParseAgentSessionKey(key); parsed != nil {
+		if named := s.agent(parsed.AgentID); named != nil && named.dir == a.dir {
+			return named
+		}
+	}
+	for _, other := range s.agents {
+		if other.dir == a.dir {
+			return other
+		}
+	}
+	return a
+}
+
+// keys returns the sorted keys of the sessions belonging to a.
+func (s *sessionSet) keys(a *agentSessions) ([]string, error) {
+	all, err := a.manager.Keys()
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, key := range all {
+		if s.owner(a, key) == a {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// selectAgents returns the agent with the given ID, or all agents if id is
+// empty.
+func (s *sessionSet) selectAgents(id string) ([]*agentSessions, error) {
+	if id == "" {
+		return s.agents, nil
+	}
+	a := s.agent(id)
+	if a == nil {
+		return nil, fmt.Errorf("unknown agent %q", id)
+	}
+	return []*agentSessions{a}, nil
+}
+
+// find returns the session for key and the agent it belongs to.
+func (s *sessionSet) find(key string) (*agentSessions, *session.Session, error) {
+	candidates := s.agents
+	if parsed := routing.ParseAgentSessionKey(key); parsed != nil {
+		if a := s.agent(parsed.AgentID); a != nil {
+			candidates = append([]*agentSessions{a}, s.agents...)
+		}
+	}
+	for _, a := range candidates {
+		if sess := a.manager.Get(key); sess != nil {
+			return s.owner(a, key), sess, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("session %q not found", key)
+}
+
+func sessionsListCmd(set *sessionSet, agentID string) error {
+	agents, err := set.selectAgents(agentID)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, a := range agents {
+		keys, err := set.keys(a)
+		if err != nil {
+			return fmt.Errorf("list sessions of agent %q: %w", a.id, err)
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		fmt.Printf("\nAgent %s (%d sessions):\n", a.id, len(keys))
+		fmt.Println("------------------")
+		for _, key := range keys {
+			sess := a.manager.Get(key)
+			if sess == nil {
+				continue
+			}
+			fmt.Printf("  %s\n", key)
+			fmt.Printf("    %d messages, last active %s\n", len(sess.Messages), sess.Updated.Local().Format(timeFormat))
+			if sess.Summary != "" {
+				fmt.Printf("    %s\n", utils.Truncate(oneLine(sess.Summary), 100))
+			}
+		}
+		total += len(keys)
+	}
+
+	if total == 0 {
+		fmt.Println("No sessions.")
+	}
+	return nil
+}
+
+func sessionsShowCmd(set *sessionSet, key string, full bool) error {
+	a, sess, err := set.find(key)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Session: %s\n", sess.Key)
+	fmt.Printf("Agent:   %s\n", a.id)
+	fmt.Printf("Created: %s\n", sess.Created.Local().Format(timeFormat))
+	fmt.Printf("Updated: %s\n", sess.Updated.Local().Format(timeFormat))
+	fmt.Printf("Messages: %d\n", len(sess.Messages))
+	if sess.Overrides.Model != "" {
+		fmt.Printf("Model:   %s\n", sess.Overrides.Model)
+	}
+	if thinking := sess.Overrides.Thinking(); thinking != "" {
+		fmt.Printf("Thinking: %s\n", thinking)
+	}
+	if sess.Summary != "" {
+		fmt.Printf("\nSummary:\n%s\n", sess.Summary)
+	}
+	fmt.Println()
+
+	for _, m := range sess.Messages {
+		writeTranscriptMessage(os.Stdout, m, full)
+	}
+	return nil
+}
+
+// writeTranscriptMessage prints m for the terminal, shortening tool results
+// unless full is set.
+func writeTranscriptMessage(w io.Writer, m providers.Message, full bool) {
+	pin := ""
+	if m.Pinned {
+		pin = " (pinned)"
+	}
+	content := m.Content
+	if m.Role == "tool" && !full {
+		content = utils.Truncate(content, 500)
+	}
+	if content != "" {
+		fmt.Fprintf(w, "[%s]%s %s\n", m.Role, pin, content)
+	}
+	for _, tc := range m.ToolCalls {
+		fmt.Fprintf(w, "[%s]%s → %s(%s)\n", m.Role, pin, toolCallName(tc), toolCallArgs(tc))
+	}
+	fmt.Fprintln(w)
+}
+
+func sessionsExportCmd(set *sessionSet, key, format, output string) error {
+	if format != "markdown" && format != "md" && format != "jsonl" {
+		return fmt.Errorf("unknown format %q (use markdown or jsonl)", format)
+	}
+	_, sess, err := set.find(key)
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	switch format {
+	case "markdown", "md":
+		err = writeMarkdown(w, sess)
+	case "jsonl":
+		err = session.WriteJSONL(w, sess)
+	}
+	if err != nil {
+		return err
+	}
+
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "✓ Exported session %s to %s\n", key, output)
+	}
+	return nil
+}
+
+// writeMarkdown renders sess as a Markdown transcript.
+func writeMarkdown(w io.Writer, sess *session.Session) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Session %s\n\n", sess.Key)
+	fmt.Fprintf(&sb, "- Created: %s\n", sess.Created.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "- Updated: %s\n", sess.Updated.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "- Messages: %d\n", len(sess.Messages))
+	if sess.Summary != "" {
+		fmt.Fprintf(&sb, "\n## Summary\n\n%s\n", sess.Summary)
+	}
+	sb.WriteString("\n## Transcript\n")
+
+	for _, m := range sess.Messages {
+		heading := roleHeading(m.Role)
+		if m.Pinned {
+			heading += " (pinned)"
+		}
+		fmt.Fprintf(&sb, "\n### %s\n\n", heading)
+		if m.Role == "tool" {
+			fmt.Fprintf(&sb, "```\n%s\n```\n", m.Content)
+			continue
+		}
+		if m.Content != "" {
+			fmt.Fprintf(&sb, "%s\n", m.Content)
+		}
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(&sb, "\n- Called `%s` with `%s`\n", toolCallName(tc), toolCallArgs(tc))
+		}
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func roleHeading(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	case "tool":
+		return "Tool result"
+	case "system":
+		return "System"
+	}
+	return role
+}
+
+func toolCallName(tc providers.ToolCall) string {
+	if tc.Name == "" && tc.Function != nil {
+		return tc.Function.Name
+	}
+	return tc.Name
+}
+
+func toolCallArgs(tc providers.ToolCall) string {
+	if tc.Function != nil {
+		return tc.Function.Arguments
+	}
+	return ""
+}
+
+func sessionsImportCmd(set *sessionSet, path, key, agentID string, force bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sess, err := session.ReadJSONL(f)
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	if key != "" {
+		sess.Key = key
+	}
+	if sess.Key == "" {
+		return fmt.Errorf("%s has no session key; pass --key", path)
+	}
+
+	var target *agentSessions
+	switch {
+	case agentID != "":
+		if target = set.agent(agentID); target == nil {
+			return fmt.Errorf("unknown agent %q", agentID)
+		}
+	case routing.ParseAgentSessionKey(sess.Key) != nil:
+		target = set.agent(routing.ParseAgentSessionKey(sess.Key).AgentID)
+	}
+	if target == nil {
+		target = set.agents[0]
+	}
+
+	if !force && target.manager.Get(sess.Key) != nil {
+		return fmt.Errorf("session %q already exists; pass --force to replace it", sess.Key)
+	}
+	if err := target.manager.Restore(sess); err != nil {
+		return fmt.Errorf("import session %q: %w", sess.Key, err)
+	}
+
+	fmt.Printf("✓ Imported session %s (%d messages) into agent %s\n", sess.Key, len(sess.Messages), target.id)
+	return nil
+}
+
+// pruneCriteria selects the sessions prune removes. Zero fields are unset.
+type pruneCriteria struct {
+	olderThan   time.Duration
+	maxMessages int
+}
+
+// matches reports whether sess is old or large enough to prune.
+func (c pruneCriteria) matches(sess *session.Session, now time.Time) bool {
+	if c.olderThan > 0 && now.Sub(sess.Updated) > c.olderThan {
+		return true
+	}
+	return c.maxMessages > 0 && len(sess.Messages) > c.maxMessages
+}
+
+// parseAge parses a duration that may also be given in days, like "30d".
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+func sessionsPruneCmd(set *sessionSet, agentID string, criteria pruneCriteria, dryRun bool) error {
+	agents, err := set.selectAgents(agentID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pruned := 0
+	for _, a := range agents {
+		keys, err := set.keys(a)
+		if err != nil {
+			return fmt.Errorf("list sessions of agent %q: %w", a.id, err)
+		}
+		for _, key := range keys {
+			sess := a.manager.Get(key)
+			if sess == nil || !criteria.matches(sess, now) {
+				continue
+			}
+			if dryRun {
+				fmt.Printf("  would remove %s (%d messages, last active %s)\n",
+					key, len(sess.Messages), sess.Updated.Local().Format(timeFormat))
+			} else if err := a.manager.Delete(key); err != nil {
+				return fmt.Errorf("delete session %q: %w", key, err)
+			}
+			pruned++
+		}
+	}
+
+	if dryRun {
+		fmt.Printf("%d sessions would be removed.\n", pruned)
+	} else {
+		fmt.Printf("✓ Removed %d sessions\n", pruned)
+	}
+	return nil
+}
+
+// matchesPeer reports whether key is a session with peer, optionally on
+// channel only. Peer sessions end in the peer ID, and carry the channel
+// right after the agent ID when they are scoped per channel.
+func matchesPeer(key, channel, peer string) bool {
+	parsed := routing.ParseAgentSessionKey(key)
+	if parsed == nil {
+		return false
+	}
+	parts := strings.Split(parsed.Rest, ":")
+	if len(parts) < 2 || parts[len(parts)-1] != strings.ToLower(peer) {
+		return false
+	}
+	return channel == "" || parts[0] == strings.ToLower(channel)
+}
+
+func sessionsDeleteCmd(set *sessionSet, key, channel, peer string) error {
+	if key != "" {
+		a, _, err := set.find(key)
+		if err != nil {
+			return err
+		}
+		if err := a.manager.Delete(key); err != nil {
+			return fmt.Errorf("delete session %q: %w", key, err)
+		}
+		fmt.Printf("✓ Removed session %s\n", key)
+		return nil
+	}
+
+	removed := 0
+	for _, a := range set.agents {
+		keys, err := set.keys(a)
+		if err != nil {
+			return fmt.Errorf("list sessions of agent %q: %w", a.id, err)
+		}
+		for _, k := range keys {
+			if !matchesPeer(k, channel, peer) {
+				continue
+			}
+			if err := a.manager.Delete(k); err != nil {
+				return fmt.Errorf("delete session %q: %w", k, err)
+			}
+			fmt.Printf("✓ Removed session %s\n", k)
+			removed++
+		}
+	}
+	if removed == 0 {
+		fmt.Printf("✗ No sessions found for peer %s\n", peer)
+	}
+	return nil
+}
+
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,217 @@
+package sessions
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+)
+
+func newTestSessionSet(t *testing.T) *sessionSet {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+
+	set, err := openSessions(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { set.Close() })
+	return set
+}
+
+func addSession(t *testing.T, a *agentSessions, key string, msgs ...providers.Message) {
+	t.Helper()
+
+	a.manager.GetOrCreate(key)
+	for _, m := range msgs {
+		a.manager.AddFullMessage(key, m)
+	}
+	require.NoError(t, a.manager.Save(key))
+}
+
+func TestSessionSet_AttributesSharedStoreByKey(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+	cfg.Agents.List = []config.AgentConfig{
+		{ID: "main", Default: true},
+		{ID: "shared", Workspace: cfg.Agents.Defaults.Workspace},
+		{ID: "helper", Workspace: t.TempDir()},
+	}
+	set, err := openSessions(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { set.Close() })
+
+	main := set.agent("main")
+	shared := set.agent("shared")
+	helper := set.agent("helper")
+	require.NotNil(t, main)
+	require.NotNil(t, shared)
+	require.NotNil(t, helper)
+	assert.Same(t, main.manager, shared.manager)
+
+	addSession(t, main, "agent:main:main", providers.Message{Role: "user", Content: "hi"})
+	addSession(t, main, "agent:shared:main", providers.Message{Role: "user", Content: "hi"})
+	addSession(t, main, "agent:helper:stray", providers.Message{Role: "user", Content: "hi"})
+	addSession(t, helper, "agent:helper:telegram:direct:42", providers.Message{Role: "user", Content: "hi"})
+
+	keys, err := set.keys(main)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"agent:helper:stray", "agent:main:main"}, keys,
+		"keys naming an agent with another store stay with the store's first agent")
+
+	keys, err = set.keys(shared)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"agent:shared:main"}, keys)
+
+	keys, err = set.keys(helper)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"agent:helper:telegram:direct:42"}, keys)
+
+	a, sess, err := set.find("agent:helper:telegram:direct:42")
+	require.NoError(t, err)
+	assert.Equal(t, "helper", a.id)
+	assert.Len(t, sess.Messages, 1)
+
+	a, _, err = set.find("agent:shared:main")
+	require.NoError(t, err)
+	assert.Equal(t, "shared", a.id)
+
+	_, _, err = set.find("agent:main:missing")
+	assert.Error(t, err)
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	sess := &session.Session{
+		Key:     "agent:main:main",
+		Summary: "Talked about the weather.",
+		Messages: []providers.Message{
+			{Role: "user", Content: "Will it rain?", Pinned: true},
+			{Role: "assistant", ToolCalls: []providers.ToolCall{{
+				ID:       "call_1",
+				Function: &providers.FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`},
+			}}},
+			{Role: "tool", Content: "rain, 12°C", ToolCallID: "call_1"},
+			{Role: "assistant", Content: "Yes, take an umbrella."},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeMarkdown(&buf, sess))
+	out := buf.String()
+
+	assert.Contains(t, out, "# Session agent:main:main")
+	assert.Contains(t, out, "## Summary\n\nTalked about the weather.")
+	assert.Contains(t, out, "### User (pinned)\n\nWill it rain?")
+	assert.Contains(t, out, "- Called `weather` with `{\"city\":\"Paris\"}`")
+	assert.Contains(t, out, "### Tool result\n\n```\nrain, 12°C\n```")
+	assert.Contains(t, out, "### Assistant\n\nYes, take an umbrella.")
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	set := newTestSessionSet(t)
+	main := set.agent("main")
+	require.NotNil(t, main)
+	addSession(t, main, "agent:main:main",
+		providers.Message{Role: "user", Content: "remember the code 1234"},
+		providers.Message{Role: "assistant", Content: "Noted."},
+	)
+
+	file := filepath.Join(t.TempDir(), "main.jsonl")
+	require.NoError(t, sessionsExportCmd(set, "agent:main:main", "jsonl", file))
+
+	err := sessionsImportCmd(set, file, "", "", false)
+	assert.ErrorContains(t, err, "already exists")
+
+	require.NoError(t, sessionsImportCmd(set, file, "agent:main:copy", "", false))
+	sess := main.manager.Get("agent:main:copy")
+	require.NotNil(t, sess)
+	require.Len(t, sess.Messages, 2)
+	assert.Equal(t, "remember the code 1234", sess.Messages[0].Content)
+
+	assert.Error(t, sessionsExportCmd(set, "agent:main:main", "html", file))
+}
+
+func TestParseAge(t *testing.T) {
+	d, err := parseAge("30d")
+	require.NoError(t, err)
+	assert.Equal(t, 30*24*time.Hour, d)
+
+	d, err = parseAge("12h")
+	require.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, d)
+
+	_, err = parseAge("soon")
+	assert.Error(t, err)
+	_, err = parseAge("-1d")
+	assert.Error(t, err)
+}
+
+func TestSessionsPruneCmd(t *testing.T) {
+	set := newTestSessionSet(t)
+	main := set.agent("main")
+	require.NotNil(t, main)
+
+	addSession(t, main, "agent:main:small", providers.Message{Role: "user", Content: "hi"})
+	addSession(t, main, "agent:main:large",
+		providers.Message{Role: "user", Content: "a"},
+		providers.Message{Role: "assistant", Content: "b"},
+		providers.Message{Role: "user", Content: "c"},
+	)
+
+	criteria := pruneCriteria{maxMessages: 2}
+	require.NoError(t, sessionsPruneCmd(set, "", criteria, true))
+	assert.NotNil(t, main.manager.Get("agent:main:large"), "dry run keeps sessions")
+
+	require.NoError(t, sessionsPruneCmd(set, "", criteria, false))
+	assert.Nil(t, main.manager.Get("agent:main:large"))
+	assert.NotNil(t, main.manager.Get("agent:main:small"))
+
+	old := &session.Session{Updated: time.Now().Add(-48 * time.Hour)}
+	assert.True(t, pruneCriteria{olderThan: 24 * time.Hour}.matches(old, time.Now()))
+	assert.False(t, pruneCriteria{olderThan: 72 * time.Hour}.matches(old, time.Now()))
+
+	assert.Error(t, sessionsPruneCmd(set, "nobody", criteria, false))
+}
+
+func TestMatchesPeer(t *testing.T) {
+	assert.True(t, matchesPeer("agent:main:direct:alice", "", "Alice"))
+	assert.True(t, matchesPeer("agent:main:telegram:direct:alice", "telegram", "alice"))
+	assert.True(t, matchesPeer("agent:main:telegram:default:direct:alice", "", "alice"))
+	assert.True(t, matchesPeer("agent:main:discord:group:alice", "", "alice"))
+	assert.False(t, matchesPeer("agent:main:telegram:direct:alice", "discord", "alice"))
+	assert.False(t, matchesPeer("agent:main:direct:bob", "", "alice"))
+	assert.False(t, matchesPeer("agent:main:main", "", "main"))
+	assert.False(t, matchesPeer("cli:default", "", "default"))
+}
+
+func TestSessionsDeleteCmd_Peer(t *testing.T) {
+	set := newTestSessionSet(t)
+	main := set.agent("main")
+	require.NotNil(t, main)
+
+	addSession(t, main, "agent:main:telegram:direct:alice", providers.Message{Role: "user", Content: "hi"})
+	addSession(t, main, "agent:main:discord:direct:alice", providers.Message{Role: "user", Content: "hi"})
+	addSession(t, main, "agent:main:telegram:direct:bob", providers.Message{Role: "user", Content: "hi"})
+
+	require.NoError(t, sessionsDeleteCmd(set, "", "telegram", "alice"))
+	assert.Nil(t, main.manager.Get("agent:main:telegram:direct:alice"))
+	assert.NotNil(t, main.manager.Get("agent:main:discord:direct:alice"))
+
+	require.NoError(t, sessionsDeleteCmd(set, "agent:main:telegram:direct:bob", "", ""))
+	assert.Nil(t, main.manager.Get("agent:main:telegram:direct:bob"))
+
+	keys, err := set.keys(main)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"agent:main:discord:direct:alice"}, keys)
+
+	_, err = os.Stat(main.dir)
+	assert.NoError(t, err)
+}
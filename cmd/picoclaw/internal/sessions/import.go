@@ -0,0 +1,28 @@
+package sessions
+
+import "github.com/spf13/cobra"
+
+func newImportCommand(setFn func() *sessionSet) *cobra.Command {
+	var (
+		key     string
+		agentID string
+		force   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Restore a session from a JSONL export",
+		Args:  cobra.ExactArgs(1),
+		Example: `picoclaw sessions import main.jsonl
+picoclaw sessions import main.jsonl --key agent:main:restored --force`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return sessionsImportCmd(setFn(), args[0], key, agentID, force)
+		},
+	}
+
+	cmd.Flags().StringVar(&key, "key", "", "Session key to import as (default: the key in the file)")
+	cmd.Flags().StringVar(&agentID, "agent", "", "Agent to import into (default: the agent in the key)")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing session with the same key")
+
+	return cmd
+}
@@ -0,0 +1,22 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewImportSubcommand(t *testing.T) {
+	cmd := newImportCommand(func() *sessionSet { return nil })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "import", cmd.Use)
+	assert.Equal(t, "Restore a session from a JSONL export", cmd.Short)
+
+	assert.True(t, cmd.HasFlags())
+	assert.NotNil(t, cmd.Flags().Lookup("key"))
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+	assert.NotNil(t, cmd.Flags().Lookup("force"))
+}
@@ -0,0 +1,22 @@
+package sessions
+
+import "github.com/spf13/cobra"
+
+func newListCommand(setFn func() *sessionSet) *cobra.Command {
+	var agentID string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List sessions per agent",
+		Args:  cobra.NoArgs,
+		Example: `picoclaw sessions list
+picoclaw sessions list --agent main`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return sessionsListCmd(setFn(), agentID)
+		},
+	}
+
+	cmd.Flags().StringVar(&agentID, "agent", "", "Only list sessions of this agent")
+
+	return cmd
+}
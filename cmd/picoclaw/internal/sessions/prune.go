@@ -0,0 +1,46 @@
+package sessions
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newPruneCommand(setFn func() *sessionSet) *cobra.Command {
+	var (
+		olderThan   string
+		maxMessages int
+		agentID     string
+		dryRun      bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove sessions by age or size",
+		Args:  cobra.NoArgs,
+		Example: `picoclaw sessions prune --older-than 30d
+picoclaw sessions prune --max-messages 500 --dry-run`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			var criteria pruneCriteria
+			if olderThan != "" {
+				age, err := parseAge(olderThan)
+				if err != nil {
+					return err
+				}
+				criteria.olderThan = age
+			}
+			criteria.maxMessages = maxMessages
+			if criteria.olderThan <= 0 && criteria.maxMessages <= 0 {
+				return fmt.Errorf("pass --older-than and/or --max-messages")
+			}
+			return sessionsPruneCmd(setFn(), agentID, criteria, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "Remove sessions inactive for longer than this (e.g. 30d, 12h)")
+	cmd.Flags().IntVar(&maxMessages, "max-messages", 0, "Remove sessions with more messages than this")
+	cmd.Flags().StringVar(&agentID, "agent", "", "Only prune sessions of this agent")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the sessions that would be removed")
+
+	return cmd
+}
@@ -0,0 +1,23 @@
+package sessions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPruneSubcommand(t *testing.T) {
+	cmd := newPruneCommand(func() *sessionSet { return nil })
+
+	require.NotNil(t, cmd)
+
+	assert.Equal(t, "prune", cmd.Use)
+	assert.Equal(t, "Remove sessions by age or size", cmd.Short)
+
+	assert.True(t, cmd.HasFlags())
+	assert.NotNil(t, cmd.Flags().Lookup("older-than"))
+	assert.NotNil(t, cmd.Flags().Lookup("max-messages"))
+	assert.NotNil(t, cmd.Flags().Lookup("agent"))
+	assert.NotNil(t, cmd.Flags().Lookup("dry-run"))
+}
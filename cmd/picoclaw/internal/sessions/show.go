@@ -0,0 +1,21 @@
+package sessions
+
+import "github.com/spf13/cobra"
+
+func newShowCommand(setFn func() *sessionSet) *cobra.Command {
+	var full bool
+
+	cmd := &cobra.Command{
+		Use:     "show",
+		Short:   "Show a session transcript",
+		Args:    cobra.ExactArgs(1),
+		Example: `picoclaw sessions show agent:main:telegram:direct:123456`,
+		RunE: func(_ *cobra.Command, args []string) error {
+			return sessionsShowCmd(setFn(), args[0], full)
+		},
+	}
+
+	cmd.Flags().BoolVar(&full, "full", false, "Show tool results in full")
+
+	return cmd
+}
@@ -1,11 +1,19 @@
 package status
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
 	"github.com/sipeed/picoclaw/pkg/auth"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
 func statusCmd() {
@@ -96,5 +104,73 @@ func statusCmd() {
 				fmt.Printf("  %s (%s): %s\n", provider, cred.AuthMethod, status)
 			}
 		}
+
+		printRateLimits(cfg)
+	}
+}
+
+// printRateLimits lists the models with rpm or tpm limits, with the queue
+// statistics of a running gateway.
+func printRateLimits(cfg *config.Config) {
+	var limited []config.ModelConfig
+	for _, m := range cfg.ModelList {
+		if m.RPM > 0 || m.TPM > 0 {
+			limited = append(limited, m)
+		}
+	}
+	if len(limited) == 0 {
+		return
+	}
+
+	live, err := fetchRateLimits(cfg.Gateway)
+	fmt.Println("\nRate Limits:")
+	for _, m := range limited {
+		var limits []string
+		if m.RPM > 0 {
+			limits = append(limits, fmt.Sprintf("%d rpm", m.RPM))
+		}
+		if m.TPM > 0 {
+			limits = append(limits, fmt.Sprintf("%d tpm", m.TPM))
+		}
+		line := fmt.Sprintf("  %s: %s", m.ModelName, strings.Join(limits, ", "))
+		if s, ok := live[m.ModelName]; ok {
+			line += fmt.Sprintf(" (%d waiting, %d served, avg wait %s, max wait %s)",
+				s.Waiting, s.Served,
+				time.Duration(s.AvgWaitMs)*time.Millisecond,
+				time.Duration(s.MaxWaitMs)*time.Millisecond)
+		}
+		fmt.Println(line)
+	}
+	if err != nil {
+		fmt.Println("  Queue statistics unavailable: gateway not reachable")
+	}
+}
+
+// fetchRateLimits reads the rate limiter queues from the gateway's health
+// endpoint, by model name.
+func fetchRateLimits(gw config.GatewayConfig) (map[string]providers.RateLimitStatus, error) {
+	host := gw.Host
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	client := &http.Client{Timeout: time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://%s/health", net.JoinHostPort(host, strconv.Itoa(gw.Port))))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var health struct {
+		Details struct {
+			RateLimits []providers.RateLimitStatus `json:"rate_limits"`
+		} `json:"details"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&health); err != nil {
+		return nil, err
+	}
+	statuses := make(map[string]providers.RateLimitStatus, len(health.Details.RateLimits))
+	for _, s := range health.Details.RateLimits {
+		statuses[s.Model] = s
 	}
+	return statuses, nil
 }
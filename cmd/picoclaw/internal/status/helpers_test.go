@@ -0,0 +1,42 @@
+package status
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestFetchRateLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/health", r.URL.Path)
+		w.Write([]byte(`{"status":"ok","details":{"rate_limits":[
+			{"model":"gpt-4o","rpm":60,"waiting":2,"served":10,"avg_wait_ms":150,"max_wait_ms":900}
+		]}}`))
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	host, portStr, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+
+	statuses, err := fetchRateLimits(config.GatewayConfig{Host: host, Port: port})
+	require.NoError(t, err)
+	require.Contains(t, statuses, "gpt-4o")
+	assert.Equal(t, 2, statuses["gpt-4o"].Waiting)
+	assert.Equal(t, int64(900), statuses["gpt-4o"].MaxWaitMs)
+
+	srv.Close()
+	_, err = fetchRateLimits(config.GatewayConfig{Host: host, Port: port})
+	assert.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sipeed/picoclaw/cmd/picoclaw/internal"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func NewUsageCommand() *cobra.Command {
+	var opts reportOptions
+
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show token usage and cost",
+		Args:  cobra.NoArgs,
+		Example: `picoclaw usage
+picoclaw usage --period today --by sender
+picoclaw usage --period 7d --by day --agent main`,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.LoadConfig()
+			if err != nil {
+				return fmt.Errorf("error loading config: %w", err)
+			}
+			return usageCmd(cfg, opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.period, "period", "month",
+		"Period to report: today, week, month, all or a number of days such as 30d")
+	cmd.Flags().StringVar(&opts.by, "by", "model",
+		"Group usage by "+strings.Join(usage.Groupings, ", "))
+	cmd.Flags().StringVar(&opts.agent, "agent", "", "Only report usage of this agent")
+
+	return cmd
+}
@@ -0,0 +1,94 @@
+package usage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+type reportOptions struct {
+	period string
+	by     string
+	agent  string
+}
+
+func usageCmd(cfg *config.Config, opts reportOptions) error {
+	since, label, err := parsePeriod(opts.period, time.Now())
+	if err != nil {
+		return err
+	}
+	records, err := usage.Load(agent.UsageDir(cfg), since, time.Time{})
+	if err != nil {
+		return fmt.Errorf("read usage log: %w", err)
+	}
+	if opts.agent != "" {
+		kept := records[:0]
+		for _, r := range records {
+			if strings.EqualFold(r.Agent, opts.agent) {
+				kept = append(kept, r)
+			}
+		}
+		records = kept
+	}
+	return writeReport(os.Stdout, records, opts.by, label)
+}
+
+// parsePeriod returns the start of the period named by period and a label
+// for it; "all" has no start.
+func parsePeriod(period string, now time.Time) (time.Time, string, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case "today":
+		return today, "today", nil
+	case "week":
+		return today.AddDate(0, 0, -6), "the last 7 days", nil
+	case "month", "":
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		return month, "this month", nil
+	case "all":
+		return time.Time{}, "all time", nil
+	}
+	days, err := strconv.Atoi(strings.TrimSuffix(period, "d"))
+	if !strings.HasSuffix(period, "d") || err != nil || days <= 0 {
+		return time.Time{}, "", fmt.Errorf("invalid period %q (use today, week, month, all or e.g. 30d)", period)
+	}
+	return today.AddDate(0, 0, 1-days), fmt.Sprintf("the last %d days", days), nil
+}
+
+func writeReport(w io.Writer, records []usage.Record, by, label string) error {
+	rows, err := usage.Summarize(records, by)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		fmt.Fprintf(w, "No usage recorded for %s.\n", label)
+		return nil
+	}
+
+	var total usage.Totals
+	for _, r := range records {
+		total.Add(r)
+	}
+
+	fmt.Fprintf(w, "Usage for %s by %s:\n\n", label, by)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tREQUESTS\tPROMPT\tCOMPLETION\tTOTAL\tCOST\t\n", strings.ToUpper(by))
+	for _, row := range rows {
+		writeRow(tw, row.Key, row.Totals)
+	}
+	writeRow(tw, "total", total)
+	return tw.Flush()
+}
+
+func writeRow(w io.Writer, key string, t usage.Totals) {
+	fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t$%.4f\t\n",
+		key, t.Requests, t.PromptTokens, t.CompletionTokens, t.TotalTokens, t.Cost)
+}
@@ -0,0 +1,58 @@
+package usage
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2026, 10, 16, 15, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		period string
+		since  time.Time
+	}{
+		{"today", time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)},
+		{"week", time.Date(2026, 10, 10, 0, 0, 0, 0, time.UTC)},
+		{"month", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+		{"30d", time.Date(2026, 9, 17, 0, 0, 0, 0, time.UTC)},
+		{"all", time.Time{}},
+	}
+	for _, tt := range tests {
+		since, _, err := parsePeriod(tt.period, now)
+		require.NoError(t, err, tt.period)
+		assert.Equal(t, tt.since, since, tt.period)
+	}
+
+	for _, bad := range []string{"yesterday", "0d", "d", "30"} {
+		_, _, err := parsePeriod(bad, now)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	records := []usage.Record{
+		{Agent: "main", Model: "gpt-4o", PromptTokens: 1000, CompletionTokens: 200, TotalTokens: 1200, Cost: 0.0045},
+		{Agent: "main", Model: "gpt-4o-mini", PromptTokens: 500, CompletionTokens: 100, TotalTokens: 600},
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, writeReport(&out, records, "model", "this month"))
+	report := out.String()
+	assert.Contains(t, report, "Usage for this month by model:")
+	assert.Contains(t, report, "MODEL")
+	assert.Regexp(t, `gpt-4o +1 +1000 +200 +1200 +\$0\.0045`, report)
+	assert.Regexp(t, `total +2 +1500 +300 +1800 +\$0\.0045`, report)
+
+	out.Reset()
+	require.NoError(t, writeReport(&out, nil, "model", "today"))
+	assert.Equal(t, "No usage recorded for today.\n", out.String())
+
+	assert.Error(t, writeReport(&out, records, "weather", "today"))
+}
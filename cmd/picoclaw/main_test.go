@@ -37,10 +37,14 @@ func TestNewPicoclawCommand(t *testing.T) {
 		"auth",
 		"cron",
 		"gateway",
+		"mcp",
 		"migrate",
+		"models",
 		"onboard",
+		"sessions",
 		"skills",
 		"status",
+		"usage",
 		"version",
 	}
 
@@ -0,0 +1,156 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// Tool results longer than toolDigestThreshold characters are folded into a
+// digest of their first toolDigestLength characters when history is
+// compacted.
+const (
+	toolDigestThreshold = 2000
+	toolDigestLength    = 300
+)
+
+// historyUnit is a run of messages that compaction keeps or removes as a
+// whole: a user message, a plain assistant reply, or an assistant tool-call
+// message together with the tool results answering it.
+type historyUnit struct {
+	start, end int // history[start:end]
+	pinned     bool
+}
+
+// splitUnits partitions history into compaction units.
+func splitUnits(history []providers.Message) []historyUnit {
+	var units []historyUnit
+	for i := 0; i < len(history); {
+		u := historyUnit{start: i, end: i + 1}
+		if m := history[i]; m.Role == "assistant" && len(m.ToolCalls) > 0 {
+			for u.end < len(history) && history[u.end].Role == "tool" {
+				u.end++
+			}
+		}
+		for _, m := range history[u.start:u.end] {
+			u.pinned = u.pinned || m.Pinned
+		}
+		units = append(units, u)
+		i = u.end
+	}
+	return units
+}
+
+// compactionPlan is the split of a history into the messages compaction
+// removes and the ones it keeps.
+type compactionPlan struct {
+	removed []providers.Message
+	kept    []providers.Message
+	pinned  int
+}
+
+// planCompaction removes the units before the one containing history[cut],
+// so a tool-call exchange is never split. Pinned units are kept in place.
+func planCompaction(history []providers.Message, cut int) compactionPlan {
+	var plan compactionPlan
+	for _, u := range splitUnits(history) {
+		msgs := history[u.start:u.end]
+		switch {
+		case u.end > cut:
+			plan.kept = append(plan.kept, msgs...)
+		case u.pinned:
+			plan.kept = append(plan.kept, msgs...)
+			plan.pinned += len(msgs)
+		default:
+			plan.removed = append(plan.removed, msgs...)
+		}
+	}
+	return plan
+}
+
+// record describes the plan as a session compaction record.
+func (p compactionPlan) record(reason string, digested int) session.Compaction {
+	c := session.Compaction{
+		Reason:   reason,
+		Removed:  len(p.removed),
+		Digested: digested,
+		Pinned:   p.pinned,
+	}
+	for _, m := range p.removed {
+		for _, tc := range m.ToolCalls {
+			c.Tools = append(c.Tools, toolCallName(tc))
+		}
+	}
+	return c
+}
+
+func toolCallName(tc providers.ToolCall) string {
+	if tc.Name == "" && tc.Function != nil {
+		return tc.Function.Name
+	}
+	return tc.Name
+}
+
+// digestToolResults folds large tool results into digests, leaving the
+// latest turn (from its user message on) intact since the model may still
+// be working with those results. It returns the number of digested results.
+func digestToolResults(msgs []providers.Message) int {
+	latest := len(msgs)
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "user" {
+			latest = i
+			break
+		}
+	}
+
+	n := 0
+	for i := range msgs[:latest] {
+		m := &msgs[i]
+		if m.Role != "tool" || m.Pinned || utf8.RuneCountInString(m.Content) <= toolDigestThreshold {
+			continue
+		}
+		m.Content = toolDigest(m.Content)
+		n++
+	}
+	return n
+}
+
+func toolDigest(content string) string {
+	return fmt.Sprintf("[Tool output compacted: %d characters, %d lines. Beginning:]\n%s",
+		utf8.RuneCountInString(content), strings.Count(content, "\n")+1,
+		utils.Truncate(content, toolDigestLength))
+}
+
+// summaryInput renders removed messages as user and assistant lines for the
+// summarizer, describing tool calls and digesting their results rather
+// than dropping them.
+func summaryInput(msgs []providers.Message) []providers.Message {
+	out := make([]providers.Message, 0, len(msgs))
+	for _, m := range msgs {
+		switch m.Role {
+		case "user":
+			out = append(out, m)
+		case "assistant":
+			content := m.Content
+			for _, tc := range m.ToolCalls {
+				args := ""
+				if tc.Function != nil {
+					args = tc.Function.Arguments
+				}
+				content += fmt.Sprintf("\n[called %s(%s)]", toolCallName(tc), utils.Truncate(args, 200))
+			}
+			out = append(out, providers.Message{Role: "assistant", Content: strings.TrimSpace(content)})
+		case "tool":
+			content := m.Content
+			if utf8.RuneCountInString(content) > toolDigestThreshold {
+				content = toolDigest(content)
+			}
+			out = append(out, providers.Message{Role: "tool", Content: content})
+		}
+	}
+	return out
+}
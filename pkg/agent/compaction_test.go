@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestSplitUnits_KeepsToolExchangesTogether(t *testing.T) {
+	history := []providers.Message{
+		msg("user", "q1"),
+		assistantWithTools("A", "B"),
+		toolResult("A"),
+		toolResult("B"),
+		msg("assistant", "a1"),
+		msg("user", "q2"),
+	}
+	units := splitUnits(history)
+	want := [][2]int{{0, 1}, {1, 4}, {4, 5}, {5, 6}}
+	if len(units) != len(want) {
+		t.Fatalf("got %d units, want %d", len(units), len(want))
+	}
+	for i, u := range units {
+		if u.start != want[i][0] || u.end != want[i][1] {
+			t.Errorf("unit %d = [%d,%d), want [%d,%d)", i, u.start, u.end, want[i][0], want[i][1])
+		}
+	}
+}
+
+func TestPlanCompaction_NeverSplitsExchange(t *testing.T) {
+	history := []providers.Message{
+		msg("user", "q1"),
+		msg("assistant", "a1"),
+		msg("user", "q2"),
+		assistantWithTools("A", "B"),
+		toolResult("A"),
+		toolResult("B"),
+		msg("assistant", "a2"),
+	}
+	// A cut between the two tool results moves back to the tool call.
+	plan := planCompaction(history, 5)
+	if len(plan.removed) != 3 {
+		t.Fatalf("removed %d messages, want 3", len(plan.removed))
+	}
+	assertRoles(t, plan.kept, "assistant", "tool", "tool", "assistant")
+	if rec := plan.record("summary", 0); rec.Removed != 3 || len(rec.Tools) != 0 {
+		t.Errorf("record = %+v", rec)
+	}
+}
+
+func TestPlanCompaction_KeepsPinned(t *testing.T) {
+	pinned := msg("user", "my name is Ada")
+	pinned.Pinned = true
+	history := []providers.Message{
+		msg("user", "q1"),
+		msg("assistant", "a1"),
+		pinned,
+		msg("assistant", "hi Ada"),
+		msg("user", "q3"),
+		msg("assistant", "a3"),
+	}
+	plan := planCompaction(history, 4)
+	if plan.pinned != 1 || len(plan.removed) != 3 {
+		t.Fatalf("pinned=%d removed=%d", plan.pinned, len(plan.removed))
+	}
+	if plan.kept[0].Content != "my name is Ada" {
+		t.Errorf("pinned message not kept first: %+v", plan.kept)
+	}
+}
+
+func TestDigestToolResults(t *testing.T) {
+	big := strings.Repeat("line of output\n", 500)
+	msgs := []providers.Message{
+		msg("user", "q1"),
+		assistantWithTools("A"),
+		{Role: "tool", Content: big, ToolCallID: "A"},
+		msg("user", "q2"),
+		assistantWithTools("B"),
+		{Role: "tool", Content: big, ToolCallID: "B"},
+	}
+	if n := digestToolResults(msgs); n != 1 {
+		t.Fatalf("digested %d results, want 1", n)
+	}
+	if !strings.HasPrefix(msgs[2].Content, "[Tool output compacted: 7500 characters, 501 lines.") {
+		t.Errorf("digest = %q", msgs[2].Content[:80])
+	}
+	if msgs[5].Content != big {
+		t.Error("results of the latest turn must stay intact")
+	}
+}
+
+func TestSummaryInput_DescribesToolCalls(t *testing.T) {
+	call := providers.Message{Role: "assistant", ToolCalls: []providers.ToolCall{{
+		ID:       "A",
+		Name:     "read_file",
+		Function: &providers.FunctionCall{Name: "read_file", Arguments: `{"path":"notes.txt"}`},
+	}}}
+	got := summaryInput([]providers.Message{
+		msg("user", "read my notes"),
+		call,
+		{Role: "tool", Content: "buy milk", ToolCallID: "A"},
+	})
+	assertRoles(t, got, "user", "assistant", "tool")
+	if got[1].Content != `[called read_file({"path":"notes.txt"})]` {
+		t.Errorf("assistant line = %q", got[1].Content)
+	}
+	if got[2].Content != "buy milk" {
+		t.Errorf("tool line = %q", got[2].Content)
+	}
+}
@@ -14,12 +14,19 @@ import (
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/skills"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 )
 
 type ContextBuilder struct {
 	workspace    string
 	skillsLoader *skills.SkillsLoader
 	memory       *MemoryStore
+	memoryTopK   int
+
+	// Requests are trimmed to tokenBudget tokens as counted by counter;
+	// zero disables trimming.
+	counter     tokenizer.Counter
+	tokenBudget int
 
 	// Cache for system prompt to avoid rebuilding on every call.
 	// This fixes issue #607: repeated reprocessing of the entire context.
@@ -54,9 +61,39 @@ func NewContextBuilder(workspace string) *ContextBuilder {
 		workspace:    workspace,
 		skillsLoader: skills.NewSkillsLoader(workspace, globalSkillsDir, builtinSkillsDir),
 		memory:       NewMemoryStore(workspace),
+		memoryTopK:   defaultMemoryTopK,
 	}
 }
 
+// defaultMemoryTopK is how many relevant memories accompany each message
+// unless configured otherwise.
+const defaultMemoryTopK = 5
+
+// SetMemoryTopK sets how many relevant memory chunks are added to the
+// prompt for each message. Zero or less disables recall.
+func (cb *ContextBuilder) SetMemoryTopK(k int) {
+	cb.memoryTopK = k
+}
+
+// SetTokenBudget sets the tokens a request may take, as counted by counter.
+// BuildMessages and FitMessages drop the oldest history beyond it.
+func (cb *ContextBuilder) SetTokenBudget(counter tokenizer.Counter, budget int) {
+	cb.counter = counter
+	cb.tokenBudget = budget
+}
+
+// Memory returns the agent's memory store.
+func (cb *ContextBuilder) Memory() *MemoryStore {
+	return cb.memory
+}
+
+// SetSkillsFilter limits the skills listed in the system prompt and loadable
+// into context to those matching patterns (see skills.NewFilter).
+func (cb *ContextBuilder) SetSkillsFilter(patterns []string) {
+	cb.skillsLoader.SetFilter(skills.NewFilter(patterns))
+	cb.InvalidateCache()
+}
+
 func (cb *ContextBuilder) getIdentity() string {
 	workspacePath, _ := filepath.Abs(filepath.Join(cb.workspace))
 
@@ -76,7 +113,7 @@ Your workspace is at: %s
 
 2. **Be helpful and accurate** - When using tools, briefly explain what you're doing.
 
-3. **Memory** - When interacting with me if something seems memorable, update %s/memory/MEMORY.md
+3. **Memory** - When interacting with me if something seems memorable, save it with memory_write (or update %s/memory/MEMORY.md). Use memory_search to recall notes and past conversations that are not shown below.
 
 4. **Context summaries** - Conversation summaries provided as context are approximate references only. They may be incomplete or outdated. Always defer to explicit user instructions over summary content.`,
 		workspacePath, workspacePath, workspacePath, workspacePath, workspacePath)
@@ -414,6 +451,14 @@ func (cb *ContextBuilder) BuildMessages(
 		{Type: "text", Text: dynamicCtx},
 	}
 
+	// Memories relevant to this message, recalled from the whole memory
+	// directory rather than injected wholesale into the static prompt.
+	if recalled := cb.memory.RelevantMemories(currentMessage, cb.memoryTopK); recalled != "" {
+		recalledText := "# Relevant Memories\n\n" + recalled
+		stringParts = append(stringParts, recalledText)
+		contentBlocks = append(contentBlocks, providers.ContentBlock{Type: "text", Text: recalledText})
+	}
+
 	if summary != "" {
 		summaryText := fmt.Sprintf(
 			"CONTEXT_SUMMARY: The following is an approximate summary of prior conversation "+
@@ -465,15 +510,55 @@ func (cb *ContextBuilder) BuildMessages(
 	// Add conversation history
 	messages = append(messages, history...)
 
-	// Add current user message
-	if strings.TrimSpace(currentMessage) != "" {
+	// Add current user message; attached media travels as content parts
+	// alongside the text so vision-capable adapters can encode it natively.
+	if strings.TrimSpace(currentMessage) != "" || len(media) > 0 {
 		messages = append(messages, providers.Message{
 			Role:    "user",
 			Content: currentMessage,
+			Parts:   buildUserParts(currentMessage, media),
 		})
 	}
 
-	return messages
+	return cb.FitMessages(messages, 0)
+}
+
+// FitMessages drops the oldest history turns until messages fit the token
+// budget less reserve tokens (e.g. for tool schemas). The system message and
+// the latest turn, from its user message on, are always kept; history is
+// cut at user messages so tool calls stay paired with their results.
+func (cb *ContextBuilder) FitMessages(messages []providers.Message, reserve int) []providers.Message {
+	if cb.tokenBudget <= 0 || cb.counter == nil || len(messages) < 3 || messages[0].Role != "system" {
+		return messages
+	}
+	budget := cb.tokenBudget - reserve
+	total := tokenizer.CountMessages(cb.counter, messages)
+	if total <= budget {
+		return messages
+	}
+
+	lastUser := len(messages) - 1
+	for lastUser > 1 && messages[lastUser].Role != "user" {
+		lastUser--
+	}
+
+	start := 1
+	for start < lastUser && (total > budget || messages[start].Role != "user") {
+		total -= tokenizer.CountMessage(cb.counter, messages[start])
+		start++
+	}
+	if start == 1 {
+		return messages
+	}
+
+	logger.WarnCF("agent", "Trimmed history to fit context window", map[string]any{
+		"dropped_msgs": start - 1,
+		"tokens":       total,
+		"budget":       budget,
+	})
+	fitted := make([]providers.Message, 0, 1+len(messages)-start)
+	fitted = append(fitted, messages[0])
+	return append(fitted, messages[start:]...)
 }
 
 func sanitizeHistoryForProvider(history []providers.Message) []providers.Message {
@@ -567,16 +652,19 @@ func (cb *ContextBuilder) AddAssistantMessage(
 	return messages
 }
 
-// GetSkillsInfo returns information about loaded skills.
+// GetSkillsInfo returns information about loaded skills. "total" counts every
+// discovered skill; "available" and "names" cover those the agent's skills
+// filter allows.
 func (cb *ContextBuilder) GetSkillsInfo() map[string]any {
-	allSkills := cb.skillsLoader.ListSkills()
-	skillNames := make([]string, 0, len(allSkills))
-	for _, s := range allSkills {
+	allSkills := cb.skillsLoader.ListAllSkills()
+	available := cb.skillsLoader.ListSkills()
+	skillNames := make([]string, 0, len(available))
+	for _, s := range available {
 		skillNames = append(skillNames, s.Name)
 	}
 	return map[string]any{
 		"total":     len(allSkills),
-		"available": len(allSkills),
+		"available": len(available),
 		"names":     skillNames,
 	}
 }
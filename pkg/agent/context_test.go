@@ -1,9 +1,11 @@
 package agent
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 )
 
 func msg(role, content string) providers.Message {
@@ -207,3 +209,34 @@ func assertRoles(t *testing.T, msgs []providers.Message, expected ...string) {
 		}
 	}
 }
+
+func TestFitMessages_DropsOldestTurns(t *testing.T) {
+	cb := NewContextBuilder(t.TempDir())
+	long := strings.Repeat("x", 250) // 100 tokens with the heuristic counter
+	messages := []providers.Message{
+		msg("system", "sys"),
+		msg("user", long),
+		assistantWithTools("A"),
+		toolResult("A"),
+		msg("assistant", long),
+		msg("user", long),
+		msg("assistant", long),
+		msg("user", "latest"),
+	}
+
+	// No budget: untouched.
+	if got := cb.FitMessages(messages, 0); len(got) != len(messages) {
+		t.Fatalf("unbudgeted FitMessages dropped messages: %d", len(got))
+	}
+
+	cb.SetTokenBudget(tokenizer.Heuristic{}, 250)
+	got := cb.FitMessages(messages, 0)
+	assertRoles(t, got, "system", "user", "assistant", "user")
+	if got[1].Content != long || got[3].Content != "latest" {
+		t.Errorf("expected the last full turn and the current message to be kept")
+	}
+
+	// A reserve that leaves no room drops all history but the current turn.
+	got = cb.FitMessages(messages, 240)
+	assertRoles(t, got, "system", "user")
+}
@@ -0,0 +1,270 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// conversationCommand handles the slash commands acting on the conversation
+// msg belongs to: /new, /reset, /undo, /retry, /model, /think and /summary.
+func (al *AgentLoop) conversationCommand(
+	ctx context.Context,
+	msg bus.InboundMessage,
+	cmd string,
+	args []string,
+) string {
+	agent, sessionKey, _ := al.resolveRoute(msg)
+	if agent == nil {
+		return "No default agent configured"
+	}
+
+	switch cmd {
+	case "/new":
+		old := agent.Sessions.Reset(sessionKey, false)
+		agent.Sessions.Save(sessionKey)
+		if len(old) == 0 {
+			return "Started a new conversation."
+		}
+		archiveDropped(agent, sessionKey, old)
+		return "Started a new conversation. The previous one was saved to memory."
+
+	case "/reset":
+		agent.Sessions.Reset(sessionKey, true)
+		agent.Sessions.Save(sessionKey)
+		return "Conversation reset: history, summary and settings cleared."
+
+	case "/undo":
+		removed := agent.Sessions.Undo(sessionKey)
+		if removed == nil {
+			return "Nothing to undo"
+		}
+		agent.Sessions.Save(sessionKey)
+		return fmt.Sprintf("Removed the last exchange (%d messages): %s",
+			len(removed), utils.Truncate(removed[0].Content, 80))
+
+	case "/retry":
+		// Only placeholders of a message's attachments are kept in the
+		// history, so retrying it would answer without them.
+		if last, ok := lastUserMessage(agent.Sessions.GetHistory(sessionKey)); ok && len(last.Parts) > 0 {
+			return "Can't retry a message with attachments: they are not kept in the history. " +
+				"Please send the message and its attachments again."
+		}
+		if _, ok := agent.Sessions.RewindToLastUser(sessionKey); !ok {
+			return "Nothing to retry"
+		}
+		response, err := al.runAgentLoop(ctx, agent, processOptions{
+			SessionKey:      sessionKey,
+			Channel:         msg.Channel,
+			ChatID:          msg.ChatID,
+			SenderID:        msg.SenderID,
+			DefaultResponse: "I've completed processing but have no response to give.",
+			EnableSummary:   true,
+			SendResponse:    false,
+			Retry:           true,
+		})
+		if err != nil {
+			return fmt.Sprintf("Retry failed: %v", err)
+		}
+		return response
+
+	case "/model":
+		return al.modelCommand(agent, sessionKey, args)
+
+	case "/think":
+		return al.thinkCommand(agent, sessionKey, args)
+
+	case "/summary":
+		return al.summaryCommand(agent, sessionKey, args)
+	}
+
+	return ""
+}
+
+func (al *AgentLoop) modelCommand(agent *AgentInstance, sessionKey string, args []string) string {
+	if len(args) == 0 {
+		model, overridden := al.conversationModel(agent, sessionKey)
+		reply := fmt.Sprintf("Model for this conversation: %s", model)
+		if overridden {
+			reply += fmt.Sprintf(" (agent default: %s)", agent.Model)
+		}
+		if names := al.modelNames(); len(names) > 0 {
+			reply += fmt.Sprintf("\nAvailable models: %s", strings.Join(names, ", "))
+		}
+		return reply + "\nUsage: /model <name> | /model default"
+	}
+
+	overrides := agent.Sessions.GetOverrides(sessionKey)
+	switch name := args[0]; name {
+	case "default", "reset":
+		overrides.Model = ""
+		agent.Sessions.SetOverrides(sessionKey, overrides)
+		agent.Sessions.Save(sessionKey)
+		return fmt.Sprintf("Model for this conversation reset to %s", agent.Model)
+	default:
+		return al.setConversationModel(agent, sessionKey, name)
+	}
+}
+
+// setConversationModel makes name the model of this conversation only. Only
+// configured models are accepted, so a chat user cannot point the
+// conversation at an arbitrary model of the default provider.
+func (al *AgentLoop) setConversationModel(agent *AgentInstance, sessionKey, name string) string {
+	if !al.knownModel(agent, name) {
+		reply := fmt.Sprintf("Unknown model: %s", name)
+		if names := al.modelNames(); len(names) > 0 {
+			reply += fmt.Sprintf("\nAvailable models: %s", strings.Join(names, ", "))
+		}
+		return reply
+	}
+	old, _ := al.conversationModel(agent, sessionKey)
+	overrides := agent.Sessions.GetOverrides(sessionKey)
+	overrides.Model = name
+	agent.Sessions.SetOverrides(sessionKey, overrides)
+	agent.Sessions.Save(sessionKey)
+	return fmt.Sprintf("Switched model for this conversation from %s to %s", old, name)
+}
+
+// conversationModel returns the model the conversation runs with and
+// whether it was chosen with /model.
+func (al *AgentLoop) conversationModel(agent *AgentInstance, sessionKey string) (string, bool) {
+	if override := agent.Sessions.GetOverrides(sessionKey).Model; override != "" {
+		return override, true
+	}
+	return agent.Model, false
+}
+
+func (al *AgentLoop) thinkCommand(agent *AgentInstance, sessionKey string, args []string) string {
+	usage := fmt.Sprintf("Usage: /think <%s|tokens> | /think default",
+		strings.Join(providers.ReasoningEfforts, "|"))
+	overrides := agent.Sessions.GetOverrides(sessionKey)
+	if len(args) == 0 {
+		thinking := overrides.Thinking()
+		if thinking == "" {
+			thinking = al.modelThinking(agent, sessionKey)
+		}
+		return fmt.Sprintf("Thinking for this conversation: %s\n%s", thinking, usage)
+	}
+
+	level := strings.ToLower(args[0])
+	if level == "off" {
+		level = "none"
+	}
+	budget, err := strconv.Atoi(level)
+	switch {
+	case level == "default" || level == "reset":
+		overrides.ReasoningEffort, overrides.ThinkingBudget = "", 0
+	case slices.Contains(providers.ReasoningEfforts, level):
+		overrides.ReasoningEffort, overrides.ThinkingBudget = level, 0
+	case err == nil && budget >= providers.MinThinkingBudget:
+		overrides.ReasoningEffort, overrides.ThinkingBudget = "", budget
+	case err == nil:
+		return fmt.Sprintf("Thinking budget must be at least %d tokens\n%s", providers.MinThinkingBudget, usage)
+	default:
+		return fmt.Sprintf("Unknown thinking level: %s\n%s", args[0], usage)
+	}
+	agent.Sessions.SetOverrides(sessionKey, overrides)
+	agent.Sessions.Save(sessionKey)
+	if thinking := overrides.Thinking(); thinking != "" {
+		return fmt.Sprintf("Thinking for this conversation set to %s", thinking)
+	}
+	return fmt.Sprintf("Thinking for this conversation reset to %s", al.modelThinking(agent, sessionKey))
+}
+
+// modelThinking describes the reasoning settings of the conversation's
+// model_list entry.
+func (al *AgentLoop) modelThinking(agent *AgentInstance, sessionKey string) string {
+	model, _ := al.conversationModel(agent, sessionKey)
+	entries := al.cfg.ModelConfigs(model)
+	if len(entries) == 0 {
+		return "model default"
+	}
+	switch mc := entries[0]; {
+	case mc.ThinkingBudget > 0:
+		return fmt.Sprintf("%d tokens (from model_list)", mc.ThinkingBudget)
+	case mc.ReasoningEffort != "":
+		return mc.ReasoningEffort + " (from model_list)"
+	default:
+		return "model default"
+	}
+}
+
+// modelNames lists the model_list names, in config order.
+func (al *AgentLoop) modelNames() []string {
+	var names []string
+	for _, m := range al.cfg.ModelList {
+		if m.ModelName != "" && !slices.Contains(names, m.ModelName) {
+			names = append(names, m.ModelName)
+		}
+	}
+	return names
+}
+
+// knownModel reports whether name is a model_list name, the protocol/model
+// reference of a model_list entry, or one of the agent's own models.
+func (al *AgentLoop) knownModel(agent *AgentInstance, name string) bool {
+	if name == agent.Model || slices.Contains(agent.Fallbacks, name) {
+		return true
+	}
+	for _, m := range al.cfg.ModelList {
+		if name == m.ModelName || name == m.Model {
+			return true
+		}
+	}
+	return false
+}
+
+// turnModels returns the model and fallback chain a turn of the session runs
+// with, honoring a model chosen with /model.
+func (al *AgentLoop) turnModels(
+	agent *AgentInstance,
+	overrides session.Overrides,
+) (string, []providers.FallbackCandidate) {
+	if overrides.Model == "" {
+		return agent.Model, agent.Candidates
+	}
+	return overrides.Model, agent.candidatesWithPrimary(overrides.Model)
+}
+
+func (al *AgentLoop) summaryCommand(agent *AgentInstance, sessionKey string, args []string) string {
+	if len(args) == 0 {
+		summary := agent.Sessions.GetSummary(sessionKey)
+		if summary == "" {
+			return "No summary yet. Use /summary now to summarize the conversation."
+		}
+		return "Summary:\n" + summary
+	}
+	if args[0] != "now" {
+		return "Usage: /summary [now]"
+	}
+
+	summarizeKey := agent.ID + ":" + sessionKey
+	if _, loading := al.summarizing.LoadOrStore(summarizeKey, true); loading {
+		return "A summary is already being written, try again shortly."
+	}
+	defer al.summarizing.Delete(summarizeKey)
+
+	before := len(agent.Sessions.GetHistory(sessionKey))
+	al.summarizeSession(agent, sessionKey)
+	if len(agent.Sessions.GetHistory(sessionKey)) == before {
+		return "Could not summarize the conversation; it may still be too short."
+	}
+	return "Summary:\n" + agent.Sessions.GetSummary(sessionKey)
+}
+
+// lastUserMessage returns the last user message in history.
+func lastUserMessage(history []providers.Message) (providers.Message, bool) {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			return history[i], true
+		}
+	}
+	return providers.Message{}, false
+}
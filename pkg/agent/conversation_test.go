@@ -0,0 +1,273 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// modelRecordingProvider answers with a numbered reply, with reasoning if
+// set, and records the model, options and last message of each request.
+type modelRecordingProvider struct {
+	mu        sync.Mutex
+	reasoning string
+	models    []string
+	options   []map[string]any
+	lastMsgs  []providers.Message
+}
+
+func (m *modelRecordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models = append(m.models, model)
+	m.options = append(m.options, opts)
+	m.lastMsgs = append(m.lastMsgs, messages[len(messages)-1])
+	return &providers.LLMResponse{
+		Content:          fmt.Sprintf("reply %d", len(m.models)),
+		ReasoningContent: m.reasoning,
+	}, nil
+}
+
+func (m *modelRecordingProvider) GetDefaultModel() string {
+	return "test-model"
+}
+
+func newConversationTestLoop(t *testing.T) (*AgentLoop, *modelRecordingProvider) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "fast", Model: "openai/fast-model-v2"},
+			{ModelName: "other", Model: "openai/other-model"},
+		},
+	}
+	provider := &modelRecordingProvider{}
+	return mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider), provider
+}
+
+func command(t *testing.T, al *AgentLoop, sessionKey, content string) string {
+	t.Helper()
+	reply, handled := al.handleCommand(context.Background(), bus.InboundMessage{
+		Channel: "test", ChatID: "chat", Content: content, SessionKey: sessionKey,
+	})
+	if !handled {
+		t.Fatalf("%s was not handled", content)
+	}
+	return reply
+}
+
+func TestConversationCommands_ModelIsPerConversation(t *testing.T) {
+	al, provider := newConversationTestLoop(t)
+	agent := al.registry.GetDefaultAgent()
+	a, b := "agent:main:a", "agent:main:b"
+
+	if reply := command(t, al, a, "/model fast"); !strings.Contains(reply, "to fast") {
+		t.Errorf("/model fast = %q", reply)
+	}
+	if reply := command(t, al, b, "/switch model to openai/other-model"); !strings.Contains(reply, "other-model") {
+		t.Errorf("/switch model = %q", reply)
+	}
+	if reply := command(t, al, "agent:main:c", "/model llama3:70b"); !strings.Contains(reply, "Unknown model") ||
+		!strings.Contains(reply, "Available models: fast, other") {
+		t.Errorf("/model with an unconfigured model = %q", reply)
+	}
+	if agent.Model != "test-model" {
+		t.Errorf("agent model changed to %q", agent.Model)
+	}
+
+	for _, key := range []string{a, b, "agent:main:c"} {
+		if _, err := al.ProcessDirectWithChannel(context.Background(), "hi", key, "test", "chat"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"fast-model-v2", "other-model", "test-model"}
+	if strings.Join(provider.models, ",") != strings.Join(want, ",") {
+		t.Errorf("models used = %v, want %v", provider.models, want)
+	}
+	if got := agent.Sessions.GetOverrides(a).Model; got != "fast" {
+		t.Errorf("stored override = %q", got)
+	}
+
+	if reply := command(t, al, a, "/show model"); reply != "Current model: fast" {
+		t.Errorf("/show model = %q", reply)
+	}
+	command(t, al, a, "/model default")
+	if reply := command(t, al, a, "/model"); !strings.Contains(reply, "conversation: test-model") ||
+		!strings.Contains(reply, "Available models: fast") {
+		t.Errorf("/model = %q", reply)
+	}
+}
+
+func TestConversationCommands_UndoAndRetry(t *testing.T) {
+	al, provider := newConversationTestLoop(t)
+	agent := al.registry.GetDefaultAgent()
+	key := "agent:main:retry"
+
+	if reply := command(t, al, key, "/retry"); reply != "Nothing to retry" {
+		t.Errorf("/retry on empty session = %q", reply)
+	}
+	for _, q := range []string{"first", "second"} {
+		if _, err := al.ProcessDirectWithChannel(context.Background(), q, key, "test", "chat"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if reply := command(t, al, key, "/retry"); reply != "reply 3" {
+		t.Errorf("/retry = %q", reply)
+	}
+	if last := provider.lastMsgs[2]; last.Role != "user" || last.Content != "second" {
+		t.Errorf("retry request ended with %+v", last)
+	}
+	history := agent.Sessions.GetHistory(key)
+	if got := joinContents(history); got != "first|reply 1|second|reply 3" {
+		t.Errorf("history after retry = %s", got)
+	}
+
+	agent.Sessions.AddFullMessage(key, providers.Message{Role: "user", Content: "what is this?", Parts: []providers.ContentPart{
+		{Type: "text", Text: "what is this?"},
+		{Type: "text", Text: "[image: photo.jpg]"},
+	}})
+	agent.Sessions.AddMessage(key, "assistant", "a placeholder")
+	if reply := command(t, al, key, "/retry"); !strings.Contains(reply, "attachments") {
+		t.Errorf("/retry of a message with attachments = %q", reply)
+	}
+	if got := joinContents(agent.Sessions.GetHistory(key)); got != "first|reply 1|second|reply 3|what is this?|a placeholder" {
+		t.Errorf("refused retry changed the history: %s", got)
+	}
+	agent.Sessions.Undo(key)
+
+	if reply := command(t, al, key, "/undo"); !strings.Contains(reply, "second") {
+		t.Errorf("/undo = %q", reply)
+	}
+	if got := joinContents(agent.Sessions.GetHistory(key)); got != "first|reply 1" {
+		t.Errorf("history after undo = %s", got)
+	}
+}
+
+func TestConversationCommands_NewAndReset(t *testing.T) {
+	al, _ := newConversationTestLoop(t)
+	agent := al.registry.GetDefaultAgent()
+	key := "agent:main:fresh"
+
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "the launch code is 0000", key, "test", "chat"); err != nil {
+		t.Fatal(err)
+	}
+	command(t, al, key, "/model fast")
+
+	if reply := command(t, al, key, "/new"); !strings.Contains(reply, "saved to memory") {
+		t.Errorf("/new = %q", reply)
+	}
+	if len(agent.Sessions.GetHistory(key)) != 0 {
+		t.Error("/new kept the history")
+	}
+	if agent.Sessions.GetOverrides(key).Model != "fast" {
+		t.Error("/new dropped the model override")
+	}
+	results, err := agent.ContextBuilder.Memory().Search("launch code", 5, key)
+	if err != nil || len(results) == 0 {
+		t.Errorf("archived conversation not found in memory: %v, %v", results, err)
+	}
+
+	command(t, al, key, "/reset")
+	if agent.Sessions.GetOverrides(key).Model != "" {
+		t.Error("/reset kept the model override")
+	}
+
+	if reply := command(t, al, key, "/summary"); !strings.HasPrefix(reply, "No summary yet") {
+		t.Errorf("/summary = %q", reply)
+	}
+	if reply := command(t, al, key, "/summary@picoclaw_bot now"); !strings.HasPrefix(reply, "Could not summarize") {
+		t.Errorf("/summary now = %q", reply)
+	}
+}
+
+func TestConversationCommands_Think(t *testing.T) {
+	al, provider := newConversationTestLoop(t)
+	agent := al.registry.GetDefaultAgent()
+	key := "agent:main:think"
+
+	if reply := command(t, al, key, "/think"); !strings.Contains(reply, "conversation: model default") {
+		t.Errorf("/think = %q", reply)
+	}
+	if reply := command(t, al, key, "/think high"); reply != "Thinking for this conversation set to high" {
+		t.Errorf("/think high = %q", reply)
+	}
+	if reply := command(t, al, key, "/think maximum"); !strings.HasPrefix(reply, "Unknown thinking level") {
+		t.Errorf("/think maximum = %q", reply)
+	}
+	if reply := command(t, al, key, "/think 100"); !strings.HasPrefix(reply, "Thinking budget must be at least 1024") {
+		t.Errorf("/think 100 = %q", reply)
+	}
+	if o := agent.Sessions.GetOverrides(key); o.ThinkingBudget != 0 || o.ReasoningEffort != "high" {
+		t.Errorf("overrides after a rejected budget = %+v", o)
+	}
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "hi", key, "test", "chat"); err != nil {
+		t.Fatal(err)
+	}
+
+	command(t, al, key, "/think 4096")
+	if o := agent.Sessions.GetOverrides(key); o.ReasoningEffort != "" || o.ThinkingBudget != 4096 {
+		t.Errorf("overrides = %+v", o)
+	}
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "hi", key, "test", "chat"); err != nil {
+		t.Fatal(err)
+	}
+
+	command(t, al, key, "/think default")
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "hi", key, "test", "chat"); err != nil {
+		t.Fatal(err)
+	}
+
+	if provider.options[0]["reasoning_effort"] != "high" || provider.options[1]["thinking_budget"] != 4096 {
+		t.Errorf("options = %v", provider.options[:2])
+	}
+	if _, ok := provider.options[2]["reasoning_effort"]; ok {
+		t.Errorf("reasoning after /think default: %v", provider.options[2])
+	}
+}
+
+func TestHandleInbound_PublishesReasoning(t *testing.T) {
+	al, provider := newConversationTestLoop(t)
+	provider.reasoning = "greeting back"
+
+	al.handleInbound(context.Background(), bus.InboundMessage{
+		Channel: "telegram", ChatID: "chat", SenderID: "user", Content: "hi", SessionKey: "agent:main:r",
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := al.bus.SubscribeOutbound(ctx)
+	if !ok {
+		t.Fatal("no reply published")
+	}
+	if msg.Content != "reply 1" || msg.Reasoning != "greeting back" {
+		t.Errorf("published %+v", msg)
+	}
+}
+
+func joinContents(msgs []providers.Message) string {
+	parts := make([]string, len(msgs))
+	for i, m := range msgs {
+		parts[i] = m.Content
+	}
+	return strings.Join(parts, "|")
+}
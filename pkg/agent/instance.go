@@ -1,6 +1,7 @@
 package agent
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,9 +10,14 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
+// defaultContextWindow is assumed for models whose model_list entry sets no
+// context_window.
+const defaultContextWindow = 32768
+
 // AgentInstance represents a fully configured agent with its own workspace,
 // session manager, context builder, and tool registry.
 type AgentInstance struct {
@@ -24,13 +30,23 @@ type AgentInstance struct {
 	MaxTokens      int
 	Temperature    float64
 	ContextWindow  int
+	TokenCounter   tokenizer.Counter
 	Provider       providers.LLMProvider
 	Sessions       *session.SessionManager
 	ContextBuilder *ContextBuilder
 	Tools          *tools.ToolRegistry
 	Subagents      *config.SubagentsConfig
-	SkillsFilter   []string
-	Candidates     []providers.FallbackCandidate
+	// SubagentModel is the model tasks delegated to this agent run with:
+	// subagents.model when set, otherwise the primary model.
+	SubagentModel string
+	SkillsFilter  []string
+	MCPFilter     []string
+	Candidates    []providers.FallbackCandidate
+
+	// ImageCandidates is the vision model chain (image_model followed by
+	// image_model_fallbacks). Empty when no image model is configured, in
+	// which case image turns stay on the primary model.
+	ImageCandidates []providers.FallbackCandidate
 }
 
 // NewAgentInstance creates an agent instance from config.
@@ -39,7 +55,7 @@ func NewAgentInstance(
 	defaults *config.AgentDefaults,
 	cfg *config.Config,
 	provider providers.LLMProvider,
-) *AgentInstance {
+) (*AgentInstance, error) {
 	workspace := resolveAgentWorkspace(agentCfg, defaults)
 	os.MkdirAll(workspace, 0o755)
 
@@ -55,21 +71,30 @@ func NewAgentInstance(
 	toolsRegistry.Register(tools.NewEditFileTool(workspace, restrict))
 	toolsRegistry.Register(tools.NewAppendFileTool(workspace, restrict))
 
-	sessionsDir := filepath.Join(workspace, "sessions")
-	sessionsManager := session.NewSessionManager(sessionsDir)
+	sessionsManager, err := newSessionManager(SessionsDir(agentCfg, defaults), cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	contextBuilder := NewContextBuilder(workspace)
+	if defaults.MemoryTopK != 0 {
+		contextBuilder.SetMemoryTopK(defaults.MemoryTopK)
+	}
+	toolsRegistry.Register(tools.NewMemorySearchTool(contextBuilder.Memory()))
+	toolsRegistry.Register(tools.NewMemoryWriteTool(contextBuilder.Memory()))
 
 	agentID := routing.DefaultAgentID
 	agentName := ""
 	var subagents *config.SubagentsConfig
 	var skillsFilter []string
+	var mcpFilter []string
 
 	if agentCfg != nil {
 		agentID = routing.NormalizeAgentID(agentCfg.ID)
 		agentName = agentCfg.Name
 		subagents = agentCfg.Subagents
 		skillsFilter = agentCfg.Skills
+		mcpFilter = agentCfg.MCP
 	}
 
 	maxIter := defaults.MaxToolIterations
@@ -87,6 +112,21 @@ func NewAgentInstance(
 		temperature = *defaults.Temperature
 	}
 
+	subagentModel := model
+	if subagents != nil && subagents.Model != nil && strings.TrimSpace(subagents.Model.Primary) != "" {
+		subagentModel = strings.TrimSpace(subagents.Model.Primary)
+	}
+
+	if skillsFilter != nil {
+		contextBuilder.SetSkillsFilter(skillsFilter)
+	}
+
+	contextWindow, modelID := resolveContextWindow(cfg, model)
+	tokenCounter := tokenizer.ForModel(modelID)
+	// Hold back room for the reply, but never more than a quarter of the
+	// window so small-window models still get most of it for the prompt.
+	contextBuilder.SetTokenBudget(tokenCounter, contextWindow-min(maxTokens, contextWindow/4))
+
 	// Resolve fallback candidates
 	modelCfg := providers.ModelConfig{
 		Primary:   model,
@@ -94,6 +134,11 @@ func NewAgentInstance(
 	}
 	candidates := providers.ResolveCandidates(modelCfg, defaults.Provider)
 
+	imageCandidates := providers.ResolveCandidates(providers.ModelConfig{
+		Primary:   defaults.ImageModel,
+		Fallbacks: defaults.ImageModelFallbacks,
+	}, defaults.Provider)
+
 	return &AgentInstance{
 		ID:             agentID,
 		Name:           agentName,
@@ -103,17 +148,64 @@ func NewAgentInstance(
 		MaxIterations:  maxIter,
 		MaxTokens:      maxTokens,
 		Temperature:    temperature,
-		ContextWindow:  maxTokens,
+		ContextWindow:  contextWindow,
+		TokenCounter:   tokenCounter,
 		Provider:       provider,
 		Sessions:       sessionsManager,
 		ContextBuilder: contextBuilder,
 		Tools:          toolsRegistry,
 		Subagents:      subagents,
+		SubagentModel:  subagentModel,
 		SkillsFilter:   skillsFilter,
+		MCPFilter:      mcpFilter,
 		Candidates:     candidates,
+
+		ImageCandidates: imageCandidates,
+	}, nil
+}
+
+// SubagentProfile returns how tasks delegated to this agent run: with its
+// own system prompt, tools and subagent model.
+func (a *AgentInstance) SubagentProfile() *tools.SubagentProfile {
+	return &tools.SubagentProfile{
+		Provider:      a.Provider,
+		Model:         a.SubagentModel,
+		SystemPrompt:  a.ContextBuilder.BuildSystemPromptWithCache(),
+		Tools:         a.Tools,
+		MaxIterations: a.MaxIterations,
+		MaxTokens:     a.MaxTokens,
+		Temperature:   a.Temperature,
 	}
 }
 
+// candidatesWithPrimary returns the agent's fallback chain led by model
+// instead of its primary model.
+func (a *AgentInstance) candidatesWithPrimary(model string) []providers.FallbackCandidate {
+	defaultProvider := ""
+	if len(a.Candidates) > 0 {
+		defaultProvider = a.Candidates[0].Provider
+	}
+	return providers.ResolveCandidates(providers.ModelConfig{
+		Primary:   model,
+		Fallbacks: a.Fallbacks,
+	}, defaultProvider)
+}
+
+// newSessionManager opens the configured session store in dir. A store that
+// cannot be opened is an error rather than a silent switch to another
+// format, which would hide the sessions already kept in it.
+func newSessionManager(dir string, cfg *config.Config) (*session.SessionManager, error) {
+	var sessionCfg config.SessionConfig
+	if cfg != nil {
+		sessionCfg = cfg.Session
+	}
+	store, err := session.OpenStore(sessionCfg.Store, dir)
+	if err != nil {
+		return nil, fmt.Errorf("opening session store in %s: %w", dir, err)
+	}
+	return session.NewSessionManagerWithStore(store, sessionCfg.MaxCached), nil
+}
+
 // resolveAgentWorkspace determines the workspace directory for an agent.
 func resolveAgentWorkspace(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
 	if agentCfg != nil && strings.TrimSpace(agentCfg.Workspace) != "" {
@@ -127,6 +219,12 @@ func resolveAgentWorkspace(agentCfg *config.AgentConfig, defaults *config.AgentD
 	return filepath.Join(home, ".picoclaw", "workspace-"+id)
 }
 
+// SessionsDir returns the directory holding the sessions of the agent
+// configured by agentCfg; a nil agentCfg means the implicit main agent.
+func SessionsDir(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
+	return filepath.Join(resolveAgentWorkspace(agentCfg, defaults), "sessions")
+}
+
 // resolveAgentModel resolves the primary model for an agent.
 func resolveAgentModel(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) string {
 	if agentCfg != nil && agentCfg.Model != nil && strings.TrimSpace(agentCfg.Model.Primary) != "" {
@@ -135,6 +233,27 @@ func resolveAgentModel(agentCfg *config.AgentConfig, defaults *config.AgentDefau
 	return defaults.GetModelName()
 }
 
+// resolveContextWindow returns the context window of model and the model
+// identifier to pick a tokenizer by, both taken from its model_list entry
+// when there is one.
+func resolveContextWindow(cfg *config.Config, model string) (int, string) {
+	window, modelID := defaultContextWindow, model
+	if cfg == nil {
+		return window, modelID
+	}
+	for _, mc := range cfg.ModelList {
+		if mc.ModelName != model {
+			continue
+		}
+		modelID = mc.Model
+		if mc.ContextWindow > 0 {
+			window = mc.ContextWindow
+		}
+		break
+	}
+	return window, modelID
+}
+
 // resolveAgentFallbacks resolves the fallback models for an agent.
 func resolveAgentFallbacks(agentCfg *config.AgentConfig, defaults *config.AgentDefaults) []string {
 	if agentCfg != nil && agentCfg.Model != nil && agentCfg.Model.Fallbacks != nil {
@@ -2,11 +2,30 @@ package agent
 
 import (
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
+// mustNewAgentInstance returns NewAgentInstance's agent, failing t on error.
+func mustNewAgentInstance(
+	t *testing.T,
+	agentCfg *config.AgentConfig,
+	defaults *config.AgentDefaults,
+	cfg *config.Config,
+	provider providers.LLMProvider,
+) *AgentInstance {
+	t.Helper()
+	agent, err := NewAgentInstance(agentCfg, defaults, cfg, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return agent
+}
+
 func TestNewAgentInstance_UsesDefaultsTemperatureAndMaxTokens(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "agent-instance-test-*")
 	if err != nil {
@@ -29,7 +48,7 @@ func TestNewAgentInstance_UsesDefaultsTemperatureAndMaxTokens(t *testing.T) {
 	cfg.Agents.Defaults.Temperature = &configuredTemp
 
 	provider := &mockProvider{}
-	agent := NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, provider)
+	agent := mustNewAgentInstance(t, nil, &cfg.Agents.Defaults, cfg, provider)
 
 	if agent.MaxTokens != 1234 {
 		t.Fatalf("MaxTokens = %d, want %d", agent.MaxTokens, 1234)
@@ -61,7 +80,7 @@ func TestNewAgentInstance_DefaultsTemperatureWhenZero(t *testing.T) {
 	cfg.Agents.Defaults.Temperature = &configuredTemp
 
 	provider := &mockProvider{}
-	agent := NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, provider)
+	agent := mustNewAgentInstance(t, nil, &cfg.Agents.Defaults, cfg, provider)
 
 	if agent.Temperature != 0.0 {
 		t.Fatalf("Temperature = %f, want %f", agent.Temperature, 0.0)
@@ -87,9 +106,59 @@ func TestNewAgentInstance_DefaultsTemperatureWhenUnset(t *testing.T) {
 	}
 
 	provider := &mockProvider{}
-	agent := NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, provider)
+	agent := mustNewAgentInstance(t, nil, &cfg.Agents.Defaults, cfg, provider)
 
 	if agent.Temperature != 0.7 {
 		t.Fatalf("Temperature = %f, want %f", agent.Temperature, 0.7)
 	}
 }
+
+func TestNewAgentInstance_ContextWindowFromModelList(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace: tmpDir,
+				ModelName: "fast",
+				MaxTokens: 4096,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "fast", Model: "openai/gpt-4o-mini", ContextWindow: 128000},
+		},
+	}
+
+	agent := mustNewAgentInstance(t, nil, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	if agent.ContextWindow != 128000 {
+		t.Errorf("ContextWindow = %d, want 128000", agent.ContextWindow)
+	}
+	if agent.ContextBuilder.tokenBudget != 128000-4096 {
+		t.Errorf("token budget = %d, want %d", agent.ContextBuilder.tokenBudget, 128000-4096)
+	}
+
+	cfg.ModelList[0].ContextWindow = 0
+	agent = mustNewAgentInstance(t, nil, &cfg.Agents.Defaults, cfg, &mockProvider{})
+	if agent.ContextWindow != defaultContextWindow {
+		t.Errorf("ContextWindow = %d, want default %d", agent.ContextWindow, defaultContextWindow)
+	}
+}
+
+func TestNewAgentInstance_FailsWhenSessionStoreCannotOpen(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace: t.TempDir(),
+				Model:     "test-model",
+			},
+		},
+		Session: config.SessionConfig{Store: "postgres"},
+	}
+
+	if _, err := NewAgentInstance(nil, &cfg.Agents.Defaults, cfg, &mockProvider{}); err == nil ||
+		!strings.Contains(err.Error(), "postgres") {
+		t.Fatalf("err = %v, want the store error", err)
+	}
+	if _, err := NewAgentLoop(cfg, bus.NewMessageBus(), &mockProvider{}); err == nil {
+		t.Fatal("NewAgentLoop should fail without a session store")
+	}
+}
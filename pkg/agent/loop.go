@@ -9,23 +9,26 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
-	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/channels"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/constants"
 	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/mcp"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
 	"github.com/sipeed/picoclaw/pkg/skills"
 	"github.com/sipeed/picoclaw/pkg/state"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usage"
 	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
@@ -38,25 +41,56 @@ type AgentLoop struct {
 	summarizing    sync.Map
 	fallback       *providers.FallbackChain
 	channelManager *channels.Manager
+	mcp            *mcp.Manager
+	usage          *usageRecorder
+	workers        *sessionWorkers
 }
 
+// mcpStartTimeout bounds how long startup waits for MCP servers to connect
+// and list their tools.
+const mcpStartTimeout = 30 * time.Second
+
 // processOptions configures how a message is processed
 type processOptions struct {
-	SessionKey      string // Session identifier for history/context
-	Channel         string // Target channel for tool execution
-	ChatID          string // Target chat ID for tool execution
-	UserMessage     string // User message content (may include prefix)
-	DefaultResponse string // Response when LLM returns empty
-	EnableSummary   bool   // Whether to trigger summarization
-	SendResponse    bool   // Whether to send response via bus
-	NoHistory       bool   // If true, don't load session history (for heartbeat)
+	SessionKey      string   // Session identifier for history/context
+	Channel         string   // Target channel for tool execution
+	ChatID          string   // Target chat ID for tool execution
+	SenderID        string   // Sender of the inbound message, if any
+	UserMessage     string   // User message content (may include prefix)
+	Media           []string // Inbound media references (local paths or URLs)
+	DefaultResponse string   // Response when LLM returns empty
+	EnableSummary   bool     // Whether to trigger summarization
+	SendResponse    bool     // Whether to send response via bus
+	NoHistory       bool     // If true, don't load session history (for heartbeat)
+	Ephemeral       bool     // If true, nothing is recorded in the session
+	// Retry answers the user message already last in the session's history
+	// again instead of adding UserMessage.
+	Retry bool
+	// Model, when set, is the only model the turn runs with, e.g. the
+	// cheaper one a usage budget downgrades to.
+	Model string
+
+	// OnDelta, when set, receives response text as it is streamed from the
+	// provider instead of the channel getting partial messages.
+	OnDelta func(delta string)
 }
 
-func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
-	registry := NewAgentRegistry(cfg, provider)
+func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) (*AgentLoop, error) {
+	tokenizer.EnableDownloads(cfg.Agents.Defaults.DownloadTokenizers)
+	registry, err := NewAgentRegistry(cfg, provider)
+	if err != nil {
+		return nil, err
+	}
+
+	// Connect MCP servers so their tools can be registered below
+	mcpManager := mcp.NewManager(cfg.Tools.MCP)
+	startCtx, cancel := context.WithTimeout(context.Background(), mcpStartTimeout)
+	mcpManager.Start(startCtx)
+	cancel()
 
 	// Register shared tools to all agents
-	registerSharedTools(cfg, msgBus, registry, provider)
+	usageRecorder := newUsageRecorder(cfg)
+	registerSharedTools(cfg, msgBus, registry, mcpManager, usageRecorder)
 
 	// Set up shared fallback chain
 	cooldown := providers.NewCooldownTracker()
@@ -76,15 +110,19 @@ func NewAgentLoop(cfg *config.Config, msgBus *bus.MessageBus, provider providers
 		state:       stateManager,
 		summarizing: sync.Map{},
 		fallback:    fallbackChain,
-	}
+		mcp:         mcpManager,
+		usage:       usageRecorder,
+		workers:     newSessionWorkers(cfg.Agents.Defaults.MaxConcurrency),
+	}, nil
 }
 
-// registerSharedTools registers tools that are shared across all agents (web, message, spawn).
+// registerSharedTools registers tools that are shared across all agents (web, message, spawn, MCP).
 func registerSharedTools(
 	cfg *config.Config,
 	msgBus *bus.MessageBus,
 	registry *AgentRegistry,
-	provider providers.LLMProvider,
+	mcpManager *mcp.Manager,
+	usageRecorder *usageRecorder,
 ) {
 	for _, agentID := range registry.ListAgentIDs() {
 		agent, ok := registry.GetAgent(agentID)
@@ -137,42 +175,46 @@ func registerSharedTools(
 			cfg.Tools.Skills.SearchCache.MaxSize,
 			time.Duration(cfg.Tools.Skills.SearchCache.TTLSeconds)*time.Second,
 		)
-		agent.Tools.Register(tools.NewFindSkillsTool(registryMgr, searchCache))
-		agent.Tools.Register(tools.NewInstallSkillTool(registryMgr, agent.Workspace))
+		skillsFilter := skills.NewFilter(agent.SkillsFilter)
+		findSkillsTool := tools.NewFindSkillsTool(registryMgr, searchCache)
+		findSkillsTool.SetFilter(skillsFilter)
+		agent.Tools.Register(findSkillsTool)
+		installSkillTool := tools.NewInstallSkillTool(registryMgr, agent.Workspace)
+		installSkillTool.SetFilter(skillsFilter)
+		agent.Tools.Register(installSkillTool)
 
 		// Academic paper search and fetch tools
-		if cfg.Tools.Academic.Enabled {
-			academicOpts := tools.AcademicSearchToolOptions{
-				EmailForPolite:        cfg.Tools.Academic.EmailForPolite,
-				MaxResultsPerSource:   cfg.Tools.Academic.MaxResultsPerSource,
-				SemanticScholarAPIKey: cfg.Tools.Academic.SemanticScholarAPIKey,
-				SpringerAPIKey:        cfg.Tools.Academic.SpringerAPIKey,
-				IEEEAPIKey:            cfg.Tools.Academic.IEEEAPIKey,
-				ElsevierAPIKey:        cfg.Tools.Academic.ElsevierAPIKey,
-				LensAPIKey:            cfg.Tools.Academic.LensAPIKey,
-				PubMedAPIKey:          cfg.Tools.Academic.PubMedAPIKey,
-			}
-			restrictWS := cfg.Agents.Defaults.RestrictToWorkspace
-			agent.Tools.Register(tools.NewAcademicSearchTool(academicOpts, agent.Workspace, restrictWS))
-			agent.Tools.Register(tools.NewAcademicFetchPaperTool(cfg.Tools.Academic.EmailForPolite, agent.Workspace, restrictWS))
-			agent.Tools.Register(tools.NewAcademicExtractCitationsTool(cfg.Tools.Academic.EmailForPolite, agent.Workspace, restrictWS))
-		}
+		registerAcademicTools(cfg, agent)
 
 		// Spawn tool with allowlist checker
-		subagentManager := tools.NewSubagentManager(provider, agent.Model, agent.Workspace, msgBus)
+		subagentManager := tools.NewSubagentManager(agent.Provider, agent.Model, agent.Workspace, msgBus)
 		subagentManager.SetLLMOptions(agent.MaxTokens, agent.Temperature)
-		spawnTool := tools.NewSpawnTool(subagentManager)
 		currentAgentID := agentID
+		subagentManager.SetResolver(registry.SubagentResolver(currentAgentID))
+		subagentManager.SetUsageHook(func(ctx context.Context, targetAgentID, model string, resp *providers.LLMResponse) {
+			if targetAgentID == "" {
+				targetAgentID = currentAgentID
+			}
+			usageRecorder.record(ctx, targetAgentID, model, usage.KindSubagent, resp)
+		})
+		spawnTool := tools.NewSpawnTool(subagentManager)
 		spawnTool.SetAllowlistChecker(func(targetAgentID string) bool {
 			return registry.CanSpawnSubagent(currentAgentID, targetAgentID)
 		})
 		agent.Tools.Register(spawnTool)
+
+		// MCP server tools, filtered by the agent's allowlist
+		for _, tool := range mcpManager.Tools(agent.MCPFilter) {
+			agent.Tools.Register(tool)
+		}
 	}
 }
 
 func (al *AgentLoop) Run(ctx context.Context) error {
 	al.running.Store(true)
 
+	defer al.workers.wait()
+
 	for al.running.Load() {
 		select {
 		case <-ctx.Done():
@@ -183,41 +225,90 @@ func (al *AgentLoop) Run(ctx context.Context) error {
 				continue
 			}
 
-			response, err := al.processMessage(ctx, msg)
-			if err != nil {
-				response = fmt.Sprintf("Error processing message: %v", err)
-			}
-
-			if response != "" {
-				// Check if the message tool already sent a response during this round.
-				// If so, skip publishing to avoid duplicate messages to the user.
-				// Use default agent's tools to check (message tool is shared).
-				alreadySent := false
-				defaultAgent := al.registry.GetDefaultAgent()
-				if defaultAgent != nil {
-					if tool, ok := defaultAgent.Tools.Get("message"); ok {
-						if mt, ok := tool.(*tools.MessageTool); ok {
-							alreadySent = mt.HasSentInRound()
-						}
-					}
-				}
-
-				if !alreadySent {
-					al.bus.PublishOutbound(bus.OutboundMessage{
-						Channel: msg.Channel,
-						ChatID:  msg.ChatID,
-						Content: response,
-					})
-				}
-			}
+			al.workers.submit(ctx, al.sessionKeyFor(msg), msg, al.handleInbound)
 		}
 	}
 
 	return nil
 }
 
+// handleInbound processes one inbound message and publishes the response.
+// It runs on the worker of the message's session.
+func (al *AgentLoop) handleInbound(ctx context.Context, msg bus.InboundMessage) {
+	tc := &tools.ToolCallContext{Channel: msg.Channel, ChatID: msg.ChatID, SenderID: msg.SenderID}
+	ctx = tools.WithToolCallContext(ctx, tc)
+
+	response, err := al.processMessage(ctx, msg)
+	if err != nil {
+		response = fmt.Sprintf("Error processing message: %v", err)
+	}
+
+	// Skip publishing if the message tool already sent a response during
+	// this request, to avoid duplicate messages to the user.
+	if response != "" && !tc.MessageSent() {
+		al.bus.PublishOutbound(bus.OutboundMessage{
+			Channel:   msg.Channel,
+			ChatID:    msg.ChatID,
+			Content:   response,
+			Reasoning: tc.Reasoning(),
+			StreamID:  tc.StreamID(),
+		})
+	} else if tc.StreamID() != "" {
+		// No final reply replaces the streamed message, so end the stream
+		// for the channel to drop its state.
+		al.bus.PublishOutbound(bus.OutboundMessage{
+			Channel:  msg.Channel,
+			ChatID:   msg.ChatID,
+			StreamID: tc.StreamID(),
+		})
+	}
+}
+
+// sessionKeyFor returns the session a message will be processed in, used to
+// keep messages of one session ordered.
+func (al *AgentLoop) sessionKeyFor(msg bus.InboundMessage) string {
+	if msg.Channel == "system" {
+		return routing.BuildAgentMainSessionKey(al.registry.GetDefaultAgent().ID)
+	}
+	_, sessionKey, _ := al.resolveRoute(msg)
+	return sessionKey
+}
+
+// resolveRoute picks the agent and session key for an inbound message.
+func (al *AgentLoop) resolveRoute(msg bus.InboundMessage) (*AgentInstance, string, routing.ResolvedRoute) {
+	route := al.registry.ResolveRoute(routing.RouteInput{
+		Channel:    msg.Channel,
+		AccountID:  msg.Metadata["account_id"],
+		Peer:       extractPeer(msg),
+		ParentPeer: extractParentPeer(msg),
+		GuildID:    msg.Metadata["guild_id"],
+		TeamID:     msg.Metadata["team_id"],
+	})
+
+	agent, ok := al.registry.GetAgent(route.AgentID)
+	if !ok {
+		agent = al.registry.GetDefaultAgent()
+	}
+
+	// Use routed session key, but honor pre-set agent-scoped keys (for ProcessDirect/cron)
+	sessionKey := route.SessionKey
+	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
+		sessionKey = msg.SessionKey
+	}
+
+	return agent, sessionKey, route
+}
+
+// Stop stops the loop and releases its sessions, MCP servers and providers.
 func (al *AgentLoop) Stop() {
 	al.running.Store(false)
+	al.mcp.Close()
+	for _, agentID := range al.registry.ListAgentIDs() {
+		if agent, ok := al.registry.GetAgent(agentID); ok {
+			agent.Sessions.Close()
+		}
+	}
+	al.registry.providers.Close()
 }
 
 func (al *AgentLoop) RegisterTool(tool tools.Tool) {
@@ -269,6 +360,80 @@ func (al *AgentLoop) ProcessDirectWithChannel(
 	return al.processMessage(ctx, msg)
 }
 
+// AgentRequest is a message for a specific agent and session, processed with
+// ProcessAgentMessage.
+type AgentRequest struct {
+	AgentID    string // Empty selects the default agent
+	SessionKey string
+	Channel    string
+	ChatID     string
+	Content    string
+	// Ephemeral runs the message without session history and records
+	// nothing, so concurrent requests sharing SessionKey stay independent.
+	Ephemeral bool
+	// OnDelta, when set and the provider can stream, receives response text
+	// as it is generated. Text streamed before a tool call is included, while
+	// only the final reply is returned.
+	OnDelta func(delta string)
+}
+
+// ProcessAgentMessage runs a message through the requested agent, bypassing
+// channel routing and slash commands. Messages with history are queued on
+// the session's worker, behind any channel message of the same session.
+func (al *AgentLoop) ProcessAgentMessage(ctx context.Context, req AgentRequest) (string, error) {
+	agent := al.registry.GetDefaultAgent()
+	if req.AgentID != "" {
+		var ok bool
+		if agent, ok = al.registry.GetAgent(routing.NormalizeAgentID(req.AgentID)); !ok {
+			return "", fmt.Errorf("agent %q not found", req.AgentID)
+		}
+	}
+	opts := processOptions{
+		SessionKey:      req.SessionKey,
+		Channel:         req.Channel,
+		ChatID:          req.ChatID,
+		UserMessage:     req.Content,
+		DefaultResponse: "I've completed processing but have no response to give.",
+		EnableSummary:   !req.Ephemeral,
+		SendResponse:    false,
+		NoHistory:       req.Ephemeral,
+		Ephemeral:       req.Ephemeral,
+		OnDelta:         req.OnDelta,
+	}
+	if req.Ephemeral {
+		return al.runAgentLoop(ctx, agent, opts)
+	}
+
+	type result struct {
+		reply string
+		err   error
+	}
+	done := make(chan result, 1)
+	al.workers.enqueue(req.SessionKey, sessionJob{ctx: ctx, run: func(ctx context.Context) {
+		reply, err := al.runAgentLoop(ctx, agent, opts)
+		done <- result{reply, err}
+	}})
+	select {
+	case r := <-done:
+		return r.reply, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// ListAgentIDs returns the IDs of all configured agents.
+func (al *AgentLoop) ListAgentIDs() []string {
+	return al.registry.ListAgentIDs()
+}
+
+// DefaultAgentID returns the ID of the agent unrouted messages go to.
+func (al *AgentLoop) DefaultAgentID() string {
+	if agent := al.registry.GetDefaultAgent(); agent != nil {
+		return agent.ID
+	}
+	return routing.DefaultAgentID
+}
+
 // ProcessHeartbeat processes a heartbeat request without session history.
 // Each heartbeat is independent and doesn't accumulate context.
 func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, chatID string) (string, error) {
@@ -286,6 +451,10 @@ func (al *AgentLoop) ProcessHeartbeat(ctx context.Context, content, channel, cha
 }
 
 func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage) (string, error) {
+	// Media files retained for this message are no longer needed once the
+	// request has been built and persisted.
+	defer releaseMedia(msg.Media)
+
 	// Add message preview to log (show full content for error messages)
 	var logContent string
 	if strings.Contains(msg.Content, "Error:") || strings.Contains(msg.Content, "error") {
@@ -312,25 +481,7 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 	}
 
 	// Route to determine agent and session key
-	route := al.registry.ResolveRoute(routing.RouteInput{
-		Channel:    msg.Channel,
-		AccountID:  msg.Metadata["account_id"],
-		Peer:       extractPeer(msg),
-		ParentPeer: extractParentPeer(msg),
-		GuildID:    msg.Metadata["guild_id"],
-		TeamID:     msg.Metadata["team_id"],
-	})
-
-	agent, ok := al.registry.GetAgent(route.AgentID)
-	if !ok {
-		agent = al.registry.GetDefaultAgent()
-	}
-
-	// Use routed session key, but honor pre-set agent-scoped keys (for ProcessDirect/cron)
-	sessionKey := route.SessionKey
-	if msg.SessionKey != "" && strings.HasPrefix(msg.SessionKey, "agent:") {
-		sessionKey = msg.SessionKey
-	}
+	agent, sessionKey, route := al.resolveRoute(msg)
 
 	logger.InfoCF("agent", "Routed message",
 		map[string]any{
@@ -343,7 +494,9 @@ func (al *AgentLoop) processMessage(ctx context.Context, msg bus.InboundMessage)
 		SessionKey:      sessionKey,
 		Channel:         msg.Channel,
 		ChatID:          msg.ChatID,
+		SenderID:        msg.SenderID,
 		UserMessage:     msg.Content,
+		Media:           msg.Media,
 		DefaultResponse: "I've completed processing but have no response to give.",
 		EnableSummary:   true,
 		SendResponse:    false,
@@ -419,33 +572,79 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 		}
 	}
 
-	// 1. Update tool contexts
-	al.updateToolContexts(agent, opts.Channel, opts.ChatID)
+	// 1. Scope tool calls to this request
+	tc := tools.ToolCallContextFrom(ctx)
+	if tc == nil {
+		tc = &tools.ToolCallContext{}
+		ctx = tools.WithToolCallContext(ctx, tc)
+	}
+	tc.Channel = opts.Channel
+	tc.ChatID = opts.ChatID
+	tc.SenderID = opts.SenderID
+	tc.SessionKey = opts.SessionKey
+	tc.AgentID = agent.ID
+
+	// 1b. Enforce usage budgets before anything reaches the session
+	if reply, blocked := al.applyBudget(agent, &opts); blocked {
+		if opts.SendResponse {
+			al.bus.PublishOutbound(bus.OutboundMessage{
+				Channel: opts.Channel,
+				ChatID:  opts.ChatID,
+				Content: reply,
+			})
+		}
+		return reply, nil
+	}
 
 	// 2. Build messages (skip history for heartbeat)
 	var history []providers.Message
 	var summary string
 	if !opts.NoHistory {
 		history = agent.Sessions.GetHistory(opts.SessionKey)
+		if al.overSummaryThreshold(agent, history) {
+			al.summarizeNow(agent, opts.SessionKey)
+			history = agent.Sessions.GetHistory(opts.SessionKey)
+		}
 		summary = agent.Sessions.GetSummary(opts.SessionKey)
 	}
+	var retried *providers.Message
+	if opts.Retry && len(history) > 0 && history[len(history)-1].Role == "user" {
+		retried = &history[len(history)-1]
+		history = history[:len(history)-1]
+		opts.UserMessage = retried.Content
+	}
 	messages := agent.ContextBuilder.BuildMessages(
 		history,
 		summary,
 		opts.UserMessage,
-		nil,
+		opts.Media,
 		opts.Channel,
 		opts.ChatID,
 	)
 
-	// 3. Save user message to session
-	agent.Sessions.AddMessage(opts.SessionKey, "user", opts.UserMessage)
+	// 3. Save user message to session, with inline media reduced to
+	// placeholders; a retried message is already there.
+	if retried != nil {
+		if last := messages[len(messages)-1]; last.Role == "user" {
+			messages = messages[:len(messages)-1]
+		}
+		messages = append(messages, *retried)
+	} else {
+		userMsg := providers.Message{Role: "user", Content: opts.UserMessage}
+		if last := messages[len(messages)-1]; last.Role == "user" {
+			userMsg = last
+		}
+		if !opts.Ephemeral {
+			agent.Sessions.AddFullMessage(opts.SessionKey, withoutInlineMedia(userMsg))
+		}
+	}
 
 	// 4. Run LLM iteration loop
-	finalContent, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
+	finalContent, reasoning, iteration, err := al.runLLMIteration(ctx, agent, messages, opts)
 	if err != nil {
 		return "", err
 	}
+	tc.SetReasoning(reasoning)
 
 	// If last tool had ForUser content and we already sent it, we might not need to send final response
 	// This is controlled by the tool's Silent flag and ForUser content
@@ -456,8 +655,10 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	}
 
 	// 6. Save final assistant message to session
-	agent.Sessions.AddMessage(opts.SessionKey, "assistant", finalContent)
-	agent.Sessions.Save(opts.SessionKey)
+	if !opts.Ephemeral {
+		agent.Sessions.AddMessage(opts.SessionKey, "assistant", finalContent)
+		agent.Sessions.Save(opts.SessionKey)
+	}
 
 	// 7. Optional: summarization
 	if opts.EnableSummary {
@@ -467,9 +668,11 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	// 8. Optional: send response via bus
 	if opts.SendResponse {
 		al.bus.PublishOutbound(bus.OutboundMessage{
-			Channel: opts.Channel,
-			ChatID:  opts.ChatID,
-			Content: finalContent,
+			Channel:   opts.Channel,
+			ChatID:    opts.ChatID,
+			Content:   finalContent,
+			Reasoning: reasoning,
+			StreamID:  tools.ToolCallContextFrom(ctx).StreamID(),
 		})
 	}
 
@@ -486,15 +689,42 @@ func (al *AgentLoop) runAgentLoop(ctx context.Context, agent *AgentInstance, opt
 	return finalContent, nil
 }
 
-// runLLMIteration executes the LLM call loop with tool handling.
+// runLLMIteration executes the LLM call loop with tool handling. It returns
+// the final reply and the reasoning the model gave with it.
 func (al *AgentLoop) runLLMIteration(
 	ctx context.Context,
 	agent *AgentInstance,
 	messages []providers.Message,
 	opts processOptions,
-) (string, int, error) {
+) (string, string, int, error) {
 	iteration := 0
-	var finalContent string
+	var finalContent, finalReasoning string
+
+	// A turn whose user message carries images goes to the image model chain
+	// when one is configured; text-only turns stay on the primary model.
+	visionTurn := len(agent.ImageCandidates) > 0 && al.fallback != nil &&
+		len(messages) > 0 && hasImageParts(messages[len(messages)-1])
+	if visionTurn {
+		logger.InfoCF("agent", "Routing image turn to image model",
+			map[string]any{
+				"agent_id": agent.ID,
+				"model":    agent.ImageCandidates[0].Model,
+			})
+	}
+
+	overrides := agent.Sessions.GetOverrides(opts.SessionKey)
+	model, candidates := al.turnModels(agent, overrides)
+	if opts.Model != "" {
+		model, candidates = opts.Model, nil
+	}
+	provider, model := al.registry.providers.Get(model)
+	candidates = al.registry.providers.Candidates(candidates)
+	imageCandidates := al.registry.providers.Candidates(agent.ImageCandidates)
+
+	stream := al.newStreamPublisher(provider, opts)
+	if stream != nil && stream.id != "" {
+		tools.ToolCallContextFrom(ctx).SetStreamID(stream.id)
+	}
 
 	for iteration < agent.MaxIterations {
 		iteration++
@@ -509,12 +739,16 @@ func (al *AgentLoop) runLLMIteration(
 		// Build tool definitions
 		providerToolDefs := agent.Tools.ToProviderDefs()
 
+		// Tool results of earlier iterations may have outgrown the window.
+		messages = agent.ContextBuilder.FitMessages(messages,
+			tokenizer.CountTools(agent.TokenCounter, providerToolDefs))
+
 		// Log LLM request details
 		logger.DebugCF("agent", "LLM request",
 			map[string]any{
 				"agent_id":          agent.ID,
 				"iteration":         iteration,
-				"model":             agent.Model,
+				"model":             model,
 				"messages_count":    len(messages),
 				"tools_count":       len(providerToolDefs),
 				"max_tokens":        agent.MaxTokens,
@@ -535,14 +769,36 @@ func (al *AgentLoop) runLLMIteration(
 		var err error
 
 		callLLM := func() (*providers.LLMResponse, error) {
-			if len(agent.Candidates) > 1 && al.fallback != nil {
-				fbResult, fbErr := al.fallback.Execute(ctx, agent.Candidates,
+			if visionTurn {
+				fbResult, fbErr := al.fallback.ExecuteImage(ctx, imageCandidates,
 					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
-						return agent.Provider.Chat(ctx, messages, providerToolDefs, model, map[string]any{
-							"max_tokens":       agent.MaxTokens,
-							"temperature":      agent.Temperature,
-							"prompt_cache_key": agent.ID,
-						})
+						p, model := al.registry.providers.Candidate(provider, model)
+						return al.chat(ctx, agent, p, messages, providerToolDefs, model, overrides, stream)
+					},
+				)
+				if fbErr != nil {
+					return nil, fbErr
+				}
+				if len(fbResult.Attempts) > 0 {
+					logger.InfoCF("agent", fmt.Sprintf("Image fallback: succeeded with %s/%s after %d attempts",
+						fbResult.Provider, fbResult.Model, len(fbResult.Attempts)+1),
+						map[string]any{"agent_id": agent.ID, "iteration": iteration})
+				}
+				return fbResult.Response, nil
+			}
+
+			// With a dedicated image model the primary is assumed text-only,
+			// so images left in the history are sent as placeholders.
+			textMessages := messages
+			if len(agent.ImageCandidates) > 0 {
+				textMessages = withoutImageParts(messages)
+			}
+
+			if len(candidates) > 1 && al.fallback != nil {
+				fbResult, fbErr := al.fallback.Execute(ctx, candidates,
+					func(ctx context.Context, provider, model string) (*providers.LLMResponse, error) {
+						p, model := al.registry.providers.Candidate(provider, model)
+						return al.chat(ctx, agent, p, textMessages, providerToolDefs, model, overrides, stream)
 					},
 				)
 				if fbErr != nil {
@@ -555,11 +811,7 @@ func (al *AgentLoop) runLLMIteration(
 				}
 				return fbResult.Response, nil
 			}
-			return agent.Provider.Chat(ctx, messages, providerToolDefs, agent.Model, map[string]any{
-				"max_tokens":       agent.MaxTokens,
-				"temperature":      agent.Temperature,
-				"prompt_cache_key": agent.ID,
-			})
+			return al.chat(ctx, agent, provider, textMessages, providerToolDefs, model, overrides, stream)
 		}
 
 		// Retry loop for context/token errors
@@ -576,20 +828,12 @@ func (al *AgentLoop) runLLMIteration(
 				strings.Contains(errMsg, "invalidparameter") ||
 				strings.Contains(errMsg, "length")
 
-			if isContextError && retry < maxRetries {
+			if isContextError && retry < maxRetries && !opts.Ephemeral {
 				logger.WarnCF("agent", "Context window error detected, attempting compression", map[string]any{
 					"error": err.Error(),
 					"retry": retry,
 				})
 
-				if retry == 0 && !constants.IsInternalChannel(opts.Channel) {
-					al.bus.PublishOutbound(bus.OutboundMessage{
-						Channel: opts.Channel,
-						ChatID:  opts.ChatID,
-						Content: "Context window exceeded. Compressing history and retrying...",
-					})
-				}
-
 				al.forceCompression(agent, opts.SessionKey)
 				newHistory := agent.Sessions.GetHistory(opts.SessionKey)
 				newSummary := agent.Sessions.GetSummary(opts.SessionKey)
@@ -609,12 +853,13 @@ func (al *AgentLoop) runLLMIteration(
 					"iteration": iteration,
 					"error":     err.Error(),
 				})
-			return "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
+			return "", "", iteration, fmt.Errorf("LLM call failed after retries: %w", err)
 		}
 
 		// Check if no tool calls - we're done
 		if len(response.ToolCalls) == 0 {
 			finalContent = response.Content
+			finalReasoning = response.ReasoningContent
 			logger.InfoCF("agent", "LLM response without tool calls (direct answer)",
 				map[string]any{
 					"agent_id":      agent.ID,
@@ -644,9 +889,10 @@ func (al *AgentLoop) runLLMIteration(
 
 		// Build assistant message with tool calls
 		assistantMsg := providers.Message{
-			Role:             "assistant",
-			Content:          response.Content,
-			ReasoningContent: response.ReasoningContent,
+			Role:               "assistant",
+			Content:            response.Content,
+			ReasoningContent:   response.ReasoningContent,
+			ReasoningSignature: response.ReasoningSignature,
 		}
 		for _, tc := range normalizedToolCalls {
 			argumentsJSON, _ := json.Marshal(tc.Arguments)
@@ -673,57 +919,66 @@ func (al *AgentLoop) runLLMIteration(
 		messages = append(messages, assistantMsg)
 
 		// Save assistant message with tool calls to session
-		agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
+		if !opts.Ephemeral {
+			agent.Sessions.AddFullMessage(opts.SessionKey, assistantMsg)
+		}
 
-		// Execute tool calls
-		for _, tc := range normalizedToolCalls {
-			argsJSON, _ := json.Marshal(tc.Arguments)
-			argsPreview := utils.Truncate(string(argsJSON), 200)
-			logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
-				map[string]any{
-					"agent_id":  agent.ID,
-					"tool":      tc.Name,
-					"iteration": iteration,
-				})
+		// Execute tool calls; independent ones run concurrently, results
+		// are appended in call order so history stays deterministic
+		results := agent.Tools.RunToolCalls(ctx, normalizedToolCalls, al.cfg.Agents.Defaults.MaxParallelTools,
+			func(ctx context.Context, tc providers.ToolCall) *tools.ToolResult {
+				argsJSON, _ := json.Marshal(tc.Arguments)
+				argsPreview := utils.Truncate(string(argsJSON), 200)
+				logger.InfoCF("agent", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
+					map[string]any{
+						"agent_id":  agent.ID,
+						"tool":      tc.Name,
+						"iteration": iteration,
+					})
+
+				// Create async callback for tools that implement AsyncTool
+				// NOTE: Following openclaw's design, async tools do NOT send results directly to users.
+				// Instead, they notify the agent via PublishInbound, and the agent decides
+				// whether to forward the result to the user (in processSystemMessage).
+				asyncCallback := func(callbackCtx context.Context, result *tools.ToolResult) {
+					// Log the async completion but don't send directly to user
+					// The agent will handle user notification via processSystemMessage
+					if !result.Silent && result.ForUser != "" {
+						logger.InfoCF("agent", "Async tool completed, agent will handle notification",
+							map[string]any{
+								"tool":        tc.Name,
+								"content_len": len(result.ForUser),
+							})
+					}
+				}
 
-			// Create async callback for tools that implement AsyncTool
-			// NOTE: Following openclaw's design, async tools do NOT send results directly to users.
-			// Instead, they notify the agent via PublishInbound, and the agent decides
-			// whether to forward the result to the user (in processSystemMessage).
-			asyncCallback := func(callbackCtx context.Context, result *tools.ToolResult) {
-				// Log the async completion but don't send directly to user
-				// The agent will handle user notification via processSystemMessage
-				if !result.Silent && result.ForUser != "" {
-					logger.InfoCF("agent", "Async tool completed, agent will handle notification",
+				toolResult := agent.Tools.ExecuteWithContext(
+					ctx,
+					tc.Name,
+					tc.Arguments,
+					opts.Channel,
+					opts.ChatID,
+					asyncCallback,
+				)
+
+				// Send ForUser content to user immediately if not Silent
+				if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
+					al.bus.PublishOutbound(bus.OutboundMessage{
+						Channel: opts.Channel,
+						ChatID:  opts.ChatID,
+						Content: toolResult.ForUser,
+					})
+					logger.DebugCF("agent", "Sent tool result to user",
 						map[string]any{
 							"tool":        tc.Name,
-							"content_len": len(result.ForUser),
+							"content_len": len(toolResult.ForUser),
 						})
 				}
-			}
+				return toolResult
+			})
 
-			toolResult := agent.Tools.ExecuteWithContext(
-				ctx,
-				tc.Name,
-				tc.Arguments,
-				opts.Channel,
-				opts.ChatID,
-				asyncCallback,
-			)
-
-			// Send ForUser content to user immediately if not Silent
-			if !toolResult.Silent && toolResult.ForUser != "" && opts.SendResponse {
-				al.bus.PublishOutbound(bus.OutboundMessage{
-					Channel: opts.Channel,
-					ChatID:  opts.ChatID,
-					Content: toolResult.ForUser,
-				})
-				logger.DebugCF("agent", "Sent tool result to user",
-					map[string]any{
-						"tool":        tc.Name,
-						"content_len": len(toolResult.ForUser),
-					})
-			}
+		for i, tc := range normalizedToolCalls {
+			toolResult := results[i]
 
 			// Determine content for LLM based on tool result
 			contentForLLM := toolResult.ForLLM
@@ -739,40 +994,39 @@ func (al *AgentLoop) runLLMIteration(
 			messages = append(messages, toolResultMsg)
 
 			// Save tool result message to session
-			agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
+			if !opts.Ephemeral {
+				agent.Sessions.AddFullMessage(opts.SessionKey, toolResultMsg)
+			}
 		}
 	}
 
-	return finalContent, iteration, nil
+	return finalContent, finalReasoning, iteration, nil
 }
 
-// updateToolContexts updates the context for tools that need channel/chatID info.
-func (al *AgentLoop) updateToolContexts(agent *AgentInstance, channel, chatID string) {
-	// Use ContextualTool interface instead of type assertions
-	if tool, ok := agent.Tools.Get("message"); ok {
-		if mt, ok := tool.(tools.ContextualTool); ok {
-			mt.SetContext(channel, chatID)
-		}
-	}
-	if tool, ok := agent.Tools.Get("spawn"); ok {
-		if st, ok := tool.(tools.ContextualTool); ok {
-			st.SetContext(channel, chatID)
-		}
-	}
-	if tool, ok := agent.Tools.Get("subagent"); ok {
-		if st, ok := tool.(tools.ContextualTool); ok {
-			st.SetContext(channel, chatID)
-		}
+// overSummaryThreshold reports whether history takes more than 75% of the
+// agent's context window.
+func (al *AgentLoop) overSummaryThreshold(agent *AgentInstance, history []providers.Message) bool {
+	return tokenizer.CountMessages(agent.TokenCounter, history) > agent.ContextWindow*75/100
+}
+
+// summarizeNow summarizes the session before a request whose history would
+// not fit the context window, unless a summarization is already running.
+func (al *AgentLoop) summarizeNow(agent *AgentInstance, sessionKey string) {
+	summarizeKey := agent.ID + ":" + sessionKey
+	if _, loading := al.summarizing.LoadOrStore(summarizeKey, true); loading {
+		return
 	}
+	defer al.summarizing.Delete(summarizeKey)
+	logger.InfoCF("agent", "History exceeds context budget, summarizing before request",
+		map[string]any{"agent_id": agent.ID, "session_key": sessionKey})
+	al.summarizeSession(agent, sessionKey)
 }
 
 // maybeSummarize triggers summarization if the session history exceeds thresholds.
 func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, chatID string) {
 	newHistory := agent.Sessions.GetHistory(sessionKey)
-	tokenEstimate := al.estimateTokens(newHistory)
-	threshold := agent.ContextWindow * 75 / 100
 
-	if len(newHistory) > 20 || tokenEstimate > threshold {
+	if len(newHistory) > 20 || al.overSummaryThreshold(agent, newHistory) {
 		summarizeKey := agent.ID + ":" + sessionKey
 		if _, loading := al.summarizing.LoadOrStore(summarizeKey, true); !loading {
 			go func() {
@@ -785,58 +1039,71 @@ func (al *AgentLoop) maybeSummarize(agent *AgentInstance, sessionKey, channel, c
 }
 
 // forceCompression aggressively reduces context when the limit is hit.
-// It drops the oldest 50% of messages (keeping system prompt and last user message).
+// It drops the oldest half of the conversation, cutting only between whole
+// turns and tool-call exchanges, and keeps pinned messages, a leading system
+// prompt and the last message.
 func (al *AgentLoop) forceCompression(agent *AgentInstance, sessionKey string) {
 	history := agent.Sessions.GetHistory(sessionKey)
 	if len(history) <= 4 {
 		return
 	}
 
-	// Keep system prompt (usually [0]) and the very last message (user's trigger)
-	// We want to drop the oldest half of the *conversation*
-	// Assuming [0] is system, [1:] is conversation
-	conversation := history[1 : len(history)-1]
-	if len(conversation) == 0 {
-		return
+	// A system prompt stored at the head of history stays there.
+	var head []providers.Message
+	conversation := history
+	if history[0].Role == "system" {
+		head, conversation = history[:1], history[1:]
 	}
 
-	// Helper to find the mid-point of the conversation
-	mid := len(conversation) / 2
-
-	// New history structure:
-	// 1. System Prompt (with compression note appended)
-	// 2. Second half of conversation
-	// 3. Last message
-
-	droppedCount := mid
-	keptConversation := conversation[mid:]
-
-	newHistory := make([]providers.Message, 0, 1+len(keptConversation)+1)
-
-	// Append compression note to the original system prompt instead of adding a new system message
-	// This avoids having two consecutive system messages which some APIs (like Zhipu) reject
-	compressionNote := fmt.Sprintf(
-		"\n\n[System Note: Emergency compression dropped %d oldest messages due to context limit]",
-		droppedCount,
-	)
-	enhancedSystemPrompt := history[0]
-	enhancedSystemPrompt.Content = enhancedSystemPrompt.Content + compressionNote
-	newHistory = append(newHistory, enhancedSystemPrompt)
-
-	newHistory = append(newHistory, keptConversation...)
-	newHistory = append(newHistory, history[len(history)-1]) // Last message
+	units := splitUnits(conversation)
+	if len(units) < 2 {
+		return
+	}
+	plan := planCompaction(conversation, units[len(units)/2].start)
+	if len(plan.removed) == 0 {
+		return
+	}
+	digested := digestToolResults(plan.kept)
+
+	newHistory := make([]providers.Message, 0, len(head)+len(plan.kept))
+	if len(head) > 0 {
+		// Append compression note to the original system prompt instead of adding a new system message
+		// This avoids having two consecutive system messages which some APIs (like Zhipu) reject
+		enhancedSystemPrompt := head[0]
+		enhancedSystemPrompt.Content += fmt.Sprintf(
+			"\n\n[System Note: Emergency compression dropped %d oldest messages due to context limit]",
+			len(plan.removed),
+		)
+		newHistory = append(newHistory, enhancedSystemPrompt)
+	}
+	newHistory = append(newHistory, plan.kept...)
 
-	// Update session
-	agent.Sessions.SetHistory(sessionKey, newHistory)
+	archiveDropped(agent, sessionKey, plan.removed)
+	if !agent.Sessions.Compact(sessionKey, len(history), newHistory, "", plan.record("overflow", digested)) {
+		return
+	}
 	agent.Sessions.Save(sessionKey)
 
 	logger.WarnCF("agent", "Forced compression executed", map[string]any{
 		"session_key":  sessionKey,
-		"dropped_msgs": droppedCount,
+		"dropped_msgs": len(plan.removed),
+		"pinned_msgs":  plan.pinned,
+		"digested":     digested,
 		"new_count":    len(newHistory),
 	})
 }
 
+// archiveDropped keeps messages about to leave a session's history in the
+// agent's memory transcripts, where memory_search can still find them.
+func archiveDropped(agent *AgentInstance, sessionKey string, msgs []providers.Message) {
+	if err := agent.ContextBuilder.Memory().ArchiveTranscript(sessionKey, msgs); err != nil {
+		logger.WarnCF("agent", "Failed to archive session transcript", map[string]any{
+			"session_key": sessionKey,
+			"error":       err.Error(),
+		})
+	}
+}
+
 // GetStartupInfo returns information about loaded tools and skills for logging.
 func (al *AgentLoop) GetStartupInfo() map[string]any {
 	info := make(map[string]any)
@@ -888,6 +1155,11 @@ func formatMessagesForLog(messages []providers.Message) string {
 			content := utils.Truncate(msg.Content, 200)
 			fmt.Fprintf(&sb, "  Content: %s\n", content)
 		}
+		for _, part := range msg.Parts {
+			if part.Type != "text" {
+				fmt.Fprintf(&sb, "  Part: %s (%s, %d bytes base64)\n", part.Type, part.MIMEType, len(part.Data))
+			}
+		}
 		if msg.ToolCallID != "" {
 			fmt.Fprintf(&sb, "  ToolCallID: %s\n", msg.ToolCallID)
 		}
@@ -920,6 +1192,7 @@ func formatToolsForLog(toolDefs []providers.ToolDefinition) string {
 func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
+	ctx = tools.WithToolCallContext(ctx, &tools.ToolCallContext{SessionKey: sessionKey, AgentID: agent.ID})
 
 	history := agent.Sessions.GetHistory(sessionKey)
 	summary := agent.Sessions.GetSummary(sessionKey)
@@ -929,19 +1202,19 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 		return
 	}
 
-	toSummarize := history[:len(history)-4]
+	// The cut moves back to the start of a tool-call exchange it would split.
+	plan := planCompaction(history, len(history)-4)
+	if len(plan.removed) == 0 {
+		return
+	}
 
 	// Oversized Message Guard
 	maxMessageTokens := agent.ContextWindow / 2
 	validMessages := make([]providers.Message, 0)
 	omitted := false
 
-	for _, m := range toSummarize {
-		if m.Role != "user" && m.Role != "assistant" {
-			continue
-		}
-		msgTokens := len(m.Content) / 2
-		if msgTokens > maxMessageTokens {
+	for _, m := range summaryInput(plan.removed) {
+		if tokenizer.CountMessage(agent.TokenCounter, m) > maxMessageTokens {
 			omitted = true
 			continue
 		}
@@ -967,19 +1240,9 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 			s1,
 			s2,
 		)
-		resp, err := agent.Provider.Chat(
-			ctx,
-			[]providers.Message{{Role: "user", Content: mergePrompt}},
-			nil,
-			agent.Model,
-			map[string]any{
-				"max_tokens":       1024,
-				"temperature":      0.3,
-				"prompt_cache_key": agent.ID,
-			},
-		)
+		merged, err := al.summarize(ctx, agent, mergePrompt)
 		if err == nil {
-			finalSummary = resp.Content
+			finalSummary = merged
 		} else {
 			finalSummary = s1 + " " + s2
 		}
@@ -992,8 +1255,11 @@ func (al *AgentLoop) summarizeSession(agent *AgentInstance, sessionKey string) {
 	}
 
 	if finalSummary != "" {
-		agent.Sessions.SetSummary(sessionKey, finalSummary)
-		agent.Sessions.TruncateHistory(sessionKey, 4)
+		digested := digestToolResults(plan.kept)
+		if !agent.Sessions.Compact(sessionKey, len(history), plan.kept, finalSummary, plan.record("summary", digested)) {
+			return
+		}
+		archiveDropped(agent, sessionKey, plan.removed)
 		agent.Sessions.Save(sessionKey)
 	}
 }
@@ -1016,35 +1282,56 @@ func (al *AgentLoop) summarizeBatch(
 	for _, m := range batch {
 		fmt.Fprintf(&sb, "%s: %s\n", m.Role, m.Content)
 	}
-	prompt := sb.String()
+	return al.summarize(ctx, agent, sb.String())
+}
 
-	response, err := agent.Provider.Chat(
+// summaryFormat is the structured reply to a summarization prompt.
+var summaryFormat = providers.ResponseFormat{
+	Name: "conversation_summary",
+	Schema: map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"summary": map[string]any{
+				"type":        "string",
+				"description": "The summary, as plain text",
+			},
+		},
+		"required":             []string{"summary"},
+		"additionalProperties": false,
+	},
+	Strict: true,
+}
+
+// summarize sends a summarization prompt and returns the summary. A reply
+// that does not match summaryFormat, as from a provider without structured
+// output, is taken as the summary itself rather than retried.
+func (al *AgentLoop) summarize(ctx context.Context, agent *AgentInstance, prompt string) (string, error) {
+	provider, model := al.registry.providers.Get(agent.Model)
+	var reply struct {
+		Summary string `json:"summary"`
+	}
+	resp, err := providers.ChatStructuredOnce(
 		ctx,
+		provider,
 		[]providers.Message{{Role: "user", Content: prompt}},
-		nil,
-		agent.Model,
+		model,
 		map[string]any{
 			"max_tokens":       1024,
 			"temperature":      0.3,
 			"prompt_cache_key": agent.ID,
 		},
+		summaryFormat,
+		&reply,
 	)
+	al.usage.record(ctx, agent.ID, model, usage.KindSummary, resp)
+	var structErr *providers.StructuredOutputError
+	if errors.As(err, &structErr) && strings.TrimSpace(structErr.Content) != "" {
+		return structErr.Content, nil
+	}
 	if err != nil {
 		return "", err
 	}
-	return response.Content, nil
-}
-
-// estimateTokens estimates the number of tokens in a message list.
-// Uses a safe heuristic of 2.5 characters per token to account for CJK and other
-// overheads better than the previous 3 chars/token.
-func (al *AgentLoop) estimateTokens(messages []providers.Message) int {
-	totalChars := 0
-	for _, m := range messages {
-		totalChars += utf8.RuneCountInString(m.Content)
-	}
-	// 2.5 chars per token = totalChars * 2 / 5
-	return totalChars * 2 / 5
+	return reply.Summary, nil
 }
 
 func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage) (string, bool) {
@@ -1060,24 +1347,41 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 
 	cmd := parts[0]
 	args := parts[1:]
+	// Group chats address commands to a bot as /cmd@botname.
+	if i := strings.Index(cmd, "@"); i > 0 {
+		cmd = cmd[:i]
+	}
 
 	switch cmd {
 	case "/show":
 		if len(args) < 1 {
-			return "Usage: /show [model|channel|agents]", true
+			return "Usage: /show [model|channel|agents|skills]", true
 		}
 		switch args[0] {
 		case "model":
-			defaultAgent := al.registry.GetDefaultAgent()
-			if defaultAgent == nil {
+			agent, sessionKey, _ := al.resolveRoute(msg)
+			if agent == nil {
 				return "No default agent configured", true
 			}
-			return fmt.Sprintf("Current model: %s", defaultAgent.Model), true
+			model, _ := al.conversationModel(agent, sessionKey)
+			return fmt.Sprintf("Current model: %s", model), true
 		case "channel":
 			return fmt.Sprintf("Current channel: %s", msg.Channel), true
 		case "agents":
 			agentIDs := al.registry.ListAgentIDs()
 			return fmt.Sprintf("Registered agents: %s", strings.Join(agentIDs, ", ")), true
+		case "skills":
+			agent, _, _ := al.resolveRoute(msg)
+			if agent == nil {
+				return "No default agent configured", true
+			}
+			info := agent.ContextBuilder.GetSkillsInfo()
+			names, _ := info["names"].([]string)
+			if len(names) == 0 {
+				return fmt.Sprintf("Agent %s has no skills (%d installed)", agent.ID, info["total"]), true
+			}
+			return fmt.Sprintf("Skills for agent %s (%d of %d installed): %s",
+				agent.ID, info["available"], info["total"], strings.Join(names, ", ")), true
 		default:
 			return fmt.Sprintf("Unknown show target: %s", args[0]), true
 		}
@@ -1114,13 +1418,11 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 
 		switch target {
 		case "model":
-			defaultAgent := al.registry.GetDefaultAgent()
-			if defaultAgent == nil {
+			agent, sessionKey, _ := al.resolveRoute(msg)
+			if agent == nil {
 				return "No default agent configured", true
 			}
-			oldModel := defaultAgent.Model
-			defaultAgent.Model = value
-			return fmt.Sprintf("Switched model from %s to %s", oldModel, value), true
+			return al.setConversationModel(agent, sessionKey, value), true
 		case "channel":
 			if al.channelManager == nil {
 				return "Channel manager not initialized", true
@@ -1132,6 +1434,21 @@ func (al *AgentLoop) handleCommand(ctx context.Context, msg bus.InboundMessage)
 		default:
 			return fmt.Sprintf("Unknown switch target: %s", target), true
 		}
+
+	case "/pin":
+		agent, sessionKey, _ := al.resolveRoute(msg)
+		if agent == nil {
+			return "No default agent configured", true
+		}
+		pinned, ok := agent.Sessions.PinLastUserMessage(sessionKey)
+		if !ok {
+			return "Nothing to pin yet", true
+		}
+		agent.Sessions.Save(sessionKey)
+		return fmt.Sprintf("Pinned: %s", utils.Truncate(pinned.Content, 80)), true
+
+	case "/new", "/reset", "/undo", "/retry", "/model", "/think", "/summary":
+		return al.conversationCommand(ctx, msg, cmd, args), true
 	}
 
 	return "", false
@@ -2,18 +2,35 @@ package agent
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tokenizer"
 	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
+// mustNewAgentLoop returns NewAgentLoop's loop, failing t on error.
+func mustNewAgentLoop(t *testing.T, cfg *config.Config, msgBus *bus.MessageBus, provider providers.LLMProvider) *AgentLoop {
+	t.Helper()
+	al, err := NewAgentLoop(cfg, msgBus, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return al
+}
+
 func TestRecordLastChannel(t *testing.T) {
 	// Create temp workspace
 	tmpDir, err := os.MkdirTemp("", "agent-test-*")
@@ -37,7 +54,7 @@ func TestRecordLastChannel(t *testing.T) {
 	// Create agent loop
 	msgBus := bus.NewMessageBus()
 	provider := &mockProvider{}
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 
 	// Test RecordLastChannel
 	testChannel := "test-channel"
@@ -53,7 +70,7 @@ func TestRecordLastChannel(t *testing.T) {
 	}
 
 	// Verify persistence by creating a new agent loop
-	al2 := NewAgentLoop(cfg, msgBus, provider)
+	al2 := mustNewAgentLoop(t, cfg, msgBus, provider)
 	if al2.state.GetLastChannel() != testChannel {
 		t.Errorf("Expected persistent channel '%s', got '%s'", testChannel, al2.state.GetLastChannel())
 	}
@@ -82,7 +99,7 @@ func TestRecordLastChatID(t *testing.T) {
 	// Create agent loop
 	msgBus := bus.NewMessageBus()
 	provider := &mockProvider{}
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 
 	// Test RecordLastChatID
 	testChatID := "test-chat-id-123"
@@ -98,7 +115,7 @@ func TestRecordLastChatID(t *testing.T) {
 	}
 
 	// Verify persistence by creating a new agent loop
-	al2 := NewAgentLoop(cfg, msgBus, provider)
+	al2 := mustNewAgentLoop(t, cfg, msgBus, provider)
 	if al2.state.GetLastChatID() != testChatID {
 		t.Errorf("Expected persistent chat ID '%s', got '%s'", testChatID, al2.state.GetLastChatID())
 	}
@@ -127,7 +144,7 @@ func TestNewAgentLoop_StateInitialized(t *testing.T) {
 	// Create agent loop
 	msgBus := bus.NewMessageBus()
 	provider := &mockProvider{}
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 
 	// Verify state manager is initialized
 	if al.state == nil {
@@ -162,7 +179,7 @@ func TestToolRegistry_ToolRegistration(t *testing.T) {
 
 	msgBus := bus.NewMessageBus()
 	provider := &mockProvider{}
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 
 	// Register a custom tool
 	customTool := &mockCustomTool{}
@@ -208,7 +225,7 @@ func TestToolContext_Updates(t *testing.T) {
 
 	msgBus := bus.NewMessageBus()
 	provider := &simpleMockProvider{response: "OK"}
-	_ = NewAgentLoop(cfg, msgBus, provider)
+	_ = mustNewAgentLoop(t, cfg, msgBus, provider)
 
 	// Verify that ContextualTool interface is defined and can be implemented
 	// This test validates the interface contract exists
@@ -218,6 +235,44 @@ func TestToolContext_Updates(t *testing.T) {
 	var _ tools.ContextualTool = ctxTool
 }
 
+// TestToolContext_RequestScoped verifies tools see the request's identity via ctx
+func TestToolContext_RequestScoped(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+
+	provider := &toolCallingProvider{toolName: "capture_ctx"}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider)
+	capture := &ctxCaptureTool{}
+	al.RegisterTool(capture)
+
+	helper := testHelper{al: al}
+	helper.executeAndGetResponse(t, context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user1",
+		ChatID:   "chat1",
+		Content:  "hi",
+	})
+
+	tc := capture.got
+	if tc == nil {
+		t.Fatal("expected a ToolCallContext in the tool's ctx")
+	}
+	if tc.Channel != "telegram" || tc.ChatID != "chat1" || tc.SenderID != "user1" {
+		t.Errorf("target = %s:%s from %s, want telegram:chat1 from user1", tc.Channel, tc.ChatID, tc.SenderID)
+	}
+	if tc.AgentID != "main" || tc.SessionKey == "" {
+		t.Errorf("AgentID = %q, SessionKey = %q", tc.AgentID, tc.SessionKey)
+	}
+}
+
 // TestToolRegistry_GetDefinitions verifies tool definitions can be retrieved
 func TestToolRegistry_GetDefinitions(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "agent-test-*")
@@ -239,7 +294,7 @@ func TestToolRegistry_GetDefinitions(t *testing.T) {
 
 	msgBus := bus.NewMessageBus()
 	provider := &mockProvider{}
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 
 	// Register a test tool and verify it shows up in startup info
 	testTool := &mockCustomTool{}
@@ -283,7 +338,7 @@ func TestAgentLoop_GetStartupInfo(t *testing.T) {
 
 	msgBus := bus.NewMessageBus()
 	provider := &mockProvider{}
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 
 	info := al.GetStartupInfo()
 
@@ -309,6 +364,48 @@ func TestAgentLoop_GetStartupInfo(t *testing.T) {
 	}
 }
 
+func TestAgentLoop_SkillsFilter(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"research-arxiv", "home-lights"} {
+		dir := filepath.Join(tmpDir, "skills", name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		content := "---\nname: " + name + "\ndescription: " + name + " skill\n---\n\n# " + name
+		if err := os.WriteFile(filepath.Join(dir, "SKILL.md"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+			List: []config.AgentConfig{
+				{ID: "research", Default: true, Workspace: tmpDir, Skills: []string{"research-*"}},
+			},
+		},
+	}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), &mockProvider{})
+	agent, _ := al.registry.GetAgent("research")
+
+	prompt := agent.ContextBuilder.BuildSystemPromptWithCache()
+	if !strings.Contains(prompt, "research-arxiv") || strings.Contains(prompt, "home-lights") {
+		t.Errorf("system prompt should list only research-arxiv:\n%s", prompt)
+	}
+
+	reply, handled := al.handleCommand(context.Background(), bus.InboundMessage{
+		Channel: "cli", SenderID: "user", ChatID: "direct", Content: "/show skills",
+	})
+	if !handled || !strings.Contains(reply, "research-arxiv") || strings.Contains(reply, "home-lights") {
+		t.Errorf("/show skills = %q, want only research-arxiv", reply)
+	}
+}
+
 // TestAgentLoop_Stop verifies Stop() sets running to false
 func TestAgentLoop_Stop(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "agent-test-*")
@@ -330,7 +427,7 @@ func TestAgentLoop_Stop(t *testing.T) {
 
 	msgBus := bus.NewMessageBus()
 	provider := &mockProvider{}
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 
 	// Note: running is only set to true when Run() is called
 	// We can't test that without starting the event loop
@@ -418,6 +515,48 @@ func (m *mockContextualTool) SetContext(channel, chatID string) {
 	m.lastChatID = chatID
 }
 
+// ctxCaptureTool records the ToolCallContext of its last call
+type ctxCaptureTool struct {
+	got *tools.ToolCallContext
+}
+
+func (m *ctxCaptureTool) Name() string        { return "capture_ctx" }
+func (m *ctxCaptureTool) Description() string { return "Captures the tool call context" }
+func (m *ctxCaptureTool) Parameters() map[string]any {
+	return map[string]any{"type": "object", "properties": map[string]any{}}
+}
+
+func (m *ctxCaptureTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	m.got = tools.ToolCallContextFrom(ctx)
+	return tools.SilentResult("captured")
+}
+
+// toolCallingProvider requests one call of toolName, then answers
+type toolCallingProvider struct {
+	toolName string
+	calls    int
+}
+
+func (m *toolCallingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.calls++
+	if m.calls == 1 {
+		return &providers.LLMResponse{
+			ToolCalls: []providers.ToolCall{{ID: "call_1", Name: m.toolName, Arguments: map[string]any{}}},
+		}, nil
+	}
+	return &providers.LLMResponse{Content: "done"}, nil
+}
+
+func (m *toolCallingProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
 // testHelper executes a message and returns the response
 type testHelper struct {
 	al *AgentLoop
@@ -458,7 +597,7 @@ func TestToolResult_SilentToolDoesNotSendUserMessage(t *testing.T) {
 
 	msgBus := bus.NewMessageBus()
 	provider := &simpleMockProvider{response: "File operation complete"}
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 	helper := testHelper{al: al}
 
 	// ReadFileTool returns SilentResult, which should not send user message
@@ -500,7 +639,7 @@ func TestToolResult_UserFacingToolDoesSendMessage(t *testing.T) {
 
 	msgBus := bus.NewMessageBus()
 	provider := &simpleMockProvider{response: "Command output: hello world"}
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 	helper := testHelper{al: al}
 
 	// ExecTool returns UserResult, which should send user message
@@ -579,7 +718,7 @@ func TestAgentLoop_ContextExhaustionRetry(t *testing.T) {
 		successResp: "Recovered from context error",
 	}
 
-	al := NewAgentLoop(cfg, msgBus, provider)
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
 
 	// Inject some history to simulate a full context
 	sessionKey := "test-session-context"
@@ -631,3 +770,400 @@ func TestAgentLoop_ContextExhaustionRetry(t *testing.T) {
 		t.Errorf("Expected history to be compressed (len < 8), got %d", len(finalHistory))
 	}
 }
+
+// budgetRecordingProvider answers summarization requests (no tools) with a
+// fixed summary and records the messages of every other request.
+type budgetRecordingProvider struct {
+	summaries int
+	requests  [][]providers.Message
+}
+
+func (m *budgetRecordingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if len(tools) == 0 {
+		m.summaries++
+		return &providers.LLMResponse{Content: "earlier talk about x"}, nil
+	}
+	m.requests = append(m.requests, messages)
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (m *budgetRecordingProvider) GetDefaultModel() string {
+	return "small"
+}
+
+// TestAgentLoop_SummarizesBeforeExceedingContextWindow verifies that an
+// oversized history is summarized before the request instead of failing.
+func TestAgentLoop_SummarizesBeforeExceedingContextWindow(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "small",
+				MaxTokens:         512,
+				MaxToolIterations: 3,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "small", Model: "openai/small-local", ContextWindow: 6000},
+		},
+	}
+	provider := &budgetRecordingProvider{}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+
+	sessionKey := "agent:main:budget"
+	agent.Sessions.GetOrCreate(sessionKey)
+	var history []providers.Message
+	for i := 0; i < 12; i++ {
+		history = append(history,
+			providers.Message{Role: "user", Content: strings.Repeat("question x ", 100)},
+			providers.Message{Role: "assistant", Content: strings.Repeat("answer x ", 100)},
+		)
+	}
+	agent.Sessions.SetHistory(sessionKey, history)
+
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "next", sessionKey, "test", "chat"); err != nil {
+		t.Fatalf("ProcessDirectWithChannel: %v", err)
+	}
+
+	if provider.summaries == 0 {
+		t.Fatal("expected the session to be summarized before the request")
+	}
+	if len(provider.requests) != 1 {
+		t.Fatalf("expected 1 chat request, got %d", len(provider.requests))
+	}
+	sent := provider.requests[0]
+	if !strings.Contains(sent[0].Content, "earlier talk about x") {
+		t.Error("summary missing from the request's system prompt")
+	}
+	if got := tokenizer.CountMessages(agent.TokenCounter, sent); got > agent.ContextWindow {
+		t.Errorf("request takes %d tokens, over the %d token window", got, agent.ContextWindow)
+	}
+}
+
+// structuredSummaryProvider answers summarization requests with the
+// structured summary they ask for.
+type structuredSummaryProvider struct {
+	formats []string
+}
+
+func (m *structuredSummaryProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	if format := providers.ResponseFormatFrom(opts); format != nil {
+		m.formats = append(m.formats, format.Name)
+		return &providers.LLMResponse{Content: `{"summary": "they planned a trip"}`}, nil
+	}
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (m *structuredSummaryProvider) GetDefaultModel() string {
+	return "mock"
+}
+
+// TestAgentLoop_SummaryUsesStructuredOutput verifies that the summarizer
+// asks for and unwraps a structured summary.
+func TestAgentLoop_SummaryUsesStructuredOutput(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "mock",
+				MaxTokens:         512,
+				MaxToolIterations: 3,
+			},
+		},
+	}
+	provider := &structuredSummaryProvider{}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+
+	sessionKey := "agent:main:structured"
+	agent.Sessions.GetOrCreate(sessionKey)
+	var history []providers.Message
+	for i := 0; i < 4; i++ {
+		history = append(history,
+			providers.Message{Role: "user", Content: "where should we go?"},
+			providers.Message{Role: "assistant", Content: "somewhere warm"},
+		)
+	}
+	agent.Sessions.SetHistory(sessionKey, history)
+
+	al.summarizeSession(agent, sessionKey)
+	if len(provider.formats) != 1 || provider.formats[0] != "conversation_summary" {
+		t.Fatalf("response formats = %v", provider.formats)
+	}
+	if got := agent.Sessions.GetSummary(sessionKey); got != "they planned a trip" {
+		t.Errorf("summary = %q", got)
+	}
+}
+
+// TestAgentLoop_PlainTextSummaryIsNotRetried verifies that a provider
+// without structured output costs one request per summary.
+func TestAgentLoop_PlainTextSummaryIsNotRetried(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "mock",
+				MaxTokens:         512,
+				MaxToolIterations: 3,
+			},
+		},
+	}
+	provider := &countingProvider{response: "they planned a trip"}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+
+	summary, err := al.summarize(context.Background(), agent, "summarize this")
+	if err != nil || summary != "they planned a trip" {
+		t.Fatalf("summarize = %q, %v", summary, err)
+	}
+	if got := provider.calls.Load(); got != 1 {
+		t.Errorf("sent %d requests, want 1", got)
+	}
+}
+
+// countingProvider answers every request with response and counts them.
+type countingProvider struct {
+	response string
+	calls    atomic.Int32
+}
+
+func (m *countingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	m.calls.Add(1)
+	return &providers.LLMResponse{Content: m.response}, nil
+}
+
+func (m *countingProvider) GetDefaultModel() string {
+	return "mock"
+}
+
+// TestAgentLoop_ForceCompressionKeepsToolPairs verifies that emergency
+// compression never orphans tool results and keeps pinned messages.
+func TestAgentLoop_ForceCompressionKeepsToolPairs(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &failFirstMockProvider{
+		failures:    1,
+		failError:   fmt.Errorf("context length exceeded"),
+		successResp: "done",
+	}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider)
+	agent := al.registry.GetDefaultAgent()
+
+	sessionKey := "agent:main:compaction"
+	agent.Sessions.GetOrCreate(sessionKey)
+	call := providers.Message{Role: "assistant", ToolCalls: []providers.ToolCall{
+		{ID: "A", Type: "function", Name: "exec", Function: &providers.FunctionCall{Name: "exec", Arguments: "{}"}},
+		{ID: "B", Type: "function", Name: "exec", Function: &providers.FunctionCall{Name: "exec", Arguments: "{}"}},
+	}}
+	agent.Sessions.SetHistory(sessionKey, []providers.Message{
+		{Role: "user", Content: "remember: deploy key is in vault"},
+		{Role: "assistant", Content: "noted"},
+		{Role: "user", Content: "run the checks"},
+		call,
+		{Role: "tool", Content: "ok", ToolCallID: "A"},
+		{Role: "tool", Content: "ok", ToolCallID: "B"},
+		{Role: "assistant", Content: "checks passed"},
+		{Role: "user", Content: "thanks"},
+		{Role: "assistant", Content: "welcome"},
+	})
+	if reply, _ := al.handleCommand(context.Background(), bus.InboundMessage{
+		Channel: "test", ChatID: "chat", Content: "/pin", SessionKey: sessionKey,
+	}); !strings.HasPrefix(reply, "Pinned: thanks") {
+		t.Fatalf("/pin reply = %q", reply)
+	}
+
+	if _, err := al.ProcessDirectWithChannel(context.Background(), "next", sessionKey, "test", "chat"); err != nil {
+		t.Fatalf("ProcessDirectWithChannel: %v", err)
+	}
+
+	history := agent.Sessions.GetHistory(sessionKey)
+	if len(sanitizeHistoryForProvider(history)) != len(history) {
+		t.Errorf("compaction left unpaired tool messages: %+v", history)
+	}
+	pinnedKept := false
+	for _, m := range history {
+		pinnedKept = pinnedKept || (m.Pinned && m.Content == "thanks")
+	}
+	if !pinnedKept {
+		t.Error("pinned message was compacted away")
+	}
+
+	compactions := agent.Sessions.GetOrCreate(sessionKey).Compactions
+	if len(compactions) != 1 || compactions[0].Reason != "overflow" || compactions[0].Removed == 0 {
+		t.Fatalf("compaction record = %+v", compactions)
+	}
+}
+
+// TestAgentLoop_FallbackUsesCandidateProvider verifies that a fallback to
+// another model_list entry is sent to that entry's own backend rather than
+// to the failing primary provider.
+func TestAgentLoop_FallbackUsesCandidateProvider(t *testing.T) {
+	var backupModel string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		backupModel = req.Model
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"from backup"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				ModelName:         "primary",
+				ModelFallbacks:    []string{"backup"},
+				MaxTokens:         4096,
+				MaxToolIterations: 3,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "primary", Model: "anthropic/claude-sonnet-4"},
+			{ModelName: "backup", Model: "openrouter/openai/gpt-4o", APIKey: "k", APIBase: server.URL},
+		},
+	}
+	primary := &failFirstMockProvider{failures: 1, failError: fmt.Errorf("status 429: rate limit exceeded")}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), primary)
+
+	reply, err := al.ProcessDirectWithChannel(context.Background(), "hi", "agent:main:fallback", "test", "chat")
+	if err != nil {
+		t.Fatalf("ProcessDirectWithChannel: %v", err)
+	}
+	if reply != "from backup" || primary.currentCall != 1 {
+		t.Errorf("reply = %q after %d primary calls", reply, primary.currentCall)
+	}
+	if backupModel != "openai/gpt-4o" {
+		t.Errorf("backup got model %q, want its model ID openai/gpt-4o", backupModel)
+	}
+}
+
+// overlapProvider records the peak number of concurrent Chat calls.
+type overlapProvider struct {
+	running, peak atomic.Int32
+}
+
+func (m *overlapProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	n := m.running.Add(1)
+	defer m.running.Add(-1)
+	for {
+		p := m.peak.Load()
+		if n <= p || m.peak.CompareAndSwap(p, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	return &providers.LLMResponse{Content: "ok"}, nil
+}
+
+func (m *overlapProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestProcessAgentMessage_SerializesSameSession(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &overlapProvider{}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider)
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := al.ProcessAgentMessage(context.Background(), AgentRequest{
+				SessionKey: "agent:main:api:direct:shared",
+				Channel:    "api",
+				ChatID:     "shared",
+				Content:    "hi",
+			}); err != nil {
+				t.Errorf("ProcessAgentMessage failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := provider.peak.Load(); got != 1 {
+		t.Errorf("peak concurrent turns = %d, want 1 for one session", got)
+	}
+	agent := al.registry.GetDefaultAgent()
+	if got := len(agent.Sessions.GetHistory("agent:main:api:direct:shared")); got != 6 {
+		t.Errorf("history length = %d, want 6 (three user/assistant pairs)", got)
+	}
+}
+
+func TestProcessAgentMessage_EphemeralRecordsNothing(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), &simpleMockProvider{response: "done"})
+
+	const key = "agent:main:api:direct:stateless"
+	for range 2 {
+		resp, err := al.ProcessAgentMessage(context.Background(), AgentRequest{
+			SessionKey: key,
+			Channel:    "api",
+			ChatID:     "stateless",
+			Content:    "hi",
+			Ephemeral:  true,
+		})
+		if err != nil || resp != "done" {
+			t.Fatalf("ProcessAgentMessage = %q, %v", resp, err)
+		}
+	}
+
+	agent := al.registry.GetDefaultAgent()
+	if history := agent.Sessions.GetHistory(key); len(history) != 0 {
+		t.Errorf("ephemeral turns recorded %d messages, want none", len(history))
+	}
+}
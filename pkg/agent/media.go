@@ -0,0 +1,167 @@
+package agent
+
+import (
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/utils"
+)
+
+// maxInlineMediaBytes caps the size of a single attachment inlined into an LLM
+// request. Larger files are referenced by a text placeholder instead.
+const maxInlineMediaBytes = 10 << 20
+
+// buildUserParts turns the user's text and inbound media references (local
+// paths or remote URLs) into multimodal content parts. It returns nil when
+// there is no media so text-only turns keep the plain Content form.
+func buildUserParts(text string, media []string) []providers.ContentPart {
+	if len(media) == 0 {
+		return nil
+	}
+
+	parts := make([]providers.ContentPart, 0, len(media)+1)
+	if strings.TrimSpace(text) != "" {
+		parts = append(parts, providers.ContentPart{Type: "text", Text: text})
+	}
+	for _, ref := range media {
+		if part, ok := loadMediaPart(ref); ok {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 || (len(parts) == 1 && parts[0].Type == "text") {
+		return nil
+	}
+	return parts
+}
+
+// loadMediaPart reads a single media reference into a content part.
+// Remote URLs are passed through by reference; local files are inlined as
+// base64 when they fit within maxInlineMediaBytes.
+func loadMediaPart(ref string) (providers.ContentPart, bool) {
+	if ref == "" {
+		return providers.ContentPart{}, false
+	}
+
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		name := filepath.Base(strings.SplitN(ref, "?", 2)[0])
+		mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+		return providers.ContentPart{
+			Type:     mediaKind(name, mimeType),
+			MIMEType: mimeType,
+			URL:      ref,
+			Filename: name,
+		}, true
+	}
+
+	info, err := os.Stat(ref)
+	if err != nil {
+		logger.WarnCF("agent", "Media file not readable", map[string]any{"path": ref, "error": err.Error()})
+		return providers.ContentPart{}, false
+	}
+	name := filepath.Base(ref)
+	if info.Size() > maxInlineMediaBytes {
+		logger.WarnCF("agent", "Media file too large to inline", map[string]any{
+			"path": ref,
+			"size": info.Size(),
+		})
+		return providers.ContentPart{Type: "text", Text: "[file too large: " + name + "]"}, true
+	}
+
+	data, err := os.ReadFile(ref)
+	if err != nil {
+		logger.WarnCF("agent", "Failed to read media file", map[string]any{"path": ref, "error": err.Error()})
+		return providers.ContentPart{}, false
+	}
+
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(name)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if idx := strings.Index(mimeType, ";"); idx >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:idx])
+	}
+
+	return providers.ContentPart{
+		Type:     mediaKind(name, mimeType),
+		MIMEType: mimeType,
+		Data:     base64.StdEncoding.EncodeToString(data),
+		Filename: name,
+	}, true
+}
+
+// mediaKind classifies a file as "image", "audio" or generic "file".
+func mediaKind(filename, mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return "image"
+	case utils.IsAudioFile(filename, mimeType):
+		return "audio"
+	default:
+		return "file"
+	}
+}
+
+// releaseMedia removes inbound media files retained for this message once the
+// agent has consumed them.
+func releaseMedia(media []string) {
+	for _, path := range media {
+		utils.ReleaseMediaFile(path)
+	}
+}
+
+// hasImageParts reports whether a message carries at least one image part.
+func hasImageParts(msg providers.Message) bool {
+	for _, part := range msg.Parts {
+		if part.Type == "image" {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutInlineMedia returns msg with inline (base64) parts replaced by text
+// placeholders. Payloads are only sent with the turn they arrive in; storing
+// them would bloat the session and resend them with every later request.
+func withoutInlineMedia(msg providers.Message) providers.Message {
+	if len(msg.Parts) == 0 {
+		return msg
+	}
+	parts := make([]providers.ContentPart, len(msg.Parts))
+	for i, part := range msg.Parts {
+		if part.Data != "" {
+			part = providers.ContentPart{Type: "text", Text: part.Placeholder()}
+		}
+		parts[i] = part
+	}
+	msg.Parts = parts
+	return msg
+}
+
+// withoutImageParts returns a copy of messages where image parts are replaced
+// by text placeholders, so earlier photos in the history do not break a
+// text-only primary model.
+func withoutImageParts(messages []providers.Message) []providers.Message {
+	out := make([]providers.Message, len(messages))
+	for i, msg := range messages {
+		if !hasImageParts(msg) {
+			out[i] = msg
+			continue
+		}
+		parts := make([]providers.ContentPart, len(msg.Parts))
+		for j, part := range msg.Parts {
+			if part.Type == "image" {
+				part = providers.ContentPart{Type: "text", Text: part.Placeholder()}
+			}
+			parts[j] = part
+		}
+		msg.Parts = parts
+		out[i] = msg
+	}
+	return out
+}
@@ -0,0 +1,172 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// pngHeader is enough of a PNG signature for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestBuildUserParts_NoMedia(t *testing.T) {
+	if parts := buildUserParts("hello", nil); parts != nil {
+		t.Fatalf("expected nil parts for text-only message, got %#v", parts)
+	}
+}
+
+func TestBuildUserParts_LocalImage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "photo.png")
+	if err := os.WriteFile(path, pngHeader, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	parts := buildUserParts("what is this?", []string{path})
+	if len(parts) != 2 {
+		t.Fatalf("len(parts) = %d, want 2", len(parts))
+	}
+	if parts[0].Type != "text" || parts[0].Text != "what is this?" {
+		t.Errorf("parts[0] = %#v, want text part", parts[0])
+	}
+	if parts[1].Type != "image" || parts[1].MIMEType != "image/png" || parts[1].Data == "" {
+		t.Errorf("parts[1] = %+v, want inlined png image", parts[1])
+	}
+}
+
+func TestBuildUserParts_RemoteURLAndMissingFile(t *testing.T) {
+	parts := buildUserParts("", []string{
+		"https://cdn.example.com/a/cat.jpg?width=100",
+		filepath.Join(t.TempDir(), "missing.png"),
+	})
+	if len(parts) != 1 {
+		t.Fatalf("len(parts) = %d, want 1", len(parts))
+	}
+	if parts[0].Type != "image" || parts[0].URL == "" || parts[0].Data != "" {
+		t.Errorf("parts[0] = %+v, want image referenced by URL", parts[0])
+	}
+}
+
+type capturingProvider struct {
+	messages []providers.Message
+	models   []string
+}
+
+func (p *capturingProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.messages = messages
+	p.models = append(p.models, model)
+	return &providers.LLMResponse{Content: "a small image"}, nil
+}
+
+func (p *capturingProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+func TestProcessMessage_ForwardsMediaAndPersistsPlaceholder(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         tmpDir,
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &capturingProvider{}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider)
+
+	imgPath := filepath.Join(t.TempDir(), "photo.png")
+	if err := os.WriteFile(imgPath, pngHeader, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel:  "telegram",
+		SenderID: "user1",
+		ChatID:   "chat1",
+		Content:  "[image: photo]",
+		Media:    []string{imgPath},
+	})
+	if err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+
+	last := provider.messages[len(provider.messages)-1]
+	if !last.HasMedia() {
+		t.Fatalf("expected the provider to receive image parts, got %#v", last)
+	}
+
+	agent := al.registry.GetDefaultAgent()
+	var persisted *providers.Message
+	for _, m := range agent.Sessions.GetHistory("agent:main:main") {
+		if m.Role == "user" {
+			persisted = &m
+		}
+	}
+	if persisted == nil {
+		t.Fatal("expected the user message to be persisted in the session")
+	}
+	if persisted.HasMedia() {
+		t.Fatalf("inline media was persisted: %#v", persisted.Parts)
+	}
+	var placeholder bool
+	for _, p := range persisted.Parts {
+		placeholder = placeholder || p.Text == "[image: photo.png]"
+	}
+	if !placeholder {
+		t.Fatalf("persisted parts = %#v, want an image placeholder", persisted.Parts)
+	}
+}
+
+func TestProcessMessage_RoutesImageTurnsToImageModel(t *testing.T) {
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "text-model",
+				ImageModel:        "vision-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	provider := &capturingProvider{}
+	al := mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider)
+
+	imgPath := filepath.Join(t.TempDir(), "photo.png")
+	if err := os.WriteFile(imgPath, pngHeader, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := bus.InboundMessage{Channel: "telegram", SenderID: "user1", ChatID: "chat1"}
+
+	msg.Content, msg.Media = "describe this", []string{imgPath}
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+	msg.Content, msg.Media = "thanks", nil
+	if _, err := al.processMessage(context.Background(), msg); err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+
+	if len(provider.models) != 2 || provider.models[0] != "vision-model" || provider.models[1] != "text-model" {
+		t.Fatalf("models = %v, want [vision-model text-model]", provider.models)
+	}
+	for _, m := range provider.messages {
+		if hasImageParts(m) {
+			t.Fatalf("text turn should not carry image parts to the primary model: %#v", m)
+		}
+	}
+}
@@ -10,19 +10,37 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
+// longTermPromptLimit caps how much of MEMORY.md goes into the system
+// prompt. Anything beyond it is only reachable through search.
+const longTermPromptLimit = 8000
+
 // MemoryStore manages persistent memory for the agent.
 // - Long-term memory: memory/MEMORY.md
 // - Daily notes: memory/YYYYMM/YYYYMMDD.md
+// - Archived session transcripts: memory/sessions/YYYYMM/<session>.md
+// Everything under memory/ is searchable through a BM25 index. A transcript
+// is only ever returned to the session that wrote it.
 type MemoryStore struct {
 	workspace  string
 	memoryDir  string
 	memoryFile string
+	index      *memory.Index
+
+	// mu serializes the read-modify-write of memory files, which sessions
+	// running in parallel share.
+	mu sync.Mutex
 }
 
 // NewMemoryStore creates a new MemoryStore with the given workspace path.
@@ -38,6 +56,7 @@ func NewMemoryStore(workspace string) *MemoryStore {
 		workspace:  workspace,
 		memoryDir:  memoryDir,
 		memoryFile: memoryFile,
+		index:      memory.NewIndex(memoryDir),
 	}
 }
 
@@ -60,6 +79,12 @@ func (ms *MemoryStore) ReadLongTerm() string {
 
 // WriteLongTerm writes content to the long-term memory file (MEMORY.md).
 func (ms *MemoryStore) WriteLongTerm(content string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.writeLongTermLocked(content)
+}
+
+func (ms *MemoryStore) writeLongTermLocked(content string) error {
 	// Use unified atomic write utility with explicit sync for flash storage reliability.
 	// Using 0o600 (owner read/write only) for secure default permissions.
 	return fileutil.WriteFileAtomic(ms.memoryFile, []byte(content), 0o600)
@@ -78,6 +103,12 @@ func (ms *MemoryStore) ReadToday() string {
 // AppendToday appends content to today's daily note.
 // If the file doesn't exist, it creates a new file with a date header.
 func (ms *MemoryStore) AppendToday(content string) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	return ms.appendTodayLocked(content)
+}
+
+func (ms *MemoryStore) appendTodayLocked(content string) error {
 	todayFile := ms.getTodayFile()
 
 	// Ensure month directory exists
@@ -129,30 +160,139 @@ func (ms *MemoryStore) GetRecentDailyNotes(days int) string {
 	return sb.String()
 }
 
-// GetMemoryContext returns formatted memory context for the agent prompt.
-// Includes long-term memory and recent daily notes.
+// GetMemoryContext returns formatted memory context for the agent prompt:
+// the long-term memory, capped at longTermPromptLimit. Daily notes and older
+// memories reach the prompt through RelevantMemories instead.
 func (ms *MemoryStore) GetMemoryContext() string {
-	longTerm := ms.ReadLongTerm()
-	recentNotes := ms.GetRecentDailyNotes(3)
+	longTerm := strings.TrimSpace(ms.ReadLongTerm())
+	if longTerm == "" {
+		return ""
+	}
+	if utf8.RuneCountInString(longTerm) > longTermPromptLimit {
+		longTerm = utils.Truncate(longTerm, longTermPromptLimit) +
+			"\n\n[MEMORY.md continues; use memory_search to recall the rest.]"
+	}
+	return "## Long-term Memory\n\n" + longTerm
+}
+
+// Search returns the memory chunks most relevant to query. Of the archived
+// transcripts, only sessionKey's own are searched.
+func (ms *MemoryStore) Search(query string, limit int, sessionKey string) ([]memory.Result, error) {
+	own := "/" + transcriptName(sessionKey) + ".md"
+	return ms.index.SearchFunc(query, limit, func(path string) bool {
+		return !isTranscript(path) || strings.HasSuffix(path, own)
+	})
+}
+
+// Write files entry in MEMORY.md or today's daily note and returns the path
+// it was written to, relative to the workspace.
+func (ms *MemoryStore) Write(entry memory.Entry) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	text := entry.Format(time.Now())
+	path := ms.getTodayFile()
+	if entry.LongTerm {
+		path = ms.memoryFile
+		existing := ms.ReadLongTerm()
+		if existing != "" && !strings.HasSuffix(existing, "\n") {
+			existing += "\n"
+		}
+		if existing != "" {
+			existing += "\n"
+		}
+		if err := ms.writeLongTermLocked(existing + text); err != nil {
+			return "", err
+		}
+	} else if err := ms.appendTodayLocked(text); err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(ms.workspace, path)
+	if err != nil {
+		return path, nil
+	}
+	return filepath.ToSlash(rel), nil
+}
 
-	if longTerm == "" && recentNotes == "" {
+// RelevantMemories formats the k memory chunks most relevant to message for
+// the prompt. MEMORY.md is skipped while it fits in the system prompt whole.
+// Archived transcripts are left to memory_search, which scopes them to the
+// session that wrote them.
+func (ms *MemoryStore) RelevantMemories(message string, k int) string {
+	if k <= 0 || strings.TrimSpace(message) == "" {
+		return ""
+	}
+	results, err := ms.index.SearchFunc(message, k+1, func(path string) bool {
+		return !isTranscript(path)
+	})
+	if err != nil || len(results) == 0 {
 		return ""
 	}
+	longTermInPrompt := utf8.RuneCountInString(strings.TrimSpace(ms.ReadLongTerm())) <= longTermPromptLimit
 
 	var sb strings.Builder
+	n := 0
+	for _, r := range results {
+		if n == k {
+			break
+		}
+		if longTermInPrompt && r.Path == "MEMORY.md" {
+			continue
+		}
+		fmt.Fprintf(&sb, "- [memory/%s:%d] %s\n", r.Path, r.Line,
+			strings.ReplaceAll(utils.Truncate(r.Text, 400), "\n", " "))
+		n++
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+var unsafeArchiveChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
 
-	if longTerm != "" {
-		sb.WriteString("## Long-term Memory\n\n")
-		sb.WriteString(longTerm)
+// transcriptName is the file name, without extension, of sessionKey's
+// archived transcripts.
+func transcriptName(sessionKey string) string {
+	name := strings.Trim(unsafeArchiveChars.ReplaceAllString(sessionKey, "_"), "_")
+	if name == "" {
+		name = "session"
 	}
+	return name
+}
 
-	if recentNotes != "" {
-		if longTerm != "" {
-			sb.WriteString("\n\n---\n\n")
+// isTranscript reports whether path, relative to memory/, is an archived
+// session transcript.
+func isTranscript(path string) bool {
+	return strings.HasPrefix(path, "sessions/")
+}
+
+// ArchiveTranscript appends user and assistant messages dropped from a
+// session's history to that session's transcript for the month, so they
+// stay searchable.
+func (ms *MemoryStore) ArchiveTranscript(sessionKey string, messages []providers.Message) error {
+	var sb strings.Builder
+	for _, m := range messages {
+		if (m.Role != "user" && m.Role != "assistant") || strings.TrimSpace(m.Content) == "" {
+			continue
 		}
-		sb.WriteString("## Recent Daily Notes\n\n")
-		sb.WriteString(recentNotes)
+		fmt.Fprintf(&sb, "**%s:** %s\n\n", m.Role, strings.TrimSpace(m.Content))
+	}
+	if sb.Len() == 0 {
+		return nil
 	}
 
-	return sb.String()
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := time.Now()
+	path := filepath.Join(ms.memoryDir, "sessions", now.Format("200601"), transcriptName(sessionKey)+".md")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	existing, _ := os.ReadFile(path)
+	content := string(existing)
+	if content == "" {
+		content = fmt.Sprintf("# Session %s\n\n", sessionKey)
+	}
+	content += fmt.Sprintf("## Archived %s\n\n%s", now.Format("2006-01-02 15:04"), sb.String())
+	return fileutil.WriteFileAtomic(path, []byte(content), 0o600)
 }
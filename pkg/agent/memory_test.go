@@ -0,0 +1,148 @@
+package agent
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestMemoryStore_WriteAndSearch(t *testing.T) {
+	ms := NewMemoryStore(t.TempDir())
+
+	path, err := ms.Write(memory.Entry{Title: "Trip", Content: "Flights to Lisbon booked", Tags: []string{"travel"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(path, "memory/") || strings.HasSuffix(path, "MEMORY.md") {
+		t.Errorf("daily entry written to %q", path)
+	}
+	if _, err := ms.Write(memory.Entry{Content: "Prefers green tea", LongTerm: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(ms.ReadLongTerm(), "Prefers green tea") {
+		t.Errorf("long-term entry missing from MEMORY.md: %q", ms.ReadLongTerm())
+	}
+
+	results, err := ms.Search("lisbon travel", 5, "")
+	if err != nil || len(results) != 1 || !strings.Contains(results[0].Text, "#travel") {
+		t.Fatalf("Search = %+v, %v", results, err)
+	}
+}
+
+func TestMemoryStore_RelevantMemoriesSkipsPromptedLongTerm(t *testing.T) {
+	ms := NewMemoryStore(t.TempDir())
+	ms.WriteLongTerm("User likes tea.\n")
+	ms.AppendToday("Drank a new oolong tea today.\n")
+
+	got := ms.RelevantMemories("which tea?", 5)
+	if strings.Contains(got, "MEMORY.md") {
+		t.Errorf("MEMORY.md already in prompt but recalled: %q", got)
+	}
+	if !strings.Contains(got, "oolong") {
+		t.Errorf("daily note not recalled: %q", got)
+	}
+	if ms.RelevantMemories("which tea?", 0) != "" {
+		t.Error("k=0 should disable recall")
+	}
+}
+
+func TestMemoryStore_ArchiveTranscript(t *testing.T) {
+	ms := NewMemoryStore(t.TempDir())
+	err := ms.ArchiveTranscript("agent:main:telegram:direct:42", []providers.Message{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "Remember the blue bicycle"},
+		{Role: "tool", Content: "tool output"},
+		{Role: "assistant", Content: "Noted the bicycle"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := ms.Search("bicycle", 5, "agent:main:telegram:direct:42")
+	if err != nil || len(results) == 0 {
+		t.Fatalf("archived transcript not searchable: %+v, %v", results, err)
+	}
+	if !strings.HasPrefix(results[0].Path, "sessions/") {
+		t.Errorf("archive path = %q", results[0].Path)
+	}
+	data, _ := os.ReadFile(filepath.Join(ms.memoryDir, results[0].Path))
+	if strings.Contains(string(data), "system prompt") || strings.Contains(string(data), "tool output") {
+		t.Errorf("archive should only hold user and assistant messages:\n%s", data)
+	}
+}
+
+func TestMemoryStore_TranscriptsStayWithTheirSession(t *testing.T) {
+	ms := NewMemoryStore(t.TempDir())
+	ms.ArchiveTranscript("agent:main:telegram:direct:42", []providers.Message{
+		{Role: "user", Content: "My bank PIN is 9999"},
+	})
+
+	if results, _ := ms.Search("bank PIN", 5, "agent:main:telegram:direct:7"); len(results) != 0 {
+		t.Errorf("another session's transcript was searched: %+v", results)
+	}
+	if results, _ := ms.Search("bank PIN", 5, "agent:main:telegram:direct:4"); len(results) != 0 {
+		t.Errorf("session key prefix matched a transcript: %+v", results)
+	}
+	if got := ms.RelevantMemories("what is my bank PIN?", 5); got != "" {
+		t.Errorf("transcript recalled into the prompt: %q", got)
+	}
+}
+
+func TestMemoryStore_ConcurrentWrites(t *testing.T) {
+	ms := NewMemoryStore(t.TempDir())
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ms.Write(memory.Entry{Content: fmt.Sprintf("note %d", i), LongTerm: i%2 == 0})
+		}()
+	}
+	wg.Wait()
+
+	all := ms.ReadLongTerm() + ms.ReadToday()
+	for i := range 20 {
+		if !strings.Contains(all, fmt.Sprintf("note %d\n", i)) {
+			t.Errorf("note %d lost", i)
+		}
+	}
+}
+
+func TestMemoryStore_LongTermPromptCap(t *testing.T) {
+	ms := NewMemoryStore(t.TempDir())
+	ms.WriteLongTerm(strings.Repeat("x", longTermPromptLimit+100))
+
+	ctx := ms.GetMemoryContext()
+	if !strings.Contains(ctx, "memory_search") {
+		t.Errorf("oversized MEMORY.md should point at memory_search")
+	}
+	if len(ctx) > longTermPromptLimit+200 {
+		t.Errorf("memory context not capped: %d chars", len(ctx))
+	}
+}
+
+func TestBuildMessages_IncludesRelevantMemories(t *testing.T) {
+	tmpDir := setupWorkspace(t, nil)
+	defer os.RemoveAll(tmpDir)
+
+	cb := NewContextBuilder(tmpDir)
+	cb.Memory().AppendToday("The garage door code is 4711.\n")
+
+	msgs := cb.BuildMessages(nil, "", "what is the garage code?", nil, "cli", "direct")
+	if !strings.Contains(msgs[0].Content, "# Relevant Memories") || !strings.Contains(msgs[0].Content, "4711") {
+		t.Errorf("relevant memory missing from system prompt:\n%s", msgs[0].Content)
+	}
+
+	cb.SetMemoryTopK(0)
+	msgs = cb.BuildMessages(nil, "", "what is the garage code?", nil, "cli", "direct")
+	if strings.Contains(msgs[0].Content, "# Relevant Memories") {
+		t.Error("recall should be disabled with top-k 0")
+	}
+}
@@ -1,29 +1,43 @@
 package agent
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/sipeed/picoclaw/pkg/config"
 	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tools"
 )
 
 // AgentRegistry manages multiple agent instances and routes messages to them.
 type AgentRegistry struct {
-	agents   map[string]*AgentInstance
-	resolver *routing.RouteResolver
-	mu       sync.RWMutex
+	agents    map[string]*AgentInstance
+	resolver  *routing.RouteResolver
+	providers *providers.Pool
+	mu        sync.RWMutex
 }
 
 // NewAgentRegistry creates a registry from config, instantiating all agents.
+// Each agent gets the provider of its model's model_list entry, with
+// provider serving the default model and models not in model_list. A nil
+// provider leaves the agents without one, for uses that never call a model.
 func NewAgentRegistry(
 	cfg *config.Config,
 	provider providers.LLMProvider,
-) *AgentRegistry {
+) (*AgentRegistry, error) {
 	registry := &AgentRegistry{
-		agents:   make(map[string]*AgentInstance),
-		resolver: routing.NewRouteResolver(cfg),
+		agents:    make(map[string]*AgentInstance),
+		resolver:  routing.NewRouteResolver(cfg),
+		providers: providers.NewPool(cfg, provider),
+	}
+	agentProvider := func(agentCfg *config.AgentConfig) providers.LLMProvider {
+		if provider == nil {
+			return nil
+		}
+		p, _ := registry.providers.Get(resolveAgentModel(agentCfg, &cfg.Agents.Defaults))
+		return p
 	}
 
 	agentConfigs := cfg.Agents.List
@@ -32,14 +46,20 @@ func NewAgentRegistry(
 			ID:      "main",
 			Default: true,
 		}
-		instance := NewAgentInstance(implicitAgent, &cfg.Agents.Defaults, cfg, provider)
+		instance, err := NewAgentInstance(implicitAgent, &cfg.Agents.Defaults, cfg, agentProvider(implicitAgent))
+		if err != nil {
+			return nil, fmt.Errorf("agent %q: %w", "main", err)
+		}
 		registry.agents["main"] = instance
 		logger.InfoCF("agent", "Created implicit main agent (no agents.list configured)", nil)
 	} else {
 		for i := range agentConfigs {
 			ac := &agentConfigs[i]
 			id := routing.NormalizeAgentID(ac.ID)
-			instance := NewAgentInstance(ac, &cfg.Agents.Defaults, cfg, provider)
+			instance, err := NewAgentInstance(ac, &cfg.Agents.Defaults, cfg, agentProvider(ac))
+			if err != nil {
+				return nil, fmt.Errorf("agent %q: %w", id, err)
+			}
 			registry.agents[id] = instance
 			logger.InfoCF("agent", "Registered agent",
 				map[string]any{
@@ -51,7 +71,7 @@ func NewAgentRegistry(
 		}
 	}
 
-	return registry
+	return registry, nil
 }
 
 // GetAgent returns the agent instance for a given ID.
@@ -100,6 +120,25 @@ func (r *AgentRegistry) CanSpawnSubagent(parentAgentID, targetAgentID string) bo
 	return false
 }
 
+// SubagentResolver returns a resolver that runs tasks spawned by
+// parentAgentID as their target agent. An empty target is the parent itself.
+func (r *AgentRegistry) SubagentResolver(parentAgentID string) tools.SubagentResolver {
+	return func(targetAgentID string) (*tools.SubagentProfile, error) {
+		if targetAgentID == "" {
+			targetAgentID = parentAgentID
+		}
+		target, ok := r.GetAgent(targetAgentID)
+		if !ok {
+			return nil, fmt.Errorf("agent %q not found", targetAgentID)
+		}
+		profile := target.SubagentProfile()
+		if profile.Provider != nil {
+			profile.Provider, profile.Model = r.providers.Get(profile.Model)
+		}
+		return profile, nil
+	}
+}
+
 // GetDefaultAgent returns the default agent instance.
 func (r *AgentRegistry) GetDefaultAgent() *AgentInstance {
 	r.mu.RLock()
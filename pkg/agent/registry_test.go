@@ -38,9 +38,20 @@ func testCfg(agents []config.AgentConfig) *config.Config {
 	}
 }
 
+// mustNewAgentRegistry returns NewAgentRegistry's registry, failing t on
+// error.
+func mustNewAgentRegistry(t *testing.T, cfg *config.Config, provider providers.LLMProvider) *AgentRegistry {
+	t.Helper()
+	registry, err := NewAgentRegistry(cfg, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return registry
+}
+
 func TestNewAgentRegistry_ImplicitMain(t *testing.T) {
 	cfg := testCfg(nil)
-	registry := NewAgentRegistry(cfg, &mockRegistryProvider{})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
 
 	ids := registry.ListAgentIDs()
 	if len(ids) != 1 || ids[0] != "main" {
@@ -61,7 +72,7 @@ func TestNewAgentRegistry_ExplicitAgents(t *testing.T) {
 		{ID: "sales", Default: true, Name: "Sales Bot"},
 		{ID: "support", Name: "Support Bot"},
 	})
-	registry := NewAgentRegistry(cfg, &mockRegistryProvider{})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
 
 	ids := registry.ListAgentIDs()
 	if len(ids) != 2 {
@@ -86,7 +97,7 @@ func TestAgentRegistry_GetAgent_Normalize(t *testing.T) {
 	cfg := testCfg([]config.AgentConfig{
 		{ID: "my-agent", Default: true},
 	})
-	registry := NewAgentRegistry(cfg, &mockRegistryProvider{})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
 
 	agent, ok := registry.GetAgent("My-Agent")
 	if !ok || agent == nil {
@@ -102,7 +113,7 @@ func TestAgentRegistry_GetDefaultAgent(t *testing.T) {
 		{ID: "alpha"},
 		{ID: "beta", Default: true},
 	})
-	registry := NewAgentRegistry(cfg, &mockRegistryProvider{})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
 
 	// GetDefaultAgent first checks for "main", then returns any
 	agent := registry.GetDefaultAgent()
@@ -124,7 +135,7 @@ func TestAgentRegistry_CanSpawnSubagent(t *testing.T) {
 		{ID: "child2"},
 		{ID: "restricted"},
 	})
-	registry := NewAgentRegistry(cfg, &mockRegistryProvider{})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
 
 	if !registry.CanSpawnSubagent("parent", "child1") {
 		t.Error("expected parent to be allowed to spawn child1")
@@ -151,7 +162,7 @@ func TestAgentRegistry_CanSpawnSubagent_Wildcard(t *testing.T) {
 		},
 		{ID: "any-agent"},
 	})
-	registry := NewAgentRegistry(cfg, &mockRegistryProvider{})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
 
 	if !registry.CanSpawnSubagent("admin", "any-agent") {
 		t.Error("expected wildcard to allow spawning any agent")
@@ -161,12 +172,53 @@ func TestAgentRegistry_CanSpawnSubagent_Wildcard(t *testing.T) {
 	}
 }
 
+func TestAgentRegistry_SubagentResolver(t *testing.T) {
+	cfg := testCfg([]config.AgentConfig{
+		{ID: "parent", Default: true, Workspace: t.TempDir()},
+		{
+			ID:        "coder",
+			Workspace: t.TempDir(),
+			Model:     &config.AgentModelConfig{Primary: "big-model"},
+			Subagents: &config.SubagentsConfig{Model: &config.AgentModelConfig{Primary: "small-model"}},
+		},
+	})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
+	resolve := registry.SubagentResolver("parent")
+
+	profile, err := resolve("coder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	coder, _ := registry.GetAgent("coder")
+	if profile.Model != "small-model" {
+		t.Errorf("model = %q, want subagents.model small-model", profile.Model)
+	}
+	if profile.Tools != coder.Tools {
+		t.Error("expected the target agent's tool registry")
+	}
+	if _, ok := profile.Tools.Get("read_file"); !ok {
+		t.Error("expected read_file in the subagent's tools")
+	}
+
+	profile, err = resolve("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Model != "gpt-4" {
+		t.Errorf("model = %q, want the parent's primary model gpt-4", profile.Model)
+	}
+
+	if _, err := resolve("nonexistent"); err == nil {
+		t.Error("expected an error for an unknown agent")
+	}
+}
+
 func TestAgentInstance_Model(t *testing.T) {
 	model := &config.AgentModelConfig{Primary: "claude-opus"}
 	cfg := testCfg([]config.AgentConfig{
 		{ID: "custom", Default: true, Model: model},
 	})
-	registry := NewAgentRegistry(cfg, &mockRegistryProvider{})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
 
 	agent, _ := registry.GetAgent("custom")
 	if agent.Model != "claude-opus" {
@@ -179,7 +231,7 @@ func TestAgentInstance_FallbackInheritance(t *testing.T) {
 		{ID: "inherit", Default: true},
 	})
 	cfg.Agents.Defaults.ModelFallbacks = []string{"openai/gpt-4o-mini", "anthropic/haiku"}
-	registry := NewAgentRegistry(cfg, &mockRegistryProvider{})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
 
 	agent, _ := registry.GetAgent("inherit")
 	if len(agent.Fallbacks) != 2 {
@@ -196,10 +248,35 @@ func TestAgentInstance_FallbackExplicitEmpty(t *testing.T) {
 		{ID: "no-fallback", Default: true, Model: model},
 	})
 	cfg.Agents.Defaults.ModelFallbacks = []string{"should-not-inherit"}
-	registry := NewAgentRegistry(cfg, &mockRegistryProvider{})
+	registry := mustNewAgentRegistry(t, cfg, &mockRegistryProvider{})
 
 	agent, _ := registry.GetAgent("no-fallback")
 	if len(agent.Fallbacks) != 0 {
 		t.Errorf("expected 0 fallbacks (explicit empty), got %d: %v", len(agent.Fallbacks), agent.Fallbacks)
 	}
 }
+
+func TestNewAgentRegistry_AgentUsesOwnProvider(t *testing.T) {
+	cfg := testCfg([]config.AgentConfig{
+		{ID: "main", Default: true, Workspace: t.TempDir()},
+		{ID: "writer", Workspace: t.TempDir(), Model: &config.AgentModelConfig{Primary: "claude"}},
+	})
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "gpt-4", Model: "openai/gpt-4", APIKey: "k1"},
+		{ModelName: "claude", Model: "anthropic/claude-sonnet-4", APIKey: "k2"},
+	}
+	provider := &mockRegistryProvider{}
+	registry := mustNewAgentRegistry(t, cfg, provider)
+
+	main, _ := registry.GetAgent("main")
+	writer, _ := registry.GetAgent("writer")
+	if main.Provider != provider {
+		t.Errorf("main agent provider = %T, want the default provider", main.Provider)
+	}
+	if writer.Provider == provider || writer.Provider == nil {
+		t.Errorf("writer agent provider = %T, want the claude entry's provider", writer.Provider)
+	}
+	if p, model := registry.providers.Get(writer.Model); p != writer.Provider || model != "claude-sonnet-4" {
+		t.Errorf("pool resolves writer model to %T, %q", p, model)
+	}
+}
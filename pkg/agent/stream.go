@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/constants"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/session"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// streamUpdateInterval throttles partial updates so channels that edit a
+// message in place stay within their API rate limits.
+const streamUpdateInterval = time.Second
+
+// streamSeq numbers streams so channels can tell one turn's stream from the
+// next in the same chat.
+var streamSeq atomic.Uint64
+
+// streamPublisher forwards streamed response text to the originating channel
+// as throttled partial outbound messages, or as raw deltas to sink.
+type streamPublisher struct {
+	bus     *bus.MessageBus
+	channel string
+	chatID  string
+	id      string
+	sink    func(delta string)
+
+	mu       sync.Mutex
+	text     strings.Builder
+	lastSent time.Time
+}
+
+// newStreamPublisher returns a publisher when the turn's provider supports
+// streaming and either the caller asked for deltas or the target channel
+// supports streaming, or nil otherwise.
+func (al *AgentLoop) newStreamPublisher(provider providers.LLMProvider, opts processOptions) *streamPublisher {
+	if _, ok := provider.(providers.StreamingProvider); !ok {
+		return nil
+	}
+	if opts.OnDelta != nil {
+		return &streamPublisher{sink: opts.OnDelta}
+	}
+	if constants.IsInternalChannel(opts.Channel) || opts.ChatID == "" {
+		return nil
+	}
+	if al.channelManager == nil || !al.channelManager.SupportsStreaming(opts.Channel) {
+		return nil
+	}
+	return &streamPublisher{
+		bus:     al.bus,
+		channel: opts.Channel,
+		chatID:  opts.ChatID,
+		id:      strconv.FormatUint(streamSeq.Add(1), 10),
+	}
+}
+
+// reset discards text from a previous call, e.g. a failed fallback candidate
+// or an earlier tool-calling iteration.
+func (s *streamPublisher) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.text.Reset()
+	s.lastSent = time.Time{}
+}
+
+func (s *streamPublisher) onDelta(delta string) {
+	if s.sink != nil {
+		s.sink(delta)
+		return
+	}
+
+	s.mu.Lock()
+	s.text.WriteString(delta)
+	if time.Since(s.lastSent) < streamUpdateInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSent = time.Now()
+	content := s.text.String()
+	s.mu.Unlock()
+
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	s.bus.PublishOutbound(bus.OutboundMessage{
+		Channel:  s.channel,
+		ChatID:   s.chatID,
+		Content:  content,
+		Partial:  true,
+		StreamID: s.id,
+	})
+}
+
+// chat sends one request for agent to provider, with the reasoning chosen
+// for the conversation in overrides, streaming the response text through
+// stream when it is non-nil, and records its token usage.
+func (al *AgentLoop) chat(
+	ctx context.Context,
+	agent *AgentInstance,
+	provider providers.LLMProvider,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	overrides session.Overrides,
+	stream *streamPublisher,
+) (*providers.LLMResponse, error) {
+	options := map[string]any{
+		"max_tokens":       agent.MaxTokens,
+		"temperature":      agent.Temperature,
+		"prompt_cache_key": agent.ID,
+	}
+	// A /think setting replaces the reasoning of the model's entry.
+	if overrides.ReasoningEffort != "" {
+		options["reasoning_effort"] = overrides.ReasoningEffort
+	}
+	if overrides.ThinkingBudget > 0 {
+		options["thinking_budget"] = overrides.ThinkingBudget
+	}
+	var resp *providers.LLMResponse
+	var err error
+	if sp, ok := provider.(providers.StreamingProvider); ok && stream != nil {
+		stream.reset()
+		resp, err = sp.ChatStream(ctx, messages, tools, model, options, stream.onDelta)
+	} else {
+		resp, err = provider.Chat(ctx, messages, tools, model, options)
+	}
+	al.usage.record(ctx, agent.ID, model, usage.KindChat, resp)
+	return resp, err
+}
@@ -0,0 +1,179 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/channels"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type streamingMockProvider struct {
+	streamed bool
+}
+
+func (p *streamingMockProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	return &providers.LLMResponse{Content: "Hello world"}, nil
+}
+
+func (p *streamingMockProvider) ChatStream(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+	onDelta func(delta string),
+) (*providers.LLMResponse, error) {
+	p.streamed = true
+	onDelta("Hello")
+	onDelta(" world")
+	return &providers.LLMResponse{Content: "Hello world"}, nil
+}
+
+func (p *streamingMockProvider) GetDefaultModel() string {
+	return "mock-model"
+}
+
+type fakeChannel struct {
+	*channels.BaseChannel
+}
+
+func (c *fakeChannel) Start(ctx context.Context) error { return nil }
+func (c *fakeChannel) Stop(ctx context.Context) error  { return nil }
+func (c *fakeChannel) Send(ctx context.Context, msg bus.OutboundMessage) error {
+	return nil
+}
+
+type fakeStreamingChannel struct {
+	fakeChannel
+}
+
+func (c *fakeStreamingChannel) SendPartial(ctx context.Context, msg bus.OutboundMessage) error {
+	return nil
+}
+
+func newStreamingTestLoop(t *testing.T, provider providers.LLMProvider, streamingChannel bool) (*AgentLoop, *bus.MessageBus) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "test-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+	}
+	msgBus := bus.NewMessageBus()
+	al := mustNewAgentLoop(t, cfg, msgBus, provider)
+
+	cm, err := channels.NewManager(cfg, msgBus)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := channels.NewBaseChannel("test", nil, msgBus, nil)
+	if streamingChannel {
+		cm.RegisterChannel("test", &fakeStreamingChannel{fakeChannel{BaseChannel: base}})
+	} else {
+		cm.RegisterChannel("test", &fakeChannel{BaseChannel: base})
+	}
+	al.SetChannelManager(cm)
+	return al, msgBus
+}
+
+func TestProcessMessage_StreamsPartialUpdates(t *testing.T) {
+	provider := &streamingMockProvider{}
+	al, msgBus := newStreamingTestLoop(t, provider, true)
+
+	resp, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "test", SenderID: "user1", ChatID: "chat1", Content: "hi",
+	})
+	if err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+	if resp != "Hello world" {
+		t.Fatalf("response = %q, want %q", resp, "Hello world")
+	}
+	if !provider.streamed {
+		t.Fatal("expected ChatStream to be used for a streaming channel")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, ok := msgBus.SubscribeOutbound(ctx)
+	if !ok || !msg.Partial || msg.Content != "Hello" || msg.ChatID != "chat1" {
+		t.Fatalf("first outbound = %+v, want partial %q", msg, "Hello")
+	}
+}
+
+func TestProcessMessage_NoStreamingForPlainChannel(t *testing.T) {
+	provider := &streamingMockProvider{}
+	al, _ := newStreamingTestLoop(t, provider, false)
+
+	if _, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "test", SenderID: "user1", ChatID: "chat1", Content: "hi",
+	}); err != nil {
+		t.Fatalf("processMessage failed: %v", err)
+	}
+	if provider.streamed {
+		t.Fatal("did not expect ChatStream for a channel without streaming support")
+	}
+}
+
+func TestProcessAgentMessage_StreamsToOnDelta(t *testing.T) {
+	provider := &streamingMockProvider{}
+	al, _ := newStreamingTestLoop(t, provider, false)
+
+	var deltas []string
+	resp, err := al.ProcessAgentMessage(context.Background(), AgentRequest{
+		SessionKey: "agent:main:api:direct:test",
+		Channel:    "api",
+		ChatID:     "test",
+		Content:    "hi",
+		OnDelta:    func(d string) { deltas = append(deltas, d) },
+	})
+	if err != nil {
+		t.Fatalf("ProcessAgentMessage failed: %v", err)
+	}
+	if resp != "Hello world" {
+		t.Fatalf("response = %q, want %q", resp, "Hello world")
+	}
+	if len(deltas) != 2 || deltas[0] != "Hello" || deltas[1] != " world" {
+		t.Fatalf("deltas = %q, want raw provider deltas", deltas)
+	}
+}
+
+func TestHandleInbound_FinalReplyEndsTheTurnsStream(t *testing.T) {
+	provider := &streamingMockProvider{}
+	al, msgBus := newStreamingTestLoop(t, provider, true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	var streamIDs []string
+	for range 2 {
+		al.handleInbound(context.Background(), bus.InboundMessage{
+			Channel: "test", SenderID: "user1", ChatID: "chat1", Content: "hi",
+		})
+		partial, _ := msgBus.SubscribeOutbound(ctx)
+		final, _ := msgBus.SubscribeOutbound(ctx)
+		if !partial.Partial || partial.StreamID == "" {
+			t.Fatalf("partial = %+v", partial)
+		}
+		if final.Partial || final.Content != "Hello world" || final.StreamID != partial.StreamID {
+			t.Fatalf("final = %+v, want reply for stream %q", final, partial.StreamID)
+		}
+		streamIDs = append(streamIDs, final.StreamID)
+	}
+	if streamIDs[0] == streamIDs[1] {
+		t.Errorf("turns share stream %q", streamIDs[0])
+	}
+}
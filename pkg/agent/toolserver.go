@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"fmt"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/routing"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// NewToolServerRegistry returns the tools of an agent that work without an
+// LLM provider or chat channels (filesystem, exec, academic search and
+// hardware), for publishing to other programs over MCP. They honor
+// restrict_to_workspace like the agent's own tools. An empty agentID selects
+// the default agent.
+//
+// cron is left out: the gateway keeps its jobs in memory and rewrites
+// jobs.json, so jobs added by another process would be lost.
+func NewToolServerRegistry(cfg *config.Config, agentID string) (*tools.ToolRegistry, error) {
+	registry, err := NewAgentRegistry(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	agent := registry.GetDefaultAgent()
+	if agentID != "" {
+		var ok bool
+		if agent, ok = registry.GetAgent(routing.NormalizeAgentID(agentID)); !ok {
+			return nil, fmt.Errorf("agent %q not found", agentID)
+		}
+	}
+	if agent == nil {
+		return nil, fmt.Errorf("no agent configured")
+	}
+
+	registerAcademicTools(cfg, agent)
+	agent.Tools.Register(tools.NewI2CTool())
+	agent.Tools.Register(tools.NewSPITool())
+
+	return agent.Tools, nil
+}
+
+// registerAcademicTools registers the academic paper tools when enabled.
+func registerAcademicTools(cfg *config.Config, agent *AgentInstance) {
+	if !cfg.Tools.Academic.Enabled {
+		return
+	}
+	academicOpts := tools.AcademicSearchToolOptions{
+		EmailForPolite:        cfg.Tools.Academic.EmailForPolite,
+		MaxResultsPerSource:   cfg.Tools.Academic.MaxResultsPerSource,
+		SemanticScholarAPIKey: cfg.Tools.Academic.SemanticScholarAPIKey,
+		SpringerAPIKey:        cfg.Tools.Academic.SpringerAPIKey,
+		IEEEAPIKey:            cfg.Tools.Academic.IEEEAPIKey,
+		ElsevierAPIKey:        cfg.Tools.Academic.ElsevierAPIKey,
+		LensAPIKey:            cfg.Tools.Academic.LensAPIKey,
+		PubMedAPIKey:          cfg.Tools.Academic.PubMedAPIKey,
+	}
+	restrictWS := cfg.Agents.Defaults.RestrictToWorkspace
+	agent.Tools.Register(tools.NewAcademicSearchTool(academicOpts, agent.Workspace, restrictWS))
+	agent.Tools.Register(tools.NewAcademicFetchPaperTool(cfg.Tools.Academic.EmailForPolite, agent.Workspace, restrictWS))
+	agent.Tools.Register(tools.NewAcademicExtractCitationsTool(cfg.Tools.Academic.EmailForPolite, agent.Workspace, restrictWS))
+}
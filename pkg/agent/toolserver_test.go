@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestNewToolServerRegistry(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.Workspace = t.TempDir()
+
+	registry, err := NewToolServerRegistry(cfg, "")
+	if err != nil {
+		t.Fatalf("NewToolServerRegistry failed: %v", err)
+	}
+	for _, name := range []string{"read_file", "write_file", "exec", "i2c", "spi"} {
+		if _, ok := registry.Get(name); !ok {
+			t.Errorf("expected tool %q to be served", name)
+		}
+	}
+	for _, name := range []string{"message", "spawn", "cron"} {
+		if _, ok := registry.Get(name); ok {
+			t.Errorf("tool %q needs the gateway and should not be served", name)
+		}
+	}
+
+	if _, err := NewToolServerRegistry(cfg, "nobody"); err == nil {
+		t.Error("expected an error for an unknown agent")
+	}
+}
@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/tools"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// usageRecorder records the token usage of LLM requests in the workspace's
+// usage log and checks it against the configured budgets.
+type usageRecorder struct {
+	tracker *usage.Tracker
+	prices  usage.PriceTable
+	budgets []config.BudgetConfig
+}
+
+// UsageDir is where usage is logged for cfg.
+func UsageDir(cfg *config.Config) string {
+	return filepath.Join(cfg.WorkspacePath(), "usage")
+}
+
+func newUsageRecorder(cfg *config.Config) *usageRecorder {
+	if cfg.WorkspacePath() == "" {
+		return nil
+	}
+	return &usageRecorder{
+		tracker: usage.NewTracker(UsageDir(cfg)),
+		prices:  usage.NewPriceTable(cfg.ModelList),
+		budgets: cfg.Usage.Budgets,
+	}
+}
+
+// record logs the usage reported in resp for a request of kind to model made
+// by agentID, attributing it to the session and sender of ctx.
+func (u *usageRecorder) record(
+	ctx context.Context,
+	agentID, model, kind string,
+	resp *providers.LLMResponse,
+) {
+	if u == nil || resp == nil || resp.Usage == nil {
+		return
+	}
+	r := usage.Record{
+		Agent:            agentID,
+		Model:            model,
+		Kind:             kind,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+		Cost:             u.prices.Cost(model, resp.Usage.PromptTokens, resp.Usage.CompletionTokens),
+	}
+	if tc := tools.ToolCallContextFrom(ctx); tc != nil {
+		r.Session, r.Channel, r.Sender = tc.SessionKey, tc.Channel, tc.SenderID
+	}
+	if err := u.tracker.Record(r); err != nil {
+		logger.WarnCF("usage", "Failed to record usage", map[string]any{"error": err.Error()})
+	}
+}
+
+// checkBudget returns the used-up budget applying to a request, or nil.
+func (u *usageRecorder) checkBudget(agentID, channel, senderID string) *usage.Exceeded {
+	if u == nil || len(u.budgets) == 0 {
+		return nil
+	}
+	return u.tracker.Check(u.budgets, agentID, channel, senderID)
+}
+
+// applyBudget enforces the usage budgets on a turn. It returns the reply
+// refusing the turn when a blocking budget is used up; a downgrading budget
+// sets the model the turn runs with instead.
+func (al *AgentLoop) applyBudget(agent *AgentInstance, opts *processOptions) (string, bool) {
+	exceeded := al.usage.checkBudget(agent.ID, opts.Channel, opts.SenderID)
+	if exceeded == nil {
+		return "", false
+	}
+	fields := map[string]any{
+		"agent_id": agent.ID,
+		"channel":  opts.Channel,
+		"sender":   opts.SenderID,
+		"budget":   exceeded.String(),
+	}
+	if exceeded.Action() == usage.ActionDowngrade {
+		opts.Model = exceeded.Budget.DowngradeModel
+		fields["model"] = opts.Model
+		logger.InfoCF("usage", "Budget exceeded, downgrading model", fields)
+		return "", false
+	}
+	logger.WarnCF("usage", "Budget exceeded, request blocked", fields)
+	return fmt.Sprintf("Usage limit reached: the %s is used up. Please try again later.", exceeded), true
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+	"github.com/sipeed/picoclaw/pkg/usage"
+)
+
+// usageProvider reports 1000 prompt and 200 completion tokens per request.
+type usageProvider struct {
+	mu     sync.Mutex
+	models []string
+}
+
+func (p *usageProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	opts map[string]any,
+) (*providers.LLMResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.models = append(p.models, model)
+	return &providers.LLMResponse{
+		Content: "ok",
+		Usage:   &providers.UsageInfo{PromptTokens: 1000, CompletionTokens: 200, TotalTokens: 1200},
+	}, nil
+}
+
+func (p *usageProvider) GetDefaultModel() string {
+	return "big-model"
+}
+
+func newUsageTestLoop(t *testing.T, budgets ...config.BudgetConfig) (*AgentLoop, *usageProvider) {
+	t.Helper()
+	cfg := &config.Config{
+		Agents: config.AgentsConfig{
+			Defaults: config.AgentDefaults{
+				Workspace:         t.TempDir(),
+				Model:             "big-model",
+				MaxTokens:         4096,
+				MaxToolIterations: 10,
+			},
+		},
+		ModelList: []config.ModelConfig{
+			{ModelName: "big", Model: "openai/big-model", Price: &config.ModelPrice{Input: 10, Output: 50}},
+			{ModelName: "small", Model: "openai/small-model"},
+		},
+		Usage: config.UsageConfig{Budgets: budgets},
+	}
+	provider := &usageProvider{}
+	return mustNewAgentLoop(t, cfg, bus.NewMessageBus(), provider), provider
+}
+
+func sendAs(t *testing.T, al *AgentLoop, sender, content string) string {
+	t.Helper()
+	reply, err := al.processMessage(context.Background(), bus.InboundMessage{
+		Channel: "telegram", SenderID: sender, ChatID: "chat", Content: content,
+		SessionKey: "agent:main:" + sender,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reply
+}
+
+func TestUsage_RecordsChatTurns(t *testing.T) {
+	al, _ := newUsageTestLoop(t)
+	sendAs(t, al, "alice", "hello")
+
+	records, err := usage.Load(UsageDir(al.cfg), time.Time{}, time.Time{})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("records = %+v, %v", records, err)
+	}
+	r := records[0]
+	if r.Agent != "main" || r.Session != "agent:main:alice" || r.Channel != "telegram" ||
+		r.Sender != "alice" || r.Model != "big-model" || r.Kind != usage.KindChat {
+		t.Errorf("record = %+v", r)
+	}
+	// 1000 prompt tokens at $10/M and 200 completion tokens at $50/M.
+	if r.TotalTokens != 1200 || r.Cost != 0.02 {
+		t.Errorf("tokens = %d, cost = %v", r.TotalTokens, r.Cost)
+	}
+}
+
+func TestUsage_BudgetBlocks(t *testing.T) {
+	al, provider := newUsageTestLoop(t, config.BudgetConfig{Sender: "*", DailyCost: 0.03})
+	agent := al.registry.GetDefaultAgent()
+
+	sendAs(t, al, "alice", "one")
+	sendAs(t, al, "alice", "two")
+	reply := sendAs(t, al, "alice", "three")
+	if !strings.HasPrefix(reply, "Usage limit reached") {
+		t.Errorf("reply over budget = %q", reply)
+	}
+	if len(provider.models) != 2 {
+		t.Errorf("provider called %d times, want 2", len(provider.models))
+	}
+	if n := len(agent.Sessions.GetHistory("agent:main:alice")); n != 4 {
+		t.Errorf("blocked turn changed the history to %d messages", n)
+	}
+
+	// Other senders have budgets of their own.
+	if reply := sendAs(t, al, "bob", "hi"); reply != "ok" {
+		t.Errorf("bob's reply = %q", reply)
+	}
+}
+
+func TestUsage_BudgetDowngrades(t *testing.T) {
+	al, provider := newUsageTestLoop(t, config.BudgetConfig{
+		Agent: "main", DailyTokens: 1000, Action: "downgrade", DowngradeModel: "small",
+	})
+
+	sendAs(t, al, "alice", "one")
+	sendAs(t, al, "bob", "two")
+	if got := strings.Join(provider.models, ","); got != "big-model,small-model" {
+		t.Errorf("models used = %s", got)
+	}
+}
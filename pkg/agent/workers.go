@@ -0,0 +1,96 @@
+package agent
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+// defaultMaxConcurrency bounds how many sessions are processed at once when
+// agents.defaults.max_concurrency is unset.
+const defaultMaxConcurrency = 4
+
+// sessionWorkers processes work with one logical worker per session key:
+// jobs of the same session run in arrival order, different sessions run in
+// parallel, up to a global concurrency limit. Inbound channel messages and
+// API turns share the workers so they never race on a session's history.
+type sessionWorkers struct {
+	slots chan struct{}
+
+	mu     sync.Mutex
+	queues map[string][]sessionJob // pending jobs of active sessions
+	wg     sync.WaitGroup
+}
+
+// sessionJob is one queued unit of work. It runs with its own context; a
+// job whose context is done before it gets a slot is dropped.
+type sessionJob struct {
+	ctx context.Context
+	run func(context.Context)
+}
+
+func newSessionWorkers(maxConcurrency int) *sessionWorkers {
+	if maxConcurrency <= 0 {
+		maxConcurrency = defaultMaxConcurrency
+	}
+	return &sessionWorkers{
+		slots:  make(chan struct{}, maxConcurrency),
+		queues: make(map[string][]sessionJob),
+	}
+}
+
+// submit queues msg behind any in-flight work of the same session and
+// starts a worker for the session if none is running.
+func (w *sessionWorkers) submit(
+	ctx context.Context,
+	sessionKey string,
+	msg bus.InboundMessage,
+	handle func(context.Context, bus.InboundMessage),
+) {
+	w.enqueue(sessionKey, sessionJob{ctx: ctx, run: func(ctx context.Context) { handle(ctx, msg) }})
+}
+
+// enqueue queues job on the session's worker, starting one if needed.
+func (w *sessionWorkers) enqueue(sessionKey string, job sessionJob) {
+	w.mu.Lock()
+	if pending, active := w.queues[sessionKey]; active {
+		w.queues[sessionKey] = append(pending, job)
+		w.mu.Unlock()
+		return
+	}
+	w.queues[sessionKey] = nil
+	w.mu.Unlock()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		next := job
+		for {
+			select {
+			case w.slots <- struct{}{}:
+				if next.ctx.Err() == nil {
+					next.run(next.ctx)
+				}
+				<-w.slots
+			case <-next.ctx.Done():
+			}
+
+			w.mu.Lock()
+			pending := w.queues[sessionKey]
+			if len(pending) == 0 {
+				delete(w.queues, sessionKey)
+				w.mu.Unlock()
+				return
+			}
+			next = pending[0]
+			w.queues[sessionKey] = pending[1:]
+			w.mu.Unlock()
+		}
+	}()
+}
+
+// wait blocks until every running worker has finished.
+func (w *sessionWorkers) wait() {
+	w.wg.Wait()
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/bus"
+)
+
+func TestSessionWorkers_OrdersMessagesWithinSession(t *testing.T) {
+	w := newSessionWorkers(4)
+
+	var mu sync.Mutex
+	var got []string
+	handle := func(ctx context.Context, msg bus.InboundMessage) {
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		got = append(got, msg.Content)
+		mu.Unlock()
+	}
+
+	for _, c := range []string{"1", "2", "3", "4", "5"} {
+		w.submit(context.Background(), "s1", bus.InboundMessage{Content: c}, handle)
+	}
+	w.wait()
+
+	if len(got) != 5 {
+		t.Fatalf("handled %d messages, want 5", len(got))
+	}
+	for i, c := range []string{"1", "2", "3", "4", "5"} {
+		if got[i] != c {
+			t.Fatalf("order = %v, want 1..5", got)
+		}
+	}
+}
+
+func TestSessionWorkers_RunsSessionsInParallel(t *testing.T) {
+	w := newSessionWorkers(2)
+
+	release := make(chan struct{})
+	var running, peak atomic.Int32
+	handle := func(ctx context.Context, msg bus.InboundMessage) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		<-release
+		running.Add(-1)
+	}
+
+	for _, key := range []string{"a", "b", "c"} {
+		w.submit(context.Background(), key, bus.InboundMessage{}, handle)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for running.Load() < 2 {
+		select {
+		case <-deadline:
+			t.Fatal("sessions did not run in parallel")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	close(release)
+	w.wait()
+
+	if peak.Load() != 2 {
+		t.Fatalf("peak concurrency = %d, want 2 (the configured limit)", peak.Load())
+	}
+}
+
+func TestSessionWorkers_StopsOnCancel(t *testing.T) {
+	w := newSessionWorkers(1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	block := make(chan struct{})
+	var handled atomic.Int32
+	handle := func(ctx context.Context, msg bus.InboundMessage) {
+		handled.Add(1)
+		<-block
+	}
+
+	w.submit(ctx, "a", bus.InboundMessage{}, handle)
+	w.submit(ctx, "b", bus.InboundMessage{}, handle)
+	for handled.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	close(block)
+	w.wait()
+
+	if handled.Load() != 1 {
+		t.Fatalf("handled = %d, want 1 (queued session dropped on cancel)", handled.Load())
+	}
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/routing"
+)
+
+// SessionHeader selects the server-side session a request continues. The
+// request's user field is used when the header is absent.
+const SessionHeader = "X-Picoclaw-Session"
+
+// apiChannel is the channel name API sessions are recorded under.
+const apiChannel = "api"
+
+// defaultModel is accepted as an alias for the default agent.
+const defaultModel = "picoclaw"
+
+// statelessSession is the session key of requests that name no session.
+// They run ephemerally: nothing is loaded from or recorded in the session,
+// so the request's own messages are the whole conversation.
+const statelessSession = "stateless"
+
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Stream   bool          `json:"stream"`
+	User     string        `json:"user"`
+}
+
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// text returns the message content, which may be a string or an array of
+// content parts. Non-text parts are ignored.
+func (m chatMessage) text() string {
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(m.Content, &parts); err != nil {
+		return ""
+	}
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+type responseMessage struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type choice struct {
+	Index        int              `json:"index"`
+	Message      *responseMessage `json:"message,omitempty"`
+	Delta        *responseMessage `json:"delta,omitempty"`
+	FinishReason *string          `json:"finish_reason"`
+}
+
+type completion struct {
+	ID      string   `json:"id"`
+	Object  string   `json:"object"`
+	Created int64    `json:"created"`
+	Model   string   `json:"model"`
+	Choices []choice `json:"choices"`
+}
+
+func (h *Handler) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	var req chatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_json", "Invalid request body: "+err.Error())
+		return
+	}
+
+	agentID, ok := h.resolveAgent(req.Model)
+	if !ok {
+		writeError(w, http.StatusNotFound, "model_not_found", fmt.Sprintf("Model %q does not exist", req.Model))
+		return
+	}
+
+	session := r.Header.Get(SessionHeader)
+	if session == "" {
+		session = req.User
+	}
+	stateless := session == ""
+	content := buildPrompt(req.Messages, stateless)
+	if content == "" {
+		writeError(w, http.StatusBadRequest, "invalid_messages", "messages must end with a user message")
+		return
+	}
+	if stateless {
+		session = statelessSession
+	}
+	agentReq := agent.AgentRequest{
+		AgentID: agentID,
+		SessionKey: routing.BuildAgentPeerSessionKey(routing.SessionKeyParams{
+			AgentID: agentID,
+			Channel: apiChannel,
+			Peer:    &routing.RoutePeer{Kind: "direct", ID: session},
+			DMScope: routing.DMScopePerChannelPeer,
+		}),
+		Channel:   apiChannel,
+		ChatID:    session,
+		Content:   content,
+		Ephemeral: stateless,
+	}
+
+	// Agent turns can run far longer than the gateway's write timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	resp := completion{
+		ID:      "chatcmpl-" + uuid.NewString(),
+		Created: h.now().Unix(),
+		Model:   req.Model,
+	}
+	if resp.Model == "" {
+		resp.Model = agentID
+	}
+
+	if req.Stream {
+		h.streamCompletion(w, r, resp, agentReq)
+		return
+	}
+
+	reply, err := h.backend.ProcessAgentMessage(r.Context(), agentReq)
+	if err != nil {
+		logger.ErrorCF("api", "Chat completion failed", map[string]any{"agent_id": agentID, "error": err.Error()})
+		writeError(w, http.StatusInternalServerError, "agent_error", err.Error())
+		return
+	}
+
+	stop := "stop"
+	resp.Object = "chat.completion"
+	resp.Choices = []choice{{
+		Message:      &responseMessage{Role: "assistant", Content: reply},
+		FinishReason: &stop,
+	}}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// streamCompletion answers with server-sent chat.completion.chunk events as
+// the agent's reply is generated. Providers that cannot stream produce a
+// single chunk with the whole reply.
+func (h *Handler) streamCompletion(
+	w http.ResponseWriter,
+	r *http.Request,
+	resp completion,
+	agentReq agent.AgentRequest,
+) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+
+	var mu sync.Mutex
+	var streamed atomic.Bool
+	send := func(delta *responseMessage, finish *string) {
+		mu.Lock()
+		defer mu.Unlock()
+		chunk := resp
+		chunk.Object = "chat.completion.chunk"
+		chunk.Choices = []choice{{Delta: delta, FinishReason: finish}}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		rc.Flush()
+	}
+
+	send(&responseMessage{Role: "assistant"}, nil)
+	agentReq.OnDelta = func(delta string) {
+		if delta == "" {
+			return
+		}
+		streamed.Store(true)
+		send(&responseMessage{Content: delta}, nil)
+	}
+	reply, err := h.backend.ProcessAgentMessage(r.Context(), agentReq)
+	if err != nil {
+		logger.ErrorCF("api", "Chat completion failed", map[string]any{"agent_id": agentReq.AgentID, "error": err.Error()})
+		data, _ := json.Marshal(map[string]any{
+			"error": errorBody{Message: err.Error(), Type: "server_error", Code: "agent_error"},
+		})
+		fmt.Fprintf(w, "data: %s\n\n", data)
+	} else {
+		if !streamed.Load() {
+			send(&responseMessage{Content: reply}, nil)
+		}
+		stop := "stop"
+		send(&responseMessage{}, &stop)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	rc.Flush()
+}
+
+// resolveAgent maps the requested model to an agent ID. An empty model or
+// "picoclaw" selects the default agent.
+func (h *Handler) resolveAgent(model string) (string, bool) {
+	if model == "" || model == defaultModel {
+		return h.backend.DefaultAgentID(), true
+	}
+	id := routing.NormalizeAgentID(model)
+	return id, slices.Contains(h.backend.ListAgentIDs(), id)
+}
+
+// buildPrompt returns the text to send to the agent: the last user message.
+// Stateless requests have no server-side history, so earlier turns of the
+// request are prepended as a transcript.
+func buildPrompt(messages []chatMessage, stateless bool) string {
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return ""
+	}
+	last := messages[len(messages)-1].text()
+	if !stateless || len(messages) == 1 {
+		return last
+	}
+
+	var b strings.Builder
+	b.WriteString("Conversation so far:\n")
+	for _, m := range messages[:len(messages)-1] {
+		fmt.Fprintf(&b, "%s: %s\n", m.Role, m.text())
+	}
+	b.WriteString("\n")
+	b.WriteString(last)
+	return b.String()
+}
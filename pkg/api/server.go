@@ -0,0 +1,108 @@
+// Package api serves an OpenAI-compatible chat completions API backed by
+// picoclaw agents, so any OpenAI SDK can talk to them.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Backend runs messages through agents. *agent.AgentLoop implements it.
+type Backend interface {
+	ListAgentIDs() []string
+	DefaultAgentID() string
+	ProcessAgentMessage(ctx context.Context, req agent.AgentRequest) (string, error)
+}
+
+// Handler serves /v1/models and /v1/chat/completions. Every request must
+// carry one of the configured bearer tokens.
+type Handler struct {
+	backend Backend
+	tokens  []string
+	mux     *http.ServeMux
+	now     func() time.Time
+}
+
+// NewHandler returns a handler for backend accepting the given tokens. With no
+// tokens every request is rejected.
+func NewHandler(backend Backend, tokens []string) *Handler {
+	h := &Handler{
+		backend: backend,
+		tokens:  tokens,
+		mux:     http.NewServeMux(),
+		now:     time.Now,
+	}
+	h.mux.HandleFunc("GET /v1/models", h.handleModels)
+	h.mux.HandleFunc("POST /v1/chat/completions", h.handleChatCompletions)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "invalid_api_key", "Invalid or missing bearer token")
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return false
+	}
+	for _, want := range h.tokens {
+		if want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+type model struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// handleModels lists each agent as a model; requests pick an agent by
+// passing its ID as the model.
+func (h *Handler) handleModels(w http.ResponseWriter, r *http.Request) {
+	ids := h.backend.ListAgentIDs()
+	data := make([]model, 0, len(ids))
+	for _, id := range ids {
+		data = append(data, model{ID: id, Object: "model", OwnedBy: "picoclaw"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"object": "list", "data": data})
+}
+
+type errorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"type"`
+	Code    string `json:"code,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	errType := "invalid_request_error"
+	if status >= http.StatusInternalServerError {
+		errType = "server_error"
+	}
+	writeJSON(w, status, map[string]any{
+		"error": errorBody{Message: message, Type: errType, Code: code},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.WarnCF("api", "Failed to write response", map[string]any{"error": err.Error()})
+	}
+}
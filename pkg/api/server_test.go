@@ -0,0 +1,186 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/agent"
+)
+
+type fakeBackend struct {
+	deltas []string
+	last   agent.AgentRequest
+}
+
+func (b *fakeBackend) ListAgentIDs() []string { return []string{"main", "coder"} }
+func (b *fakeBackend) DefaultAgentID() string { return "main" }
+
+func (b *fakeBackend) ProcessAgentMessage(ctx context.Context, req agent.AgentRequest) (string, error) {
+	b.last = req
+	if req.OnDelta != nil {
+		for _, d := range b.deltas {
+			req.OnDelta(d)
+		}
+	}
+	return "reply to " + req.Content, nil
+}
+
+func newTestServer(t *testing.T, backend *fakeBackend) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(NewHandler(backend, []string{"secret"}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path, token, body string, header map[string]string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestHandler_RequiresBearerToken(t *testing.T) {
+	srv := newTestServer(t, &fakeBackend{})
+
+	for _, token := range []string{"", "wrong"} {
+		resp := do(t, srv, http.MethodGet, "/v1/models", token, "", nil)
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want 401", token, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandler_ListsAgentsAsModels(t *testing.T) {
+	srv := newTestServer(t, &fakeBackend{})
+
+	resp := do(t, srv, http.MethodGet, "/v1/models", "secret", "", nil)
+	var body struct {
+		Data []model `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Data) != 2 || body.Data[0].ID != "main" || body.Data[1].ID != "coder" {
+		t.Errorf("models = %+v, want main and coder", body.Data)
+	}
+}
+
+func TestHandler_ChatCompletion(t *testing.T) {
+	backend := &fakeBackend{}
+	srv := newTestServer(t, backend)
+
+	resp := do(t, srv, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"model":"coder","user":"alice","messages":[{"role":"user","content":"hi"}]}`, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	var body completion
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Choices) != 1 || body.Choices[0].Message.Content != "reply to hi" {
+		t.Errorf("choices = %+v", body.Choices)
+	}
+	if backend.last.AgentID != "coder" || backend.last.SessionKey != "agent:coder:api:direct:alice" {
+		t.Errorf("routed to %s / %s", backend.last.AgentID, backend.last.SessionKey)
+	}
+	if backend.last.Ephemeral {
+		t.Error("a request naming a session should keep history")
+	}
+}
+
+func TestHandler_SessionHeaderOverridesUser(t *testing.T) {
+	backend := &fakeBackend{}
+	srv := newTestServer(t, backend)
+
+	do(t, srv, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"user":"alice","messages":[{"role":"user","content":"hi"}]}`,
+		map[string]string{SessionHeader: "Project-X"})
+	if backend.last.SessionKey != "agent:main:api:direct:project-x" {
+		t.Errorf("session key = %s", backend.last.SessionKey)
+	}
+}
+
+func TestHandler_StatelessRequestCarriesTranscript(t *testing.T) {
+	backend := &fakeBackend{}
+	srv := newTestServer(t, backend)
+
+	do(t, srv, http.MethodPost, "/v1/chat/completions", "secret", `{"messages":[
+		{"role":"user","content":"my name is Bo"},
+		{"role":"assistant","content":"hello Bo"},
+		{"role":"user","content":[{"type":"text","text":"what is my name?"}]}
+	]}`, nil)
+	if !backend.last.Ephemeral {
+		t.Error("a request without a session should not load history")
+	}
+	for _, want := range []string{"user: my name is Bo", "assistant: hello Bo", "what is my name?"} {
+		if !strings.Contains(backend.last.Content, want) {
+			t.Errorf("prompt missing %q:\n%s", want, backend.last.Content)
+		}
+	}
+}
+
+func TestHandler_UnknownModel(t *testing.T) {
+	srv := newTestServer(t, &fakeBackend{})
+
+	resp := do(t, srv, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestHandler_StreamingChatCompletion(t *testing.T) {
+	srv := newTestServer(t, &fakeBackend{deltas: []string{"Hel", "lo"}})
+
+	resp := do(t, srv, http.MethodPost, "/v1/chat/completions", "secret",
+		`{"stream":true,"user":"alice","messages":[{"role":"user","content":"hi"}]}`, nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	data, _ := io.ReadAll(resp.Body)
+
+	var content strings.Builder
+	var finish string
+	for _, line := range strings.Split(string(data), "\n") {
+		payload, ok := strings.CutPrefix(line, "data: ")
+		if !ok || payload == "[DONE]" {
+			continue
+		}
+		var chunk completion
+		if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+			t.Fatalf("bad chunk %q: %v", payload, err)
+		}
+		if chunk.Object != "chat.completion.chunk" {
+			t.Errorf("object = %q", chunk.Object)
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		if fr := chunk.Choices[0].FinishReason; fr != nil {
+			finish = *fr
+		}
+	}
+	if content.String() != "Hello" || finish != "stop" {
+		t.Errorf("streamed %q (finish %q), want %q (stop)", content.String(), finish, "Hello")
+	}
+	if !strings.HasSuffix(string(data), "data: [DONE]\n\n") {
+		t.Error("stream should end with [DONE]")
+	}
+}
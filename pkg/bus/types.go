@@ -14,6 +14,15 @@ type OutboundMessage struct {
 	Channel string `json:"channel"`
 	ChatID  string `json:"chat_id"`
 	Content string `json:"content"`
+	// Partial marks an in-progress streaming update carrying the full text
+	// generated so far. The final message follows with Partial unset.
+	Partial bool `json:"partial,omitempty"`
+	// StreamID ties the partial updates of one turn to its final message. A
+	// final message with a StreamID and no Content only ends the stream.
+	StreamID string `json:"stream_id,omitempty"`
+	// Reasoning is the model's thinking behind Content, shown collapsed by
+	// channels configured with show_thinking.
+	Reasoning string `json:"reasoning,omitempty"`
 }
 
 type MessageHandler func(InboundMessage) error
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/sipeed/picoclaw/pkg/bus"
+	"github.com/sipeed/picoclaw/pkg/utils"
 )
 
 type Channel interface {
@@ -16,6 +17,14 @@ type Channel interface {
 	IsAllowed(senderID string) bool
 }
 
+// StreamingChannel is implemented by channels that can progressively edit a
+// placeholder message while a response is still being generated. Each partial
+// message carries the full text so far; the final Send replaces it.
+type StreamingChannel interface {
+	Channel
+	SendPartial(ctx context.Context, msg bus.OutboundMessage) error
+}
+
 type BaseChannel struct {
 	config    any
 	bus       *bus.MessageBus
@@ -86,12 +95,22 @@ func (c *BaseChannel) HandleMessage(senderID, chatID, content string, media []st
 		return
 	}
 
+	// Channels clean up their downloads when the handler returns, but the
+	// agent loop reads media later, so hand it files that outlive that cleanup.
+	var retained []string
+	if len(media) > 0 {
+		retained = make([]string, 0, len(media))
+		for _, path := range media {
+			retained = append(retained, utils.RetainMediaFile(path))
+		}
+	}
+
 	msg := bus.InboundMessage{
 		Channel:  c.name,
 		SenderID: senderID,
 		ChatID:   chatID,
 		Content:  content,
-		Media:    media,
+		Media:    retained,
 		Metadata: metadata,
 	}
 
@@ -0,0 +1,20 @@
+package channels
+
+// ConversationCommand is a slash command the agent handles in any chat.
+type ConversationCommand struct {
+	Name        string
+	Description string
+}
+
+// ConversationCommands are the slash commands acting on the current
+// conversation, for channels to advertise in their command menus.
+var ConversationCommands = []ConversationCommand{
+	{Name: "new", Description: "Start a new conversation"},
+	{Name: "reset", Description: "Clear this conversation and its settings"},
+	{Name: "undo", Description: "Remove the last exchange"},
+	{Name: "retry", Description: "Regenerate the last answer"},
+	{Name: "model", Description: "Show or set the model for this conversation"},
+	{Name: "think", Description: "Show or set how much the model thinks"},
+	{Name: "summary", Description: "Show the summary, or /summary now to refresh it"},
+	{Name: "pin", Description: "Keep your last message when history is compacted"},
+}
@@ -22,6 +22,10 @@ const (
 	sendTimeout          = 10 * time.Second
 )
 
+// discordThinkingLength caps the reasoning shown before a reply, in
+// characters.
+const discordThinkingLength = 1500
+
 type DiscordChannel struct {
 	*BaseChannel
 	session     *discordgo.Session
@@ -31,6 +35,7 @@ type DiscordChannel struct {
 	typingMu    sync.Mutex
 	typingStop  map[string]chan struct{} // chatID → stop signal
 	botUserID   string                   // stored for mention checking
+	streams     sync.Map                 // stream ID → message ID being streamed into
 }
 
 func NewDiscordChannel(cfg config.DiscordConfig, bus *bus.MessageBus) (*DiscordChannel, error) {
@@ -121,12 +126,28 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 		return fmt.Errorf("channel ID is empty")
 	}
 
+	streamed, isStream := c.endStream(msg.StreamID)
 	runes := []rune(msg.Content)
 	if len(runes) == 0 {
 		return nil
 	}
 
 	chunks := utils.SplitMessage(msg.Content, 2000) // Split messages into chunks, Discord length limit: 2000 chars
+	if c.config.ShowThinking && strings.TrimSpace(msg.Reasoning) != "" {
+		thinking := thinkingSpoiler(msg.Reasoning)
+		if len(thinking)+len(chunks[0]) <= 2000 {
+			chunks[0] = thinking + chunks[0]
+		} else {
+			chunks = append([]string{thinking}, chunks...)
+		}
+	}
+
+	// Replace the streamed message with the first chunk, then send the rest.
+	if isStream {
+		if _, err := c.session.ChannelMessageEdit(channelID, streamed, chunks[0]); err == nil {
+			chunks = chunks[1:]
+		}
+	}
 
 	for _, chunk := range chunks {
 		if err := c.sendChunk(ctx, channelID, chunk); err != nil {
@@ -137,6 +158,53 @@ func (c *DiscordChannel) Send(ctx context.Context, msg bus.OutboundMessage) erro
 	return nil
 }
 
+// thinkingSpoiler renders reasoning as a spoiler to put before a reply.
+func thinkingSpoiler(reasoning string) string {
+	reasoning = strings.ReplaceAll(strings.TrimSpace(reasoning), "||", "|")
+	return "💭 **Thinking**\n||" + utils.Truncate(reasoning, discordThinkingLength) + "||\n\n"
+}
+
+// SendPartial posts the text streamed so far as a message on the first update
+// and edits that message on subsequent ones.
+func (c *DiscordChannel) SendPartial(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("discord bot not running")
+	}
+
+	content := msg.Content
+	if runes := []rune(content); len(runes) > 2000 {
+		content = string(runes[:1999]) + "…"
+	}
+	if strings.TrimSpace(content) == "" {
+		return nil
+	}
+
+	if messageID, ok := c.streams.Load(msg.StreamID); ok {
+		_, err := c.session.ChannelMessageEdit(msg.ChatID, messageID.(string), content)
+		return err
+	}
+
+	sent, err := c.session.ChannelMessageSend(msg.ChatID, content)
+	if err != nil {
+		return fmt.Errorf("failed to send discord message: %w", err)
+	}
+	c.streams.Store(msg.StreamID, sent.ID)
+	return nil
+}
+
+// endStream forgets streamID and returns the message it was streamed into,
+// if any.
+func (c *DiscordChannel) endStream(streamID string) (string, bool) {
+	if streamID == "" {
+		return "", false
+	}
+	messageID, ok := c.streams.LoadAndDelete(streamID)
+	if !ok {
+		return "", false
+	}
+	return messageID.(string), true
+}
+
 func (c *DiscordChannel) sendChunk(ctx context.Context, channelID, content string) error {
 	// Use the passed ctx for timeout control
 	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
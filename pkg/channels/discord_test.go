@@ -0,0 +1,15 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThinkingSpoiler(t *testing.T) {
+	if got, want := thinkingSpoiler(" a || b \n"), "💭 **Thinking**\n||a | b||\n\n"; got != want {
+		t.Errorf("thinkingSpoiler() = %q, want %q", got, want)
+	}
+	if got := thinkingSpoiler(strings.Repeat("x", 5000)); len([]rune(got)) > discordThinkingLength+30 {
+		t.Errorf("reasoning not shortened: %d characters", len([]rune(got)))
+	}
+}
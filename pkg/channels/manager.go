@@ -298,6 +298,20 @@ func (m *Manager) dispatchOutbound(ctx context.Context) {
 				continue
 			}
 
+			if msg.Partial {
+				// Streaming updates are best effort and only reach channels
+				// that can edit messages in place.
+				if sc, ok := channel.(StreamingChannel); ok {
+					if err := sc.SendPartial(ctx, msg); err != nil {
+						logger.DebugCF("channels", "Error sending partial message", map[string]any{
+							"channel": msg.Channel,
+							"error":   err.Error(),
+						})
+					}
+				}
+				continue
+			}
+
 			if err := channel.Send(ctx, msg); err != nil {
 				logger.ErrorCF("channels", "Error sending message to channel", map[string]any{
 					"channel": msg.Channel,
@@ -315,6 +329,19 @@ func (m *Manager) GetChannel(name string) (Channel, bool) {
 	return channel, ok
 }
 
+// SupportsStreaming reports whether the named channel can render partial
+// (streaming) updates.
+func (m *Manager) SupportsStreaming(channelName string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	channel, ok := m.channels[channelName]
+	if !ok {
+		return false
+	}
+	_, ok = channel.(StreamingChannel)
+	return ok
+}
+
 func (m *Manager) GetStatus() map[string]any {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
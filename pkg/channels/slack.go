@@ -30,6 +30,7 @@ type SlackChannel struct {
 	ctx          context.Context
 	cancel       context.CancelFunc
 	pendingAcks  sync.Map
+	streams      sync.Map // stream ID → timestamp of the message being streamed into
 }
 
 type slackMessageRef struct {
@@ -119,6 +120,11 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		return fmt.Errorf("invalid slack chat ID: %s", msg.ChatID)
 	}
 
+	streamed, isStream := c.endStream(msg.StreamID)
+	if msg.Content == "" {
+		return nil
+	}
+
 	opts := []slack.MsgOption{
 		slack.MsgOptionText(msg.Content, false),
 	}
@@ -127,8 +133,12 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 		opts = append(opts, slack.MsgOptionTS(threadTS))
 	}
 
-	_, _, err := c.api.PostMessageContext(ctx, channelID, opts...)
-	if err != nil {
+	if isStream {
+		_, _, _, err := c.api.UpdateMessageContext(ctx, channelID, streamed, opts...)
+		if err != nil {
+			return fmt.Errorf("failed to update slack message: %w", err)
+		}
+	} else if _, _, err := c.api.PostMessageContext(ctx, channelID, opts...); err != nil {
 		return fmt.Errorf("failed to send slack message: %w", err)
 	}
 
@@ -148,6 +158,51 @@ func (c *SlackChannel) Send(ctx context.Context, msg bus.OutboundMessage) error
 	return nil
 }
 
+// SendPartial posts the text streamed so far on the first update and edits
+// that message on subsequent ones.
+func (c *SlackChannel) SendPartial(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("slack channel not running")
+	}
+
+	channelID, threadTS := parseSlackChatID(msg.ChatID)
+	if channelID == "" {
+		return fmt.Errorf("invalid slack chat ID: %s", msg.ChatID)
+	}
+	if strings.TrimSpace(msg.Content) == "" {
+		return nil
+	}
+
+	if ts, ok := c.streams.Load(msg.StreamID); ok {
+		_, _, _, err := c.api.UpdateMessageContext(ctx, channelID, ts.(string), slack.MsgOptionText(msg.Content, false))
+		return err
+	}
+
+	opts := []slack.MsgOption{slack.MsgOptionText(msg.Content, false)}
+	if threadTS != "" {
+		opts = append(opts, slack.MsgOptionTS(threadTS))
+	}
+	_, ts, err := c.api.PostMessageContext(ctx, channelID, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to send slack message: %w", err)
+	}
+	c.streams.Store(msg.StreamID, ts)
+	return nil
+}
+
+// endStream forgets streamID and returns the timestamp of the message it was
+// streamed into, if any.
+func (c *SlackChannel) endStream(streamID string) (string, bool) {
+	if streamID == "" {
+		return "", false
+	}
+	ts, ok := c.streams.LoadAndDelete(streamID)
+	if !ok {
+		return "", false
+	}
+	return ts.(string), true
+}
+
 func (c *SlackChannel) eventLoop() {
 	for {
 		select {
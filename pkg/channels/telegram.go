@@ -36,6 +36,13 @@ var (
 	reInlineCode = regexp.MustCompile("`([^`]+)`")
 )
 
+// telegramMaxMessageLength is the Bot API limit for message text.
+const telegramMaxMessageLength = 4096
+
+// telegramMinThinkingLength is the least room for reasoning worth showing it
+// before a reply.
+const telegramMinThinkingLength = 200
+
 type TelegramChannel struct {
 	*BaseChannel
 	bot          *telego.Bot
@@ -118,6 +125,8 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 		return fmt.Errorf("failed to create bot handler: %w", err)
 	}
 
+	c.setCommandMenu(ctx)
+
 	bh.HandleMessage(func(ctx *th.Context, message telego.Message) error {
 		c.commands.Help(ctx, message)
 		return nil
@@ -153,6 +162,25 @@ func (c *TelegramChannel) Start(ctx context.Context) error {
 	return nil
 }
 
+// setCommandMenu publishes the bot's commands, including the conversation
+// commands handled by the agent, as the chat's command menu.
+func (c *TelegramChannel) setCommandMenu(ctx context.Context) {
+	commands := []telego.BotCommand{
+		{Command: "start", Description: "Start the bot"},
+		{Command: "help", Description: "Show available commands"},
+		{Command: "show", Description: "Show current configuration"},
+		{Command: "list", Description: "List available options"},
+	}
+	for _, cc := range ConversationCommands {
+		commands = append(commands, telego.BotCommand{Command: cc.Name, Description: cc.Description})
+	}
+	if err := c.bot.SetMyCommands(ctx, &telego.SetMyCommandsParams{Commands: commands}); err != nil {
+		logger.WarnCF("telegram", "Failed to set command menu", map[string]any{
+			"error": err.Error(),
+		})
+	}
+}
+
 func (c *TelegramChannel) Stop(ctx context.Context) error {
 	logger.InfoC("telegram", "Stopping Telegram bot...")
 	c.setRunning(false)
@@ -176,8 +204,15 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 		}
 		c.stopThinking.Delete(msg.ChatID)
 	}
+	// A message without content only ends the turn's stream.
+	if msg.Content == "" {
+		return nil
+	}
 
 	htmlContent := markdownToTelegramHTML(msg.Content)
+	if c.config.Channels.Telegram.ShowThinking && strings.TrimSpace(msg.Reasoning) != "" {
+		htmlContent = thinkingHTML(msg.Reasoning, len([]rune(msg.Content))) + htmlContent
+	}
 
 	// Try to edit placeholder
 	if pID, ok := c.placeholders.Load(msg.ChatID); ok {
@@ -206,6 +241,46 @@ func (c *TelegramChannel) Send(ctx context.Context, msg bus.OutboundMessage) err
 	return nil
 }
 
+// thinkingHTML renders reasoning as an expandable quote to put before a
+// reply of replyLen characters, shortened to stay within the message limit.
+// It is empty when the reply leaves too little room.
+func thinkingHTML(reasoning string, replyLen int) string {
+	const title = "Thinking"
+	room := telegramMaxMessageLength - replyLen - len(title) - 2
+	if room < telegramMinThinkingLength {
+		return ""
+	}
+	reasoning = utils.Truncate(strings.TrimSpace(reasoning), room)
+	return "<blockquote expandable><b>" + title + "</b>\n" + escapeHTML(reasoning) + "</blockquote>\n"
+}
+
+// SendPartial edits the "Thinking..." placeholder with the text streamed so
+// far. Partial text is sent without HTML formatting since incomplete markdown
+// may not convert cleanly; the final Send applies formatting.
+func (c *TelegramChannel) SendPartial(ctx context.Context, msg bus.OutboundMessage) error {
+	if !c.IsRunning() {
+		return fmt.Errorf("telegram bot not running")
+	}
+
+	chatID, err := parseChatID(msg.ChatID)
+	if err != nil {
+		return fmt.Errorf("invalid chat ID: %w", err)
+	}
+
+	pID, ok := c.placeholders.Load(msg.ChatID)
+	if !ok {
+		return nil
+	}
+
+	content := msg.Content
+	if runes := []rune(content); len(runes) > telegramMaxMessageLength {
+		content = string(runes[:telegramMaxMessageLength-1]) + "…"
+	}
+
+	_, err = c.bot.EditMessageText(ctx, tu.EditMessageText(tu.ID(chatID), pID.(int), content))
+	return err
+}
+
 func (c *TelegramChannel) handleMessage(ctx context.Context, message *telego.Message) error {
 	if message == nil {
 		return fmt.Errorf("message is nil")
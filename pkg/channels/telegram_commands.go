@@ -38,11 +38,16 @@ func commandArgs(text string) string {
 }
 
 func (c *cmd) Help(ctx context.Context, message telego.Message) error {
-	msg := `/start - Start the bot
+	var sb strings.Builder
+	sb.WriteString(`/start - Start the bot
 /help - Show this help message
 /show [model|channel] - Show current configuration
 /list [models|channels] - List available options
-	`
+`)
+	for _, cc := range ConversationCommands {
+		fmt.Fprintf(&sb, "/%s - %s\n", cc.Name, cc.Description)
+	}
+	msg := sb.String()
 	_, err := c.bot.SendMessage(ctx, &telego.SendMessageParams{
 		ChatID: telego.ChatID{ID: message.Chat.ID},
 		Text:   msg,
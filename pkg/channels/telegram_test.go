@@ -0,0 +1,22 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestThinkingHTML(t *testing.T) {
+	got := thinkingHTML("  a < b  ", 10)
+	want := "<blockquote expandable><b>Thinking</b>\na &lt; b</blockquote>\n"
+	if got != want {
+		t.Errorf("thinkingHTML() = %q, want %q", got, want)
+	}
+
+	long := strings.Repeat("x", telegramMaxMessageLength)
+	if got := thinkingHTML(long, 3000); len([]rune(got)) > telegramMaxMessageLength-3000+60 {
+		t.Errorf("reasoning not shortened to the room left: %d characters", len([]rune(got)))
+	}
+	if got := thinkingHTML("why", telegramMaxMessageLength-100); got != "" {
+		t.Errorf("thinkingHTML() = %q for a reply leaving no room", got)
+	}
+}
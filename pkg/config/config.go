@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
+	"strings"
 	"sync/atomic"
 
 	"github.com/caarlos0/env/v11"
 
 	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
 
 // rrCounter is a global counter for round-robin load balancing across models.
@@ -58,6 +61,7 @@ type Config struct {
 	Tools     ToolsConfig     `json:"tools"`
 	Heartbeat HeartbeatConfig `json:"heartbeat"`
 	Devices   DevicesConfig   `json:"devices"`
+	Usage     UsageConfig     `json:"usage,omitzero"`
 }
 
 // MarshalJSON implements custom JSON marshaling for Config
@@ -78,7 +82,8 @@ func (c Config) MarshalJSON() ([]byte, error) {
 	}
 
 	// Only include session if not empty
-	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 {
+	if c.Session.DMScope != "" || len(c.Session.IdentityLinks) > 0 ||
+		c.Session.Store != "" || c.Session.MaxCached != 0 {
 		aux.Session = &c.Session
 	}
 
@@ -137,6 +142,10 @@ type AgentConfig struct {
 	Model     *AgentModelConfig `json:"model,omitempty"`
 	Skills    []string          `json:"skills,omitempty"`
 	Subagents *SubagentsConfig  `json:"subagents,omitempty"`
+	// MCP restricts which MCP tools the agent gets. Entries are server names
+	// (all of the server's tools) or namespaced tool names such as
+	// "mcp_github_create_issue". Unset means every configured server.
+	MCP []string `json:"mcp,omitempty"`
 }
 
 type SubagentsConfig struct {
@@ -165,6 +174,11 @@ type AgentBinding struct {
 type SessionConfig struct {
 	DMScope       string              `json:"dm_scope,omitempty"`
 	IdentityLinks map[string][]string `json:"identity_links,omitempty"`
+	// Store selects the session backend: "jsonl" (default), "json" or "bolt".
+	Store string `json:"store,omitempty"      env:"PICOCLAW_SESSION_STORE"`
+	// MaxCached bounds how many idle sessions each agent keeps in memory
+	// (0 means unlimited).
+	MaxCached int `json:"max_cached,omitempty" env:"PICOCLAW_SESSION_MAX_CACHED"`
 }
 
 type AgentDefaults struct {
@@ -179,6 +193,12 @@ type AgentDefaults struct {
 	MaxTokens           int      `json:"max_tokens"                      env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOKENS"`
 	Temperature         *float64 `json:"temperature,omitempty"           env:"PICOCLAW_AGENTS_DEFAULTS_TEMPERATURE"`
 	MaxToolIterations   int      `json:"max_tool_iterations"             env:"PICOCLAW_AGENTS_DEFAULTS_MAX_TOOL_ITERATIONS"`
+	MaxConcurrency      int      `json:"max_concurrency,omitempty"       env:"PICOCLAW_AGENTS_DEFAULTS_MAX_CONCURRENCY"`
+	MaxParallelTools    int      `json:"max_parallel_tools,omitempty"    env:"PICOCLAW_AGENTS_DEFAULTS_MAX_PARALLEL_TOOLS"`
+	MemoryTopK          int      `json:"memory_top_k,omitempty"          env:"PICOCLAW_AGENTS_DEFAULTS_MEMORY_TOP_K"`
+	// DownloadTokenizers lets OpenAI BPE tokenizer data be downloaded once
+	// when it is not in ~/.picoclaw/tokenizers. Off for offline devices.
+	DownloadTokenizers bool `json:"download_tokenizers,omitempty"   env:"PICOCLAW_AGENTS_DEFAULTS_DOWNLOAD_TOKENIZERS"`
 }
 
 // GetModelName returns the effective model name for the agent defaults.
@@ -212,10 +232,11 @@ type WhatsAppConfig struct {
 }
 
 type TelegramConfig struct {
-	Enabled   bool                `json:"enabled"    env:"PICOCLAW_CHANNELS_TELEGRAM_ENABLED"`
-	Token     string              `json:"token"      env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
-	Proxy     string              `json:"proxy"      env:"PICOCLAW_CHANNELS_TELEGRAM_PROXY"`
-	AllowFrom FlexibleStringSlice `json:"allow_from" env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_FROM"`
+	Enabled      bool                `json:"enabled"       env:"PICOCLAW_CHANNELS_TELEGRAM_ENABLED"`
+	Token        string              `json:"token"         env:"PICOCLAW_CHANNELS_TELEGRAM_TOKEN"`
+	Proxy        string              `json:"proxy"         env:"PICOCLAW_CHANNELS_TELEGRAM_PROXY"`
+	AllowFrom    FlexibleStringSlice `json:"allow_from"    env:"PICOCLAW_CHANNELS_TELEGRAM_ALLOW_FROM"`
+	ShowThinking bool                `json:"show_thinking" env:"PICOCLAW_CHANNELS_TELEGRAM_SHOW_THINKING"` // reasoning as a collapsed quote
 }
 
 type FeishuConfig struct {
@@ -228,10 +249,11 @@ type FeishuConfig struct {
 }
 
 type DiscordConfig struct {
-	Enabled     bool                `json:"enabled"      env:"PICOCLAW_CHANNELS_DISCORD_ENABLED"`
-	Token       string              `json:"token"        env:"PICOCLAW_CHANNELS_DISCORD_TOKEN"`
-	AllowFrom   FlexibleStringSlice `json:"allow_from"   env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_FROM"`
-	MentionOnly bool                `json:"mention_only" env:"PICOCLAW_CHANNELS_DISCORD_MENTION_ONLY"`
+	Enabled      bool                `json:"enabled"       env:"PICOCLAW_CHANNELS_DISCORD_ENABLED"`
+	Token        string              `json:"token"         env:"PICOCLAW_CHANNELS_DISCORD_TOKEN"`
+	AllowFrom    FlexibleStringSlice `json:"allow_from"    env:"PICOCLAW_CHANNELS_DISCORD_ALLOW_FROM"`
+	MentionOnly  bool                `json:"mention_only"  env:"PICOCLAW_CHANNELS_DISCORD_MENTION_ONLY"`
+	ShowThinking bool                `json:"show_thinking" env:"PICOCLAW_CHANNELS_DISCORD_SHOW_THINKING"` // reasoning as a spoiler
 }
 
 type MaixCamConfig struct {
@@ -407,8 +429,38 @@ type ModelConfig struct {
 
 	// Optional optimizations
 	RPM            int    `json:"rpm,omitempty"`              // Requests per minute limit
+	TPM            int    `json:"tpm,omitempty"`              // Tokens per minute limit
+	Weight         int    `json:"weight,omitempty"`           // Share of requests among entries with the same model_name (default 1)
 	MaxTokensField string `json:"max_tokens_field,omitempty"` // Field name for max tokens (e.g., "max_completion_tokens")
 	RequestTimeout int    `json:"request_timeout,omitempty"`
+	ContextWindow  int    `json:"context_window,omitempty"` // Total tokens (prompt + reply) the model accepts
+
+	// SafetySettings maps Gemini harm categories to blocking thresholds,
+	// e.g. "HARM_CATEGORY_HARASSMENT": "BLOCK_ONLY_HIGH" (gemini protocol only).
+	SafetySettings map[string]string `json:"safety_settings,omitempty"`
+
+	// Ollama protocol only: how long the model stays loaded after a request
+	// (e.g. "10m", "-1" for always), model options such as num_ctx or top_k,
+	// and whether to pull the model when the server does not have it.
+	KeepAlive string         `json:"keep_alive,omitempty"`
+	Options   map[string]any `json:"options,omitempty"`
+	AutoPull  bool           `json:"auto_pull,omitempty"`
+
+	// ReasoningEffort ("none", "minimal", "low", "medium" or "high") and
+	// ThinkingBudget (tokens) set how much the model thinks before answering.
+	// Providers use whichever their API takes, derived from the other if
+	// only one is set. /think overrides them for a conversation.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	ThinkingBudget  int    `json:"thinking_budget,omitempty"`
+
+	// Price is used to cost the model's token usage in reports and budgets.
+	Price *ModelPrice `json:"price,omitempty"`
+}
+
+// ModelPrice is a model's price in USD per million tokens.
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
 }
 
 // Validate checks if the ModelConfig has all required fields.
@@ -419,12 +471,53 @@ func (c *ModelConfig) Validate() error {
 	if c.Model == "" {
 		return fmt.Errorf("model is required")
 	}
+	if c.ReasoningEffort != "" && !slices.Contains(protocoltypes.ReasoningEfforts, c.ReasoningEffort) {
+		return fmt.Errorf("reasoning_effort must be one of %s",
+			strings.Join(protocoltypes.ReasoningEfforts, ", "))
+	}
+	if c.ThinkingBudget != 0 && c.ThinkingBudget < protocoltypes.MinThinkingBudget {
+		return fmt.Errorf("thinking_budget must be at least %d tokens", protocoltypes.MinThinkingBudget)
+	}
 	return nil
 }
 
+// UsageConfig configures token usage accounting.
+type UsageConfig struct {
+	Budgets []BudgetConfig `json:"budgets,omitempty"`
+}
+
+// BudgetConfig caps the daily and monthly usage of an agent or a sender.
+// Zero limits are not enforced.
+type BudgetConfig struct {
+	// Agent limits the budget to one agent's usage; empty means all agents.
+	Agent string `json:"agent,omitempty"`
+	// Sender limits the budget to one sender, as "channel:id" or a bare
+	// sender ID; "*" gives every sender a budget of their own.
+	Sender string `json:"sender,omitempty"`
+
+	DailyCost     float64 `json:"daily_cost,omitempty"`   // USD
+	MonthlyCost   float64 `json:"monthly_cost,omitempty"` // USD
+	DailyTokens   int     `json:"daily_tokens,omitempty"`
+	MonthlyTokens int     `json:"monthly_tokens,omitempty"`
+
+	// Action is "block" (default) to refuse requests once the budget is
+	// used up, or "downgrade" to answer them with DowngradeModel.
+	Action         string `json:"action,omitempty"`
+	DowngradeModel string `json:"downgrade_model,omitempty"`
+}
+
 type GatewayConfig struct {
-	Host string `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
-	Port int    `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	Host string           `json:"host" env:"PICOCLAW_GATEWAY_HOST"`
+	Port int              `json:"port" env:"PICOCLAW_GATEWAY_PORT"`
+	API  GatewayAPIConfig `json:"api"`
+}
+
+// GatewayAPIConfig controls the OpenAI-compatible chat API served on the
+// gateway port.
+type GatewayAPIConfig struct {
+	Enabled bool `json:"enabled" env:"PICOCLAW_GATEWAY_API_ENABLED"`
+	// Tokens are the accepted bearer tokens. The API stays off without any.
+	Tokens []string `json:"tokens,omitempty"`
 }
 
 type BraveConfig struct {
@@ -476,6 +569,44 @@ type ToolsConfig struct {
 	Exec     ExecConfig          `json:"exec"`
 	Skills   SkillsToolsConfig   `json:"skills"`
 	Academic AcademicToolsConfig `json:"academic"`
+	MCP      MCPConfig           `json:"mcp"`
+}
+
+// MCPConfig declares external Model Context Protocol servers whose tools are
+// exposed to agents.
+type MCPConfig struct {
+	Servers map[string]MCPServerConfig `json:"servers,omitempty"`
+	Serve   MCPServeConfig             `json:"serve"`
+}
+
+// MCPServeConfig controls `picoclaw mcp serve`, which publishes an agent's
+// tools to other MCP clients.
+type MCPServeConfig struct {
+	// Agent whose workspace and tools are served; the default agent if empty.
+	Agent string `json:"agent,omitempty" env:"PICOCLAW_TOOLS_MCP_SERVE_AGENT"`
+	// Tools is the allowlist of published tool names. Unset publishes all
+	// but those that run commands, change files or drive hardware.
+	Tools []string `json:"tools,omitempty"`
+	// Token is the bearer token required by the HTTP transport, if set.
+	Token string `json:"token,omitempty" env:"PICOCLAW_TOOLS_MCP_SERVE_TOKEN"`
+	// AllowedOrigins are browser origins accepted besides localhost.
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+	// Host and Port are where the HTTP transport listens.
+	Host string `json:"host" env:"PICOCLAW_TOOLS_MCP_SERVE_HOST"`
+	Port int    `json:"port" env:"PICOCLAW_TOOLS_MCP_SERVE_PORT"`
+}
+
+// MCPServerConfig describes one MCP server. Set Command to launch a stdio
+// server, or URL to connect to a streamable-HTTP server.
+type MCPServerConfig struct {
+	Enabled bool              `json:"enabled"`
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Timeout bounds each request to the server in seconds (default 60).
+	Timeout int `json:"timeout,omitempty"`
 }
 
 // AcademicToolsConfig holds configuration for academic paper search and download tools.
@@ -663,6 +794,12 @@ func (c *Config) GetModelConfig(modelName string) (*ModelConfig, error) {
 	return &matches[idx], nil
 }
 
+// ModelConfigs returns all model_list entries with the given model_name,
+// which a provider balances requests over.
+func (c *Config) ModelConfigs(modelName string) []ModelConfig {
+	return c.findMatches(modelName)
+}
+
 // findMatches finds all ModelConfig entries with the given model_name.
 func (c *Config) findMatches(modelName string) []ModelConfig {
 	var matches []ModelConfig
@@ -17,11 +17,16 @@ func DefaultConfig() *Config {
 				MaxTokens:           8192,
 				Temperature:         nil, // nil means use provider default
 				MaxToolIterations:   20,
+				MaxConcurrency:      4,
+				MaxParallelTools:    4,
+				MemoryTopK:          5,
 			},
 		},
 		Bindings: []AgentBinding{},
 		Session: SessionConfig{
-			DMScope: "per-channel-peer",
+			DMScope:   "per-channel-peer",
+			Store:     "jsonl",
+			MaxCached: 100,
 		},
 		Channels: ChannelsConfig{
 			WhatsApp: WhatsAppConfig{
@@ -251,8 +256,8 @@ func DefaultConfig() *Config {
 			{
 				ModelName: "llama3",
 				Model:     "ollama/llama3",
-				APIBase:   "http://localhost:11434/v1",
-				APIKey:    "ollama",
+				APIBase:   "http://localhost:11434",
+				APIKey:    "",
 			},
 
 			// Mistral AI - https://console.mistral.ai/api-keys
@@ -312,6 +317,12 @@ func DefaultConfig() *Config {
 					TTLSeconds: 300,
 				},
 			},
+			MCP: MCPConfig{
+				Serve: MCPServeConfig{
+					Host: "127.0.0.1",
+					Port: 18791,
+				},
+			},
 		},
 		Heartbeat: HeartbeatConfig{
 			Enabled:  true,
@@ -279,6 +279,21 @@ func TestModelConfig_Validate(t *testing.T) {
 			config:  ModelConfig{},
 			wantErr: true,
 		},
+		{
+			name:    "reasoning settings",
+			config:  ModelConfig{ModelName: "test", Model: "anthropic/claude", ReasoningEffort: "high", ThinkingBudget: 4096},
+			wantErr: false,
+		},
+		{
+			name:    "unknown reasoning effort",
+			config:  ModelConfig{ModelName: "test", Model: "openai/o3", ReasoningEffort: "maximum"},
+			wantErr: true,
+		},
+		{
+			name:    "thinking budget below minimum",
+			config:  ModelConfig{ModelName: "test", Model: "anthropic/claude", ThinkingBudget: 100},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -11,9 +11,11 @@ import (
 
 type Server struct {
 	server    *http.Server
+	mux       *http.ServeMux
 	mu        sync.RWMutex
 	ready     bool
 	checks    map[string]Check
+	details   map[string]func() any
 	startTime time.Time
 }
 
@@ -25,16 +27,19 @@ type Check struct {
 }
 
 type StatusResponse struct {
-	Status string           `json:"status"`
-	Uptime string           `json:"uptime"`
-	Checks map[string]Check `json:"checks,omitempty"`
+	Status  string           `json:"status"`
+	Uptime  string           `json:"uptime"`
+	Checks  map[string]Check `json:"checks,omitempty"`
+	Details map[string]any   `json:"details,omitempty"`
 }
 
 func NewServer(host string, port int) *Server {
 	mux := http.NewServeMux()
 	s := &Server{
+		mux:       mux,
 		ready:     false,
 		checks:    make(map[string]Check),
+		details:   make(map[string]func() any),
 		startTime: time.Now(),
 	}
 
@@ -52,6 +57,13 @@ func NewServer(host string, port int) *Server {
 	return s
 }
 
+// Handle mounts an additional handler on the server, e.g. an API sharing the
+// gateway port. Handlers that respond slowly should lift the write deadline
+// with http.ResponseController.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
 func (s *Server) Start() error {
 	s.mu.Lock()
 	s.ready = true
@@ -103,6 +115,14 @@ func (s *Server) RegisterCheck(name string, checkFn func() (bool, string)) {
 	}
 }
 
+// RegisterDetail adds the value fn returns, computed per request, to the
+// /health response under name, e.g. queue statistics.
+func (s *Server) RegisterDetail(name string, fn func() any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.details[name] = fn
+}
+
 func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -112,6 +132,14 @@ func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
 		Status: "ok",
 		Uptime: uptime.String(),
 	}
+	s.mu.RLock()
+	for name, fn := range s.details {
+		if resp.Details == nil {
+			resp.Details = make(map[string]any)
+		}
+		resp.Details[name] = fn()
+	}
+	s.mu.RUnlock()
 
 	json.NewEncoder(w).Encode(resp)
 }
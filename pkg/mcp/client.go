@@ -0,0 +1,193 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const defaultRequestTimeout = 60 * time.Second
+
+// Client is a connection to one MCP server. It connects lazily and
+// reconnects when the server goes away: a request that never reached the
+// server is retried once on a fresh connection, any other transport failure
+// drops the connection so the next request starts a new one.
+type Client struct {
+	name    string
+	cfg     config.MCPServerConfig
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn transport
+}
+
+// NewClient creates a client for the server declared under name in config.
+func NewClient(name string, cfg config.MCPServerConfig) *Client {
+	timeout := defaultRequestTimeout
+	if cfg.Timeout > 0 {
+		timeout = time.Duration(cfg.Timeout) * time.Second
+	}
+	return &Client{name: name, cfg: cfg, timeout: timeout}
+}
+
+// Name returns the server name from config.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Connect opens the connection and performs the MCP handshake, if not
+// already connected.
+func (c *Client) Connect(ctx context.Context) error {
+	_, err := c.connection(ctx)
+	return err
+}
+
+// ListTools returns every tool the server advertises.
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var all []ToolInfo
+	params := listToolsParams{}
+	for {
+		var res listToolsResult
+		if err := c.request(ctx, "tools/list", params, &res); err != nil {
+			return nil, err
+		}
+		all = append(all, res.Tools...)
+		if res.NextCursor == "" {
+			return all, nil
+		}
+		params.Cursor = res.NextCursor
+	}
+}
+
+// CallTool invokes a server tool by its original (un-namespaced) name.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallToolResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var res CallToolResult
+	if err := c.request(ctx, "tools/call", callToolParams{Name: name, Arguments: args}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Close shuts down the connection. The client reconnects if used again.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.close()
+}
+
+func (c *Client) request(ctx context.Context, method string, params, out any) error {
+	raw, err := c.roundTrip(ctx, method, params)
+	if errors.Is(err, errNotDelivered) && ctx.Err() == nil {
+		logger.InfoCF("mcp", "Reconnecting to MCP server", map[string]any{
+			"server": c.name,
+			"error":  err.Error(),
+		})
+		raw, err = c.roundTrip(ctx, method, params)
+	}
+	if err != nil {
+		return err
+	}
+	if out != nil && len(raw) > 0 {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("decoding %s result: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// roundTrip sends one request. A transport failure drops the connection so
+// the next request reconnects; server errors keep it.
+func (c *Client) roundTrip(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	conn, err := c.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	raw, err := conn.call(callCtx, method, params)
+
+	var rpcErr *RPCError
+	if err != nil && !errors.As(err, &rpcErr) && ctx.Err() == nil {
+		c.drop(conn)
+	}
+	return raw, err
+}
+
+func (c *Client) connection(ctx context.Context) (transport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		return c.conn, nil
+	}
+
+	conn, err := c.dial()
+	if err != nil {
+		return nil, fmt.Errorf("connecting to mcp server %s: %w", c.name, err)
+	}
+
+	initCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	var info initializeResult
+	raw, err := conn.call(initCtx, "initialize", initializeParams{
+		ProtocolVersion: protocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      implementation{Name: clientName, Version: clientVersion},
+	})
+	if err == nil {
+		err = json.Unmarshal(raw, &info)
+	}
+	if err == nil {
+		err = conn.notify(initCtx, "notifications/initialized", nil)
+	}
+	if err != nil {
+		conn.close()
+		return nil, fmt.Errorf("initializing mcp server %s: %w", c.name, err)
+	}
+
+	logger.InfoCF("mcp", "Connected to MCP server", map[string]any{
+		"server":   c.name,
+		"name":     info.ServerInfo.Name,
+		"version":  info.ServerInfo.Version,
+		"protocol": info.ProtocolVersion,
+	})
+	c.conn = conn
+	return conn, nil
+}
+
+func (c *Client) dial() (transport, error) {
+	switch {
+	case c.cfg.Command != "":
+		return startStdio(c.name, c.cfg.Command, c.cfg.Args, c.cfg.Env)
+	case c.cfg.URL != "":
+		return newHTTPTransport(c.cfg.URL, c.cfg.Headers), nil
+	default:
+		return nil, errors.New("neither command nor url is set")
+	}
+}
+
+// drop discards conn if it is still the current connection.
+func (c *Client) drop(conn transport) {
+	c.mu.Lock()
+	if c.conn != conn {
+		c.mu.Unlock()
+		return
+	}
+	c.conn = nil
+	c.mu.Unlock()
+	conn.close()
+}
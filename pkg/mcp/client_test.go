@@ -0,0 +1,222 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func fakeStdioConfig() config.MCPServerConfig {
+	return config.MCPServerConfig{
+		Enabled: true,
+		Command: os.Args[0],
+		Env:     map[string]string{fakeServerEnv: "1"},
+		Timeout: 10,
+	}
+}
+
+func TestClient_StdioListAndCall(t *testing.T) {
+	client := NewClient("fake", fakeStdioConfig())
+	defer client.Close()
+
+	infos, err := client.ListTools(t.Context())
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(infos) != 3 || infos[0].Name != "echo" || infos[2].Name != "crash" {
+		t.Fatalf("tools = %+v, want echo, fail, crash across two pages", infos)
+	}
+
+	res, err := client.CallTool(t.Context(), "echo", map[string]any{"text": "hello"})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if got := formatContent(res); got != "hello" {
+		t.Errorf("echo = %q, want %q", got, "hello")
+	}
+
+	_, err = client.CallTool(t.Context(), "missing", nil)
+	if _, ok := err.(*RPCError); !ok {
+		t.Errorf("unknown tool error = %v, want *RPCError", err)
+	}
+}
+
+func TestClient_StdioReconnectsAfterCrash(t *testing.T) {
+	client := NewClient("fake", fakeStdioConfig())
+	defer client.Close()
+
+	if _, err := client.CallTool(t.Context(), "crash", nil); err == nil {
+		t.Fatal("expected an error when the server dies mid-call")
+	}
+
+	res, err := client.CallTool(t.Context(), "echo", map[string]any{"text": "back"})
+	if err != nil {
+		t.Fatalf("CallTool after crash failed: %v", err)
+	}
+	if got := formatContent(res); got != "back" {
+		t.Errorf("echo = %q, want %q", got, "back")
+	}
+}
+
+// fakeHTTPServer serves handleFake over streamable HTTP, answering with SSE
+// and issuing session IDs. expire forgets every session.
+type fakeHTTPServer struct {
+	mu       sync.Mutex
+	sessions map[string]bool
+	inits    int
+}
+
+func (s *fakeHTTPServer) expire() {
+	s.mu.Lock()
+	s.sessions = map[string]bool{}
+	s.mu.Unlock()
+}
+
+func (s *fakeHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req message
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	if req.Method == "initialize" {
+		s.inits++
+		id := fmt.Sprintf("session-%d", s.inits)
+		s.sessions[id] = true
+		w.Header().Set(sessionIDHeader, id)
+	} else if !s.sessions[r.Header.Get(sessionIDHeader)] {
+		s.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	s.mu.Unlock()
+
+	resp := handleFake(&req)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	data, _ := json.Marshal(resp)
+	w.Header().Set("Content-Type", "text/event-stream")
+	fmt.Fprintf(w, "event: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+	fmt.Fprintf(w, "event: message\ndata: %s\n\n", data)
+}
+
+func TestClient_HTTPListAndCall(t *testing.T) {
+	fake := &fakeHTTPServer{sessions: map[string]bool{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client := NewClient("remote", config.MCPServerConfig{Enabled: true, URL: srv.URL})
+	defer client.Close()
+
+	infos, err := client.ListTools(t.Context())
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(infos) != 3 {
+		t.Fatalf("got %d tools, want 3", len(infos))
+	}
+
+	res, err := client.CallTool(t.Context(), "echo", map[string]any{"text": "over http"})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if got := formatContent(res); got != "over http" {
+		t.Errorf("echo = %q, want %q", got, "over http")
+	}
+}
+
+func TestClient_HTTPReinitializesExpiredSession(t *testing.T) {
+	fake := &fakeHTTPServer{sessions: map[string]bool{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	client := NewClient("remote", config.MCPServerConfig{Enabled: true, URL: srv.URL})
+	defer client.Close()
+
+	if err := client.Connect(t.Context()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	fake.expire()
+
+	res, err := client.CallTool(t.Context(), "echo", map[string]any{"text": "again"})
+	if err != nil {
+		t.Fatalf("CallTool after expiry failed: %v", err)
+	}
+	if got := formatContent(res); got != "again" {
+		t.Errorf("echo = %q, want %q", got, "again")
+	}
+	if fake.inits != 2 {
+		t.Errorf("initialize calls = %d, want 2", fake.inits)
+	}
+}
+
+func TestManager_ToolsAllowlist(t *testing.T) {
+	m := NewManager(config.MCPConfig{Servers: map[string]config.MCPServerConfig{
+		"fake":     fakeStdioConfig(),
+		"disabled": {Command: "does-not-exist"},
+	}})
+	defer m.Close()
+	m.Start(t.Context())
+
+	if got := len(m.Tools(nil)); got != 3 {
+		t.Fatalf("Tools(nil) returned %d tools, want 3", got)
+	}
+	if got := len(m.Tools([]string{"fake"})); got != 3 {
+		t.Errorf("server allowlist returned %d tools, want 3", got)
+	}
+	if got := m.Tools([]string{"mcp_fake_echo"}); len(got) != 1 || got[0].Name() != "mcp_fake_echo" {
+		t.Errorf("tool allowlist = %v, want only mcp_fake_echo", got)
+	}
+	if got := len(m.Tools([]string{})); got != 0 {
+		t.Errorf("empty allowlist returned %d tools, want 0", got)
+	}
+}
+
+func TestTool_Execute(t *testing.T) {
+	client := NewClient("fake", fakeStdioConfig())
+	defer client.Close()
+
+	echo := NewTool(client, ToolInfo{Name: "echo"})
+	if res := echo.Execute(t.Context(), map[string]any{"text": "hi"}); res.IsError || res.ForLLM != "hi" {
+		t.Errorf("echo result = %+v", res)
+	}
+
+	fail := NewTool(client, ToolInfo{Name: "fail"})
+	if res := fail.Execute(t.Context(), nil); !res.IsError || res.ForLLM != "it broke" {
+		t.Errorf("fail result = %+v, want error %q", res, "it broke")
+	}
+}
+
+func TestTool_ParallelSafeOnlyWhenReadOnly(t *testing.T) {
+	if (&Tool{info: ToolInfo{Name: "write"}}).ParallelSafe() {
+		t.Error("tool without annotations should not be parallel-safe")
+	}
+	readOnly := &Tool{info: ToolInfo{Name: "read", Annotations: &ToolAnnotations{ReadOnlyHint: true}}}
+	if !readOnly.ParallelSafe() {
+		t.Error("read-only tool should be parallel-safe")
+	}
+}
+
+func TestToolName(t *testing.T) {
+	if got := ToolName("git hub", "create.issue"); got != "mcp_git_hub_create_issue" {
+		t.Errorf("ToolName = %q", got)
+	}
+
+	long := ToolName("server", strings.Repeat("x", 100))
+	if len(long) != maxToolNameLength {
+		t.Errorf("long name length = %d, want %d", len(long), maxToolNameLength)
+	}
+	if long == ToolName("server", strings.Repeat("x", 101)) {
+		t.Error("truncated names of different tools should differ")
+	}
+}
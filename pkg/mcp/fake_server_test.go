@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+// fakeServerEnv makes the test binary act as a stdio MCP server, so tests
+// can launch a real subprocess with os.Args[0].
+const fakeServerEnv = "PICOCLAW_MCP_FAKE_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakeServerEnv) == "1" {
+		serveFakeStdio(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func serveFakeStdio(in io.Reader, out io.Writer) {
+	r := bufio.NewReader(in)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil {
+			return
+		}
+		var req message
+		if json.Unmarshal(line, &req) != nil {
+			continue
+		}
+		if req.Method == "tools/call" && callName(req.Params) == "crash" {
+			os.Exit(1)
+		}
+		if resp := handleFake(&req); resp != nil {
+			data, _ := json.Marshal(resp)
+			fmt.Fprintf(out, "%s\n", data)
+		}
+	}
+}
+
+// handleFake implements a small MCP server: two pages of tools and an echo
+// tool. It returns nil for notifications.
+func handleFake(req *message) *message {
+	if req.ID == nil {
+		return nil
+	}
+	resp := &message{JSONRPC: jsonrpcVersion, ID: req.ID}
+	var res any
+	switch req.Method {
+	case "initialize":
+		res = initializeResult{
+			ProtocolVersion: protocolVersion,
+			Capabilities:    map[string]any{"tools": map[string]any{}},
+			ServerInfo:      implementation{Name: "fake", Version: "1.0"},
+		}
+	case "tools/list":
+		var p listToolsParams
+		json.Unmarshal(req.Params, &p)
+		if p.Cursor == "" {
+			res = listToolsResult{
+				Tools: []ToolInfo{{
+					Name:        "echo",
+					Description: "Echoes its input",
+					InputSchema: map[string]any{
+						"type":       "object",
+						"properties": map[string]any{"text": map[string]any{"type": "string"}},
+					},
+				}},
+				NextCursor: "page2",
+			}
+		} else {
+			res = listToolsResult{Tools: []ToolInfo{{Name: "fail"}, {Name: "crash"}}}
+		}
+	case "tools/call":
+		var p callToolParams
+		json.Unmarshal(req.Params, &p)
+		switch p.Name {
+		case "echo":
+			res = CallToolResult{Content: []Content{{Type: "text", Text: fmt.Sprint(p.Arguments["text"])}}}
+		case "fail":
+			res = CallToolResult{Content: []Content{{Type: "text", Text: "it broke"}}, IsError: true}
+		default:
+			resp.Error = &RPCError{Code: codeInvalidParams, Message: "unknown tool " + p.Name}
+			return resp
+		}
+	default:
+		resp.Error = &RPCError{Code: codeMethodNotFound, Message: "method not found"}
+		return resp
+	}
+	resp.Result, _ = json.Marshal(res)
+	return resp
+}
+
+func callName(params json.RawMessage) string {
+	var p callToolParams
+	json.Unmarshal(params, &p)
+	return p.Name
+}
@@ -0,0 +1,98 @@
+package mcp
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// Manager owns the clients of every enabled MCP server and the proxy tools
+// discovered on them.
+type Manager struct {
+	clients []*Client
+
+	mu    sync.Mutex
+	tools []*Tool
+}
+
+// NewManager creates clients for the enabled servers in cfg. Nothing is
+// started until Start is called.
+func NewManager(cfg config.MCPConfig) *Manager {
+	names := make([]string, 0, len(cfg.Servers))
+	for name, server := range cfg.Servers {
+		if server.Enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	m := &Manager{}
+	for _, name := range names {
+		m.clients = append(m.clients, NewClient(name, cfg.Servers[name]))
+	}
+	return m
+}
+
+// Start connects to all servers in parallel and lists their tools. Servers
+// that fail are logged and skipped so one bad server does not block the rest.
+func (m *Manager) Start(ctx context.Context) {
+	results := make([][]*Tool, len(m.clients))
+	var wg sync.WaitGroup
+	for i, client := range m.clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			infos, err := client.ListTools(ctx)
+			if err != nil {
+				logger.ErrorCF("mcp", "Failed to load MCP server tools", map[string]any{
+					"server": client.Name(),
+					"error":  err.Error(),
+				})
+				return
+			}
+			for _, info := range infos {
+				results[i] = append(results[i], NewTool(client, info))
+			}
+			logger.InfoCF("mcp", "Loaded MCP server tools", map[string]any{
+				"server": client.Name(),
+				"tools":  len(infos),
+			})
+		}()
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tools = m.tools[:0]
+	for _, ts := range results {
+		m.tools = append(m.tools, ts...)
+	}
+}
+
+// Tools returns the discovered tools permitted by allow. A nil allowlist
+// permits every tool; otherwise each entry names a server or a namespaced
+// tool.
+func (m *Manager) Tools(allow []string) []tools.Tool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var out []tools.Tool
+	for _, t := range m.tools {
+		if allow == nil || slices.Contains(allow, t.Server()) || slices.Contains(allow, t.Name()) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// Close shuts down every server connection.
+func (m *Manager) Close() {
+	for _, client := range m.clients {
+		client.Close()
+	}
+}
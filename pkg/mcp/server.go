@@ -0,0 +1,269 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// ServerOptions configures a Server.
+type ServerOptions struct {
+	Name    string
+	Version string
+	// Allow lists the tools that are published. Nil publishes every tool
+	// except those in UnsafeTools.
+	Allow []string
+	// Token, if set, is the bearer token HTTP requests must present.
+	Token string
+	// AllowedOrigins are browser origins accepted over HTTP besides localhost.
+	AllowedOrigins []string
+}
+
+// UnsafeTools are left out when no allowlist is configured: they run
+// commands, change files or drive hardware, so they must be published
+// explicitly.
+var UnsafeTools = []string{
+	"exec", "write_file", "edit_file", "append_file", "memory_write",
+	"install_skill", "cron", "spi", "i2c",
+}
+
+// Server publishes the tools of a ToolRegistry over MCP, on stdio or as a
+// stateless streamable-HTTP handler.
+type Server struct {
+	registry *tools.ToolRegistry
+	opts     ServerOptions
+}
+
+// NewServer creates a server for the tools in registry permitted by opts.
+func NewServer(registry *tools.ToolRegistry, opts ServerOptions) *Server {
+	return &Server{registry: registry, opts: opts}
+}
+
+// ServeStdio reads newline-delimited JSON-RPC messages from in and writes
+// responses to out until in is exhausted or ctx is done. Requests are handled
+// concurrently so a slow tool does not block pings or other calls.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	lines := make(chan []byte)
+	readErr := make(chan error, 1)
+	go func() {
+		r := bufio.NewReader(in)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(line) > 0 {
+				select {
+				case lines <- line:
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				readErr <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-readErr:
+			return err
+		case line := <-lines:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp := s.handleRaw(ctx, line)
+				if resp == nil {
+					return
+				}
+				data, err := json.Marshal(resp)
+				if err != nil {
+					return
+				}
+				writeMu.Lock()
+				out.Write(append(data, '\n'))
+				writeMu.Unlock()
+			}()
+		}
+	}
+}
+
+// ServeHTTP implements the streamable-HTTP transport. Every POST carries one
+// message and gets a JSON response; the server keeps no session state.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// A web page can POST to localhost, so browser requests from foreign
+	// origins (including DNS-rebound hosts) are refused outright.
+	if !s.originAllowed(r.Header.Get("Origin")) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "content type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+	if !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "invalid or missing bearer token", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp := s.handleRaw(r.Context(), body)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// originAllowed accepts requests without an Origin header (non-browser
+// clients), localhost origins and the configured allowlist.
+func (s *Server) originAllowed(origin string) bool {
+	if origin == "" || slices.Contains(s.opts.AllowedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.opts.Token == "" {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.opts.Token)) == 1
+}
+
+// handleRaw decodes and handles one message. It returns nil for
+// notifications, which get no response.
+func (s *Server) handleRaw(ctx context.Context, data []byte) *message {
+	var req message
+	if err := json.Unmarshal(data, &req); err != nil {
+		null := json.RawMessage("null")
+		return &message{
+			JSONRPC: jsonrpcVersion,
+			ID:      &null,
+			Error:   &RPCError{Code: codeParseError, Message: "parse error: " + err.Error()},
+		}
+	}
+	if req.ID == nil {
+		return nil
+	}
+
+	res, err := s.handle(ctx, &req)
+	resp := &message{JSONRPC: jsonrpcVersion, ID: req.ID, Error: err}
+	if err == nil {
+		raw, mErr := json.Marshal(res)
+		if mErr != nil {
+			resp.Error = &RPCError{Code: codeInternalError, Message: mErr.Error()}
+		} else {
+			resp.Result = raw
+		}
+	}
+	return resp
+}
+
+func (s *Server) handle(ctx context.Context, req *message) (any, *RPCError) {
+	switch req.Method {
+	case "initialize":
+		var p initializeParams
+		json.Unmarshal(req.Params, &p)
+		version := protocolVersion
+		if p.ProtocolVersion != "" && p.ProtocolVersion < protocolVersion {
+			version = p.ProtocolVersion
+		}
+		return initializeResult{
+			ProtocolVersion: version,
+			Capabilities:    map[string]any{"tools": map[string]any{"listChanged": false}},
+			ServerInfo:      implementation{Name: s.opts.Name, Version: s.opts.Version},
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return listToolsResult{Tools: s.listTools()}, nil
+	case "tools/call":
+		var p callToolParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return nil, &RPCError{Code: codeInvalidParams, Message: err.Error()}
+		}
+		return s.callTool(ctx, p)
+	default:
+		return nil, &RPCError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+}
+
+func (s *Server) allowed(name string) bool {
+	if s.opts.Allow == nil {
+		return !slices.Contains(UnsafeTools, name)
+	}
+	return slices.Contains(s.opts.Allow, name)
+}
+
+func (s *Server) listTools() []ToolInfo {
+	infos := []ToolInfo{}
+	for _, name := range s.registry.List() {
+		tool, ok := s.registry.Get(name)
+		if !ok || !s.allowed(name) {
+			continue
+		}
+		infos = append(infos, ToolInfo{
+			Name:        name,
+			Description: tool.Description(),
+			InputSchema: tool.Parameters(),
+		})
+	}
+	return infos
+}
+
+func (s *Server) callTool(ctx context.Context, p callToolParams) (any, *RPCError) {
+	if _, ok := s.registry.Get(p.Name); !ok || !s.allowed(p.Name) {
+		return nil, &RPCError{Code: codeInvalidParams, Message: "unknown tool: " + p.Name}
+	}
+
+	result := s.registry.ExecuteWithContext(ctx, p.Name, p.Arguments, "", "", nil)
+	text := result.ForLLM
+	if text == "" {
+		text = result.ForUser
+	}
+	return CallToolResult{
+		Content: []Content{{Type: "text", Text: text}},
+		IsError: result.IsError,
+	}, nil
+}
@@ -0,0 +1,233 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+type upperTool struct{}
+
+func (upperTool) Name() string        { return "upper" }
+func (upperTool) Description() string { return "Uppercases text" }
+func (upperTool) Parameters() map[string]any {
+	return map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"text": map[string]any{"type": "string"}},
+	}
+}
+
+func (upperTool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	text, _ := args["text"].(string)
+	if text == "" {
+		return tools.ErrorResult("text is required")
+	}
+	return tools.NewToolResult(strings.ToUpper(text))
+}
+
+type hiddenTool struct{ upperTool }
+
+func (hiddenTool) Name() string { return "hidden" }
+
+func newTestServer(allow []string) *Server {
+	registry := tools.NewToolRegistry()
+	registry.Register(upperTool{})
+	registry.Register(hiddenTool{})
+	return NewServer(registry, ServerOptions{Name: "picoclaw", Version: "test", Allow: allow})
+}
+
+func TestServer_HTTPRoundTripWithClient(t *testing.T) {
+	srv := httptest.NewServer(newTestServer([]string{"upper"}))
+	defer srv.Close()
+
+	client := NewClient("self", config.MCPServerConfig{Enabled: true, URL: srv.URL})
+	defer client.Close()
+
+	infos, err := client.ListTools(t.Context())
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+	if len(infos) != 1 || infos[0].Name != "upper" {
+		t.Fatalf("tools = %+v, want only the allowlisted upper tool", infos)
+	}
+
+	res, err := client.CallTool(t.Context(), "upper", map[string]any{"text": "abc"})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+	if got := formatContent(res); got != "ABC" || res.IsError {
+		t.Errorf("upper = %q (isError=%v), want ABC", got, res.IsError)
+	}
+
+	res, err = client.CallTool(t.Context(), "upper", nil)
+	if err != nil || !res.IsError {
+		t.Errorf("expected a tool error result, got %+v, %v", res, err)
+	}
+
+	if _, err := client.CallTool(t.Context(), "hidden", nil); err == nil {
+		t.Error("expected tools outside the allowlist to be rejected")
+	}
+}
+
+func TestServer_Stdio(t *testing.T) {
+	in := strings.NewReader(strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"bogus"}`,
+	}, "\n") + "\n")
+	var out bytes.Buffer
+
+	if err := newTestServer(nil).ServeStdio(t.Context(), in, &out); err != nil {
+		t.Fatalf("ServeStdio failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d responses, want 3 (none for the notification):\n%s", len(lines), out.String())
+	}
+	all := out.String()
+	for _, want := range []string{`"serverInfo":{"name":"picoclaw"`, `"name":"hidden"`, `"code":-32601`} {
+		if !strings.Contains(all, want) {
+			t.Errorf("responses missing %s:\n%s", want, all)
+		}
+	}
+}
+
+func TestServer_RejectsGet(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(nil))
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 405 {
+		t.Errorf("GET status = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestServer_HTTPRejectsForeignOriginsAndContentTypes(t *testing.T) {
+	srv := httptest.NewServer(newTestServer(nil))
+	defer srv.Close()
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"ping"}`
+	tests := []struct {
+		name        string
+		origin      string
+		contentType string
+		want        int
+	}{
+		{"no origin", "", "application/json", 200},
+		{"localhost", "http://localhost:3000", "application/json", 200},
+		{"loopback", "http://127.0.0.1:8080", "application/json", 200},
+		{"foreign origin", "https://evil.example", "application/json", 403},
+		{"rebound host", "http://attacker.localhost.evil.example", "application/json", 403},
+		{"simple request", "", "text/plain", 415},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", srv.URL, strings.NewReader(body))
+			req.RequestURI = ""
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestServer_HTTPAllowedOriginAndToken(t *testing.T) {
+	registry := tools.NewToolRegistry()
+	registry.Register(upperTool{})
+	srv := httptest.NewServer(NewServer(registry, ServerOptions{
+		Token:          "secret",
+		AllowedOrigins: []string{"https://app.example"},
+	}))
+	defer srv.Close()
+
+	post := func(origin, token string) int {
+		req := httptest.NewRequest("POST", srv.URL, strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}`))
+		req.RequestURI = ""
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Origin", origin)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if got := post("https://app.example", ""); got != 401 {
+		t.Errorf("missing token status = %d, want 401", got)
+	}
+	if got := post("https://app.example", "wrong"); got != 401 {
+		t.Errorf("wrong token status = %d, want 401", got)
+	}
+	if got := post("https://app.example", "secret"); got != 200 {
+		t.Errorf("allowed origin with token status = %d, want 200", got)
+	}
+
+	client := NewClient("self", config.MCPServerConfig{
+		Enabled: true,
+		URL:     srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer secret"},
+	})
+	defer client.Close()
+	if _, err := client.ListTools(t.Context()); err != nil {
+		t.Fatalf("ListTools with token failed: %v", err)
+	}
+}
+
+func TestServer_DefaultLeavesOutUnsafeTools(t *testing.T) {
+	registry := tools.NewToolRegistry()
+	registry.Register(upperTool{})
+	for _, name := range UnsafeTools {
+		registry.Register(namedTool{name: name})
+	}
+	s := NewServer(registry, ServerOptions{})
+
+	infos := s.listTools()
+	if len(infos) != 1 || infos[0].Name != "upper" {
+		t.Errorf("tools = %+v, want only upper", infos)
+	}
+	for _, name := range []string{"exec", "cron", "append_file", "memory_write", "install_skill"} {
+		if _, err := s.callTool(t.Context(), callToolParams{Name: name}); err == nil {
+			t.Errorf("expected %s to be rejected without an allowlist", name)
+		}
+	}
+
+	s = NewServer(registry, ServerOptions{Allow: []string{"exec"}})
+	if infos := s.listTools(); len(infos) != 1 || infos[0].Name != "exec" {
+		t.Errorf("tools = %+v, want exec when allowlisted", infos)
+	}
+}
+
+type namedTool struct {
+	upperTool
+	name string
+}
+
+func (t namedTool) Name() string { return t.name }
@@ -0,0 +1,202 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// stdioStopTimeout is how long a server gets to exit after its stdin closes
+// before it is killed.
+const stdioStopTimeout = 2 * time.Second
+
+// stdioTransport talks to an MCP server launched as a subprocess, exchanging
+// newline-delimited JSON-RPC messages over its stdin and stdout.
+type stdioTransport struct {
+	server string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]chan *message
+
+	done chan struct{} // closed once the process has exited
+}
+
+func startStdio(server, command string, args []string, env map[string]string) (*stdioTransport, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", command, err)
+	}
+
+	t := &stdioTransport{
+		server:  server,
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan *message),
+		done:    make(chan struct{}),
+	}
+	go t.logStderr(stderr)
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	data, err := newRequest(id, method, params)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan *message, 1)
+	t.mu.Lock()
+	t.pending[id] = ch
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		delete(t.pending, id)
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(data); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return result(resp)
+	case <-t.done:
+		select {
+		case resp := <-ch:
+			return result(resp)
+		default:
+			return nil, fmt.Errorf("mcp server %s exited", t.server)
+		}
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) notify(ctx context.Context, method string, params any) error {
+	data, err := newNotification(method, params)
+	if err != nil {
+		return err
+	}
+	return t.write(data)
+}
+
+func (t *stdioTransport) write(data []byte) error {
+	select {
+	case <-t.done:
+		return errClosed
+	default:
+	}
+
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("%w: %v", errNotDelivered, err)
+	}
+	return nil
+}
+
+// readLoop dispatches responses to waiting calls and answers server requests
+// until stdout closes, then reaps the process.
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	defer close(t.done)
+
+	r := bufio.NewReader(stdout)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			t.dispatch(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	if err := t.cmd.Wait(); err != nil {
+		logger.DebugCF("mcp", "MCP server exited", map[string]any{
+			"server": t.server,
+			"error":  err.Error(),
+		})
+	}
+}
+
+func (t *stdioTransport) dispatch(line []byte) {
+	var msg message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		logger.DebugCF("mcp", "Ignoring non-JSON output from MCP server", map[string]any{
+			"server": t.server,
+		})
+		return
+	}
+
+	switch {
+	case msg.isResponse():
+		id, err := strconv.ParseInt(string(*msg.ID), 10, 64)
+		if err != nil {
+			return
+		}
+		t.mu.Lock()
+		ch := t.pending[id]
+		t.mu.Unlock()
+		if ch != nil {
+			ch <- &msg
+		}
+	case msg.ID != nil:
+		if reply, err := replyTo(&msg); err == nil {
+			t.write(reply)
+		}
+	}
+}
+
+func (t *stdioTransport) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		logger.DebugCF("mcp", scanner.Text(), map[string]any{"server": t.server})
+	}
+}
+
+func (t *stdioTransport) close() error {
+	t.stdin.Close()
+	select {
+	case <-t.done:
+		return nil
+	case <-time.After(stdioStopTimeout):
+	}
+	t.cmd.Process.Kill()
+	<-t.done
+	return nil
+}
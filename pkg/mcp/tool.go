@@ -0,0 +1,130 @@
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/tools"
+)
+
+// maxToolNameLength is the longest function name LLM providers accept.
+const maxToolNameLength = 64
+
+// Tool exposes one MCP server tool as a picoclaw tool.
+type Tool struct {
+	client *Client
+	info   ToolInfo
+	name   string
+}
+
+// NewTool wraps info, as advertised by client's server, in a tools.Tool.
+func NewTool(client *Client, info ToolInfo) *Tool {
+	return &Tool{
+		client: client,
+		info:   info,
+		name:   ToolName(client.Name(), info.Name),
+	}
+}
+
+// ToolName returns the namespaced name an MCP tool is registered under:
+// "mcp_<server>_<tool>", restricted to characters every provider accepts.
+func ToolName(server, tool string) string {
+	name := "mcp_" + sanitizeName(server) + "_" + sanitizeName(tool)
+	if len(name) <= maxToolNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(server + "/" + tool))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	return name[:maxToolNameLength-len(suffix)] + suffix
+}
+
+func sanitizeName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// Server returns the name of the MCP server the tool belongs to.
+func (t *Tool) Server() string {
+	return t.client.Name()
+}
+
+func (t *Tool) Name() string {
+	return t.name
+}
+
+func (t *Tool) Description() string {
+	if t.info.Description != "" {
+		return t.info.Description
+	}
+	return fmt.Sprintf("Tool %q from MCP server %q", t.info.Name, t.client.Name())
+}
+
+func (t *Tool) Parameters() map[string]any {
+	if t.info.InputSchema == nil {
+		return map[string]any{"type": "object", "properties": map[string]any{}}
+	}
+	return t.info.InputSchema
+}
+
+// ParallelSafe reports true only for tools the server marks read-only; other
+// remote tools may have side effects that must stay in call order.
+func (t *Tool) ParallelSafe() bool {
+	return t.info.Annotations != nil && t.info.Annotations.ReadOnlyHint
+}
+
+func (t *Tool) Execute(ctx context.Context, args map[string]any) *tools.ToolResult {
+	res, err := t.client.CallTool(ctx, t.info.Name, args)
+	if err != nil {
+		return tools.ErrorResult(fmt.Sprintf("MCP tool %s failed: %v", t.name, err)).WithError(err)
+	}
+
+	text := formatContent(res)
+	if res.IsError {
+		return tools.ErrorResult(text)
+	}
+	return tools.NewToolResult(text)
+}
+
+// formatContent renders a tool result as text for the LLM. Binary content is
+// described rather than inlined.
+func formatContent(res *CallToolResult) string {
+	var parts []string
+	for _, c := range res.Content {
+		switch c.Type {
+		case "text":
+			parts = append(parts, c.Text)
+		case "image", "audio":
+			parts = append(parts, fmt.Sprintf("[%s: %s, %d bytes base64]", c.Type, c.MimeType, len(c.Data)))
+		case "resource":
+			if c.Resource == nil {
+				continue
+			}
+			if c.Resource.Text != "" {
+				parts = append(parts, c.Resource.Text)
+			} else {
+				parts = append(parts, fmt.Sprintf("[resource: %s]", c.Resource.URI))
+			}
+		default:
+			parts = append(parts, fmt.Sprintf("[%s content]", c.Type))
+		}
+	}
+
+	if len(parts) == 0 && res.StructuredContent != nil {
+		if data, err := json.Marshal(res.StructuredContent); err == nil {
+			return string(data)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,69 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// errNotDelivered marks transport failures that happened before the server
+// could see the request, so the client may reconnect and safely retry it.
+var errNotDelivered = errors.New("mcp: request not delivered")
+
+// errClosed is returned for requests on a connection that has shut down.
+var errClosed = fmt.Errorf("%w: connection closed", errNotDelivered)
+
+// transport carries JSON-RPC messages to one MCP server.
+type transport interface {
+	// call sends a request and returns the raw result of its response.
+	call(ctx context.Context, method string, params any) (json.RawMessage, error)
+	// notify sends a notification, which has no response.
+	notify(ctx context.Context, method string, params any) error
+	close() error
+}
+
+func newRequest(id int64, method string, params any) ([]byte, error) {
+	rawID := json.RawMessage(fmt.Sprintf("%d", id))
+	msg := message{JSONRPC: jsonrpcVersion, ID: &rawID, Method: method}
+	if params != nil {
+		p, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling %s params: %w", method, err)
+		}
+		msg.Params = p
+	}
+	return json.Marshal(msg)
+}
+
+func newNotification(method string, params any) ([]byte, error) {
+	msg := message{JSONRPC: jsonrpcVersion, Method: method}
+	if params != nil {
+		p, err := json.Marshal(params)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling %s params: %w", method, err)
+		}
+		msg.Params = p
+	}
+	return json.Marshal(msg)
+}
+
+// replyTo answers a request the server sent to the client. Only ping is
+// supported; anything else gets method-not-found.
+func replyTo(req *message) ([]byte, error) {
+	resp := message{JSONRPC: jsonrpcVersion, ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &RPCError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+	}
+	return json.Marshal(resp)
+}
+
+// result extracts the result or server error from a response.
+func result(resp *message) (json.RawMessage, error) {
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return resp.Result, nil
+}
@@ -0,0 +1,112 @@
+// Package memory implements an offline full-text index over an agent's
+// memory directory, ranked with BM25.
+package memory
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// BM25 parameters: k1 controls term-frequency saturation, b the strength of
+// document-length normalization.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "do": true, "for": true, "from": true,
+	"has": true, "have": true, "i": true, "if": true, "in": true, "is": true,
+	"it": true, "me": true, "my": true, "of": true, "on": true, "or": true,
+	"so": true, "that": true, "the": true, "this": true, "to": true, "was": true,
+	"we": true, "what": true, "when": true, "where": true, "which": true,
+	"who": true, "will": true, "with": true, "you": true, "your": true,
+}
+
+// isCJK reports whether r belongs to a script written without spaces, where
+// each character is indexed as its own term.
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// tokenize splits text into lowercase index terms: runs of letters and
+// digits, with CJK characters as single terms. Stop words are dropped and
+// plural "s" endings folded.
+func tokenize(text string) []string {
+	var terms []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() == 0 {
+			return
+		}
+		w := word.String()
+		word.Reset()
+		if stopWords[w] {
+			return
+		}
+		if len(w) > 3 && strings.HasSuffix(w, "s") && !strings.HasSuffix(w, "ss") {
+			w = w[:len(w)-1]
+		}
+		terms = append(terms, w)
+	}
+
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flush()
+			terms = append(terms, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flush()
+		}
+	}
+	flush()
+	return terms
+}
+
+// corpus holds the statistics BM25 needs across all chunks.
+type corpus struct {
+	docFreq  map[string]int
+	docs     int
+	totalLen int
+}
+
+func (c *corpus) add(terms map[string]int, length int) {
+	c.docs++
+	c.totalLen += length
+	for term := range terms {
+		c.docFreq[term]++
+	}
+}
+
+// score returns the BM25 score of a chunk with the given term frequencies
+// and length for the query terms.
+func (c *corpus) score(query []string, terms map[string]int, length int) float64 {
+	if c.docs == 0 {
+		return 0
+	}
+	avgLen := float64(c.totalLen) / float64(c.docs)
+	var s float64
+	for _, q := range query {
+		tf := float64(terms[q])
+		if tf == 0 {
+			continue
+		}
+		df := float64(c.docFreq[q])
+		idf := math.Log(1 + (float64(c.docs)-df+0.5)/(df+0.5))
+		s += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(length)/avgLen))
+	}
+	return s
+}
+
+// termCounts returns the frequency of each term.
+func termCounts(terms []string) map[string]int {
+	counts := make(map[string]int, len(terms))
+	for _, t := range terms {
+		counts[t]++
+	}
+	return counts
+}
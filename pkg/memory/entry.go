@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Entry is a structured memory, rendered as a markdown section so it is
+// readable in the memory files and indexed like any other note.
+type Entry struct {
+	Title   string
+	Content string
+	Tags    []string
+	// LongTerm files the entry in MEMORY.md instead of today's daily note.
+	LongTerm bool
+}
+
+// Format renders the entry as a markdown section. Untitled entries are
+// headed with the time they were written.
+func (e Entry) Format(now time.Time) string {
+	title := strings.TrimSpace(e.Title)
+	if title == "" {
+		title = now.Format("15:04")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "## %s\n\n%s\n", title, strings.TrimSpace(e.Content))
+	if len(e.Tags) > 0 {
+		tags := make([]string, 0, len(e.Tags))
+		for _, t := range e.Tags {
+			if t = strings.TrimSpace(strings.TrimPrefix(t, "#")); t != "" {
+				tags = append(tags, "#"+t)
+			}
+		}
+		if len(tags) > 0 {
+			fmt.Fprintf(&sb, "\nTags: %s\n", strings.Join(tags, " "))
+		}
+	}
+	return sb.String()
+}
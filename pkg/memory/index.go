@@ -0,0 +1,243 @@
+package memory
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chunks are cut at headings, and at paragraph breaks once they pass
+// chunkSoftLimit characters; no chunk grows past chunkHardLimit.
+const (
+	chunkSoftLimit = 800
+	chunkHardLimit = 1600
+)
+
+// Result is one matching chunk of a memory file.
+type Result struct {
+	// Path is relative to the indexed directory, with forward slashes.
+	Path string
+	// Line is the 1-based line the chunk starts on.
+	Line int
+	// Heading is the nearest markdown heading above the chunk, if any.
+	Heading string
+	Text    string
+	Score   float64
+}
+
+type chunk struct {
+	path    string
+	line    int
+	heading string
+	text    string
+	terms   map[string]int
+	length  int
+}
+
+type indexedFile struct {
+	modTime time.Time
+	size    int64
+	chunks  []chunk
+}
+
+// Index is a BM25 index over the markdown files under a directory. It is
+// refreshed on every search, re-reading only files whose size or
+// modification time changed, so edits made by any means are picked up.
+type Index struct {
+	dir    string
+	mu     sync.Mutex
+	files  map[string]*indexedFile
+	corpus *corpus
+}
+
+// NewIndex returns an index over the markdown files under dir.
+func NewIndex(dir string) *Index {
+	return &Index{dir: dir, files: map[string]*indexedFile{}}
+}
+
+// Search returns up to limit chunks ranked by relevance to query. Chunks
+// sharing no terms with the query are never returned.
+func (idx *Index) Search(query string, limit int) ([]Result, error) {
+	return idx.SearchFunc(query, limit, nil)
+}
+
+// SearchFunc is like Search but only considers files whose path keep
+// accepts. A nil keep accepts every file.
+func (idx *Index) SearchFunc(query string, limit int, keep func(path string) bool) ([]Result, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if err := idx.refreshLocked(); err != nil {
+		return nil, err
+	}
+
+	queryTerms := uniqueTerms(tokenize(query))
+	if len(queryTerms) == 0 || limit <= 0 {
+		return nil, nil
+	}
+
+	var results []Result
+	for path, f := range idx.files {
+		if keep != nil && !keep(path) {
+			continue
+		}
+		for _, c := range f.chunks {
+			score := idx.corpus.score(queryTerms, c.terms, c.length)
+			if score <= 0 {
+				continue
+			}
+			results = append(results, Result{
+				Path:    c.path,
+				Line:    c.line,
+				Heading: c.heading,
+				Text:    c.text,
+				Score:   score,
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		// Prefer newer files (daily notes sort by date) on ties.
+		if results[i].Path != results[j].Path {
+			return results[i].Path > results[j].Path
+		}
+		return results[i].Line < results[j].Line
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// refreshLocked re-reads changed files and rebuilds corpus statistics when
+// anything changed.
+func (idx *Index) refreshLocked() error {
+	seen := make(map[string]bool)
+	changed := idx.corpus == nil
+
+	err := filepath.WalkDir(idx.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == idx.dir && os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".md") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(idx.dir, path)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		seen[rel] = true
+
+		if f, ok := idx.files[rel]; ok && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		idx.files[rel] = &indexedFile{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+			chunks:  splitChunks(rel, string(data)),
+		}
+		changed = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for rel := range idx.files {
+		if !seen[rel] {
+			delete(idx.files, rel)
+			changed = true
+		}
+	}
+
+	if changed {
+		idx.corpus = &corpus{docFreq: map[string]int{}}
+		for _, f := range idx.files {
+			for _, c := range f.chunks {
+				idx.corpus.add(c.terms, c.length)
+			}
+		}
+	}
+	return nil
+}
+
+// splitChunks cuts a markdown file into indexable chunks.
+func splitChunks(path, content string) []chunk {
+	var chunks []chunk
+	var buf []string
+	heading := ""
+	start := 1
+	size := 0
+
+	emit := func(nextLine int) {
+		text := strings.TrimSpace(strings.Join(buf, "\n"))
+		if text != "" {
+			terms := tokenize(text)
+			chunks = append(chunks, chunk{
+				path:    path,
+				line:    start,
+				heading: heading,
+				text:    text,
+				terms:   termCounts(terms),
+				length:  len(terms),
+			})
+		}
+		buf = buf[:0]
+		size = 0
+		start = nextLine
+	}
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lineNo := i + 1
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "#"):
+			emit(lineNo)
+			heading = strings.TrimSpace(strings.TrimLeft(trimmed, "#"))
+		case trimmed == "" && size >= chunkSoftLimit:
+			emit(lineNo + 1)
+			continue
+		case size+len(line) > chunkHardLimit && size > 0:
+			emit(lineNo)
+		}
+		if len(buf) == 0 {
+			start = lineNo
+		}
+		buf = append(buf, line)
+		size += len(line) + 1
+	}
+	emit(len(lines) + 1)
+	return chunks
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := make(map[string]bool, len(terms))
+	out := terms[:0]
+	for _, t := range terms {
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	return out
+}
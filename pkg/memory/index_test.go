@@ -0,0 +1,126 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeNote(t *testing.T, dir, rel, content string) {
+	t.Helper()
+	path := filepath.Join(dir, rel)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIndex_RanksByRelevance(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "MEMORY.md", "# Memory\n\n## Preferences\n\nUser prefers Go for backend services.\n")
+	writeNote(t, dir, "202601/20260105.md", "# 2026-01-05\n\n## Trip\n\nBooked flights to Lisbon. Lisbon hotel near the river.\n")
+	writeNote(t, dir, "202601/20260106.md", "# 2026-01-06\n\nWorked on the garden.\n")
+
+	idx := NewIndex(dir)
+	results, err := idx.Search("Lisbon hotels", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d: %+v", len(results), results)
+	}
+	r := results[0]
+	if r.Path != "202601/20260105.md" || r.Heading != "Trip" || r.Line != 3 {
+		t.Errorf("unexpected result %+v", r)
+	}
+	if !strings.Contains(r.Text, "Lisbon hotel") {
+		t.Errorf("result text = %q", r.Text)
+	}
+}
+
+func TestIndex_NoMatchesAndStopWords(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "MEMORY.md", "The user is in the office.\n")
+
+	results, err := NewIndex(dir).Search("the is", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("stop-word query matched %d chunks", len(results))
+	}
+}
+
+func TestIndex_MissingDirectory(t *testing.T) {
+	results, err := NewIndex(filepath.Join(t.TempDir(), "missing")).Search("anything", 5)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Search = %v, %v; want no results, no error", results, err)
+	}
+}
+
+func TestIndex_RefreshesChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "MEMORY.md", "Favourite colour is blue.\n")
+	idx := NewIndex(dir)
+
+	if results, _ := idx.Search("green", 5); len(results) != 0 {
+		t.Fatalf("unexpected match before edit")
+	}
+
+	writeNote(t, dir, "MEMORY.md", "Favourite colour is now green.\n")
+	// Make sure the change is visible even on coarse mtime filesystems.
+	later := time.Now().Add(2 * time.Second)
+	os.Chtimes(filepath.Join(dir, "MEMORY.md"), later, later)
+
+	if results, _ := idx.Search("green", 5); len(results) != 1 {
+		t.Fatalf("edit not picked up, got %d results", len(results))
+	}
+
+	os.Remove(filepath.Join(dir, "MEMORY.md"))
+	if results, _ := idx.Search("green", 5); len(results) != 0 {
+		t.Fatalf("removed file still matched")
+	}
+}
+
+func TestIndex_CJK(t *testing.T) {
+	dir := t.TempDir()
+	writeNote(t, dir, "MEMORY.md", "## 偏好\n\n用户喜欢喝绿茶。\n\n## Other\n\nSomething unrelated.\n")
+
+	results, err := NewIndex(dir).Search("绿茶", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Heading != "偏好" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+}
+
+func TestSplitChunks_LongSections(t *testing.T) {
+	para := strings.Repeat("word ", 100) // 500 chars
+	content := "# Title\n\n" + para + "\n\n" + para + "\n\n" + para + "\n"
+	chunks := splitChunks("a.md", content)
+	if len(chunks) < 2 {
+		t.Fatalf("expected long section to be split, got %d chunk(s)", len(chunks))
+	}
+	for _, c := range chunks {
+		if len(c.text) > chunkHardLimit {
+			t.Errorf("chunk of %d chars exceeds hard limit", len(c.text))
+		}
+		if c.heading != "Title" {
+			t.Errorf("chunk heading = %q", c.heading)
+		}
+	}
+}
+
+func TestEntry_Format(t *testing.T) {
+	now := time.Date(2026, 1, 5, 9, 30, 0, 0, time.UTC)
+	got := Entry{Content: " Likes tea ", Tags: []string{"#drinks", " prefs", ""}}.Format(now)
+	want := "## 09:30\n\nLikes tea\n\nTags: #drinks #prefs\n"
+	if got != want {
+		t.Errorf("Format = %q, want %q", got, want)
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/anthropics/anthropic-sdk-go"
 	"github.com/anthropics/anthropic-sdk-go/option"
+	"github.com/anthropics/anthropic-sdk-go/packages/param"
 
 	"github.com/sipeed/picoclaw/pkg/providers/protocoltypes"
 )
@@ -21,6 +22,8 @@ type (
 	Message                = protocoltypes.Message
 	ToolDefinition         = protocoltypes.ToolDefinition
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
+	ContentPart            = protocoltypes.ContentPart
+	ResponseFormat         = protocoltypes.ResponseFormat
 )
 
 const defaultBaseURL = "https://api.anthropic.com"
@@ -90,7 +93,57 @@ func (p *Provider) Chat(
 		return nil, fmt.Errorf("claude API call: %w", err)
 	}
 
-	return parseResponse(resp), nil
+	out := parseResponse(resp)
+	protocoltypes.ResponseFormatFrom(options).TakeToolOutput(out)
+	return out, nil
+}
+
+// ChatStream is like Chat but uses the streaming Messages API, calling
+// onDelta with each text fragment as it arrives.
+func (p *Provider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	var opts []option.RequestOption
+	if p.tokenSource != nil {
+		tok, err := p.tokenSource()
+		if err != nil {
+			return nil, fmt.Errorf("refreshing token: %w", err)
+		}
+		opts = append(opts, option.WithAuthToken(tok))
+	}
+
+	params, err := buildParams(messages, tools, model, options)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := p.client.Messages.NewStreaming(ctx, params, opts...)
+	defer stream.Close()
+
+	message := anthropic.Message{}
+	for stream.Next() {
+		event := stream.Current()
+		if err := message.Accumulate(event); err != nil {
+			return nil, fmt.Errorf("claude stream: %w", err)
+		}
+		if event.Type == "content_block_delta" && onDelta != nil {
+			if delta := event.AsContentBlockDelta().Delta; delta.Type == "text_delta" && delta.Text != "" {
+				onDelta(delta.Text)
+			}
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return nil, fmt.Errorf("claude API call: %w", err)
+	}
+
+	out := parseResponse(&message)
+	protocoltypes.ResponseFormatFrom(options).TakeToolOutput(out)
+	return out, nil
 }
 
 func (p *Provider) GetDefaultModel() string {
@@ -132,6 +185,10 @@ func buildParams(
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewToolResultBlock(msg.ToolCallID, msg.Content, false)),
 				)
+			} else if len(msg.Parts) > 0 {
+				anthropicMessages = append(anthropicMessages,
+					anthropic.NewUserMessage(translateParts(msg.Parts)...),
+				)
 			} else {
 				anthropicMessages = append(anthropicMessages,
 					anthropic.NewUserMessage(anthropic.NewTextBlock(msg.Content)),
@@ -140,6 +197,10 @@ func buildParams(
 		case "assistant":
 			if len(msg.ToolCalls) > 0 {
 				var blocks []anthropic.ContentBlockParamUnion
+				// Thinking before tool calls has to be sent back with them.
+				if msg.ReasoningContent != "" && msg.ReasoningSignature != "" {
+					blocks = append(blocks, anthropic.NewThinkingBlock(msg.ReasoningSignature, msg.ReasoningContent))
+				}
 				if msg.Content != "" {
 					blocks = append(blocks, anthropic.NewTextBlock(msg.Content))
 				}
@@ -182,9 +243,78 @@ func buildParams(
 		params.Tools = translateTools(tools)
 	}
 
+	// Structured output is a forced call of a tool taking the schema as input.
+	if format := protocoltypes.ResponseFormatFrom(options); format != nil {
+		params.Tools = append(params.Tools, translateTools([]ToolDefinition{{
+			Type: "function",
+			Function: ToolFunctionDefinition{
+				Name:        format.Name,
+				Description: format.Description,
+				Parameters:  format.Schema,
+			},
+		}})...)
+		params.ToolChoice = anthropic.ToolChoiceUnionParam{
+			OfTool: &anthropic.ToolChoiceToolParam{Name: format.Name},
+		}
+	}
+
+	if reasoning := protocoltypes.ReasoningFrom(options); reasoning != nil {
+		applyThinking(&params, reasoning)
+	}
+
 	return params, nil
 }
 
+// applyThinking enables extended thinking with the reasoning's budget.
+// Thinking cannot be combined with a forced tool call, such as that of a
+// response format, and needs the default temperature.
+func applyThinking(params *anthropic.MessageNewParams, reasoning *protocoltypes.Reasoning) {
+	if reasoning.Off() || params.ToolChoice.OfTool != nil {
+		return
+	}
+	budget := int64(reasoning.BudgetTokens())
+	params.Thinking = anthropic.ThinkingConfigParamOfEnabled(budget)
+	// The budget counts toward max_tokens.
+	if params.MaxTokens <= budget {
+		params.MaxTokens += budget
+	}
+	params.Temperature = param.Opt[float64]{}
+}
+
+// translateParts maps multimodal parts to Anthropic content blocks.
+// Images and PDFs are sent natively; anything else (audio, other files)
+// degrades to a text placeholder because the Messages API cannot carry it.
+func translateParts(parts []ContentPart) []anthropic.ContentBlockParamUnion {
+	blocks := make([]anthropic.ContentBlockParamUnion, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case part.Type == "text":
+			blocks = append(blocks, anthropic.NewTextBlock(part.Text))
+		case part.Type == "image" && part.Data != "" && isSupportedImageType(part.MIMEType):
+			blocks = append(blocks, anthropic.NewImageBlockBase64(part.MIMEType, part.Data))
+		case part.Type == "image" && part.Data == "" && part.URL != "":
+			blocks = append(blocks, anthropic.NewImageBlock(anthropic.URLImageSourceParam{URL: part.URL}))
+		case part.Type == "file" && part.MIMEType == "application/pdf" && part.Data != "":
+			blocks = append(blocks, anthropic.NewDocumentBlock(anthropic.Base64PDFSourceParam{Data: part.Data}))
+		default:
+			blocks = append(blocks, anthropic.NewTextBlock(part.Placeholder()))
+		}
+	}
+	return blocks
+}
+
+func isSupportedImageType(mimeType string) bool {
+	switch anthropic.Base64ImageSourceMediaType(mimeType) {
+	case anthropic.Base64ImageSourceMediaTypeImageJPEG,
+		anthropic.Base64ImageSourceMediaTypeImagePNG,
+		anthropic.Base64ImageSourceMediaTypeImageGIF,
+		anthropic.Base64ImageSourceMediaTypeImageWebP:
+		return true
+	default:
+		return false
+	}
+}
+
 func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 	result := make([]anthropic.ToolUnionParam, 0, len(tools))
 	for _, t := range tools {
@@ -197,7 +327,8 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 		if desc := t.Function.Description; desc != "" {
 			tool.Description = anthropic.String(desc)
 		}
-		if req, ok := t.Function.Parameters["required"].([]any); ok {
+		switch req := t.Function.Parameters["required"].(type) {
+		case []any:
 			required := make([]string, 0, len(req))
 			for _, r := range req {
 				if s, ok := r.(string); ok {
@@ -205,6 +336,8 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 				}
 			}
 			tool.InputSchema.Required = required
+		case []string:
+			tool.InputSchema.Required = req
 		}
 		result = append(result, anthropic.ToolUnionParam{OfTool: &tool})
 	}
@@ -212,11 +345,15 @@ func translateTools(tools []ToolDefinition) []anthropic.ToolUnionParam {
 }
 
 func parseResponse(resp *anthropic.Message) *LLMResponse {
-	var content string
+	var content, reasoning, signature string
 	var toolCalls []ToolCall
 
 	for _, block := range resp.Content {
 		switch block.Type {
+		case "thinking":
+			tb := block.AsThinking()
+			reasoning += tb.Thinking
+			signature = tb.Signature
 		case "text":
 			tb := block.AsText()
 			content += tb.Text
@@ -246,9 +383,11 @@ func parseResponse(resp *anthropic.Message) *LLMResponse {
 	}
 
 	return &LLMResponse{
-		Content:      content,
-		ToolCalls:    toolCalls,
-		FinishReason: finishReason,
+		Content:            content,
+		ReasoningContent:   reasoning,
+		ReasoningSignature: signature,
+		ToolCalls:          toolCalls,
+		FinishReason:       finishReason,
 		Usage: &UsageInfo{
 			PromptTokens:     int(resp.Usage.InputTokens),
 			CompletionTokens: int(resp.Usage.OutputTokens),
@@ -77,6 +77,36 @@ func TestBuildParams_ToolCallMessage(t *testing.T) {
 	}
 }
 
+func TestBuildParams_MultimodalUserMessage(t *testing.T) {
+	messages := []Message{{
+		Role:    "user",
+		Content: "describe",
+		Parts: []ContentPart{
+			{Type: "text", Text: "describe"},
+			{Type: "image", MIMEType: "image/jpeg", Data: "aGVsbG8="},
+			{Type: "file", MIMEType: "application/pdf", Data: "aGVsbG8=", Filename: "a.pdf"},
+			{Type: "audio", MIMEType: "audio/ogg", Data: "aGVsbG8=", Filename: "v.ogg"},
+		},
+	}}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	blocks := params.Messages[0].Content
+	if len(blocks) != 4 {
+		t.Fatalf("len(Content) = %d, want 4", len(blocks))
+	}
+	if blocks[1].OfImage == nil || blocks[1].OfImage.Source.OfBase64 == nil {
+		t.Fatalf("Content[1] is not a base64 image block")
+	}
+	if blocks[2].OfDocument == nil {
+		t.Fatalf("Content[2] is not a document block")
+	}
+	if blocks[3].OfText == nil || blocks[3].OfText.Text != "[audio: v.ogg]" {
+		t.Fatalf("Content[3] should be an audio placeholder")
+	}
+}
+
 func TestBuildParams_WithTools(t *testing.T) {
 	tools := []ToolDefinition{
 		{
@@ -103,6 +133,31 @@ func TestBuildParams_WithTools(t *testing.T) {
 	}
 }
 
+func TestBuildParams_ResponseFormatForcesTool(t *testing.T) {
+	format := ResponseFormat{
+		Name: "place",
+		Schema: map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"city": map[string]any{"type": "string"}},
+			"required":   []string{"city"},
+		},
+	}
+	params, err := buildParams([]Message{{Role: "user", Content: "where?"}}, nil, "claude-sonnet-4.6",
+		map[string]any{"response_format": format})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if len(params.Tools) != 1 || params.Tools[0].OfTool.Name != "place" {
+		t.Fatalf("Tools = %+v", params.Tools)
+	}
+	if req := params.Tools[0].OfTool.InputSchema.Required; len(req) != 1 || req[0] != "city" {
+		t.Errorf("Required = %v", req)
+	}
+	if params.ToolChoice.OfTool == nil || params.ToolChoice.OfTool.Name != "place" {
+		t.Errorf("ToolChoice = %+v", params.ToolChoice)
+	}
+}
+
 func TestParseResponse_TextOnly(t *testing.T) {
 	resp := &anthropic.Message{
 		Content: []anthropic.ContentBlockUnion{},
@@ -269,3 +324,110 @@ func createAnthropicTestClient(baseURL, token string) *anthropic.Client {
 	)
 	return &c
 }
+
+func TestProvider_ChatStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var reqBody map[string]any
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		if reqBody["stream"] != true {
+			http.Error(w, "expected stream", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4.6","content":[],"usage":{"input_tokens":10,"output_tokens":0}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi "}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"there"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, e := range events {
+			var typed struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal([]byte(e), &typed)
+			w.Write([]byte("event: " + typed.Type + "\ndata: " + e + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	var deltas []string
+	provider := NewProviderWithClient(createAnthropicTestClient(server.URL, "test-token"))
+	resp, err := provider.ChatStream(t.Context(), []Message{{Role: "user", Content: "Hello"}}, nil,
+		"claude-sonnet-4.6", map[string]any{"max_tokens": 1024},
+		func(delta string) { deltas = append(deltas, delta) })
+	if err != nil {
+		t.Fatalf("ChatStream() error: %v", err)
+	}
+	if len(deltas) != 2 || deltas[0] != "Hi " || deltas[1] != "there" {
+		t.Errorf("deltas = %q, want [\"Hi \" \"there\"]", deltas)
+	}
+	if resp.Content != "Hi there" {
+		t.Errorf("Content = %q, want %q", resp.Content, "Hi there")
+	}
+	if resp.Usage.PromptTokens != 10 || resp.Usage.CompletionTokens != 4 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestBuildParams_Thinking(t *testing.T) {
+	params, err := buildParams([]Message{{Role: "user", Content: "why?"}}, nil, "claude-sonnet-4.6",
+		map[string]any{"thinking_budget": 5000, "max_tokens": 4096, "temperature": 0.7})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	if params.Thinking.OfEnabled == nil || params.Thinking.OfEnabled.BudgetTokens != 5000 {
+		t.Fatalf("Thinking = %+v", params.Thinking)
+	}
+	if params.MaxTokens != 9096 {
+		t.Errorf("MaxTokens = %d, want room for the budget", params.MaxTokens)
+	}
+	if params.Temperature.Valid() {
+		t.Error("temperature must be left out with thinking")
+	}
+
+	params, _ = buildParams([]Message{{Role: "user", Content: "why?"}}, nil, "claude-sonnet-4.6",
+		map[string]any{"reasoning_effort": "none"})
+	if params.Thinking.OfEnabled != nil {
+		t.Error("thinking enabled with effort none")
+	}
+}
+
+func TestBuildParams_ThinkingSentBackWithToolCalls(t *testing.T) {
+	messages := []Message{
+		{Role: "user", Content: "weather?"},
+		{
+			Role: "assistant", ReasoningContent: "need the tool", ReasoningSignature: "sig",
+			ToolCalls: []ToolCall{{ID: "call_1", Name: "weather", Arguments: map[string]any{}}},
+		},
+		{Role: "tool", ToolCallID: "call_1", Content: "sunny"},
+	}
+	params, err := buildParams(messages, nil, "claude-sonnet-4.6", map[string]any{"reasoning_effort": "low"})
+	if err != nil {
+		t.Fatalf("buildParams() error: %v", err)
+	}
+	blocks := params.Messages[1].Content
+	if len(blocks) != 2 || blocks[0].OfThinking == nil || blocks[0].OfThinking.Signature != "sig" {
+		t.Fatalf("assistant blocks = %+v", blocks)
+	}
+}
+
+func TestParseResponse_Thinking(t *testing.T) {
+	var resp anthropic.Message
+	if err := json.Unmarshal([]byte(`{"content":[`+
+		`{"type":"thinking","thinking":"6 times 7","signature":"sig"},`+
+		`{"type":"text","text":"42"}],"stop_reason":"end_turn"}`), &resp); err != nil {
+		t.Fatal(err)
+	}
+	out := parseResponse(&resp)
+	if out.Content != "42" || out.ReasoningContent != "6 times 7" || out.ReasoningSignature != "sig" {
+		t.Errorf("parseResponse() = %+v", out)
+	}
+}
@@ -154,11 +154,14 @@ func (p *AntigravityProvider) GetDefaultModel() string {
 
 // --- Request building ---
 
+// antigravityRequest is a Gemini generateContent request, sent as is by
+// GeminiProvider and wrapped in an envelope by AntigravityProvider.
 type antigravityRequest struct {
-	Contents     []antigravityContent     `json:"contents"`
-	Tools        []antigravityTool        `json:"tools,omitempty"`
-	SystemPrompt *antigravitySystemPrompt `json:"systemInstruction,omitempty"`
-	Config       *antigravityGenConfig    `json:"generationConfig,omitempty"`
+	Contents       []antigravityContent     `json:"contents"`
+	Tools          []antigravityTool        `json:"tools,omitempty"`
+	SystemPrompt   *antigravitySystemPrompt `json:"systemInstruction,omitempty"`
+	Config         *antigravityGenConfig    `json:"generationConfig,omitempty"`
+	SafetySettings []geminiSafetySetting    `json:"safetySettings,omitempty"`
 }
 
 type antigravityContent struct {
@@ -168,12 +171,25 @@ type antigravityContent struct {
 
 type antigravityPart struct {
 	Text                  string                       `json:"text,omitempty"`
+	InlineData            *geminiInlineData            `json:"inlineData,omitempty"`
 	ThoughtSignature      string                       `json:"thoughtSignature,omitempty"`
 	ThoughtSignatureSnake string                       `json:"thought_signature,omitempty"`
 	FunctionCall          *antigravityFunctionCall     `json:"functionCall,omitempty"`
 	FunctionResponse      *antigravityFunctionResponse `json:"functionResponse,omitempty"`
 }
 
+// geminiInlineData is a base64-encoded media part.
+type geminiInlineData struct {
+	MIMEType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// geminiSafetySetting sets the blocking threshold of one harm category.
+type geminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
 type antigravityFunctionCall struct {
 	Name string         `json:"name"`
 	Args map[string]any `json:"args"`
@@ -199,8 +215,19 @@ type antigravitySystemPrompt struct {
 }
 
 type antigravityGenConfig struct {
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
-	Temperature     float64 `json:"temperature,omitempty"`
+	MaxOutputTokens  int             `json:"maxOutputTokens,omitempty"`
+	Temperature      float64         `json:"temperature,omitempty"`
+	ResponseMIMEType string          `json:"responseMimeType,omitempty"`
+	ResponseSchema   map[string]any  `json:"responseSchema,omitempty"`
+	ThinkingConfig   *geminiThinking `json:"thinkingConfig,omitempty"`
+}
+
+// geminiThinking sets how many tokens the model may think with; a budget of
+// 0 turns thinking off. Thoughts are included so they reach the response's
+// reasoning.
+type geminiThinking struct {
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
 }
 
 func (p *AntigravityProvider) buildRequest(
@@ -208,6 +235,16 @@ func (p *AntigravityProvider) buildRequest(
 	tools []ToolDefinition,
 	model string,
 	options map[string]any,
+) antigravityRequest {
+	return buildGeminiRequest(messages, tools, options)
+}
+
+// buildGeminiRequest converts messages, tools and options to a Gemini
+// generateContent request.
+func buildGeminiRequest(
+	messages []Message,
+	tools []ToolDefinition,
+	options map[string]any,
 ) antigravityRequest {
 	req := antigravityRequest{}
 	toolCallNames := make(map[string]string)
@@ -223,16 +260,11 @@ func (p *AntigravityProvider) buildRequest(
 			if msg.ToolCallID != "" {
 				toolName := resolveToolResponseName(msg.ToolCallID, toolCallNames)
 				// Tool result
+				req.Contents = appendFunctionResponse(req.Contents, toolName, msg.Content)
+			} else if len(msg.Parts) > 0 {
 				req.Contents = append(req.Contents, antigravityContent{
-					Role: "user",
-					Parts: []antigravityPart{{
-						FunctionResponse: &antigravityFunctionResponse{
-							Name: toolName,
-							Response: map[string]any{
-								"result": msg.Content,
-							},
-						},
-					}},
+					Role:  "user",
+					Parts: geminiParts(msg.Parts),
 				})
 			} else {
 				req.Contents = append(req.Contents, antigravityContent{
@@ -276,17 +308,7 @@ func (p *AntigravityProvider) buildRequest(
 			}
 		case "tool":
 			toolName := resolveToolResponseName(msg.ToolCallID, toolCallNames)
-			req.Contents = append(req.Contents, antigravityContent{
-				Role: "user",
-				Parts: []antigravityPart{{
-					FunctionResponse: &antigravityFunctionResponse{
-						Name: toolName,
-						Response: map[string]any{
-							"result": msg.Content,
-						},
-					},
-				}},
-			})
+			req.Contents = appendFunctionResponse(req.Contents, toolName, msg.Content)
 		}
 	}
 
@@ -321,13 +343,59 @@ func (p *AntigravityProvider) buildRequest(
 	if temp, ok := options["temperature"].(float64); ok {
 		config.Temperature = temp
 	}
-	if config.MaxOutputTokens > 0 || config.Temperature > 0 {
+	if format := ResponseFormatFrom(options); format != nil {
+		config.ResponseMIMEType = "application/json"
+		config.ResponseSchema = sanitizeSchemaForGemini(format.Schema)
+	}
+	if reasoning := ReasoningFrom(options); reasoning != nil {
+		budget := reasoning.BudgetTokens()
+		config.ThinkingConfig = &geminiThinking{ThinkingBudget: &budget, IncludeThoughts: budget > 0}
+	}
+	if config.MaxOutputTokens > 0 || config.Temperature > 0 || config.ResponseSchema != nil ||
+		config.ThinkingConfig != nil {
 		req.Config = config
 	}
 
 	return req
 }
 
+// appendFunctionResponse adds a tool result to contents. Results of parallel
+// calls share one turn, as Gemini expects one response part per call.
+func appendFunctionResponse(contents []antigravityContent, name, result string) []antigravityContent {
+	part := antigravityPart{
+		FunctionResponse: &antigravityFunctionResponse{
+			Name:     name,
+			Response: map[string]any{"result": result},
+		},
+	}
+	if n := len(contents); n > 0 && contents[n-1].Role == "user" &&
+		len(contents[n-1].Parts) > 0 && contents[n-1].Parts[0].FunctionResponse != nil {
+		contents[n-1].Parts = append(contents[n-1].Parts, part)
+		return contents
+	}
+	return append(contents, antigravityContent{Role: "user", Parts: []antigravityPart{part}})
+}
+
+// geminiParts maps multimodal parts to Gemini parts. Media that was not
+// inlined degrades to a text placeholder, since Gemini only fetches files
+// it stores itself.
+func geminiParts(parts []ContentPart) []antigravityPart {
+	out := make([]antigravityPart, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case part.Type == "text":
+			out = append(out, antigravityPart{Text: part.Text})
+		case part.Data != "" && part.MIMEType != "":
+			out = append(out, antigravityPart{
+				InlineData: &geminiInlineData{MIMEType: part.MIMEType, Data: part.Data},
+			})
+		default:
+			out = append(out, antigravityPart{Text: part.Placeholder()})
+		}
+	}
+	return out
+}
+
 func normalizeStoredToolCall(tc ToolCall) (string, map[string]any, string) {
 	name := tc.Name
 	args := tc.Arguments
@@ -389,6 +457,7 @@ type antigravityJSONResponse struct {
 		Content struct {
 			Parts []struct {
 				Text                  string                   `json:"text,omitempty"`
+				Thought               bool                     `json:"thought,omitempty"`
 				ThoughtSignature      string                   `json:"thoughtSignature,omitempty"`
 				ThoughtSignatureSnake string                   `json:"thought_signature,omitempty"`
 				FunctionCall          *antigravityFunctionCall `json:"functionCall,omitempty"`
@@ -397,18 +466,19 @@ type antigravityJSONResponse struct {
 		} `json:"content"`
 		FinishReason string `json:"finishReason"`
 	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
 	UsageMetadata struct {
 		PromptTokenCount     int `json:"promptTokenCount"`
 		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
 		TotalTokenCount      int `json:"totalTokenCount"`
 	} `json:"usageMetadata"`
 }
 
 func (p *AntigravityProvider) parseSSEResponse(body string) (*LLMResponse, error) {
-	var contentParts []string
-	var toolCalls []ToolCall
-	var usage *UsageInfo
-	var finishReason string
+	var acc geminiAccumulator
 
 	scanner := bufio.NewScanner(strings.NewReader(body))
 	for scanner.Scan() {
@@ -428,58 +498,90 @@ func (p *AntigravityProvider) parseSSEResponse(body string) (*LLMResponse, error
 		if err := json.Unmarshal([]byte(data), &sseChunk); err != nil {
 			continue
 		}
-		resp := sseChunk.Response
+		acc.add(sseChunk.Response, nil)
+	}
 
-		for _, candidate := range resp.Candidates {
-			for _, part := range candidate.Content.Parts {
-				if part.Text != "" {
-					contentParts = append(contentParts, part.Text)
-				}
-				if part.FunctionCall != nil {
-					argumentsJSON, _ := json.Marshal(part.FunctionCall.Args)
-					toolCalls = append(toolCalls, ToolCall{
-						ID:        fmt.Sprintf("call_%s_%d", part.FunctionCall.Name, time.Now().UnixNano()),
-						Name:      part.FunctionCall.Name,
-						Arguments: part.FunctionCall.Args,
-						Function: &FunctionCall{
-							Name:      part.FunctionCall.Name,
-							Arguments: string(argumentsJSON),
-							ThoughtSignature: extractPartThoughtSignature(
-								part.ThoughtSignature,
-								part.ThoughtSignatureSnake,
-							),
-						},
-					})
+	return acc.response(), nil
+}
+
+// geminiAccumulator collects the chunks of a Gemini response, streamed or
+// not, into one LLMResponse.
+type geminiAccumulator struct {
+	content      strings.Builder
+	reasoning    strings.Builder
+	toolCalls    []ToolCall
+	usage        *UsageInfo
+	finishReason string
+	blockReason  string
+}
+
+// add collects one chunk, passing its answer text to onDelta when set.
+func (a *geminiAccumulator) add(resp antigravityJSONResponse, onDelta func(delta string)) {
+	for _, candidate := range resp.Candidates {
+		for _, part := range candidate.Content.Parts {
+			switch {
+			case part.Thought:
+				a.reasoning.WriteString(part.Text)
+			case part.Text != "":
+				a.content.WriteString(part.Text)
+				if onDelta != nil {
+					onDelta(part.Text)
 				}
 			}
-			if candidate.FinishReason != "" {
-				finishReason = candidate.FinishReason
+			if part.FunctionCall != nil {
+				argumentsJSON, _ := json.Marshal(part.FunctionCall.Args)
+				a.toolCalls = append(a.toolCalls, ToolCall{
+					ID:        fmt.Sprintf("call_%s_%d", part.FunctionCall.Name, time.Now().UnixNano()),
+					Name:      part.FunctionCall.Name,
+					Arguments: part.FunctionCall.Args,
+					Function: &FunctionCall{
+						Name:      part.FunctionCall.Name,
+						Arguments: string(argumentsJSON),
+						ThoughtSignature: extractPartThoughtSignature(
+							part.ThoughtSignature,
+							part.ThoughtSignatureSnake,
+						),
+					},
+				})
 			}
 		}
+		if candidate.FinishReason != "" {
+			a.finishReason = candidate.FinishReason
+		}
+	}
+	if resp.PromptFeedback.BlockReason != "" {
+		a.blockReason = resp.PromptFeedback.BlockReason
+	}
 
-		if resp.UsageMetadata.TotalTokenCount > 0 {
-			usage = &UsageInfo{
-				PromptTokens:     resp.UsageMetadata.PromptTokenCount,
-				CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
-				TotalTokens:      resp.UsageMetadata.TotalTokenCount,
-			}
+	if meta := resp.UsageMetadata; meta.TotalTokenCount > 0 {
+		// Thinking tokens are billed as output.
+		a.usage = &UsageInfo{
+			PromptTokens:     meta.PromptTokenCount,
+			CompletionTokens: meta.CandidatesTokenCount + meta.ThoughtsTokenCount,
+			TotalTokens:      meta.TotalTokenCount,
 		}
 	}
+}
 
+func (a *geminiAccumulator) response() *LLMResponse {
 	mappedFinish := "stop"
-	if len(toolCalls) > 0 {
+	if len(a.toolCalls) > 0 {
 		mappedFinish = "tool_calls"
 	}
-	if finishReason == "MAX_TOKENS" {
+	switch a.finishReason {
+	case "MAX_TOKENS":
 		mappedFinish = "length"
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII":
+		mappedFinish = "content_filter"
 	}
 
 	return &LLMResponse{
-		Content:      strings.Join(contentParts, ""),
-		ToolCalls:    toolCalls,
-		FinishReason: mappedFinish,
-		Usage:        usage,
-	}, nil
+		Content:          a.content.String(),
+		ReasoningContent: a.reasoning.String(),
+		ToolCalls:        a.toolCalls,
+		FinishReason:     mappedFinish,
+		Usage:            a.usage,
+	}
 }
 
 func extractPartThoughtSignature(thoughtSignature string, thoughtSignatureSnake string) string {
@@ -0,0 +1,225 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// BalancedProvider spreads requests over several model_list entries sharing
+// a model_name, e.g. one model behind several API keys or endpoints. Every
+// request goes to the healthy entry with the fewest requests in flight for
+// its weight; idle entries take turns in proportion to their weights.
+// Entries failing with rate limit, auth, billing or overload errors cool
+// down in a CooldownTracker and the request is retried on another entry.
+type BalancedProvider struct {
+	modelName string
+	entries   []*balancedEntry
+	cooldown  *CooldownTracker
+
+	mu sync.Mutex // guards the entries' inFlight and current
+}
+
+type balancedEntry struct {
+	key      string // cooldown key, model_name[index]
+	provider LLMProvider
+	modelID  string
+	weight   int
+
+	inFlight int
+	current  int // smooth weighted round-robin state
+}
+
+// NewBalancedProvider creates the providers for entries, which share a
+// model_name.
+func NewBalancedProvider(entries []config.ModelConfig) (*BalancedProvider, error) {
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no model entries to balance")
+	}
+	b := &BalancedProvider{
+		modelName: entries[0].ModelName,
+		cooldown:  NewCooldownTracker(),
+	}
+	for i := range entries {
+		provider, modelID, err := CreateProviderFromConfig(&entries[i])
+		if err != nil {
+			return nil, fmt.Errorf("model_list entry %d of %q: %w", i, b.modelName, err)
+		}
+		b.entries = append(b.entries, &balancedEntry{
+			key:      fmt.Sprintf("%s[%d]", b.modelName, i),
+			provider: provider,
+			modelID:  modelID,
+			weight:   max(entries[i].Weight, 1),
+		})
+	}
+	return b, nil
+}
+
+func (b *BalancedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	return b.do(ctx, model, func(e *balancedEntry, model string) (*LLMResponse, error) {
+		return e.provider.Chat(ctx, messages, tools, model, options)
+	})
+}
+
+// ChatStream streams from the chosen entry when it supports streaming. A
+// failed request is only retried elsewhere if nothing was streamed yet.
+func (b *BalancedProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	streamed := false
+	resp, err := b.do(ctx, model, func(e *balancedEntry, model string) (*LLMResponse, error) {
+		if streamed {
+			return nil, errStreamStarted
+		}
+		sp, ok := e.provider.(StreamingProvider)
+		if !ok {
+			return e.provider.Chat(ctx, messages, tools, model, options)
+		}
+		return sp.ChatStream(ctx, messages, tools, model, options, func(delta string) {
+			streamed = true
+			onDelta(delta)
+		})
+	})
+	return resp, err
+}
+
+var errStreamStarted = errors.New("response already partially streamed")
+
+func (b *BalancedProvider) GetDefaultModel() string {
+	return b.entries[0].modelID
+}
+
+// Close closes the entries' providers that hold resources.
+func (b *BalancedProvider) Close() {
+	for _, e := range b.entries {
+		if sp, ok := e.provider.(StatefulProvider); ok {
+			sp.Close()
+		}
+	}
+}
+
+// do runs call on one entry after another until one succeeds, an error is
+// not worth retrying, or every entry was tried.
+func (b *BalancedProvider) do(
+	ctx context.Context,
+	model string,
+	call func(e *balancedEntry, model string) (*LLMResponse, error),
+) (*LLMResponse, error) {
+	tried := make(map[*balancedEntry]bool, len(b.entries))
+	var lastErr error
+	for len(tried) < len(b.entries) {
+		e := b.pick(tried)
+		tried[e] = true
+
+		resp, err := call(e, b.modelFor(e, model))
+		b.release(e)
+		if err == nil {
+			b.cooldown.MarkSuccess(e.key)
+			return resp, nil
+		}
+		if errors.Is(err, errStreamStarted) {
+			return nil, lastErr
+		}
+		lastErr = err
+
+		failErr := ClassifyError(err, e.key, model)
+		if failErr == nil || !failErr.IsRetriable() || ctx.Err() != nil {
+			return nil, err
+		}
+		b.cooldown.MarkFailure(e.key, failErr.Reason)
+		logger.WarnCF("provider", "Model entry failed, trying another",
+			map[string]any{
+				"entry":  e.key,
+				"reason": string(failErr.Reason),
+				"error":  err.Error(),
+			})
+	}
+	return nil, lastErr
+}
+
+// pick reserves the entry for the next request among those not tried yet:
+// the least loaded healthy entry, by smooth weighted round-robin among
+// equally loaded ones. If every entry is cooling down, the one recovering
+// first is used rather than failing outright.
+func (b *BalancedProvider) pick(tried map[*balancedEntry]bool) *balancedEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var healthy []*balancedEntry
+	var soonest *balancedEntry
+	for _, e := range b.entries {
+		if tried[e] {
+			continue
+		}
+		if b.cooldown.IsAvailable(e.key) {
+			healthy = append(healthy, e)
+		} else if soonest == nil || b.cooldown.CooldownRemaining(e.key) < b.cooldown.CooldownRemaining(soonest.key) {
+			soonest = e
+		}
+	}
+	if len(healthy) == 0 {
+		soonest.inFlight++
+		return soonest
+	}
+
+	// Keep the least loaded entries, comparing inFlight/weight by cross
+	// multiplication.
+	least := []*balancedEntry{healthy[0]}
+	for _, e := range healthy[1:] {
+		switch l := least[0]; {
+		case e.inFlight*l.weight < l.inFlight*e.weight:
+			least = []*balancedEntry{e}
+		case e.inFlight*l.weight == l.inFlight*e.weight:
+			least = append(least, e)
+		}
+	}
+
+	var chosen *balancedEntry
+	total := 0
+	for _, e := range least {
+		e.current += e.weight
+		total += e.weight
+		if chosen == nil || e.current > chosen.current {
+			chosen = e
+		}
+	}
+	chosen.current -= total
+	chosen.inFlight++
+	return chosen
+}
+
+func (b *BalancedProvider) release(e *balancedEntry) {
+	b.mu.Lock()
+	e.inFlight--
+	b.mu.Unlock()
+}
+
+// modelFor maps a request for the balanced model to the entry's model ID.
+// Other models, e.g. fallbacks sent through the same provider, are passed
+// on unchanged.
+func (b *BalancedProvider) modelFor(e *balancedEntry, model string) string {
+	if model == b.modelName {
+		return e.modelID
+	}
+	for _, other := range b.entries {
+		if other.modelID == model {
+			return e.modelID
+		}
+	}
+	return model
+}
@@ -0,0 +1,199 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// entryProvider answers as its name, failing with err when set and blocking
+// on gate when set.
+type entryProvider struct {
+	name string
+	err  error
+	gate chan struct{}
+
+	mu     sync.Mutex
+	models []string
+}
+
+func (p *entryProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	p.mu.Lock()
+	p.models = append(p.models, model)
+	p.mu.Unlock()
+	if p.gate != nil {
+		<-p.gate
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &LLMResponse{Content: p.name}, nil
+}
+
+func (p *entryProvider) GetDefaultModel() string { return p.name }
+
+func newTestBalancer(entries ...*balancedEntry) *BalancedProvider {
+	b := &BalancedProvider{modelName: "gpt", cooldown: NewCooldownTracker()}
+	for _, e := range entries {
+		e.key = e.provider.GetDefaultModel()
+		if e.weight == 0 {
+			e.weight = 1
+		}
+		if e.modelID == "" {
+			e.modelID = "gpt-4o"
+		}
+		b.entries = append(b.entries, e)
+	}
+	return b
+}
+
+func countAnswers(t *testing.T, b *BalancedProvider, n int) map[string]int {
+	t.Helper()
+	counts := map[string]int{}
+	for range n {
+		resp, err := b.Chat(context.Background(), nil, nil, "gpt", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[resp.Content]++
+	}
+	return counts
+}
+
+func TestBalancedProvider_Weights(t *testing.T) {
+	b := newTestBalancer(
+		&balancedEntry{provider: &entryProvider{name: "a"}, weight: 3},
+		&balancedEntry{provider: &entryProvider{name: "b"}},
+	)
+	if counts := countAnswers(t, b, 8); counts["a"] != 6 || counts["b"] != 2 {
+		t.Errorf("answers = %v, want a:6 b:2", counts)
+	}
+}
+
+func TestBalancedProvider_PrefersIdleEntry(t *testing.T) {
+	gate := make(chan struct{})
+	slow := &entryProvider{name: "slow", gate: gate}
+	b := newTestBalancer(
+		&balancedEntry{provider: slow},
+		&balancedEntry{provider: &entryProvider{name: "idle"}},
+	)
+
+	done := make(chan string)
+	go func() {
+		resp, _ := b.Chat(context.Background(), nil, nil, "gpt", nil)
+		done <- resp.Content
+	}()
+	// Wait until the first request is in flight on the slow entry.
+	for {
+		slow.mu.Lock()
+		n := len(slow.models)
+		slow.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if counts := countAnswers(t, b, 3); counts["idle"] != 3 {
+		t.Errorf("answers while slow is busy = %v", counts)
+	}
+	close(gate)
+	if got := <-done; got != "slow" {
+		t.Errorf("first request answered by %q", got)
+	}
+}
+
+func TestBalancedProvider_SkipsFailingEntry(t *testing.T) {
+	failing := &entryProvider{name: "limited", err: errors.New("HTTP 429: rate limit exceeded")}
+	b := newTestBalancer(
+		&balancedEntry{provider: failing},
+		&balancedEntry{provider: &entryProvider{name: "healthy"}},
+	)
+
+	if counts := countAnswers(t, b, 4); counts["healthy"] != 4 {
+		t.Errorf("answers = %v", counts)
+	}
+	if len(failing.models) != 1 {
+		t.Errorf("failing entry tried %d times, want once before cooling down", len(failing.models))
+	}
+	if b.cooldown.IsAvailable("limited") {
+		t.Error("failing entry not cooling down")
+	}
+}
+
+func TestBalancedProvider_ErrorsNotRetried(t *testing.T) {
+	bad := &entryProvider{name: "a", err: errors.New("HTTP 400: invalid request format")}
+	other := &entryProvider{name: "b", err: errors.New("HTTP 400: invalid request format")}
+	b := newTestBalancer(&balancedEntry{provider: bad}, &balancedEntry{provider: other})
+
+	if _, err := b.Chat(context.Background(), nil, nil, "gpt", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if calls := len(bad.models) + len(other.models); calls != 1 {
+		t.Errorf("format error tried on %d entries, want 1", calls)
+	}
+
+	all := newTestBalancer(
+		&balancedEntry{provider: &entryProvider{name: "x", err: errors.New("503 overloaded")}},
+		&balancedEntry{provider: &entryProvider{name: "y", err: errors.New("503 overloaded")}},
+	)
+	if _, err := all.Chat(context.Background(), nil, nil, "gpt", nil); err == nil {
+		t.Error("expected the last entry's error when all fail")
+	}
+}
+
+func TestBalancedProvider_MapsModel(t *testing.T) {
+	a := &entryProvider{name: "a"}
+	b := newTestBalancer(&balancedEntry{provider: a, modelID: "gpt-4o-2024"})
+
+	for _, model := range []string{"gpt", "gpt-4o-2024", "other-model"} {
+		if _, err := b.Chat(context.Background(), nil, nil, model, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{"gpt-4o-2024", "gpt-4o-2024", "other-model"}
+	for i, m := range a.models {
+		if m != want[i] {
+			t.Errorf("request %d sent as %q, want %q", i, m, want[i])
+		}
+	}
+}
+
+func TestCreateProvider_BalancesDuplicateEntries(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.ModelName = "balanced"
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "balanced", Model: "openai/gpt-4o", APIKey: "k1"},
+		{ModelName: "balanced", Model: "openai/gpt-4o", APIKey: "k2", Weight: 2},
+		{ModelName: "single", Model: "openai/gpt-4o-mini", APIKey: "k3"},
+	}
+
+	provider, modelID, err := CreateProvider(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok := provider.(*BalancedProvider)
+	if !ok {
+		t.Fatalf("provider is %T, want *BalancedProvider", provider)
+	}
+	if modelID != "gpt-4o" || len(b.entries) != 2 || b.entries[1].weight != 2 {
+		t.Errorf("model %q, entries %d", modelID, len(b.entries))
+	}
+
+	cfg.Agents.Defaults.ModelName = "single"
+	if provider, _, err = CreateProvider(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := provider.(*BalancedProvider); ok {
+		t.Error("single entry was balanced")
+	}
+}
@@ -224,6 +224,15 @@ func buildCodexParams(
 						},
 					},
 				})
+			} else if len(msg.Parts) > 0 {
+				inputItems = append(inputItems, responses.ResponseInputItemUnionParam{
+					OfMessage: &responses.EasyInputMessageParam{
+						Role: responses.EasyInputMessageRoleUser,
+						Content: responses.EasyInputMessageContentUnionParam{
+							OfInputItemContentList: translatePartsForCodex(msg.Parts),
+						},
+					},
+				})
 			} else {
 				inputItems = append(inputItems, responses.ResponseInputItemUnionParam{
 					OfMessage: &responses.EasyInputMessageParam{
@@ -305,9 +314,59 @@ func buildCodexParams(
 		params.Tools = translateToolsForCodex(tools, enableWebSearch)
 	}
 
+	if format := ResponseFormatFrom(options); format != nil {
+		schema := &responses.ResponseFormatTextJSONSchemaConfigParam{
+			Name:   format.Name,
+			Schema: format.Schema,
+			Strict: openai.Opt(format.Strict),
+		}
+		if format.Description != "" {
+			schema.Description = openai.Opt(format.Description)
+		}
+		params.Text = responses.ResponseTextConfigParam{
+			Format: responses.ResponseFormatTextConfigUnionParam{OfJSONSchema: schema},
+		}
+	}
+
+	if reasoning := ReasoningFrom(options); reasoning != nil {
+		params.Reasoning = openai.ReasoningParam{
+			Effort:  openai.ReasoningEffort(reasoning.EffortLevel()),
+			Summary: openai.ReasoningSummaryAuto,
+		}
+		if reasoning.Off() {
+			params.Reasoning.Summary = ""
+		}
+	}
+
 	return params
 }
 
+// translatePartsForCodex maps multimodal parts to Responses API input content.
+// Audio has no input type in the Responses API and degrades to a placeholder.
+func translatePartsForCodex(parts []ContentPart) responses.ResponseInputMessageContentListParam {
+	content := make(responses.ResponseInputMessageContentListParam, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case part.Type == "text":
+			content = append(content, responses.ResponseInputContentParamOfInputText(part.Text))
+		case part.Type == "image" && part.DataURL() != "":
+			img := responses.ResponseInputContentParamOfInputImage(responses.ResponseInputImageDetailAuto)
+			img.OfInputImage.ImageURL = openai.Opt(part.DataURL())
+			content = append(content, img)
+		case part.Type == "file" && part.Data != "":
+			content = append(content, responses.ResponseInputContentUnionParam{
+				OfInputFile: &responses.ResponseInputFileParam{
+					FileData: openai.Opt(part.DataURL()),
+					Filename: openai.Opt(part.Filename),
+				},
+			})
+		default:
+			content = append(content, responses.ResponseInputContentParamOfInputText(part.Placeholder()))
+		}
+	}
+	return content
+}
+
 func resolveCodexToolCall(tc ToolCall) (name string, arguments string, ok bool) {
 	name = tc.Name
 	if name == "" && tc.Function != nil {
@@ -362,11 +421,18 @@ func translateToolsForCodex(tools []ToolDefinition, enableWebSearch bool) []resp
 }
 
 func parseCodexResponse(resp *responses.Response) *LLMResponse {
-	var content strings.Builder
+	var content, reasoning strings.Builder
 	var toolCalls []ToolCall
 
 	for _, item := range resp.Output {
 		switch item.Type {
+		case "reasoning":
+			for _, s := range item.Summary {
+				if reasoning.Len() > 0 {
+					reasoning.WriteString("\n\n")
+				}
+				reasoning.WriteString(s.Text)
+			}
 		case "message":
 			for _, c := range item.Content {
 				if c.Type == "output_text" {
@@ -404,10 +470,11 @@ func parseCodexResponse(resp *responses.Response) *LLMResponse {
 	}
 
 	return &LLMResponse{
-		Content:      content.String(),
-		ToolCalls:    toolCalls,
-		FinishReason: finishReason,
-		Usage:        usage,
+		Content:          content.String(),
+		ReasoningContent: reasoning.String(),
+		ToolCalls:        toolCalls,
+		FinishReason:     finishReason,
+		Usage:            usage,
 	}
 }
 
@@ -34,6 +34,63 @@ func TestBuildCodexParams_BasicMessage(t *testing.T) {
 	}
 }
 
+func TestBuildCodexParams_ResponseFormat(t *testing.T) {
+	format := ResponseFormat{Name: "place", Schema: map[string]any{"type": "object"}, Strict: true}
+	params := buildCodexParams([]Message{{Role: "user", Content: "where?"}}, nil, "gpt-5.2",
+		map[string]any{"response_format": format}, false)
+	schema := params.Text.Format.OfJSONSchema
+	if schema == nil || schema.Name != "place" || !schema.Strict.Or(false) {
+		t.Fatalf("Text.Format = %+v", params.Text.Format)
+	}
+}
+
+func TestBuildCodexParams_Reasoning(t *testing.T) {
+	params := buildCodexParams([]Message{{Role: "user", Content: "why?"}}, nil, "gpt-5.2",
+		map[string]any{"reasoning_effort": "high"}, false)
+	if params.Reasoning.Effort != "high" || params.Reasoning.Summary != "auto" {
+		t.Fatalf("Reasoning = %+v", params.Reasoning)
+	}
+}
+
+func TestParseCodexResponse_ReasoningSummary(t *testing.T) {
+	var resp responses.Response
+	if err := json.Unmarshal([]byte(`{"status":"completed","output":[
+		{"type":"reasoning","summary":[{"type":"summary_text","text":"Multiply."}]},
+		{"type":"message","content":[{"type":"output_text","text":"42"}]}]}`), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	result := parseCodexResponse(&resp)
+	if result.Content != "42" || result.ReasoningContent != "Multiply." {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestBuildCodexParams_ImageParts(t *testing.T) {
+	messages := []Message{{
+		Role:    "user",
+		Content: "what is this?",
+		Parts: []ContentPart{
+			{Type: "text", Text: "what is this?"},
+			{Type: "image", MIMEType: "image/png", Data: "aGVsbG8="},
+		},
+	}}
+	params := buildCodexParams(messages, nil, "gpt-4o", map[string]any{}, false)
+	items := params.Input.OfInputItemList
+	if len(items) != 1 || items[0].OfMessage == nil {
+		t.Fatalf("expected one user message, got %#v", items)
+	}
+	content := items[0].OfMessage.Content.OfInputItemContentList
+	if len(content) != 2 {
+		t.Fatalf("len(content) = %d, want 2", len(content))
+	}
+	if content[1].OfInputImage == nil {
+		t.Fatalf("content[1] is not an input_image")
+	}
+	if got := content[1].OfInputImage.ImageURL.Value; got != "data:image/png;base64,aGVsbG8=" {
+		t.Errorf("ImageURL = %q", got)
+	}
+}
+
 func TestBuildCodexParams_SystemAsInstructions(t *testing.T) {
 	messages := []Message{
 		{Role: "system", Content: "You are helpful"},
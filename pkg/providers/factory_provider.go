@@ -53,9 +53,19 @@ func ExtractProtocol(model string) (protocol, modelID string) {
 
 // CreateProviderFromConfig creates a provider based on the ModelConfig.
 // It uses the protocol prefix in the Model field to determine which provider to create.
-// Supported protocols: openai, anthropic, antigravity, claude-cli, codex-cli, github-copilot
+// Supported protocols: openai, anthropic, gemini, ollama, antigravity, claude-cli, codex-cli, github-copilot
+// Requests are paced to the entry's rpm and tpm limits, if set, and default
+// to the entry's reasoning settings.
 // Returns the provider, the model ID (without protocol prefix), and any error.
 func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, error) {
+	provider, modelID, err := createProviderFromConfig(cfg)
+	if err != nil {
+		return nil, "", err
+	}
+	return WithRateLimit(WithReasoning(provider, cfg), RateLimiterFor(cfg)), modelID, nil
+}
+
+func createProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, error) {
 	if cfg == nil {
 		return nil, "", fmt.Errorf("config is nil")
 	}
@@ -92,8 +102,39 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 			cfg.RequestTimeout,
 		), modelID, nil
 
-	case "openrouter", "groq", "zhipu", "gemini", "nvidia",
-		"ollama", "moonshot", "shengsuanyun", "deepseek", "cerebras",
+	case "gemini":
+		if cfg.APIKey == "" && cfg.APIBase == "" {
+			return nil, "", fmt.Errorf("api_key or api_base is required for protocol %q", protocol)
+		}
+		// Gemini's OpenAI-compatible endpoint keeps working through the shim.
+		if strings.HasSuffix(strings.TrimRight(cfg.APIBase, "/"), "/openai") {
+			return NewHTTPProviderWithMaxTokensFieldAndRequestTimeout(
+				cfg.APIKey,
+				cfg.APIBase,
+				cfg.Proxy,
+				cfg.MaxTokensField,
+				cfg.RequestTimeout,
+			), modelID, nil
+		}
+		return NewGeminiProvider(
+			cfg.APIKey,
+			cfg.APIBase,
+			cfg.Proxy,
+			cfg.RequestTimeout,
+			cfg.SafetySettings,
+		), modelID, nil
+
+	case "ollama":
+		return NewOllamaProvider(
+			cfg.APIKey,
+			cfg.APIBase,
+			cfg.Proxy,
+			cfg.RequestTimeout,
+			ollamaSettings(cfg),
+		), modelID, nil
+
+	case "openrouter", "groq", "zhipu", "nvidia",
+		"moonshot", "shengsuanyun", "deepseek", "cerebras",
 		"volcengine", "vllm", "qwen", "mistral":
 		// All other OpenAI-compatible HTTP providers
 		if cfg.APIKey == "" && cfg.APIBase == "" {
@@ -173,6 +214,24 @@ func CreateProviderFromConfig(cfg *config.ModelConfig) (LLMProvider, string, err
 	}
 }
 
+// ollamaSettings returns the Ollama settings of cfg. The entry's
+// context_window sets num_ctx unless its options do.
+func ollamaSettings(cfg *config.ModelConfig) OllamaSettings {
+	settings := OllamaSettings{
+		KeepAlive: cfg.KeepAlive,
+		Options:   cfg.Options,
+		AutoPull:  cfg.AutoPull,
+	}
+	if _, ok := cfg.Options["num_ctx"]; !ok && cfg.ContextWindow > 0 {
+		settings.Options = make(map[string]any, len(cfg.Options)+1)
+		for k, v := range cfg.Options {
+			settings.Options[k] = v
+		}
+		settings.Options["num_ctx"] = cfg.ContextWindow
+	}
+	return settings
+}
+
 // getDefaultAPIBase returns the default API base URL for a given protocol.
 func getDefaultAPIBase(protocol string) string {
 	switch protocol {
@@ -189,7 +248,7 @@ func getDefaultAPIBase(protocol string) string {
 	case "nvidia":
 		return "https://integrate.api.nvidia.com/v1"
 	case "ollama":
-		return "http://localhost:11434/v1"
+		return "http://localhost:11434"
 	case "moonshot":
 		return "https://api.moonshot.cn/v1"
 	case "shengsuanyun":
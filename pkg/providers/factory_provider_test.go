@@ -111,7 +111,6 @@ func TestCreateProviderFromConfig_DefaultAPIBase(t *testing.T) {
 		{"qwen", "qwen"},
 		{"vllm", "vllm"},
 		{"deepseek", "deepseek"},
-		{"ollama", "ollama"},
 	}
 
 	for _, tt := range tests {
@@ -154,6 +153,29 @@ func TestCreateProviderFromConfig_Anthropic(t *testing.T) {
 	}
 }
 
+func TestCreateProviderFromConfig_OllamaIsNative(t *testing.T) {
+	cfg := &config.ModelConfig{
+		ModelName: "test-ollama",
+		Model:     "ollama/llama3.1",
+		APIBase:   "http://edge-box:11434/v1/",
+	}
+
+	provider, modelID, err := CreateProviderFromConfig(cfg)
+	if err != nil {
+		t.Fatalf("CreateProviderFromConfig() error = %v", err)
+	}
+	ollama, ok := provider.(*OllamaProvider)
+	if !ok {
+		t.Fatalf("expected *OllamaProvider, got %T", provider)
+	}
+	if modelID != "llama3.1" {
+		t.Errorf("modelID = %q, want %q", modelID, "llama3.1")
+	}
+	if ollama.apiBase != "http://edge-box:11434" {
+		t.Errorf("apiBase = %q, want the /v1 suffix stripped", ollama.apiBase)
+	}
+}
+
 func TestCreateProviderFromConfig_Antigravity(t *testing.T) {
 	cfg := &config.ModelConfig{
 		ModelName: "test-antigravity",
@@ -0,0 +1,208 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	geminiDefaultAPIBase = "https://generativelanguage.googleapis.com/v1beta"
+	geminiDefaultTimeout = 120 * time.Second
+)
+
+// GeminiProvider talks to the Gemini API natively with generateContent and
+// streamGenerateContent, authenticating with an API key.
+type GeminiProvider struct {
+	apiKey     string
+	apiBase    string
+	safety     []geminiSafetySetting
+	httpClient *http.Client
+}
+
+// NewGeminiProvider returns a provider for the Gemini API at apiBase (the
+// public endpoint when empty). safety maps harm categories to blocking
+// thresholds; a zero requestTimeoutSeconds keeps the default timeout.
+func NewGeminiProvider(
+	apiKey, apiBase, proxy string,
+	requestTimeoutSeconds int,
+	safety map[string]string,
+) *GeminiProvider {
+	if apiBase == "" {
+		apiBase = geminiDefaultAPIBase
+	}
+	client := &http.Client{Timeout: geminiDefaultTimeout}
+	if requestTimeoutSeconds > 0 {
+		client.Timeout = time.Duration(requestTimeoutSeconds) * time.Second
+	}
+	if proxy != "" {
+		if parsed, err := url.Parse(proxy); err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(parsed)}
+		} else {
+			logger.WarnCF("provider.gemini", "Invalid proxy URL", map[string]any{
+				"proxy": proxy,
+				"error": err.Error(),
+			})
+		}
+	}
+
+	p := &GeminiProvider{
+		apiKey:     apiKey,
+		apiBase:    strings.TrimRight(apiBase, "/"),
+		httpClient: client,
+	}
+	categories := make([]string, 0, len(safety))
+	for category := range safety {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	for _, category := range categories {
+		p.safety = append(p.safety, geminiSafetySetting{Category: category, Threshold: safety[category]})
+	}
+	return p
+}
+
+func (p *GeminiProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	resp, err := p.post(ctx, model, "generateContent", messages, tools, options)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	var chunk antigravityJSONResponse
+	if err := json.Unmarshal(body, &chunk); err != nil {
+		return nil, fmt.Errorf("parsing gemini response: %w", err)
+	}
+
+	var acc geminiAccumulator
+	acc.add(chunk, nil)
+	return acc.result()
+}
+
+// ChatStream is like Chat but reads a server-sent event stream, calling
+// onDelta with each piece of the answer as it arrives.
+func (p *GeminiProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	resp, err := p.post(ctx, model, "streamGenerateContent?alt=sse", messages, tools, options)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var acc geminiAccumulator
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(strings.TrimSpace(scanner.Text()), "data:")
+		if !ok {
+			continue
+		}
+		var chunk antigravityJSONResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &chunk); err != nil {
+			return nil, fmt.Errorf("parsing gemini stream chunk: %w", err)
+		}
+		acc.add(chunk, onDelta)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading gemini stream: %w", err)
+	}
+	return acc.result()
+}
+
+// GetDefaultModel returns the default model identifier.
+func (p *GeminiProvider) GetDefaultModel() string {
+	return ""
+}
+
+// post sends a request to the model's method and returns the response if
+// its status is OK.
+func (p *GeminiProvider) post(
+	ctx context.Context,
+	model, method string,
+	messages []Message,
+	tools []ToolDefinition,
+	options map[string]any,
+) (*http.Response, error) {
+	model = strings.TrimPrefix(model, "models/")
+	if model == "" {
+		return nil, fmt.Errorf("gemini: model is required")
+	}
+
+	req := buildGeminiRequest(messages, tools, options)
+	req.SafetySettings = p.safety
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	apiURL := fmt.Sprintf("%s/models/%s:%s", p.apiBase, url.PathEscape(model), method)
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		httpReq.Header.Set("x-goog-api-key", p.apiKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("gemini API call: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, parseGeminiError(resp.StatusCode, respBody)
+	}
+	return resp, nil
+}
+
+// parseGeminiError turns an error response into an error carrying its HTTP
+// status, so that failover can classify it.
+func parseGeminiError(statusCode int, body []byte) error {
+	var errResp struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error.Message == "" {
+		return fmt.Errorf("gemini API error (status %d): %s", statusCode, truncateString(string(body), 500))
+	}
+	return fmt.Errorf("gemini API error (status %d, %s): %s", statusCode, errResp.Error.Status, errResp.Error.Message)
+}
+
+// result returns the collected response, or an error when Gemini refused
+// the prompt outright.
+func (a *geminiAccumulator) result() (*LLMResponse, error) {
+	if a.blockReason != "" && a.content.Len() == 0 && len(a.toolCalls) == 0 {
+		return nil, fmt.Errorf("gemini blocked the prompt: %s", a.blockReason)
+	}
+	return a.response(), nil
+}
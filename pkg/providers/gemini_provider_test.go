@@ -0,0 +1,268 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// geminiStub serves one canned answer and keeps the last request.
+type geminiStub struct {
+	path, apiKey string
+	request      map[string]any
+}
+
+func (s *geminiStub) serve(t *testing.T, status int, answer string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.path = r.URL.Path + "?" + r.URL.RawQuery
+		s.apiKey = r.Header.Get("x-goog-api-key")
+		s.request = nil
+		json.NewDecoder(r.Body).Decode(&s.request)
+		if strings.Contains(r.URL.Path, "stream") {
+			w.Header().Set("Content-Type", "text/event-stream")
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, answer)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGeminiProvider_Chat(t *testing.T) {
+	stub := &geminiStub{}
+	server := stub.serve(t, http.StatusOK, `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [
+				{"text": "pondering", "thought": true},
+				{"text": "Reading it."},
+				{"functionCall": {"name": "read_file", "args": {"path": "a.txt"}}, "thoughtSignature": "sig-1"}
+			]},
+			"finishReason": "STOP"
+		}],
+		"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 5, "thoughtsTokenCount": 3, "totalTokenCount": 20}
+	}`)
+	p := NewGeminiProvider("key", server.URL, "", 0, map[string]string{
+		"HARM_CATEGORY_HARASSMENT": "BLOCK_NONE",
+	})
+
+	resp, err := p.Chat(context.Background(), []Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "read a.txt"},
+	}, []ToolDefinition{{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name: "read_file",
+			Parameters: map[string]any{
+				"type":                 "object",
+				"additionalProperties": false,
+				"properties":           map[string]any{"path": map[string]any{"type": "string", "minLength": 1}},
+			},
+		},
+	}}, "gemini-2.5-flash", map[string]any{"max_tokens": 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stub.path != "/models/gemini-2.5-flash:generateContent?" || stub.apiKey != "key" {
+		t.Errorf("request to %q with key %q", stub.path, stub.apiKey)
+	}
+	body, _ := json.Marshal(stub.request)
+	for _, want := range []string{
+		`"systemInstruction":{"parts":[{"text":"be brief"}]}`,
+		`"safetySettings":[{"category":"HARM_CATEGORY_HARASSMENT","threshold":"BLOCK_NONE"}]`,
+		`"maxOutputTokens":100`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("request lacks %s: %s", want, body)
+		}
+	}
+	if strings.Contains(string(body), "additionalProperties") || strings.Contains(string(body), "minLength") {
+		t.Errorf("tool schema was not sanitized: %s", body)
+	}
+
+	if resp.Content != "Reading it." || resp.ReasoningContent != "pondering" || resp.FinishReason != "tool_calls" {
+		t.Errorf("response = %+v", resp)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "read_file" ||
+		resp.ToolCalls[0].Arguments["path"] != "a.txt" || resp.ToolCalls[0].Function.ThoughtSignature != "sig-1" {
+		t.Errorf("tool calls = %+v", resp.ToolCalls)
+	}
+	if u := resp.Usage; u == nil || u.PromptTokens != 12 || u.CompletionTokens != 8 || u.TotalTokens != 20 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestGeminiProvider_SendsHistory(t *testing.T) {
+	stub := &geminiStub{}
+	server := stub.serve(t, http.StatusOK, `{"candidates":[{"content":{"parts":[{"text":"done"}]}}]}`)
+	p := NewGeminiProvider("key", server.URL, "", 0, nil)
+
+	_, err := p.Chat(context.Background(), []Message{
+		{Role: "user", Content: "look", Parts: []ContentPart{
+			{Type: "text", Text: "look"},
+			{Type: "image", MIMEType: "image/png", Data: "aW1n"},
+		}},
+		{Role: "assistant", ToolCalls: []ToolCall{
+			{ID: "call_1", Name: "a", Arguments: map[string]any{}, Function: &FunctionCall{ThoughtSignature: "sig"}},
+			{ID: "call_2", Name: "b", Arguments: map[string]any{}},
+		}},
+		{Role: "tool", ToolCallID: "call_1", Content: "one"},
+		{Role: "tool", ToolCallID: "call_2", Content: "two"},
+	}, nil, "gemini-2.5-pro", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req antigravityRequest
+	body, _ := json.Marshal(stub.request)
+	json.Unmarshal(body, &req)
+	if len(req.Contents) != 3 {
+		t.Fatalf("contents = %s", body)
+	}
+	if img := req.Contents[0].Parts[1].InlineData; img == nil || img.MIMEType != "image/png" || img.Data != "aW1n" {
+		t.Errorf("image part = %s", body)
+	}
+	if req.Contents[1].Role != "model" || req.Contents[1].Parts[0].ThoughtSignature != "sig" {
+		t.Errorf("model turn = %s", body)
+	}
+	// Results of parallel calls go back in one turn.
+	if parts := req.Contents[2].Parts; len(parts) != 2 ||
+		parts[0].FunctionResponse.Name != "a" || parts[1].FunctionResponse.Name != "b" {
+		t.Errorf("tool results = %s", body)
+	}
+}
+
+func TestGeminiProvider_ResponseFormat(t *testing.T) {
+	stub := &geminiStub{}
+	server := stub.serve(t, http.StatusOK, `{"candidates":[{"content":{"parts":[{"text":"{\"city\":\"SF\"}"}]}}]}`)
+	p := NewGeminiProvider("key", server.URL, "", 0, nil)
+
+	format := ResponseFormat{Name: "place", Schema: map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           map[string]any{"city": map[string]any{"type": "string"}},
+	}}
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "where?"}}, nil,
+		"gemini-2.5-flash", map[string]any{"response_format": format})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(stub.request)
+	if !strings.Contains(string(body), `"responseMimeType":"application/json"`) ||
+		!strings.Contains(string(body), `"responseSchema":{"properties":{"city":{"type":"string"}},"type":"object"}`) {
+		t.Errorf("request lacks the response schema: %s", body)
+	}
+	if resp.Content != `{"city":"SF"}` {
+		t.Errorf("content = %q", resp.Content)
+	}
+}
+
+func TestGeminiProvider_Thinking(t *testing.T) {
+	stub := &geminiStub{}
+	server := stub.serve(t, http.StatusOK, `{"candidates":[{"content":{"parts":[`+
+		`{"text":"6 times 7","thought":true},{"text":"42"}]}}]}`)
+	p := NewGeminiProvider("key", server.URL, "", 0, nil)
+
+	resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "6*7?"}}, nil,
+		"gemini-2.5-flash", map[string]any{"reasoning_effort": "low"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(stub.request)
+	if !strings.Contains(string(body), `"thinkingConfig":{"includeThoughts":true,"thinkingBudget":2048}`) {
+		t.Errorf("request lacks the thinking config: %s", body)
+	}
+	if resp.Content != "42" || resp.ReasoningContent != "6 times 7" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "6*7?"}}, nil,
+		"gemini-2.5-flash", map[string]any{"reasoning_effort": "none"}); err != nil {
+		t.Fatal(err)
+	}
+	body, _ = json.Marshal(stub.request)
+	if !strings.Contains(string(body), `"thinkingConfig":{"thinkingBudget":0}`) {
+		t.Errorf("request does not turn thinking off: %s", body)
+	}
+}
+
+func TestGeminiProvider_ChatStream(t *testing.T) {
+	stub := &geminiStub{}
+	server := stub.serve(t, http.StatusOK,
+		"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"Hel\"}]}}]}\n\n"+
+			"data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"lo\"}]},\"finishReason\":\"MAX_TOKENS\"}],"+
+			"\"usageMetadata\":{\"promptTokenCount\":4,\"candidatesTokenCount\":2,\"totalTokenCount\":6}}\n\n")
+	p := NewGeminiProvider("key", server.URL, "", 0, nil)
+
+	var deltas []string
+	resp, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil,
+		"gemini-2.5-flash", nil, func(delta string) { deltas = append(deltas, delta) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stub.path != "/models/gemini-2.5-flash:streamGenerateContent?alt=sse" {
+		t.Errorf("request to %q", stub.path)
+	}
+	if strings.Join(deltas, "|") != "Hel|lo" || resp.Content != "Hello" || resp.FinishReason != "length" {
+		t.Errorf("deltas %q, response %+v", deltas, resp)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 6 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestGeminiProvider_Errors(t *testing.T) {
+	stub := &geminiStub{}
+	server := stub.serve(t, http.StatusTooManyRequests,
+		`{"error":{"code":429,"message":"Resource has been exhausted","status":"RESOURCE_EXHAUSTED"}}`)
+	p := NewGeminiProvider("key", server.URL, "", 0, nil)
+
+	_, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "gemini-2.5-flash", nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if failErr := ClassifyError(err, "gemini", "gemini-2.5-flash"); failErr == nil || failErr.Reason != FailoverRateLimit {
+		t.Errorf("error %q classified as %+v", err, failErr)
+	}
+
+	blocked := (&geminiStub{}).serve(t, http.StatusOK, `{"promptFeedback":{"blockReason":"SAFETY"}}`)
+	p = NewGeminiProvider("key", blocked.URL, "", 0, nil)
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "gemini-2.5-flash", nil); err == nil ||
+		!strings.Contains(err.Error(), "SAFETY") {
+		t.Errorf("blocked prompt error = %v", err)
+	}
+}
+
+func TestCreateProviderFromConfig_Gemini(t *testing.T) {
+	provider, modelID, err := createProviderFromConfig(&config.ModelConfig{
+		ModelName: "gemini",
+		Model:     "gemini/gemini-2.5-flash",
+		APIKey:    "key",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := provider.(*GeminiProvider); !ok || modelID != "gemini-2.5-flash" {
+		t.Errorf("provider %T, model %q", provider, modelID)
+	}
+
+	// The OpenAI-compatible endpoint still goes through the shim.
+	provider, _, err = createProviderFromConfig(&config.ModelConfig{
+		Model:   "gemini/gemini-2.5-flash",
+		APIKey:  "key",
+		APIBase: "https://generativelanguage.googleapis.com/v1beta/openai/",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := provider.(*HTTPProvider); !ok {
+		t.Errorf("openai endpoint provider = %T", provider)
+	}
+}
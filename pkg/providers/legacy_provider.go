@@ -51,6 +51,20 @@ func CreateProvider(cfg *config.Config) (LLMProvider, string, error) {
 		modelCfg.Workspace = cfg.WorkspacePath()
 	}
 
+	// Several entries for the model: balance every request across them
+	if entries := cfg.ModelConfigs(model); len(entries) > 1 {
+		for i := range entries {
+			if entries[i].Workspace == "" {
+				entries[i].Workspace = cfg.WorkspacePath()
+			}
+		}
+		provider, err := NewBalancedProvider(entries)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create providers for model %q: %w", model, err)
+		}
+		return provider, provider.GetDefaultModel(), nil
+	}
+
 	// Use factory to create provider
 	provider, modelID, err := CreateProviderFromConfig(modelCfg)
 	if err != nil {
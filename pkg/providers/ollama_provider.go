@@ -0,0 +1,586 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+const (
+	ollamaDefaultAPIBase = "http://localhost:11434"
+	ollamaDefaultTimeout = 300 * time.Second
+)
+
+// OllamaSettings are the per-model settings of an Ollama model_list entry.
+type OllamaSettings struct {
+	// KeepAlive is how long Ollama keeps the model loaded after a request,
+	// e.g. "10m", or "-1" to keep it loaded. Empty leaves Ollama's default.
+	KeepAlive string
+	// Options are Ollama model options such as num_ctx or top_k. They take
+	// precedence over the max_tokens and temperature of a request.
+	Options map[string]any
+	// AutoPull pulls a model that is not available locally before using it.
+	AutoPull bool
+}
+
+// OllamaProvider talks to an Ollama server natively with /api/chat, and
+// lists and pulls its models.
+type OllamaProvider struct {
+	apiKey     string
+	apiBase    string
+	settings   OllamaSettings
+	httpClient *http.Client
+
+	mu     sync.Mutex
+	pulled map[string]bool // models known to be available locally
+	listed bool            // whether pulled holds the server's model list
+}
+
+// OllamaModel is a model available on an Ollama server.
+type OllamaModel struct {
+	Name       string    `json:"name"`
+	Size       int64     `json:"size"`
+	Digest     string    `json:"digest"`
+	ModifiedAt time.Time `json:"modified_at"`
+	Details    struct {
+		Family            string `json:"family"`
+		ParameterSize     string `json:"parameter_size"`
+		QuantizationLevel string `json:"quantization_level"`
+	} `json:"details"`
+}
+
+// OllamaPullProgress is one progress update of a model pull.
+type OllamaPullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// OllamaAPIBase returns the native API base of an Ollama api_base: the
+// local server when empty, and without the "/v1" of the OpenAI-compatible
+// endpoint, so existing entries keep working.
+func OllamaAPIBase(apiBase string) string {
+	apiBase = strings.TrimSuffix(strings.TrimRight(apiBase, "/"), "/v1")
+	if apiBase == "" {
+		return ollamaDefaultAPIBase
+	}
+	return apiBase
+}
+
+// NewOllamaProvider returns a provider for the Ollama server at apiBase, as
+// resolved by OllamaAPIBase. A non-empty apiKey is sent as a bearer token,
+// for servers behind an authenticating proxy. A zero requestTimeoutSeconds
+// keeps the default timeout.
+func NewOllamaProvider(
+	apiKey, apiBase, proxy string,
+	requestTimeoutSeconds int,
+	settings OllamaSettings,
+) *OllamaProvider {
+	client := &http.Client{Timeout: ollamaDefaultTimeout}
+	if requestTimeoutSeconds > 0 {
+		client.Timeout = time.Duration(requestTimeoutSeconds) * time.Second
+	}
+	if proxy != "" {
+		if parsed, err := url.Parse(proxy); err == nil {
+			client.Transport = &http.Transport{Proxy: http.ProxyURL(parsed)}
+		} else {
+			logger.WarnCF("provider.ollama", "Invalid proxy URL", map[string]any{
+				"proxy": proxy,
+				"error": err.Error(),
+			})
+		}
+	}
+
+	return &OllamaProvider{
+		apiKey:     apiKey,
+		apiBase:    OllamaAPIBase(apiBase),
+		settings:   settings,
+		httpClient: client,
+		pulled:     map[string]bool{},
+	}
+}
+
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaChatRequest struct {
+	Model     string           `json:"model"`
+	Messages  []ollamaMessage  `json:"messages"`
+	Tools     []ToolDefinition `json:"tools,omitempty"`
+	Stream    bool             `json:"stream"`
+	KeepAlive string           `json:"keep_alive,omitempty"`
+	Options   map[string]any   `json:"options,omitempty"`
+	Format    map[string]any   `json:"format,omitempty"` // JSON schema of the reply
+	Think     any              `json:"think,omitempty"`  // true, false or, for gpt-oss, an effort
+}
+
+type ollamaChatResponse struct {
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+func (p *OllamaProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	resp, err := p.chat(ctx, messages, tools, model, options, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var chunk ollamaChatResponse
+	if err := json.NewDecoder(resp.Body).Decode(&chunk); err != nil {
+		return nil, fmt.Errorf("parsing ollama response: %w", err)
+	}
+	var acc ollamaAccumulator
+	if err := acc.add(chunk, nil); err != nil {
+		return nil, err
+	}
+	return acc.response(), nil
+}
+
+// ChatStream is like Chat but reads Ollama's line-delimited stream, calling
+// onDelta with each piece of the answer as it arrives.
+func (p *OllamaProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	resp, err := p.chat(ctx, messages, tools, model, options, true)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var acc ollamaAccumulator
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return nil, fmt.Errorf("parsing ollama stream chunk: %w", err)
+		}
+		if err := acc.add(chunk, onDelta); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading ollama stream: %w", err)
+	}
+	return acc.response(), nil
+}
+
+// GetDefaultModel returns the default model identifier.
+func (p *OllamaProvider) GetDefaultModel() string {
+	return ""
+}
+
+// ListModels returns the models available on the server.
+func (p *OllamaProvider) ListModels(ctx context.Context) ([]OllamaModel, error) {
+	req, err := p.newRequest(ctx, "GET", "/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama API call: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, parseOllamaError(resp.StatusCode, body)
+	}
+
+	var tags struct {
+		Models []OllamaModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("parsing ollama models: %w", err)
+	}
+
+	p.mu.Lock()
+	for _, m := range tags.Models {
+		p.pulled[OllamaModelTag(m.Name)] = true
+	}
+	p.listed = true
+	p.mu.Unlock()
+	return tags.Models, nil
+}
+
+// Pull downloads model to the server, calling progress, if not nil, with
+// each update. Pulls are not bound by the request timeout.
+func (p *OllamaProvider) Pull(ctx context.Context, model string, progress func(OllamaPullProgress)) error {
+	body, err := json.Marshal(map[string]any{"model": model, "stream": true})
+	if err != nil {
+		return fmt.Errorf("marshaling request: %w", err)
+	}
+	req, err := p.newRequest(ctx, "POST", "/api/pull", body)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Transport: p.httpClient.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ollama API call: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return parseOllamaError(resp.StatusCode, respBody)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var update OllamaPullProgress
+		if err := json.Unmarshal(line, &update); err != nil {
+			return fmt.Errorf("parsing ollama pull progress: %w", err)
+		}
+		if update.Error != "" {
+			return fmt.Errorf("pulling %s: %s", model, update.Error)
+		}
+		if progress != nil {
+			progress(update)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading ollama pull progress: %w", err)
+	}
+
+	p.mu.Lock()
+	p.pulled[OllamaModelTag(model)] = true
+	p.mu.Unlock()
+	return nil
+}
+
+// ensureModel pulls model when auto_pull is set and the server does not
+// have it yet. The server's models are listed once and remembered.
+func (p *OllamaProvider) ensureModel(ctx context.Context, model string) error {
+	if !p.settings.AutoPull {
+		return nil
+	}
+	p.mu.Lock()
+	known, listed := p.pulled[OllamaModelTag(model)], p.listed
+	p.mu.Unlock()
+	if known {
+		return nil
+	}
+	if !listed {
+		if _, err := p.ListModels(ctx); err != nil {
+			return err
+		}
+		p.mu.Lock()
+		known = p.pulled[OllamaModelTag(model)]
+		p.mu.Unlock()
+		if known {
+			return nil
+		}
+	}
+
+	logger.InfoCF("provider.ollama", "Pulling missing model", map[string]any{
+		"model":    model,
+		"api_base": p.apiBase,
+	})
+	if err := p.Pull(ctx, model, nil); err != nil {
+		return err
+	}
+	logger.InfoCF("provider.ollama", "Pulled model", map[string]any{"model": model})
+	return nil
+}
+
+// newRequest returns a request to path on the server, with a JSON body when
+// body is not nil.
+func (p *OllamaProvider) newRequest(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, p.apiBase+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	return req, nil
+}
+
+// chat sends a chat request and returns the response if its status is OK.
+func (p *OllamaProvider) chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	stream bool,
+) (*http.Response, error) {
+	if model == "" {
+		return nil, fmt.Errorf("ollama: model is required")
+	}
+	if err := p.ensureModel(ctx, model); err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(p.buildRequest(messages, tools, model, options, stream))
+	if err != nil {
+		return nil, fmt.Errorf("marshaling request: %w", err)
+	}
+	req, err := p.newRequest(ctx, "POST", "/api/chat", body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("ollama API call: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, parseOllamaError(resp.StatusCode, respBody)
+	}
+	return resp, nil
+}
+
+func (p *OllamaProvider) buildRequest(
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	stream bool,
+) ollamaChatRequest {
+	req := ollamaChatRequest{
+		Model:     model,
+		Stream:    stream,
+		KeepAlive: p.settings.KeepAlive,
+	}
+	for _, t := range tools {
+		if t.Type == "function" {
+			req.Tools = append(req.Tools, t)
+		}
+	}
+	if format := ResponseFormatFrom(options); format != nil {
+		req.Format = format.Schema
+	}
+	if reasoning := ReasoningFrom(options); reasoning != nil {
+		req.Think = ollamaThink(model, reasoning)
+	}
+
+	modelOptions := map[string]any{}
+	if maxTokens, ok := options["max_tokens"].(int); ok && maxTokens > 0 {
+		modelOptions["num_predict"] = maxTokens
+	}
+	if temperature, ok := options["temperature"].(float64); ok {
+		modelOptions["temperature"] = temperature
+	}
+	for k, v := range p.settings.Options {
+		modelOptions[k] = v
+	}
+	if len(modelOptions) > 0 {
+		req.Options = modelOptions
+	}
+
+	toolCallNames := map[string]string{}
+	for _, msg := range messages {
+		switch msg.Role {
+		case "assistant":
+			out := ollamaMessage{Role: "assistant", Content: msg.Content}
+			for _, tc := range msg.ToolCalls {
+				name, args, _ := normalizeStoredToolCall(tc)
+				if name == "" {
+					continue
+				}
+				if tc.ID != "" {
+					toolCallNames[tc.ID] = name
+				}
+				var call ollamaToolCall
+				call.Function.Name = name
+				call.Function.Arguments = args
+				out.ToolCalls = append(out.ToolCalls, call)
+			}
+			req.Messages = append(req.Messages, out)
+		case "tool":
+			req.Messages = append(req.Messages, ollamaMessage{
+				Role:     "tool",
+				Content:  msg.Content,
+				ToolName: resolveToolResponseName(msg.ToolCallID, toolCallNames),
+			})
+		default:
+			req.Messages = append(req.Messages, ollamaUserMessage(msg))
+		}
+	}
+	return req
+}
+
+// ollamaUserMessage maps a system or user message, passing inline images
+// natively and other media as placeholders.
+func ollamaUserMessage(msg Message) ollamaMessage {
+	out := ollamaMessage{Role: msg.Role, Content: msg.Content}
+	var placeholders []string
+	for _, part := range msg.Parts {
+		switch {
+		case part.Type == "text":
+		case part.Type == "image" && part.Data != "":
+			out.Images = append(out.Images, part.Data)
+		default:
+			placeholders = append(placeholders, part.Placeholder())
+		}
+	}
+	if len(placeholders) > 0 {
+		out.Content = strings.TrimSpace(out.Content + "\n" + strings.Join(placeholders, "\n"))
+	}
+	return out
+}
+
+// ollamaAccumulator collects the chunks of a chat response.
+type ollamaAccumulator struct {
+	content, reasoning strings.Builder
+	toolCalls          []ToolCall
+	usage              *UsageInfo
+	doneReason         string
+}
+
+func (a *ollamaAccumulator) add(chunk ollamaChatResponse, onDelta func(string)) error {
+	if chunk.Error != "" {
+		return fmt.Errorf("ollama API error: %s", chunk.Error)
+	}
+	if chunk.Message.Content != "" {
+		a.content.WriteString(chunk.Message.Content)
+		if onDelta != nil {
+			onDelta(chunk.Message.Content)
+		}
+	}
+	a.reasoning.WriteString(chunk.Message.Thinking)
+	for _, tc := range chunk.Message.ToolCalls {
+		args := tc.Function.Arguments
+		if args == nil {
+			args = map[string]any{}
+		}
+		argsJSON, _ := json.Marshal(args)
+		a.toolCalls = append(a.toolCalls, ToolCall{
+			ID:        fmt.Sprintf("call_%s_%d", tc.Function.Name, time.Now().UnixNano()),
+			Type:      "function",
+			Name:      tc.Function.Name,
+			Arguments: args,
+			Function: &FunctionCall{
+				Name:      tc.Function.Name,
+				Arguments: string(argsJSON),
+			},
+		})
+	}
+	if chunk.Done {
+		a.doneReason = chunk.DoneReason
+		a.usage = &UsageInfo{
+			PromptTokens:     chunk.PromptEvalCount,
+			CompletionTokens: chunk.EvalCount,
+			TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+		}
+	}
+	return nil
+}
+
+func (a *ollamaAccumulator) response() *LLMResponse {
+	finishReason := "stop"
+	switch {
+	case len(a.toolCalls) > 0:
+		finishReason = "tool_calls"
+	case a.doneReason == "length":
+		finishReason = "length"
+	}
+	return &LLMResponse{
+		Content:          a.content.String(),
+		ReasoningContent: a.reasoning.String(),
+		ToolCalls:        a.toolCalls,
+		FinishReason:     finishReason,
+		Usage:            a.usage,
+	}
+}
+
+// parseOllamaError turns an error response into an error carrying its HTTP
+// status, so that failover can classify it.
+func parseOllamaError(statusCode int, body []byte) error {
+	var errResp struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error == "" {
+		return fmt.Errorf("ollama API error (status %d): %s", statusCode, truncateString(string(body), 500))
+	}
+	if statusCode == http.StatusNotFound && strings.Contains(errResp.Error, "not found") {
+		return fmt.Errorf("ollama API error (status %d): %s; run \"picoclaw models pull\" or set auto_pull",
+			statusCode, errResp.Error)
+	}
+	return fmt.Errorf("ollama API error (status %d): %s", statusCode, errResp.Error)
+}
+
+// OllamaModelTag returns model with the tag Ollama gives it, "latest" when
+// it has none, so that "llama3" and "llama3:latest" compare equal.
+func OllamaModelTag(model string) string {
+	if strings.Contains(model, ":") {
+		return model
+	}
+	return model + ":latest"
+}
+
+// ollamaThink maps reasoning to the think field: gpt-oss takes an effort,
+// other thinking models only on or off.
+func ollamaThink(model string, reasoning *Reasoning) any {
+	if reasoning.Off() {
+		return false
+	}
+	if strings.HasPrefix(model, "gpt-oss") {
+		switch effort := reasoning.EffortLevel(); effort {
+		case "low", "medium", "high":
+			return effort
+		default:
+			return "low"
+		}
+	}
+	return true
+}
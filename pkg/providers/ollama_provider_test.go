@@ -0,0 +1,306 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// ollamaStub is an Ollama server with a fixed set of local models. It keeps
+// the requests it was sent.
+type ollamaStub struct {
+	models   []string
+	answer   string
+	requests []string // method and path
+	auth     []string // Authorization header of each request
+	chat     map[string]any
+}
+
+func (s *ollamaStub) serve(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		s.auth = append(s.auth, r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/api/tags":
+			var models []string
+			for _, m := range s.models {
+				models = append(models, fmt.Sprintf(`{"name":%q,"size":4661224676,"details":{"parameter_size":"8B"}}`, m))
+			}
+			fmt.Fprintf(w, `{"models":[%s]}`, strings.Join(models, ","))
+		case "/api/pull":
+			var req struct{ Model string }
+			json.NewDecoder(r.Body).Decode(&req)
+			s.models = append(s.models, OllamaModelTag(req.Model))
+			fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+			fmt.Fprintln(w, `{"status":"downloading","digest":"sha256:1","total":100,"completed":50}`)
+			fmt.Fprintln(w, `{"status":"success"}`)
+		case "/api/chat":
+			s.chat = nil
+			json.NewDecoder(r.Body).Decode(&s.chat)
+			fmt.Fprint(w, s.answer)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOllamaProvider_Chat(t *testing.T) {
+	stub := &ollamaStub{answer: `{
+		"message": {"role": "assistant", "content": "Reading it.", "thinking": "hmm",
+			"tool_calls": [{"function": {"name": "read_file", "arguments": {"path": "a.txt"}}}]},
+		"done": true, "done_reason": "stop", "prompt_eval_count": 20, "eval_count": 7
+	}`}
+	server := stub.serve(t)
+	p := NewOllamaProvider("", server.URL+"/v1", "", 0, OllamaSettings{
+		KeepAlive: "10m",
+		Options:   map[string]any{"num_ctx": 8192, "temperature": 0.2},
+	})
+
+	resp, err := p.Chat(context.Background(), []Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "read this", Parts: []ContentPart{
+			{Type: "text", Text: "read this"},
+			{Type: "image", MIMEType: "image/png", Data: "aW1n"},
+			{Type: "audio", Filename: "memo.ogg"},
+		}},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "list_dir", Arguments: map[string]any{"path": "."}}}},
+		{Role: "tool", ToolCallID: "call_1", Content: "a.txt"},
+	}, []ToolDefinition{{
+		Type:     "function",
+		Function: ToolFunctionDefinition{Name: "read_file", Parameters: map[string]any{"type": "object"}},
+	}}, "llama3.1", map[string]any{"max_tokens": 512, "temperature": 0.7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The OpenAI-compatible "/v1" suffix is dropped.
+	if len(stub.requests) != 1 || stub.requests[0] != "POST /api/chat" {
+		t.Errorf("requests = %v", stub.requests)
+	}
+	body, _ := json.Marshal(stub.chat)
+	for _, want := range []string{
+		`"keep_alive":"10m"`,
+		`"options":{"num_ctx":8192,"num_predict":512,"temperature":0.2}`,
+		`"stream":false`,
+		`"images":["aW1n"]`,
+		`"content":"read this\n[audio: memo.ogg]"`,
+		`"tool_calls":[{"function":{"arguments":{"path":"."},"name":"list_dir"}}]`,
+		`{"content":"a.txt","role":"tool","tool_name":"list_dir"}`,
+		`"tools":[{"function":{"description":"","name":"read_file"`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("request lacks %s: %s", want, body)
+		}
+	}
+
+	if resp.Content != "Reading it." || resp.ReasoningContent != "hmm" || resp.FinishReason != "tool_calls" {
+		t.Errorf("response = %+v", resp)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "read_file" ||
+		resp.ToolCalls[0].Arguments["path"] != "a.txt" || resp.ToolCalls[0].Function.Arguments != `{"path":"a.txt"}` {
+		t.Errorf("tool calls = %+v", resp.ToolCalls)
+	}
+	if u := resp.Usage; u == nil || u.PromptTokens != 20 || u.CompletionTokens != 7 || u.TotalTokens != 27 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestOllamaProvider_ChatStream(t *testing.T) {
+	stub := &ollamaStub{answer: `{"message":{"role":"assistant","content":"Hel"},"done":false}
+{"message":{"role":"assistant","content":"lo"},"done":false}
+{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":3,"eval_count":2}
+`}
+	p := NewOllamaProvider("", stub.serve(t).URL, "", 0, OllamaSettings{})
+
+	var deltas []string
+	resp, err := p.ChatStream(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil,
+		"llama3.1", nil, func(delta string) { deltas = append(deltas, delta) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stub.chat["stream"] != true {
+		t.Errorf("stream = %v", stub.chat["stream"])
+	}
+	if strings.Join(deltas, "|") != "Hel|lo" || resp.Content != "Hello" || resp.FinishReason != "length" {
+		t.Errorf("deltas %q, response %+v", deltas, resp)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 5 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+}
+
+func TestOllamaProvider_ResponseFormat(t *testing.T) {
+	stub := &ollamaStub{answer: `{"message":{"role":"assistant","content":"{\"city\":\"SF\"}"},"done":true}`}
+	p := NewOllamaProvider("", stub.serve(t).URL, "", 0, OllamaSettings{})
+
+	schema := map[string]any{"type": "object"}
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "where?"}}, nil, "llama3.1",
+		map[string]any{"response_format": ResponseFormat{Name: "place", Schema: schema}}); err != nil {
+		t.Fatal(err)
+	}
+	if format, _ := stub.chat["format"].(map[string]any); format["type"] != "object" {
+		t.Errorf("format = %v", stub.chat["format"])
+	}
+}
+
+func TestOllamaProvider_Think(t *testing.T) {
+	stub := &ollamaStub{answer: `{"message":{"role":"assistant","content":"42","thinking":"6 times 7"},"done":true}`}
+	p := NewOllamaProvider("", stub.serve(t).URL, "", 0, OllamaSettings{})
+
+	tests := []struct {
+		model   string
+		options map[string]any
+		think   any
+	}{
+		{"qwen3:4b", map[string]any{"reasoning_effort": "high"}, true},
+		{"qwen3:4b", map[string]any{"reasoning_effort": "none"}, false},
+		{"gpt-oss:20b", map[string]any{"thinking_budget": 2048}, "low"},
+		{"qwen3:4b", map[string]any{}, nil},
+	}
+	for _, tt := range tests {
+		resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "6*7?"}}, nil, tt.model, tt.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stub.chat["think"] != tt.think {
+			t.Errorf("%s %v: think = %v, want %v", tt.model, tt.options, stub.chat["think"], tt.think)
+		}
+		if resp.ReasoningContent != "6 times 7" {
+			t.Errorf("ReasoningContent = %q", resp.ReasoningContent)
+		}
+	}
+}
+
+func TestOllamaProvider_AutoPull(t *testing.T) {
+	stub := &ollamaStub{
+		models: []string{"llama3.1:latest"},
+		answer: `{"message":{"role":"assistant","content":"ok"},"done":true}`,
+	}
+	p := NewOllamaProvider("", stub.serve(t).URL, "", 0, OllamaSettings{AutoPull: true})
+	ctx := context.Background()
+	msgs := []Message{{Role: "user", Content: "hi"}}
+
+	if _, err := p.Chat(ctx, msgs, nil, "llama3.1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Chat(ctx, msgs, nil, "qwen3:4b", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Chat(ctx, msgs, nil, "qwen3:4b", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "GET /api/tags,POST /api/chat,POST /api/pull,POST /api/chat,POST /api/chat"
+	if got := strings.Join(stub.requests, ","); got != want {
+		t.Errorf("requests = %s, want %s", got, want)
+	}
+}
+
+func TestOllamaProvider_SendsAPIKey(t *testing.T) {
+	stub := &ollamaStub{answer: `{"message":{"role":"assistant","content":"ok"},"done":true}`}
+	p := NewOllamaProvider("secret", stub.serve(t).URL, "", 0, OllamaSettings{AutoPull: true})
+
+	if _, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "qwen3:4b", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(stub.auth) != 3 {
+		t.Fatalf("requests = %v, want list, pull and chat", stub.requests)
+	}
+	for i, auth := range stub.auth {
+		if auth != "Bearer secret" {
+			t.Errorf("%s sent Authorization %q", stub.requests[i], auth)
+		}
+	}
+}
+
+func TestOllamaProvider_AutoPullListsAfterExplicitPull(t *testing.T) {
+	stub := &ollamaStub{
+		models: []string{"llama3.1:latest"},
+		answer: `{"message":{"role":"assistant","content":"ok"},"done":true}`,
+	}
+	p := NewOllamaProvider("", stub.serve(t).URL, "", 0, OllamaSettings{AutoPull: true})
+	ctx := context.Background()
+
+	if err := p.Pull(ctx, "qwen3:4b", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Chat(ctx, []Message{{Role: "user", Content: "hi"}}, nil, "llama3.1", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "POST /api/pull,GET /api/tags,POST /api/chat"
+	if got := strings.Join(stub.requests, ","); got != want {
+		t.Errorf("requests = %s, want %s (no second pull of a listed model)", got, want)
+	}
+}
+
+func TestOllamaProvider_ListAndPull(t *testing.T) {
+	stub := &ollamaStub{models: []string{"llama3.1:latest"}}
+	p := NewOllamaProvider("", stub.serve(t).URL, "", 0, OllamaSettings{})
+	ctx := context.Background()
+
+	models, err := p.ListModels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 1 || models[0].Name != "llama3.1:latest" || models[0].Details.ParameterSize != "8B" {
+		t.Errorf("models = %+v", models)
+	}
+
+	var statuses []string
+	err = p.Pull(ctx, "qwen3:4b", func(progress OllamaPullProgress) {
+		statuses = append(statuses, fmt.Sprintf("%s %d/%d", progress.Status, progress.Completed, progress.Total))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(statuses, ","); got != "pulling manifest 0/0,downloading 50/100,success 0/0" {
+		t.Errorf("progress = %s", got)
+	}
+}
+
+func TestOllamaProvider_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"model 'qwen3' not found"}`)
+	}))
+	defer server.Close()
+	p := NewOllamaProvider("", server.URL, "", 0, OllamaSettings{})
+
+	_, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, nil, "qwen3", nil)
+	if err == nil || !strings.Contains(err.Error(), "status 404") || !strings.Contains(err.Error(), "auto_pull") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestCreateProviderFromConfig_Ollama(t *testing.T) {
+	provider, modelID, err := createProviderFromConfig(&config.ModelConfig{
+		ModelName:     "local",
+		Model:         "ollama/qwen2.5:14b",
+		APIKey:        "secret",
+		ContextWindow: 16384,
+		Options:       map[string]any{"top_k": 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ollama, ok := provider.(*OllamaProvider)
+	if !ok || modelID != "qwen2.5:14b" {
+		t.Fatalf("provider %T, model %q", provider, modelID)
+	}
+	if ollama.apiBase != "http://localhost:11434" || ollama.apiKey != "secret" {
+		t.Errorf("api base = %q, api key = %q", ollama.apiBase, ollama.apiKey)
+	}
+	if opts := ollama.settings.Options; opts["num_ctx"] != 16384 || opts["top_k"] != 20 {
+		t.Errorf("options = %v", opts)
+	}
+}
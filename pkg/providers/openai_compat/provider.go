@@ -25,6 +25,8 @@ type (
 	ToolFunctionDefinition = protocoltypes.ToolFunctionDefinition
 	ExtraContent           = protocoltypes.ExtraContent
 	GoogleExtra            = protocoltypes.GoogleExtra
+	ContentPart            = protocoltypes.ContentPart
+	ResponseFormat         = protocoltypes.ResponseFormat
 )
 
 type Provider struct {
@@ -111,6 +113,39 @@ func (p *Provider) Chat(
 		return nil, fmt.Errorf("API base not configured")
 	}
 
+	requestBody := p.buildRequestBody(messages, tools, model, options)
+
+	resp, err := p.post(ctx, requestBody)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
+	}
+
+	out, err := parseResponse(body)
+	if err != nil {
+		return nil, err
+	}
+	protocoltypes.ResponseFormatFrom(options).TakeToolOutput(out)
+	return out, nil
+}
+
+// buildRequestBody assembles the chat-completions request shared by Chat and
+// ChatStream.
+func (p *Provider) buildRequestBody(
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) map[string]any {
 	model = normalizeModel(model, p.apiBase)
 
 	requestBody := map[string]any{
@@ -123,6 +158,10 @@ func (p *Provider) Chat(
 		requestBody["tool_choice"] = "auto"
 	}
 
+	if format := protocoltypes.ResponseFormatFrom(options); format != nil {
+		p.applyResponseFormat(requestBody, tools, format)
+	}
+
 	if maxTokens, ok := asInt(options["max_tokens"]); ok {
 		// Use configured maxTokensField if specified, otherwise fallback to model-based detection
 		fieldName := p.maxTokensField
@@ -149,6 +188,10 @@ func (p *Provider) Chat(
 		}
 	}
 
+	if reasoning := protocoltypes.ReasoningFrom(options); reasoning != nil {
+		p.applyReasoning(requestBody, reasoning)
+	}
+
 	// Prompt caching: pass a stable cache key so OpenAI can bucket requests
 	// with the same key and reuse prefix KV cache across calls.
 	// The key is typically the agent ID — stable per agent, shared across requests.
@@ -161,6 +204,65 @@ func (p *Provider) Chat(
 		}
 	}
 
+	return requestBody
+}
+
+// applyResponseFormat asks for a reply matching format: natively with
+// response_format, or by forcing a call of a tool taking the schema as its
+// input where the endpoint, such as Anthropic's, lacks json_schema support.
+func (p *Provider) applyResponseFormat(requestBody map[string]any, tools []ToolDefinition, format *ResponseFormat) {
+	if !strings.Contains(p.apiBase, "anthropic.com") {
+		requestBody["response_format"] = map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":        format.Name,
+				"description": format.Description,
+				"schema":      format.Schema,
+				"strict":      format.Strict,
+			},
+		}
+		return
+	}
+	requestBody["tools"] = append(tools, ToolDefinition{
+		Type: "function",
+		Function: ToolFunctionDefinition{
+			Name:        format.Name,
+			Description: format.Description,
+			Parameters:  format.Schema,
+		},
+	})
+	requestBody["tool_choice"] = map[string]any{
+		"type":     "function",
+		"function": map[string]any{"name": format.Name},
+	}
+}
+
+// applyReasoning asks for the requested reasoning: as reasoning_effort, or as
+// an extended-thinking budget on Anthropic's endpoint.
+func (p *Provider) applyReasoning(requestBody map[string]any, reasoning *protocoltypes.Reasoning) {
+	if !strings.Contains(p.apiBase, "anthropic.com") {
+		requestBody["reasoning_effort"] = reasoning.EffortLevel()
+		return
+	}
+	// Thinking cannot be combined with a forced tool call.
+	if _, forced := requestBody["tool_choice"].(map[string]any); forced || reasoning.Off() {
+		return
+	}
+	budget := reasoning.BudgetTokens()
+	requestBody["thinking"] = map[string]any{
+		"type":          "enabled",
+		"budget_tokens": budget,
+	}
+	// The budget counts toward max_tokens, and thinking needs the default
+	// temperature.
+	if maxTokens, ok := requestBody["max_tokens"].(int); ok && maxTokens <= budget {
+		requestBody["max_tokens"] = maxTokens + budget
+	}
+	delete(requestBody, "temperature")
+}
+
+// post sends a chat-completions request and returns the raw HTTP response.
+func (p *Provider) post(ctx context.Context, requestBody map[string]any) (*http.Response, error) {
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -180,18 +282,7 @@ func (p *Provider) Chat(
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed:\n  Status: %d\n  Body:   %s", resp.StatusCode, string(body))
-	}
-
-	return parseResponse(body)
+	return resp, nil
 }
 
 func parseResponse(body []byte) (*LLMResponse, error) {
@@ -200,6 +291,7 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 			Message struct {
 				Content          string `json:"content"`
 				ReasoningContent string `json:"reasoning_content"`
+				Reasoning        string `json:"reasoning"` // OpenRouter's name for reasoning_content
 				ToolCalls        []struct {
 					ID       string `json:"id"`
 					Type     string `json:"type"`
@@ -271,9 +363,14 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 		toolCalls = append(toolCalls, toolCall)
 	}
 
+	reasoning := choice.Message.ReasoningContent
+	if reasoning == "" {
+		reasoning = choice.Message.Reasoning
+	}
+
 	return &LLMResponse{
 		Content:          choice.Message.Content,
-		ReasoningContent: choice.Message.ReasoningContent,
+		ReasoningContent: reasoning,
 		ToolCalls:        toolCalls,
 		FinishReason:     choice.FinishReason,
 		Usage:            apiResponse.Usage,
@@ -283,9 +380,11 @@ func parseResponse(body []byte) (*LLMResponse, error) {
 // openaiMessage is the wire-format message for OpenAI-compatible APIs.
 // It mirrors protocoltypes.Message but omits SystemParts, which is an
 // internal field that would be unknown to third-party endpoints.
+// Content is either a plain string or, for multimodal messages, a list of
+// content parts.
 type openaiMessage struct {
 	Role       string     `json:"role"`
-	Content    string     `json:"content"`
+	Content    any        `json:"content"`
 	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string     `json:"tool_call_id,omitempty"`
 }
@@ -296,9 +395,13 @@ type openaiMessage struct {
 func stripSystemParts(messages []Message) []openaiMessage {
 	out := make([]openaiMessage, len(messages))
 	for i, m := range messages {
+		var content any = m.Content
+		if len(m.Parts) > 0 && m.Role == "user" {
+			content = encodeContentParts(m.Parts)
+		}
 		out[i] = openaiMessage{
 			Role:       m.Role,
-			Content:    m.Content,
+			Content:    content,
 			ToolCalls:  m.ToolCalls,
 			ToolCallID: m.ToolCallID,
 		}
@@ -306,6 +409,56 @@ func stripSystemParts(messages []Message) []openaiMessage {
 	return out
 }
 
+// encodeContentParts maps multimodal parts to the chat-completions content
+// array. Parts the API cannot carry inline degrade to a text placeholder.
+func encodeContentParts(parts []ContentPart) []map[string]any {
+	out := make([]map[string]any, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case "text":
+			out = append(out, map[string]any{"type": "text", "text": part.Text})
+		case "image":
+			out = append(out, map[string]any{
+				"type":      "image_url",
+				"image_url": map[string]any{"url": part.DataURL()},
+			})
+		case "audio":
+			format := audioFormat(part.MIMEType)
+			if part.Data == "" || format == "" {
+				out = append(out, map[string]any{"type": "text", "text": part.Placeholder()})
+				continue
+			}
+			out = append(out, map[string]any{
+				"type":        "input_audio",
+				"input_audio": map[string]any{"data": part.Data, "format": format},
+			})
+		default:
+			if part.Data == "" {
+				out = append(out, map[string]any{"type": "text", "text": part.Placeholder()})
+				continue
+			}
+			out = append(out, map[string]any{
+				"type": "file",
+				"file": map[string]any{"filename": part.Filename, "file_data": part.DataURL()},
+			})
+		}
+	}
+	return out
+}
+
+// audioFormat returns the input_audio format for a MIME type, or "" when the
+// chat-completions API does not accept it.
+func audioFormat(mimeType string) string {
+	switch strings.ToLower(mimeType) {
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return "wav"
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	default:
+		return ""
+	}
+}
+
 func normalizeModel(model, apiBase string) string {
 	idx := strings.Index(model, "/")
 	if idx == -1 {
@@ -361,3 +361,156 @@ func TestProvider_FunctionalOptionRequestTimeoutNonPositive(t *testing.T) {
 		t.Fatalf("http timeout = %v, want %v", p.httpClient.Timeout, defaultRequestTimeout)
 	}
 }
+
+func TestProviderChat_EncodesImageParts(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{
+				{"message": map[string]any{"content": "a cat"}, "finish_reason": "stop"},
+			},
+		})
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	_, err := p.Chat(t.Context(), []Message{{
+		Role:    "user",
+		Content: "what is this?",
+		Parts: []ContentPart{
+			{Type: "text", Text: "what is this?"},
+			{Type: "image", MIMEType: "image/png", Data: "aGVsbG8="},
+			{Type: "audio", MIMEType: "audio/ogg", Data: "aGVsbG8=", Filename: "voice.ogg"},
+		},
+	}}, nil, "gpt-4o", nil)
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+
+	msgs, _ := requestBody["messages"].([]any)
+	if len(msgs) != 1 {
+		t.Fatalf("len(messages) = %d, want 1", len(msgs))
+	}
+	content, ok := msgs[0].(map[string]any)["content"].([]any)
+	if !ok || len(content) != 3 {
+		t.Fatalf("content = %#v, want 3 parts", msgs[0].(map[string]any)["content"])
+	}
+	img := content[1].(map[string]any)
+	if img["type"] != "image_url" {
+		t.Fatalf("part[1].type = %v, want image_url", img["type"])
+	}
+	if url := img["image_url"].(map[string]any)["url"]; url != "data:image/png;base64,aGVsbG8=" {
+		t.Fatalf("image url = %v", url)
+	}
+	audio := content[2].(map[string]any)
+	if audio["type"] != "text" || audio["text"] != "[audio: voice.ogg]" {
+		t.Fatalf("unsupported audio should degrade to placeholder, got %#v", audio)
+	}
+}
+
+func TestProviderChat_TextOnlyContentStaysString(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"choices": []map[string]any{}})
+	}))
+	defer server.Close()
+
+	p := NewProvider("key", server.URL, "")
+	if _, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "hi"}}, nil, "gpt-4o", nil); err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	msgs, _ := requestBody["messages"].([]any)
+	if content := msgs[0].(map[string]any)["content"]; content != "hi" {
+		t.Fatalf("content = %#v, want plain string", content)
+	}
+}
+
+func TestProviderChat_ResponseFormat(t *testing.T) {
+	var requestBody map[string]any
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&requestBody)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"choices": []map[string]any{
+			{"message": map[string]any{"content": `{"city":"SF"}`}, "finish_reason": "stop"},
+		}})
+	}))
+	defer server.Close()
+
+	format := ResponseFormat{Name: "place", Schema: map[string]any{"type": "object"}}
+	p := NewProvider("key", server.URL, "")
+	resp, err := p.Chat(t.Context(), []Message{{Role: "user", Content: "where?"}}, nil, "gpt-4o",
+		map[string]any{"response_format": format})
+	if err != nil {
+		t.Fatalf("Chat() error = %v", err)
+	}
+	rf, _ := requestBody["response_format"].(map[string]any)
+	if rf["type"] != "json_schema" || rf["json_schema"].(map[string]any)["name"] != "place" {
+		t.Fatalf("response_format = %#v", requestBody["response_format"])
+	}
+	if resp.Content != `{"city":"SF"}` {
+		t.Fatalf("Content = %q", resp.Content)
+	}
+}
+
+func TestProviderChat_ResponseFormatForcesToolForAnthropic(t *testing.T) {
+	p := NewProvider("key", "https://api.anthropic.com/v1", "")
+	format := &ResponseFormat{Name: "place", Schema: map[string]any{"type": "object"}}
+	body := p.buildRequestBody([]Message{{Role: "user", Content: "where?"}}, nil, "claude-sonnet-4",
+		map[string]any{"response_format": format})
+
+	if _, ok := body["response_format"]; ok {
+		t.Fatal("did not expect response_format for the Anthropic endpoint")
+	}
+	tools, _ := body["tools"].([]ToolDefinition)
+	if len(tools) != 1 || tools[0].Function.Name != "place" {
+		t.Fatalf("tools = %#v", body["tools"])
+	}
+	choice, _ := body["tool_choice"].(map[string]any)
+	if choice["function"].(map[string]any)["name"] != "place" {
+		t.Fatalf("tool_choice = %#v", body["tool_choice"])
+	}
+}
+
+func TestProviderChat_Reasoning(t *testing.T) {
+	p := NewProvider("key", "https://api.openai.com/v1", "")
+	body := p.buildRequestBody([]Message{{Role: "user", Content: "why?"}}, nil, "o3",
+		map[string]any{"thinking_budget": 20000})
+	if body["reasoning_effort"] != "medium" {
+		t.Fatalf("reasoning_effort = %v", body["reasoning_effort"])
+	}
+
+	p = NewProvider("key", "https://api.anthropic.com/v1", "")
+	body = p.buildRequestBody([]Message{{Role: "user", Content: "why?"}}, nil, "claude-sonnet-4",
+		map[string]any{"reasoning_effort": "medium", "max_tokens": 4096, "temperature": 0.7})
+	thinking, _ := body["thinking"].(map[string]any)
+	if thinking["type"] != "enabled" || thinking["budget_tokens"] != 8192 {
+		t.Fatalf("thinking = %#v", body["thinking"])
+	}
+	if body["max_tokens"] != 4096+8192 {
+		t.Errorf("max_tokens = %v, want room for the budget", body["max_tokens"])
+	}
+	if _, ok := body["temperature"]; ok {
+		t.Error("temperature must be left out with thinking")
+	}
+}
+
+func TestParseResponse_OpenRouterReasoning(t *testing.T) {
+	resp, err := parseResponse([]byte(`{"choices":[{"message":{"content":"42","reasoning":"6 times 7"},` +
+		`"finish_reason":"stop"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ReasoningContent != "6 times 7" {
+		t.Errorf("ReasoningContent = %q", resp.ReasoningContent)
+	}
+}
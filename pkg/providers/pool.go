@@ -0,0 +1,214 @@
+package providers
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Pool hands out the provider of each model_list entry, so agents and
+// fallback candidates on different protocols or credentials each talk to
+// their own backend. Providers are created on first use and shared after.
+// The default provider, created by CreateProvider, serves the default model
+// and any model not in model_list.
+type Pool struct {
+	cfg      *config.Config
+	fallback LLMProvider
+
+	mu        sync.Mutex
+	providers map[string]*pooledProvider // by poolKey
+	closed    bool
+}
+
+type pooledProvider struct {
+	provider LLMProvider
+	modelID  string
+	owned    bool // created by the pool rather than the default provider
+}
+
+// NewPool returns a pool for cfg's model_list with fallback as the default
+// provider.
+func NewPool(cfg *config.Config, fallback LLMProvider) *Pool {
+	p := &Pool{
+		cfg:       cfg,
+		fallback:  fallback,
+		providers: map[string]*pooledProvider{},
+	}
+	if cfg != nil {
+		if mc, ok := p.lookup(cfg.Agents.Defaults.GetModelName()); ok {
+			p.providers[poolKey(mc)] = &pooledProvider{provider: fallback, modelID: entryModelID(mc)}
+		}
+	}
+	return p
+}
+
+// Get returns the provider for model, a model_name, a "protocol/model"
+// reference or a bare model ID, and the model ID to send it.
+func (p *Pool) Get(model string) (LLMProvider, string) {
+	mc, ok := p.lookup(model)
+	if !ok {
+		return p.fallback, model
+	}
+	pp := p.get(mc)
+	return pp.provider, pp.modelID
+}
+
+// Candidate returns the provider and model ID for a fallback candidate.
+func (p *Pool) Candidate(provider, model string) (LLMProvider, string) {
+	if provider != "" {
+		if mc, ok := p.lookup(provider + "/" + model); ok {
+			pp := p.get(mc)
+			return pp.provider, pp.modelID
+		}
+	}
+	return p.Get(model)
+}
+
+// Candidates returns a copy of candidates in which those naming a model_list
+// entry carry the entry's protocol as their provider, so that cooldowns
+// apply to the backend actually serving them rather than the default one.
+func (p *Pool) Candidates(candidates []FallbackCandidate) []FallbackCandidate {
+	if len(candidates) == 0 {
+		return candidates
+	}
+	out := make([]FallbackCandidate, len(candidates))
+	for i, c := range candidates {
+		out[i] = c
+		if p.cfg == nil {
+			continue
+		}
+		for _, mc := range p.cfg.ModelList {
+			if mc.ModelName == c.Model {
+				protocol, _ := ExtractProtocol(mc.Model)
+				out[i].Provider = NormalizeProvider(protocol)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// Close closes every provider of the pool that holds resources, the default
+// provider included.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	for _, pp := range p.providers {
+		if sp, ok := pp.provider.(StatefulProvider); ok && pp.owned {
+			sp.Close()
+		}
+	}
+	if sp, ok := p.fallback.(StatefulProvider); ok {
+		sp.Close()
+	}
+}
+
+func (p *Pool) get(mc *config.ModelConfig) *pooledProvider {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pp, ok := p.providers[poolKey(mc)]; ok {
+		return pp
+	}
+	pp := p.create(mc)
+	p.providers[poolKey(mc)] = pp
+	return pp
+}
+
+// poolKey is the model_name of mc, or its model for an unnamed entry.
+func poolKey(mc *config.ModelConfig) string {
+	if mc.ModelName != "" {
+		return mc.ModelName
+	}
+	return mc.Model
+}
+
+// create builds the provider for mc's model_name, balanced across all its
+// entries when there are several. An entry that cannot be built is served by
+// the default provider, as it was before the pool.
+func (p *Pool) create(mc *config.ModelConfig) *pooledProvider {
+	entries := []config.ModelConfig{*mc}
+	if mc.ModelName != "" {
+		entries = p.cfg.ModelConfigs(mc.ModelName)
+	}
+	for i := range entries {
+		if entries[i].Workspace == "" {
+			entries[i].Workspace = p.cfg.WorkspacePath()
+		}
+	}
+
+	var (
+		provider LLMProvider
+		modelID  string
+		err      error
+	)
+	if len(entries) > 1 {
+		var balanced *BalancedProvider
+		if balanced, err = NewBalancedProvider(entries); err == nil {
+			provider, modelID = balanced, balanced.GetDefaultModel()
+		}
+	} else {
+		provider, modelID, err = CreateProviderFromConfig(&entries[0])
+	}
+	if err != nil {
+		logger.WarnCF("provider", "Failed to create provider, using the default one",
+			map[string]any{
+				"model": poolKey(mc),
+				"error": err.Error(),
+			})
+		return &pooledProvider{provider: p.fallback, modelID: entryModelID(mc)}
+	}
+
+	logger.InfoCF("provider", "Created provider",
+		map[string]any{
+			"model":    poolKey(mc),
+			"model_id": modelID,
+			"entries":  len(entries),
+		})
+	return &pooledProvider{provider: provider, modelID: modelID, owned: true}
+}
+
+func entryModelID(mc *config.ModelConfig) string {
+	_, id := ExtractProtocol(mc.Model)
+	return id
+}
+
+// lookup finds the model_list entry model refers to: by model_name first,
+// then by its full model field, its protocol and ID, or its bare ID.
+func (p *Pool) lookup(model string) (*config.ModelConfig, bool) {
+	model = strings.TrimSpace(model)
+	if p.cfg == nil || model == "" {
+		return nil, false
+	}
+	list := p.cfg.ModelList
+	for i := range list {
+		if list[i].ModelName == model {
+			return &list[i], true
+		}
+	}
+	for i := range list {
+		if list[i].Model == model {
+			return &list[i], true
+		}
+	}
+	if idx := strings.Index(model, "/"); idx > 0 {
+		protocol, id := NormalizeProvider(model[:idx]), model[idx+1:]
+		for i := range list {
+			entryProtocol, entryID := ExtractProtocol(list[i].Model)
+			if NormalizeProvider(entryProtocol) == protocol && entryID == id {
+				return &list[i], true
+			}
+		}
+	}
+	for i := range list {
+		if _, id := ExtractProtocol(list[i].Model); id == model {
+			return &list[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// closingProvider counts how often it was closed.
+type closingProvider struct {
+	entryProvider
+	closed int
+}
+
+func (p *closingProvider) Close() { p.closed++ }
+
+func newTestPoolConfig() *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Agents.Defaults.ModelName = "default"
+	cfg.ModelList = []config.ModelConfig{
+		{ModelName: "default", Model: "openai/gpt-4o", APIKey: "k0"},
+		{ModelName: "claude", Model: "anthropic/claude-sonnet-4", APIKey: "k1"},
+		{ModelName: "router", Model: "openrouter/anthropic/claude-sonnet-4", APIKey: "k2"},
+		{ModelName: "broken", Model: "anthropic/claude-haiku"},
+		{ModelName: "balanced", Model: "openai/gpt-4o-mini", APIKey: "k3"},
+		{ModelName: "balanced", Model: "openai/gpt-4o-mini", APIKey: "k4"},
+	}
+	return cfg
+}
+
+func TestPool_ResolvesEntries(t *testing.T) {
+	fallback := &entryProvider{name: "default"}
+	pool := NewPool(newTestPoolConfig(), fallback)
+
+	claude, _ := pool.Get("claude")
+	router, _ := pool.Get("router")
+	balanced, _ := pool.Get("balanced")
+	if claude == fallback || router == fallback || claude == router {
+		t.Fatal("expected a provider of their own for the claude and router entries")
+	}
+	if _, ok := balanced.(*BalancedProvider); !ok {
+		t.Errorf("balanced entry is %T, want *BalancedProvider", balanced)
+	}
+
+	tests := []struct {
+		ref    string
+		want   LLMProvider
+		wantID string
+	}{
+		{"default", fallback, "gpt-4o"},
+		{"gpt-4o", fallback, "gpt-4o"},
+		{"claude", claude, "claude-sonnet-4"},
+		{"anthropic/claude-sonnet-4", claude, "claude-sonnet-4"},
+		{"claude-sonnet-4", claude, "claude-sonnet-4"},
+		{"router", router, "anthropic/claude-sonnet-4"},
+		{"openrouter/anthropic/claude-sonnet-4", router, "anthropic/claude-sonnet-4"},
+		{"balanced", balanced, "gpt-4o-mini"},
+		{"broken", fallback, "claude-haiku"},
+		{"not-listed", fallback, "not-listed"},
+	}
+	for _, tt := range tests {
+		provider, id := pool.Get(tt.ref)
+		if provider != tt.want || id != tt.wantID {
+			t.Errorf("Get(%q) = %T, %q; want %T, %q", tt.ref, provider, id, tt.want, tt.wantID)
+		}
+	}
+}
+
+func TestPool_Candidate(t *testing.T) {
+	pool := NewPool(newTestPoolConfig(), &entryProvider{name: "default"})
+
+	// A provider/model reference is matched before the bare model name.
+	provider, id := pool.Candidate("openrouter", "anthropic/claude-sonnet-4")
+	router, _ := pool.Get("router")
+	if provider != router || id != "anthropic/claude-sonnet-4" {
+		t.Errorf("openrouter candidate = %T, %q", provider, id)
+	}
+	provider, id = pool.Candidate("anthropic", "claude-sonnet-4")
+	claude, _ := pool.Get("claude")
+	if provider != claude || id != "claude-sonnet-4" {
+		t.Errorf("anthropic candidate = %T, %q", provider, id)
+	}
+
+	// A model_name parsed with the default provider still finds its entry.
+	if provider, _ = pool.Candidate("openai", "claude"); provider != claude {
+		t.Errorf("model_name candidate = %T", provider)
+	}
+}
+
+func TestPool_CandidatesTakeEntryProtocol(t *testing.T) {
+	pool := NewPool(newTestPoolConfig(), &entryProvider{name: "default"})
+	candidates := ResolveCandidates(ModelConfig{
+		Primary:   "claude",
+		Fallbacks: []string{"router", "groq/llama-3"},
+	}, "openai")
+
+	got := pool.Candidates(candidates)
+	want := []string{"anthropic/claude", "openrouter/router", "groq/llama-3"}
+	for i, c := range got {
+		if c.Provider+"/"+c.Model != want[i] {
+			t.Errorf("candidate %d = %s/%s, want %s", i, c.Provider, c.Model, want[i])
+		}
+	}
+	if candidates[0].Provider != "openai" {
+		t.Error("Candidates modified its argument")
+	}
+}
+
+func TestPool_CloseClosesAllOnce(t *testing.T) {
+	cfg := newTestPoolConfig()
+	fallback := &closingProvider{entryProvider: entryProvider{name: "default"}}
+	pool := NewPool(cfg, fallback)
+	owned := &closingProvider{entryProvider: entryProvider{name: "claude"}}
+	pool.providers["claude"] = &pooledProvider{provider: owned, modelID: "claude-sonnet-4", owned: true}
+	pool.Get("broken") // served by the default provider
+
+	pool.Close()
+	pool.Close()
+	if fallback.closed != 1 || owned.closed != 1 {
+		t.Errorf("closed default %d, owned %d times; want once each", fallback.closed, owned.closed)
+	}
+}
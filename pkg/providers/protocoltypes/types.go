@@ -1,5 +1,7 @@
 package protocoltypes
 
+import "encoding/json"
+
 type ToolCall struct {
 	ID               string         `json:"id"`
 	Type             string         `json:"type,omitempty"`
@@ -25,11 +27,14 @@ type FunctionCall struct {
 }
 
 type LLMResponse struct {
-	Content          string     `json:"content"`
-	ReasoningContent string     `json:"reasoning_content,omitempty"`
-	ToolCalls        []ToolCall `json:"tool_calls,omitempty"`
-	FinishReason     string     `json:"finish_reason"`
-	Usage            *UsageInfo `json:"usage,omitempty"`
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content,omitempty"`
+	// ReasoningSignature verifies ReasoningContent when it is sent back, as
+	// Anthropic requires for thinking followed by tool calls.
+	ReasoningSignature string     `json:"reasoning_signature,omitempty"`
+	ToolCalls          []ToolCall `json:"tool_calls,omitempty"`
+	FinishReason       string     `json:"finish_reason"`
+	Usage              *UsageInfo `json:"usage,omitempty"`
 }
 
 type UsageInfo struct {
@@ -53,13 +58,62 @@ type ContentBlock struct {
 	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
 
+// ContentPart is one segment of a multimodal message (text, image, audio or file).
+// Binary payloads are carried base64-encoded in Data so the message survives a
+// JSON round trip through the session store. Adapters map each part to their
+// native block type and degrade to a text placeholder when they cannot.
+type ContentPart struct {
+	Type     string `json:"type"` // "text", "image", "audio", "file"
+	Text     string `json:"text,omitempty"`
+	MIMEType string `json:"mime_type,omitempty"`
+	Data     string `json:"data,omitempty"` // base64-encoded payload
+	URL      string `json:"url,omitempty"`  // remote location when the payload was not inlined
+	Filename string `json:"filename,omitempty"`
+}
+
+// DataURL returns the part as a "data:" URL, or its remote URL when the
+// payload was not inlined.
+func (p ContentPart) DataURL() string {
+	if p.Data == "" {
+		return p.URL
+	}
+	return "data:" + p.MIMEType + ";base64," + p.Data
+}
+
+// Placeholder returns a short textual stand-in for adapters or models that
+// cannot consume the part natively.
+func (p ContentPart) Placeholder() string {
+	name := p.Filename
+	if name == "" {
+		name = p.URL
+	}
+	if name == "" {
+		return "[" + p.Type + "]"
+	}
+	return "[" + p.Type + ": " + name + "]"
+}
+
 type Message struct {
-	Role             string         `json:"role"`
-	Content          string         `json:"content"`
-	ReasoningContent string         `json:"reasoning_content,omitempty"`
-	SystemParts      []ContentBlock `json:"system_parts,omitempty"` // structured system blocks for cache-aware adapters
-	ToolCalls        []ToolCall     `json:"tool_calls,omitempty"`
-	ToolCallID       string         `json:"tool_call_id,omitempty"`
+	Role               string         `json:"role"`
+	Content            string         `json:"content"`
+	Parts              []ContentPart  `json:"parts,omitempty"` // multimodal content; Content keeps the text-only form
+	ReasoningContent   string         `json:"reasoning_content,omitempty"`
+	ReasoningSignature string         `json:"reasoning_signature,omitempty"`
+	SystemParts        []ContentBlock `json:"system_parts,omitempty"` // structured system blocks for cache-aware adapters
+	ToolCalls          []ToolCall     `json:"tool_calls,omitempty"`
+	ToolCallID         string         `json:"tool_call_id,omitempty"`
+	// Pinned messages survive history compaction. Never sent to providers.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+// HasMedia reports whether the message carries any non-text parts.
+func (m Message) HasMedia() bool {
+	for _, p := range m.Parts {
+		if p.Type != "text" {
+			return true
+		}
+	}
+	return false
 }
 
 type ToolDefinition struct {
@@ -72,3 +126,147 @@ type ToolFunctionDefinition struct {
 	Description string         `json:"description"`
 	Parameters  map[string]any `json:"parameters"`
 }
+
+// ResponseFormat asks for a reply that is a JSON value matching Schema. It is
+// passed to Chat as options["response_format"]. Providers map it to their
+// native structured output; the reply's Content is then the JSON text.
+type ResponseFormat struct {
+	Name        string         `json:"name"` // a-z, A-Z, 0-9, _ and -
+	Description string         `json:"description,omitempty"`
+	Schema      map[string]any `json:"schema"`
+	// Strict asks OpenAI for strict schema adherence, which requires every
+	// property to be required and additionalProperties to be false.
+	Strict bool `json:"strict,omitempty"`
+}
+
+// DefaultResponseFormatName names a response format given without a name.
+const DefaultResponseFormatName = "structured_output"
+
+// ResponseFormatFrom returns the response format in options, or nil when
+// there is none.
+func ResponseFormatFrom(options map[string]any) *ResponseFormat {
+	var format ResponseFormat
+	switch v := options["response_format"].(type) {
+	case ResponseFormat:
+		format = v
+	case *ResponseFormat:
+		if v == nil {
+			return nil
+		}
+		format = *v
+	default:
+		return nil
+	}
+	if format.Schema == nil {
+		return nil
+	}
+	if format.Name == "" {
+		format.Name = DefaultResponseFormatName
+	}
+	return &format
+}
+
+// TakeToolOutput moves the output of a response format forced as a tool
+// call, as with Anthropic, from the tool calls of resp to its Content.
+func (f *ResponseFormat) TakeToolOutput(resp *LLMResponse) {
+	if f == nil || resp == nil {
+		return
+	}
+	for i, tc := range resp.ToolCalls {
+		if tc.Name != f.Name && (tc.Function == nil || tc.Function.Name != f.Name) {
+			continue
+		}
+		switch {
+		case tc.Arguments != nil:
+			if data, err := json.Marshal(tc.Arguments); err == nil {
+				resp.Content = string(data)
+			}
+		case tc.Function != nil:
+			resp.Content = tc.Function.Arguments
+		}
+		resp.ToolCalls = append(resp.ToolCalls[:i:i], resp.ToolCalls[i+1:]...)
+		if len(resp.ToolCalls) == 0 && resp.FinishReason == "tool_calls" {
+			resp.FinishReason = "stop"
+		}
+		return
+	}
+}
+
+// ReasoningEfforts are the reasoning efforts a model can be asked for, from
+// none, which turns reasoning off, to high.
+var ReasoningEfforts = []string{"none", "minimal", "low", "medium", "high"}
+
+// MinThinkingBudget is the smallest thinking budget APIs accept; Anthropic
+// rejects anything below it.
+const MinThinkingBudget = 1024
+
+// thinkingBudgets are the thinking token budgets standing in for each
+// reasoning effort with APIs that take a budget.
+var thinkingBudgets = map[string]int{
+	"none":    0,
+	"minimal": MinThinkingBudget,
+	"low":     2048,
+	"medium":  8192,
+	"high":    24576,
+}
+
+// Reasoning is how much a model should think before it answers, set with the
+// "reasoning_effort" and "thinking_budget" options. APIs take either an
+// effort or a token budget; the one not set is derived from the other.
+type Reasoning struct {
+	Effort string // one of ReasoningEfforts
+	Budget int    // thinking tokens
+}
+
+// ReasoningFrom returns the reasoning requested in options, or nil when
+// there is none.
+func ReasoningFrom(options map[string]any) *Reasoning {
+	var r Reasoning
+	r.Effort, _ = options["reasoning_effort"].(string)
+	switch v := options["thinking_budget"].(type) {
+	case int:
+		r.Budget = v
+	case float64:
+		r.Budget = int(v)
+	}
+	if r.Effort == "" && r.Budget <= 0 {
+		return nil
+	}
+	return &r
+}
+
+// Off reports whether reasoning was turned off with the "none" effort.
+func (r *Reasoning) Off() bool {
+	return r.Effort == "none"
+}
+
+// EffortLevel returns the effort, or the one closest to the budget when
+// only a budget is set.
+func (r *Reasoning) EffortLevel() string {
+	switch {
+	case r.Effort != "":
+		return r.Effort
+	case r.Budget < thinkingBudgets["medium"]:
+		return "low"
+	case r.Budget < thinkingBudgets["high"]:
+		return "medium"
+	default:
+		return "high"
+	}
+}
+
+// BudgetTokens returns the budget, raised to MinThinkingBudget, or the one
+// standing in for the effort when only an effort is set. It is 0 when
+// reasoning is off.
+func (r *Reasoning) BudgetTokens() int {
+	if r.Off() {
+		return 0
+	}
+	if r.Budget > 0 {
+		return max(r.Budget, MinThinkingBudget)
+	}
+	if budget, ok := thinkingBudgets[r.Effort]; ok {
+		return budget
+	}
+	return thinkingBudgets["medium"]
+}
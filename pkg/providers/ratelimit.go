@@ -0,0 +1,236 @@
+package providers
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// slowWait is how long a request has to wait for its rate limit before the
+// wait is logged.
+const slowWait = time.Second
+
+// RateLimiter paces the requests to one model_list entry to its requests
+// per minute and, optionally, tokens per minute. Waiting callers are served
+// in arrival order.
+type RateLimiter struct {
+	name     string
+	rpm, tpm int
+	requests *rate.Limiter // nil without an RPM limit
+	tokens   *rate.Limiter // nil without a TPM limit
+
+	mu        sync.Mutex
+	waiting   int
+	served    int
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+// RateLimitStatus is a snapshot of a limiter's queue.
+type RateLimitStatus struct {
+	Model     string `json:"model"`
+	RPM       int    `json:"rpm,omitempty"`
+	TPM       int    `json:"tpm,omitempty"`
+	Waiting   int    `json:"waiting"` // requests queued right now
+	Served    int    `json:"served"`
+	AvgWaitMs int64  `json:"avg_wait_ms"`
+	MaxWaitMs int64  `json:"max_wait_ms"`
+}
+
+// NewRateLimiter returns a limiter for rpm requests and tpm tokens per
+// minute; zero leaves the respective limit off. Requests are spread evenly
+// over the minute rather than allowed in a burst, since providers count
+// their limits over sliding windows.
+func NewRateLimiter(name string, rpm, tpm int) *RateLimiter {
+	l := &RateLimiter{name: name, rpm: rpm, tpm: tpm}
+	if rpm > 0 {
+		l.requests = rate.NewLimiter(rate.Limit(float64(rpm)/60), 1)
+	}
+	if tpm > 0 {
+		l.tokens = rate.NewLimiter(rate.Limit(float64(tpm)/60), tpm)
+	}
+	return l
+}
+
+// Wait blocks until a request may be sent, or ctx is done. A request waits
+// for a free request slot and until the tokens used by earlier requests are
+// paid back.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+	l.mu.Lock()
+	l.waiting++
+	l.mu.Unlock()
+
+	err := l.wait(ctx)
+	waited := time.Since(start)
+
+	l.mu.Lock()
+	l.waiting--
+	queued := l.waiting
+	if err == nil {
+		l.served++
+		l.totalWait += waited
+		l.maxWait = max(l.maxWait, waited)
+	}
+	l.mu.Unlock()
+
+	if err == nil && waited >= slowWait {
+		logger.InfoCF("ratelimit", "Request delayed by rate limit",
+			map[string]any{
+				"model":   l.name,
+				"waited":  waited.Round(time.Millisecond).String(),
+				"waiting": queued,
+			})
+	}
+	return err
+}
+
+func (l *RateLimiter) wait(ctx context.Context) error {
+	if l.requests != nil {
+		if err := l.requests.Wait(ctx); err != nil {
+			return err
+		}
+	}
+	if l.tokens != nil {
+		return l.tokens.Wait(ctx)
+	}
+	return nil
+}
+
+// Used charges the tokens a request used against the TPM limit.
+func (l *RateLimiter) Used(resp *LLMResponse) {
+	if l.tokens == nil || resp == nil || resp.Usage == nil {
+		return
+	}
+	// A reservation larger than the burst is refused, so a single huge
+	// request is charged as a full minute.
+	n := min(resp.Usage.TotalTokens, l.tpm)
+	if n > 0 {
+		l.tokens.ReserveN(time.Now(), n)
+	}
+}
+
+// Status returns a snapshot of the limiter's queue.
+func (l *RateLimiter) Status() RateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := RateLimitStatus{
+		Model:     l.name,
+		RPM:       l.rpm,
+		TPM:       l.tpm,
+		Waiting:   l.waiting,
+		Served:    l.served,
+		MaxWaitMs: l.maxWait.Milliseconds(),
+	}
+	if l.served > 0 {
+		s.AvgWaitMs = (l.totalWait / time.Duration(l.served)).Milliseconds()
+	}
+	return s
+}
+
+var (
+	rateLimitersMu sync.Mutex
+	rateLimiters   = map[string]*RateLimiter{}
+)
+
+// RateLimiterFor returns the limiter shared by all providers created for a
+// model_list entry, or nil if the entry sets no limit.
+func RateLimiterFor(cfg *config.ModelConfig) *RateLimiter {
+	if cfg.RPM <= 0 && cfg.TPM <= 0 {
+		return nil
+	}
+	name := cfg.ModelName
+	if name == "" {
+		name = cfg.Model
+	}
+	key := name + "|" + cfg.Model + "|" + cfg.APIBase + "|" + cfg.APIKey
+
+	rateLimitersMu.Lock()
+	defer rateLimitersMu.Unlock()
+	l, ok := rateLimiters[key]
+	if !ok || l.rpm != cfg.RPM || l.tpm != cfg.TPM {
+		l = NewRateLimiter(name, cfg.RPM, cfg.TPM)
+		rateLimiters[key] = l
+	}
+	return l
+}
+
+// RateLimitStatuses reports the queues of all rate limiters in use.
+func RateLimitStatuses() []RateLimitStatus {
+	rateLimitersMu.Lock()
+	statuses := make([]RateLimitStatus, 0, len(rateLimiters))
+	for _, l := range rateLimiters {
+		statuses = append(statuses, l.Status())
+	}
+	rateLimitersMu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Model < statuses[j].Model })
+	return statuses
+}
+
+// WithRateLimit wraps provider so every request first waits for limiter.
+// The result is a StreamingProvider if provider is one.
+func WithRateLimit(provider LLMProvider, limiter *RateLimiter) LLMProvider {
+	if limiter == nil {
+		return provider
+	}
+	p := &rateLimitedProvider{LLMProvider: provider, limiter: limiter}
+	if sp, ok := provider.(StreamingProvider); ok {
+		return &rateLimitedStreamingProvider{rateLimitedProvider: p, streaming: sp}
+	}
+	return p
+}
+
+type rateLimitedProvider struct {
+	LLMProvider
+	limiter *RateLimiter
+}
+
+func (p *rateLimitedProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := p.LLMProvider.Chat(ctx, messages, tools, model, options)
+	p.limiter.Used(resp)
+	return resp, err
+}
+
+// Close closes the wrapped provider if it holds resources.
+func (p *rateLimitedProvider) Close() {
+	if sp, ok := p.LLMProvider.(StatefulProvider); ok {
+		sp.Close()
+	}
+}
+
+type rateLimitedStreamingProvider struct {
+	*rateLimitedProvider
+	streaming StreamingProvider
+}
+
+func (p *rateLimitedStreamingProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := p.streaming.ChatStream(ctx, messages, tools, model, options, onDelta)
+	p.limiter.Used(resp)
+	return resp, err
+}
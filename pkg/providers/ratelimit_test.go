@@ -0,0 +1,156 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+type countingProvider struct {
+	calls int
+	usage int
+}
+
+func (p *countingProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	p.calls++
+	return &LLMResponse{Content: "ok", Usage: &UsageInfo{TotalTokens: p.usage}}, nil
+}
+
+func (p *countingProvider) GetDefaultModel() string { return "m" }
+
+type countingStreamProvider struct{ countingProvider }
+
+func (p *countingStreamProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(string),
+) (*LLMResponse, error) {
+	onDelta("ok")
+	return p.Chat(ctx, messages, tools, model, options)
+}
+
+func TestRateLimiter_SpacesRequests(t *testing.T) {
+	// 600 rpm is one request every 100ms.
+	provider := WithRateLimit(&countingProvider{}, NewRateLimiter("fast", 600, 0))
+
+	start := time.Now()
+	for range 3 {
+		if _, err := provider.Chat(context.Background(), nil, nil, "m", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Errorf("3 requests at 600 rpm took %v, want at least 200ms", elapsed)
+	}
+}
+
+func TestRateLimiter_WaitHonorsContext(t *testing.T) {
+	limiter := NewRateLimiter("slow", 1, 0)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := limiter.Wait(ctx); err == nil {
+		t.Fatal("expected the second request within a minute to be refused")
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Wait outlived its context")
+	}
+
+	s := limiter.Status()
+	if s.Waiting != 0 || s.Served != 1 || s.RPM != 1 {
+		t.Errorf("status = %+v", s)
+	}
+}
+
+func TestRateLimiter_TokensPerMinute(t *testing.T) {
+	// The first two requests use up this minute's tokens and the next
+	// minute's; the third waits for them to be paid back.
+	inner := &countingProvider{usage: 6000}
+	provider := WithRateLimit(inner, NewRateLimiter("tpm", 0, 6000))
+	for range 2 {
+		if _, err := provider.Chat(context.Background(), nil, nil, "m", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := provider.Chat(ctx, nil, nil, "m", nil); err == nil {
+		t.Error("expected a request over the token budget to wait")
+	}
+	if inner.calls != 2 {
+		t.Errorf("provider called %d times, want 2", inner.calls)
+	}
+}
+
+func TestWithRateLimit_KeepsStreaming(t *testing.T) {
+	limiter := NewRateLimiter("s", 600, 0)
+	if _, ok := WithRateLimit(&countingProvider{}, limiter).(StreamingProvider); ok {
+		t.Error("non-streaming provider became streaming")
+	}
+	sp, ok := WithRateLimit(&countingStreamProvider{}, limiter).(StreamingProvider)
+	if !ok {
+		t.Fatal("streaming provider lost ChatStream")
+	}
+	var got string
+	if _, err := sp.ChatStream(context.Background(), nil, nil, "m", nil, func(d string) { got += d }); err != nil {
+		t.Fatal(err)
+	}
+	if got != "ok" || limiter.Status().Served != 1 {
+		t.Errorf("streamed %q, status %+v", got, limiter.Status())
+	}
+	if WithRateLimit(&countingProvider{}, nil) == nil {
+		t.Error("nil limiter dropped the provider")
+	}
+}
+
+func TestRateLimiterFor_SharedPerEntry(t *testing.T) {
+	entry := &config.ModelConfig{ModelName: "shared-rl", Model: "openai/gpt-4o", RPM: 30}
+	a := RateLimiterFor(entry)
+	if a == nil || RateLimiterFor(entry) != a {
+		t.Fatal("entries should share one limiter")
+	}
+	other := *entry
+	other.APIBase = "https://other.example.com/v1"
+	if RateLimiterFor(&other) == a {
+		t.Error("different endpoints share a limiter")
+	}
+	if RateLimiterFor(&config.ModelConfig{ModelName: "unlimited", Model: "openai/x"}) != nil {
+		t.Error("limiter created without limits")
+	}
+
+	provider, _, err := CreateProviderFromConfig(&config.ModelConfig{
+		ModelName: "shared-rl", Model: "openai/gpt-4o", APIKey: "k", RPM: 30,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := provider.(*rateLimitedStreamingProvider); !ok {
+		if _, ok := provider.(*rateLimitedProvider); !ok {
+			t.Errorf("provider %T is not rate limited", provider)
+		}
+	}
+
+	found := false
+	for _, s := range RateLimitStatuses() {
+		found = found || s.Model == "shared-rl"
+	}
+	if !found {
+		t.Error("limiter missing from RateLimitStatuses")
+	}
+}
@@ -0,0 +1,81 @@
+package providers
+
+import (
+	"context"
+	"maps"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// WithReasoning wraps provider so requests that do not set reasoning_effort
+// or thinking_budget themselves use those of the model_list entry cfg.
+func WithReasoning(provider LLMProvider, cfg *config.ModelConfig) LLMProvider {
+	if cfg.ReasoningEffort == "" && cfg.ThinkingBudget <= 0 {
+		return provider
+	}
+	p := &reasoningProvider{LLMProvider: provider, effort: cfg.ReasoningEffort, budget: cfg.ThinkingBudget}
+	if sp, ok := provider.(StreamingProvider); ok {
+		return &reasoningStreamingProvider{reasoningProvider: p, streaming: sp}
+	}
+	return p
+}
+
+type reasoningProvider struct {
+	LLMProvider
+	effort string
+	budget int
+}
+
+// options returns options with the entry's reasoning, unless they carry
+// their own, such as a conversation's /think setting.
+func (p *reasoningProvider) options(options map[string]any) map[string]any {
+	_, hasEffort := options["reasoning_effort"]
+	_, hasBudget := options["thinking_budget"]
+	if hasEffort || hasBudget {
+		return options
+	}
+	opts := maps.Clone(options)
+	if opts == nil {
+		opts = map[string]any{}
+	}
+	if p.effort != "" {
+		opts["reasoning_effort"] = p.effort
+	}
+	if p.budget > 0 {
+		opts["thinking_budget"] = p.budget
+	}
+	return opts
+}
+
+func (p *reasoningProvider) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	return p.LLMProvider.Chat(ctx, messages, tools, model, p.options(options))
+}
+
+// Close closes the wrapped provider if it holds resources.
+func (p *reasoningProvider) Close() {
+	if sp, ok := p.LLMProvider.(StatefulProvider); ok {
+		sp.Close()
+	}
+}
+
+type reasoningStreamingProvider struct {
+	*reasoningProvider
+	streaming StreamingProvider
+}
+
+func (p *reasoningStreamingProvider) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(delta string),
+) (*LLMResponse, error) {
+	return p.streaming.ChatStream(ctx, messages, tools, model, p.options(options), onDelta)
+}
@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// optionsRecorder records the options of its last request.
+type optionsRecorder struct {
+	countingProvider
+	options map[string]any
+}
+
+func (p *optionsRecorder) Chat(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	p.options = options
+	return &LLMResponse{Content: "ok"}, nil
+}
+
+func (p *optionsRecorder) ChatStream(
+	ctx context.Context,
+	messages []Message,
+	tools []ToolDefinition,
+	model string,
+	options map[string]any,
+	onDelta func(string),
+) (*LLMResponse, error) {
+	return p.Chat(ctx, messages, tools, model, options)
+}
+
+func TestWithReasoning(t *testing.T) {
+	inner := &optionsRecorder{}
+	if WithReasoning(inner, &config.ModelConfig{}) != LLMProvider(inner) {
+		t.Error("provider wrapped without reasoning settings")
+	}
+
+	p := WithReasoning(inner, &config.ModelConfig{ReasoningEffort: "high", ThinkingBudget: 4096})
+	sp, ok := p.(StreamingProvider)
+	if !ok {
+		t.Fatal("streaming provider lost ChatStream")
+	}
+
+	options := map[string]any{"max_tokens": 1000}
+	if _, err := p.Chat(context.Background(), nil, nil, "m", options); err != nil {
+		t.Fatal(err)
+	}
+	if inner.options["reasoning_effort"] != "high" || inner.options["thinking_budget"] != 4096 ||
+		inner.options["max_tokens"] != 1000 {
+		t.Errorf("options = %v", inner.options)
+	}
+	if _, ok := options["reasoning_effort"]; ok {
+		t.Error("caller's options were modified")
+	}
+
+	// A request's own setting, such as /think, replaces both.
+	if _, err := sp.ChatStream(context.Background(), nil, nil, "m",
+		map[string]any{"reasoning_effort": "none"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if inner.options["reasoning_effort"] != "none" || inner.options["thinking_budget"] != nil {
+		t.Errorf("overridden options = %v", inner.options)
+	}
+}
+
+func TestReasoningFrom(t *testing.T) {
+	if ReasoningFrom(map[string]any{"max_tokens": 10}) != nil {
+		t.Error("expected no reasoning")
+	}
+
+	tests := []struct {
+		options map[string]any
+		effort  string
+		budget  int
+		off     bool
+	}{
+		{map[string]any{"reasoning_effort": "high"}, "high", 24576, false},
+		{map[string]any{"reasoning_effort": "low"}, "low", 2048, false},
+		{map[string]any{"reasoning_effort": "none"}, "none", 0, true},
+		{map[string]any{"thinking_budget": 1024}, "low", 1024, false},
+		{map[string]any{"thinking_budget": 100}, "low", MinThinkingBudget, false},
+		{map[string]any{"thinking_budget": float64(10000)}, "medium", 10000, false},
+		{map[string]any{"thinking_budget": 32000}, "high", 32000, false},
+		{map[string]any{"reasoning_effort": "minimal", "thinking_budget": 5000}, "minimal", 5000, false},
+	}
+	for _, tt := range tests {
+		r := ReasoningFrom(tt.options)
+		if r == nil {
+			t.Fatalf("ReasoningFrom(%v) = nil", tt.options)
+		}
+		if r.EffortLevel() != tt.effort || r.BudgetTokens() != tt.budget || r.Off() != tt.off {
+			t.Errorf("ReasoningFrom(%v) = effort %q, budget %d, off %v", tt.options,
+				r.EffortLevel(), r.BudgetTokens(), r.Off())
+		}
+	}
+}
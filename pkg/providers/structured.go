@@ -0,0 +1,178 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/google/jsonschema-go/jsonschema"
+
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// maxStructuredRetries is how often ChatStructured sends a reply that does
+// not match the schema back for correction.
+const maxStructuredRetries = 2
+
+// StructuredOutputError reports a reply that still did not match the schema
+// after the retries. Content is the last reply.
+type StructuredOutputError struct {
+	Content string
+	Err     error
+}
+
+func (e *StructuredOutputError) Error() string {
+	return fmt.Sprintf("reply does not match the response schema: %v", e.Err)
+}
+
+func (e *StructuredOutputError) Unwrap() error {
+	return e.Err
+}
+
+// ChatStructured asks provider for a reply matching format and decodes it
+// into out, if not nil. Providers map the format to their native structured
+// output where they have one; the reply is validated against the schema
+// either way, and an invalid one is sent back with the violation up to
+// maxStructuredRetries times. The returned response holds the JSON reply and
+// the usage of all attempts.
+func ChatStructured(
+	ctx context.Context,
+	provider LLMProvider,
+	messages []Message,
+	model string,
+	options map[string]any,
+	format ResponseFormat,
+	out any,
+) (*LLMResponse, error) {
+	return chatStructured(ctx, provider, messages, model, options, format, out, maxStructuredRetries)
+}
+
+// ChatStructuredOnce is ChatStructured without the correction retries: an
+// invalid reply is returned at once in a StructuredOutputError. It suits
+// callers that can use a plain-text reply as is, which saves the retries
+// on providers without native structured output.
+func ChatStructuredOnce(
+	ctx context.Context,
+	provider LLMProvider,
+	messages []Message,
+	model string,
+	options map[string]any,
+	format ResponseFormat,
+	out any,
+) (*LLMResponse, error) {
+	return chatStructured(ctx, provider, messages, model, options, format, out, 0)
+}
+
+func chatStructured(
+	ctx context.Context,
+	provider LLMProvider,
+	messages []Message,
+	model string,
+	options map[string]any,
+	format ResponseFormat,
+	out any,
+	retries int,
+) (*LLMResponse, error) {
+	schema, err := resolveSchema(format.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("invalid response schema: %w", err)
+	}
+	opts := maps.Clone(options)
+	if opts == nil {
+		opts = map[string]any{}
+	}
+	opts["response_format"] = format
+	messages = append([]Message(nil), messages...)
+
+	var usage *UsageInfo
+	for attempt := 0; ; attempt++ {
+		resp, err := provider.Chat(ctx, messages, nil, model, opts)
+		if resp != nil {
+			usage = addUsage(usage, resp.Usage)
+		}
+		if err != nil {
+			return resp, err
+		}
+		resp.Usage = usage
+
+		content := trimJSONFence(resp.Content)
+		verr := validateStructured(schema, content)
+		if verr == nil {
+			resp.Content = content
+			if out != nil {
+				if err := json.Unmarshal([]byte(content), out); err != nil {
+					return resp, fmt.Errorf("decoding structured reply: %w", err)
+				}
+			}
+			return resp, nil
+		}
+		if attempt == retries {
+			return resp, &StructuredOutputError{Content: resp.Content, Err: verr}
+		}
+
+		logger.WarnCF("provider", "Reply does not match the response schema, retrying",
+			map[string]any{
+				"model":   model,
+				"format":  format.Name,
+				"attempt": attempt + 1,
+				"error":   verr.Error(),
+			})
+		schemaJSON, _ := json.Marshal(format.Schema)
+		messages = append(messages,
+			Message{Role: "assistant", Content: resp.Content},
+			Message{Role: "user", Content: fmt.Sprintf(
+				"Your reply does not match the required JSON schema: %v\n"+
+					"Reply again with only a JSON value matching this schema:\n%s", verr, schemaJSON)},
+		)
+	}
+}
+
+// resolveSchema compiles a JSON schema given as a map.
+func resolveSchema(schema map[string]any) (*jsonschema.Resolved, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var s jsonschema.Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	return s.Resolve(nil)
+}
+
+// validateStructured checks that content is JSON matching schema.
+func validateStructured(schema *jsonschema.Resolved, content string) error {
+	var value any
+	if err := json.Unmarshal([]byte(content), &value); err != nil {
+		return fmt.Errorf("not valid JSON: %w", err)
+	}
+	return schema.Validate(value)
+}
+
+// trimJSONFence returns content without surrounding space and the Markdown
+// code fence some models wrap JSON in.
+func trimJSONFence(content string) string {
+	content = strings.TrimSpace(content)
+	if rest, ok := strings.CutPrefix(content, "```"); ok {
+		if body, ok := strings.CutSuffix(rest, "```"); ok {
+			body = strings.TrimPrefix(body, "json")
+			content = strings.TrimSpace(body)
+		}
+	}
+	return content
+}
+
+func addUsage(total, usage *UsageInfo) *UsageInfo {
+	if usage == nil {
+		return total
+	}
+	if total == nil {
+		total = &UsageInfo{}
+	}
+	total.PromptTokens += usage.PromptTokens
+	total.CompletionTokens += usage.CompletionTokens
+	total.TotalTokens += usage.TotalTokens
+	return total
+}
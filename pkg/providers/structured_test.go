@@ -0,0 +1,122 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// scriptedProvider answers with its replies in turn and keeps the requests.
+type scriptedProvider struct {
+	replies  []string
+	messages [][]Message
+	options  []map[string]any
+}
+
+func (p *scriptedProvider) Chat(
+	_ context.Context,
+	messages []Message,
+	_ []ToolDefinition,
+	_ string,
+	options map[string]any,
+) (*LLMResponse, error) {
+	p.messages = append(p.messages, messages)
+	p.options = append(p.options, options)
+	reply := p.replies[min(len(p.messages), len(p.replies))-1]
+	return &LLMResponse{Content: reply, Usage: &UsageInfo{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12}}, nil
+}
+
+func (p *scriptedProvider) GetDefaultModel() string { return "" }
+
+var testPlaceFormat = ResponseFormat{
+	Name: "place",
+	Schema: map[string]any{
+		"type":       "object",
+		"properties": map[string]any{"city": map[string]any{"type": "string"}},
+		"required":   []string{"city"},
+	},
+}
+
+func TestChatStructured_RetriesUntilValid(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{
+		"It is San Francisco.",
+		`{"city": 3}`,
+		"```json\n{\"city\": \"SF\"}\n```",
+	}}
+
+	var place struct{ City string }
+	resp, err := ChatStructured(context.Background(), provider, []Message{{Role: "user", Content: "where?"}},
+		"m", map[string]any{"temperature": 0.1}, testPlaceFormat, &place)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if place.City != "SF" || resp.Content != `{"city": "SF"}` {
+		t.Errorf("place = %+v, content %q", place, resp.Content)
+	}
+	if resp.Usage == nil || resp.Usage.TotalTokens != 36 {
+		t.Errorf("usage = %+v, want the sum of all attempts", resp.Usage)
+	}
+
+	if len(provider.messages) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(provider.messages))
+	}
+	if f := ResponseFormatFrom(provider.options[0]); f == nil || f.Name != "place" || provider.options[0]["temperature"] != 0.1 {
+		t.Errorf("options = %v", provider.options[0])
+	}
+	retry := provider.messages[2]
+	if len(retry) != 5 || retry[3].Role != "assistant" || !strings.Contains(retry[4].Content, "does not match") {
+		t.Errorf("retry messages = %+v", retry)
+	}
+}
+
+func TestChatStructured_GivesUp(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{"San Francisco"}}
+
+	resp, err := ChatStructured(context.Background(), provider, nil, "m", nil, testPlaceFormat, nil)
+	var structErr *StructuredOutputError
+	if !errors.As(err, &structErr) || structErr.Content != "San Francisco" {
+		t.Fatalf("error = %v", err)
+	}
+	if len(provider.messages) != maxStructuredRetries+1 || resp == nil {
+		t.Errorf("sent %d requests, response %+v", len(provider.messages), resp)
+	}
+
+	if _, err := ChatStructured(context.Background(), provider, nil, "m", nil,
+		ResponseFormat{Schema: map[string]any{"type": 5}}, nil); err == nil {
+		t.Error("expected an invalid schema to be rejected")
+	}
+}
+
+func TestChatStructuredOnce_DoesNotRetry(t *testing.T) {
+	provider := &scriptedProvider{replies: []string{"San Francisco", `{"city": "SF"}`}}
+
+	_, err := ChatStructuredOnce(context.Background(), provider, nil, "m", nil, testPlaceFormat, nil)
+	var structErr *StructuredOutputError
+	if !errors.As(err, &structErr) || structErr.Content != "San Francisco" {
+		t.Fatalf("error = %v", err)
+	}
+	if len(provider.messages) != 1 {
+		t.Errorf("sent %d requests, want 1", len(provider.messages))
+	}
+}
+
+func TestResponseFormat_TakeToolOutput(t *testing.T) {
+	resp := &LLMResponse{
+		FinishReason: "tool_calls",
+		ToolCalls:    []ToolCall{{ID: "t1", Name: "place", Arguments: map[string]any{"city": "SF"}}},
+	}
+	format := ResponseFormatFrom(map[string]any{"response_format": testPlaceFormat})
+	format.TakeToolOutput(resp)
+	if resp.Content != `{"city":"SF"}` || len(resp.ToolCalls) != 0 || resp.FinishReason != "stop" {
+		t.Errorf("response = %+v", resp)
+	}
+
+	if ResponseFormatFrom(map[string]any{"response_format": ResponseFormat{}}) != nil {
+		t.Error("a format without a schema should be ignored")
+	}
+	if f := ResponseFormatFrom(map[string]any{"response_format": &ResponseFormat{Schema: map[string]any{}}}); f == nil ||
+		f.Name != "structured_output" {
+		t.Errorf("unnamed format = %+v", f)
+	}
+}
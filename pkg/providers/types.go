@@ -18,9 +18,30 @@ type (
 	ExtraContent           = protocoltypes.ExtraContent
 	GoogleExtra            = protocoltypes.GoogleExtra
 	ContentBlock           = protocoltypes.ContentBlock
+	ContentPart            = protocoltypes.ContentPart
 	CacheControl           = protocoltypes.CacheControl
+	ResponseFormat         = protocoltypes.ResponseFormat
+	Reasoning              = protocoltypes.Reasoning
 )
 
+// ReasoningEfforts are the reasoning efforts a model can be asked for.
+var ReasoningEfforts = protocoltypes.ReasoningEfforts
+
+// MinThinkingBudget is the smallest thinking budget APIs accept.
+const MinThinkingBudget = protocoltypes.MinThinkingBudget
+
+// ResponseFormatFrom returns the response format in options, or nil when
+// there is none.
+func ResponseFormatFrom(options map[string]any) *ResponseFormat {
+	return protocoltypes.ResponseFormatFrom(options)
+}
+
+// ReasoningFrom returns the reasoning requested in options, or nil when
+// there is none.
+func ReasoningFrom(options map[string]any) *Reasoning {
+	return protocoltypes.ReasoningFrom(options)
+}
+
 type LLMProvider interface {
 	Chat(
 		ctx context.Context,
@@ -32,6 +53,21 @@ type LLMProvider interface {
 	GetDefaultModel() string
 }
 
+// StreamingProvider is implemented by providers that can deliver response
+// text incrementally. ChatStream calls onDelta for every text fragment as it
+// arrives and returns the same aggregated response Chat would.
+type StreamingProvider interface {
+	LLMProvider
+	ChatStream(
+		ctx context.Context,
+		messages []Message,
+		tools []ToolDefinition,
+		model string,
+		options map[string]any,
+		onDelta func(delta string),
+	) (*LLMResponse, error)
+}
+
 type StatefulProvider interface {
 	LLMProvider
 	Close()
@@ -0,0 +1,217 @@
+package session
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// BoltStore keeps every session in one embedded database file. Each session
+// is a bucket holding its metadata and a sub-bucket of messages keyed by
+// sequence number, so saving new messages only writes those messages.
+type BoltStore struct {
+	path   string
+	db     *bolt.DB
+	closed bool
+}
+
+var (
+	bucketSessions = []byte("sessions")
+	bucketMessages = []byte("messages")
+	keyMeta        = []byte("meta")
+)
+
+// boltOpenTimeout bounds how long opening waits for another process holding
+// the database file.
+const boltOpenTimeout = 2 * time.Second
+
+// Agents sharing a workspace share its sessions directory. A database file
+// can only be opened once, so opens of the same path share one handle.
+var (
+	boltMu   sync.Mutex
+	boltDBs  = map[string]*bolt.DB{}
+	boltRefs = map[string]int{}
+)
+
+// OpenBoltStore opens (creating if needed) the session database at path.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	boltMu.Lock()
+	defer boltMu.Unlock()
+
+	db, ok := boltDBs[abs]
+	if !ok {
+		db, err = bolt.Open(abs, 0o600, &bolt.Options{Timeout: boltOpenTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("open session database %s: %w", abs, err)
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			_, err := tx.CreateBucketIfNotExists(bucketSessions)
+			return err
+		})
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		boltDBs[abs] = db
+	}
+	boltRefs[abs]++
+	return &BoltStore{path: abs, db: db}, nil
+}
+
+// boltMeta is the stored form of a session's metadata.
+type boltMeta struct {
+	Key         string       `json:"key"`
+	Summary     string       `json:"summary,omitempty"`
+	Created     time.Time    `json:"created"`
+	Updated     time.Time    `json:"updated"`
+	Compactions []Compaction `json:"compactions,omitempty"`
+	Overrides   Overrides    `json:"overrides,omitzero"`
+}
+
+func (s *BoltStore) Load(key string) (*Session, error) {
+	var sess *Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketSessions).Bucket([]byte(key))
+		if b == nil {
+			return nil
+		}
+		var meta boltMeta
+		if err := json.Unmarshal(b.Get(keyMeta), &meta); err != nil {
+			return fmt.Errorf("session %q: %w", key, err)
+		}
+		sess = &Session{
+			Key:         key,
+			Summary:     meta.Summary,
+			Created:     meta.Created,
+			Updated:     meta.Updated,
+			Compactions: meta.Compactions,
+			Overrides:   meta.Overrides,
+			Messages:    []providers.Message{},
+		}
+		msgs := b.Bucket(bucketMessages)
+		if msgs == nil {
+			return nil
+		}
+		return msgs.ForEach(func(_, v []byte) error {
+			var msg providers.Message
+			if err := json.Unmarshal(v, &msg); err != nil {
+				return fmt.Errorf("session %q: %w", key, err)
+			}
+			sess.Messages = append(sess.Messages, msg)
+			return nil
+		})
+	})
+	return sess, err
+}
+
+func (s *BoltStore) Append(sess *Session, msgs []providers.Message) error {
+	return s.write(sess, msgs, false)
+}
+
+func (s *BoltStore) Replace(sess *Session) error {
+	return s.write(sess, sess.Messages, true)
+}
+
+func (s *BoltStore) write(sess *Session, msgs []providers.Message, replace bool) error {
+	if sess.Key == "" {
+		return errors.New("session key is empty")
+	}
+	meta, err := json.Marshal(boltMeta{
+		Key:         sess.Key,
+		Summary:     sess.Summary,
+		Created:     sess.Created,
+		Updated:     sess.Updated,
+		Compactions: sess.Compactions,
+		Overrides:   sess.Overrides,
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(bucketSessions)
+		name := []byte(sess.Key)
+		if replace && sessions.Bucket(name) != nil {
+			if err := sessions.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+		b, err := sessions.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(keyMeta, meta); err != nil {
+			return err
+		}
+		mb, err := b.CreateBucketIfNotExists(bucketMessages)
+		if err != nil {
+			return err
+		}
+		for _, msg := range msgs {
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return err
+			}
+			seq, err := mb.NextSequence()
+			if err != nil {
+				return err
+			}
+			if err := mb.Put(binary.BigEndian.AppendUint64(nil, seq), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Delete(key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(bucketSessions).DeleteBucket([]byte(key))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+func (s *BoltStore) Keys() ([]string, error) {
+	var keys []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSessions).ForEachBucket(func(k []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
+
+// Close releases this handle; the database closes with its last handle.
+func (s *BoltStore) Close() error {
+	boltMu.Lock()
+	defer boltMu.Unlock()
+
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	boltRefs[s.path]--
+	if boltRefs[s.path] > 0 {
+		return nil
+	}
+	delete(boltDBs, s.path)
+	delete(boltRefs, s.path)
+	return s.db.Close()
+}
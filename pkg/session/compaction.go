@@ -0,0 +1,102 @@
+package session
+
+import (
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// maxCompactionRecords bounds the compaction log kept with each session.
+const maxCompactionRecords = 20
+
+// Compaction records one compaction of a session's history, kept in the
+// session file for auditing.
+type Compaction struct {
+	Time time.Time `json:"time"`
+	// Reason is "summary" for routine summarization or "overflow" when the
+	// provider rejected the request as too long.
+	Reason string `json:"reason"`
+	// Before and After are the history lengths around the compaction.
+	Before int `json:"before"`
+	After  int `json:"after"`
+	// Removed counts the messages taken out of the history, and Tools names
+	// the tool calls among them.
+	Removed int      `json:"removed"`
+	Tools   []string `json:"tools,omitempty"`
+	// Digested counts kept tool results shortened to a digest.
+	Digested int `json:"digested,omitempty"`
+	// Pinned counts pinned messages kept from the compacted span.
+	Pinned int `json:"pinned,omitempty"`
+}
+
+// Compact replaces the first n messages of the session's history with kept
+// and records c. Messages added after those n are left in place, so a
+// compaction computed from an earlier snapshot does not lose them. A
+// non-empty summary replaces the session's summary. It reports false and
+// changes nothing if the history is shorter than n, i.e. it was rewritten
+// since the snapshot.
+func (sm *SessionManager) Compact(
+	key string,
+	n int,
+	kept []providers.Message,
+	summary string,
+	c Compaction,
+) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	e := sm.lookupLocked(key, false)
+	if e == nil || len(e.session.Messages) < n {
+		return false
+	}
+
+	added := e.session.Messages[n:]
+	msgs := make([]providers.Message, 0, len(kept)+len(added))
+	msgs = append(msgs, kept...)
+	msgs = append(msgs, added...)
+
+	if c.Time.IsZero() {
+		c.Time = time.Now()
+	}
+	c.Before = len(e.session.Messages)
+	c.After = len(msgs)
+
+	e.session.Messages = msgs
+	if summary != "" {
+		e.session.Summary = summary
+	}
+	e.session.Compactions = append(e.session.Compactions, c)
+	if extra := len(e.session.Compactions) - maxCompactionRecords; extra > 0 {
+		e.session.Compactions = append([]Compaction(nil), e.session.Compactions[extra:]...)
+	}
+	e.session.Updated = time.Now()
+	e.rewrite = true
+	e.changes++
+	return true
+}
+
+// PinLastUserMessage pins the session's most recent user message so it
+// survives compaction, and returns it.
+func (sm *SessionManager) PinLastUserMessage(key string) (providers.Message, bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	e := sm.lookupLocked(key, false)
+	if e == nil {
+		return providers.Message{}, false
+	}
+	msgs := e.session.Messages
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role != "user" {
+			continue
+		}
+		if !msgs[i].Pinned {
+			msgs[i].Pinned = true
+			e.session.Updated = time.Now()
+			e.rewrite = true
+			e.changes++
+		}
+		return msgs[i], true
+	}
+	return providers.Message{}, false
+}
@@ -0,0 +1,92 @@
+package session
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSessionManager_CompactKeepsLaterMessages(t *testing.T) {
+	for kind, store := range openTestStores(t) {
+		t.Run(kind, func(t *testing.T) {
+			sm := NewSessionManagerWithStore(store, 0)
+			for _, c := range []string{"1", "2", "3", "4"} {
+				sm.AddMessage("k", "user", c)
+			}
+			snapshot := sm.GetHistory("k")
+			// A message arriving while the compaction was computed.
+			sm.AddMessage("k", "user", "5")
+
+			ok := sm.Compact("k", len(snapshot), snapshot[2:], "talked about 1 and 2",
+				Compaction{Reason: "summary", Removed: 2, Tools: []string{"exec"}})
+			if !ok {
+				t.Fatal("Compact rejected a valid snapshot")
+			}
+			if err := sm.Save("k"); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := store.Load("k")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := contents(loaded.Messages); !slices.Equal(got, []string{"3", "4", "5"}) {
+				t.Errorf("stored history = %v", got)
+			}
+			if loaded.Summary != "talked about 1 and 2" {
+				t.Errorf("summary = %q", loaded.Summary)
+			}
+			if len(loaded.Compactions) != 1 {
+				t.Fatalf("compactions = %+v", loaded.Compactions)
+			}
+			c := loaded.Compactions[0]
+			if c.Before != 5 || c.After != 3 || c.Removed != 2 || c.Time.IsZero() || c.Tools[0] != "exec" {
+				t.Errorf("compaction record = %+v", c)
+			}
+		})
+	}
+}
+
+func TestSessionManager_CompactRejectsStaleSnapshot(t *testing.T) {
+	sm := NewSessionManager("")
+	for _, c := range []string{"1", "2", "3"} {
+		sm.AddMessage("k", "user", c)
+	}
+	snapshot := sm.GetHistory("k")
+	sm.TruncateHistory("k", 1)
+
+	if sm.Compact("k", len(snapshot), snapshot[1:], "", Compaction{Reason: "summary"}) {
+		t.Error("Compact should reject a snapshot of rewritten history")
+	}
+	if got := contents(sm.GetHistory("k")); !slices.Equal(got, []string{"3"}) {
+		t.Errorf("history changed: %v", got)
+	}
+}
+
+func TestSessionManager_CompactionLogIsBounded(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.AddMessage("k", "user", "1")
+	for i := 0; i < maxCompactionRecords+5; i++ {
+		sm.Compact("k", 1, sm.GetHistory("k"), "", Compaction{Reason: "summary", Removed: i})
+	}
+	log := sm.GetOrCreate("k").Compactions
+	if len(log) != maxCompactionRecords || log[0].Removed != 5 {
+		t.Errorf("log has %d records starting at %d", len(log), log[0].Removed)
+	}
+}
+
+func TestSessionManager_PinLastUserMessage(t *testing.T) {
+	sm := NewSessionManager("")
+	if _, ok := sm.PinLastUserMessage("k"); ok {
+		t.Error("pinned a message in a missing session")
+	}
+	sm.AddMessage("k", "user", "keep me")
+	sm.AddMessage("k", "assistant", "ok")
+
+	pinned, ok := sm.PinLastUserMessage("k")
+	if !ok || pinned.Content != "keep me" {
+		t.Fatalf("PinLastUserMessage = %+v, %v", pinned, ok)
+	}
+	if h := sm.GetHistory("k"); !h[0].Pinned || h[1].Pinned {
+		t.Errorf("pin flags = %v, %v", h[0].Pinned, h[1].Pinned)
+	}
+}
@@ -0,0 +1,120 @@
+package session
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Overrides are settings chosen for one conversation with slash commands,
+// taking precedence over the agent's configuration.
+type Overrides struct {
+	// Model replaces the agent's primary model.
+	Model string `json:"model,omitempty"`
+	// ReasoningEffort and ThinkingBudget replace those of the model's
+	// model_list entry.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+	ThinkingBudget  int    `json:"thinking_budget,omitempty"`
+}
+
+// Thinking describes the reasoning override, such as "high" or "8192
+// tokens", or is empty when there is none.
+func (o Overrides) Thinking() string {
+	if o.ThinkingBudget > 0 {
+		return fmt.Sprintf("%d tokens", o.ThinkingBudget)
+	}
+	return o.ReasoningEffort
+}
+
+// GetOverrides returns the session's overrides.
+func (sm *SessionManager) GetOverrides(key string) Overrides {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	e := sm.lookupLocked(key, false)
+	if e == nil {
+		return Overrides{}
+	}
+	return e.session.Overrides
+}
+
+// SetOverrides replaces the session's overrides, creating the session if
+// needed.
+func (sm *SessionManager) SetOverrides(key string, o Overrides) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	e := sm.lookupLocked(key, true)
+	e.session.Overrides = o
+	e.session.Updated = time.Now()
+	e.changes++
+}
+
+// Reset clears the session's history, summary and compaction log, and
+// returns the messages it held. Overrides are kept unless clearOverrides is
+// set.
+func (sm *SessionManager) Reset(key string, clearOverrides bool) []providers.Message {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	e := sm.lookupLocked(key, false)
+	if e == nil {
+		return nil
+	}
+	old := e.session.Messages
+	e.session.Messages = []providers.Message{}
+	e.session.Summary = ""
+	e.session.Compactions = nil
+	if clearOverrides {
+		e.session.Overrides = Overrides{}
+	}
+	e.session.Updated = time.Now()
+	e.rewrite = true
+	e.changes++
+	return old
+}
+
+// Undo removes the session's last exchange: its last user message and
+// everything after it. It returns the removed messages, or nil if the
+// history holds no user message.
+func (sm *SessionManager) Undo(key string) []providers.Message {
+	return sm.truncateAtLastUser(key, 0)
+}
+
+// RewindToLastUser removes the reply to the session's last user message,
+// leaving that message last in the history, and returns it.
+func (sm *SessionManager) RewindToLastUser(key string) (providers.Message, bool) {
+	removed := sm.truncateAtLastUser(key, 1)
+	if removed == nil {
+		return providers.Message{}, false
+	}
+	return removed[0], true
+}
+
+// truncateAtLastUser cuts the history keep messages after its last user
+// message and returns the messages from that user message on.
+func (sm *SessionManager) truncateAtLastUser(key string, keep int) []providers.Message {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	e := sm.lookupLocked(key, false)
+	if e == nil {
+		return nil
+	}
+	msgs := e.session.Messages
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role != "user" {
+			continue
+		}
+		tail := append([]providers.Message(nil), msgs[i:]...)
+		if i+keep < len(msgs) {
+			e.session.Messages = msgs[:i+keep]
+			e.session.Updated = time.Now()
+			e.rewrite = true
+			e.changes++
+		}
+		return tail
+	}
+	return nil
+}
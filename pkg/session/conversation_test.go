@@ -0,0 +1,102 @@
+package session
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func exchange() []providers.Message {
+	return []providers.Message{
+		{Role: "user", Content: "q1"},
+		{Role: "assistant", Content: "a1"},
+		{Role: "user", Content: "q2"},
+		{Role: "assistant", Content: "", ToolCalls: []providers.ToolCall{{ID: "t1", Name: "exec"}}},
+		{Role: "tool", Content: "out", ToolCallID: "t1"},
+		{Role: "assistant", Content: "a2"},
+	}
+}
+
+func TestSessionManager_OverridesPersist(t *testing.T) {
+	for kind, store := range openTestStores(t) {
+		t.Run(kind, func(t *testing.T) {
+			sm := NewSessionManagerWithStore(store, 0)
+			sm.SetOverrides("k", Overrides{Model: "gpt-4o"})
+			if err := sm.Save("k"); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := store.Load("k")
+			if err != nil || loaded == nil {
+				t.Fatalf("Load = %v, %v", loaded, err)
+			}
+			if loaded.Overrides.Model != "gpt-4o" {
+				t.Errorf("stored overrides = %+v", loaded.Overrides)
+			}
+			if got := NewSessionManagerWithStore(store, 0).GetOverrides("k"); got.Model != "gpt-4o" {
+				t.Errorf("reloaded overrides = %+v", got)
+			}
+		})
+	}
+}
+
+func TestSessionManager_Undo(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.GetOrCreate("k")
+	sm.SetHistory("k", exchange())
+
+	removed := sm.Undo("k")
+	if got := contents(removed); !slices.Equal(got, []string{"q2", "", "out", "a2"}) {
+		t.Errorf("removed = %v", got)
+	}
+	if got := contents(sm.GetHistory("k")); !slices.Equal(got, []string{"q1", "a1"}) {
+		t.Errorf("history after undo = %v", got)
+	}
+
+	sm.Undo("k")
+	if removed := sm.Undo("k"); removed != nil {
+		t.Errorf("undo of empty history removed %v", removed)
+	}
+}
+
+func TestSessionManager_RewindToLastUser(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.GetOrCreate("k")
+	sm.SetHistory("k", exchange())
+
+	last, ok := sm.RewindToLastUser("k")
+	if !ok || last.Content != "q2" {
+		t.Fatalf("RewindToLastUser = %+v, %v", last, ok)
+	}
+	if got := contents(sm.GetHistory("k")); !slices.Equal(got, []string{"q1", "a1", "q2"}) {
+		t.Errorf("history after rewind = %v", got)
+	}
+
+	if _, ok := sm.RewindToLastUser("missing"); ok {
+		t.Error("rewind of a missing session succeeded")
+	}
+}
+
+func TestSessionManager_Reset(t *testing.T) {
+	sm := NewSessionManager("")
+	sm.GetOrCreate("k")
+	sm.SetHistory("k", exchange())
+	sm.SetSummary("k", "earlier talk")
+	sm.SetOverrides("k", Overrides{Model: "m"})
+
+	if old := sm.Reset("k", false); len(old) != 6 {
+		t.Errorf("Reset returned %d messages, want 6", len(old))
+	}
+	if len(sm.GetHistory("k")) != 0 || sm.GetSummary("k") != "" {
+		t.Error("Reset left history or summary")
+	}
+	if sm.GetOverrides("k").Model != "m" {
+		t.Error("Reset without clearOverrides dropped the overrides")
+	}
+
+	sm.Reset("k", true)
+	if sm.GetOverrides("k").Model != "" {
+		t.Error("Reset with clearOverrides kept the overrides")
+	}
+}
@@ -0,0 +1,118 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// JSONStore keeps each session in its own pretty-printed JSON file. Every
+// write rewrites the whole file.
+type JSONStore struct {
+	dir string
+}
+
+// NewJSONStore returns a JSONStore writing to dir.
+func NewJSONStore(dir string) *JSONStore {
+	return &JSONStore{dir: dir}
+}
+
+func (s *JSONStore) Load(key string) (*Session, error) {
+	filename, err := sessionFilename(key)
+	if err != nil {
+		return nil, err
+	}
+	return readJSONSession(filepath.Join(s.dir, filename+".json"), key)
+}
+
+// Append rewrites the whole file; the format has no cheaper update.
+func (s *JSONStore) Append(sess *Session, msgs []providers.Message) error {
+	return s.Replace(sess)
+}
+
+func (s *JSONStore) Replace(sess *Session) error {
+	filename, err := sessionFilename(sess.Key)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(sess, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.dir, filename+".json", data)
+}
+
+func (s *JSONStore) Delete(key string) error {
+	filename, err := sessionFilename(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(s.dir, filename+".json")); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *JSONStore) Keys() ([]string, error) {
+	return jsonKeys(s.dir, nil)
+}
+
+func (s *JSONStore) Close() error {
+	return nil
+}
+
+// readJSONSession reads a session file. It returns nil when the file does
+// not exist or belongs to a different key (sanitized filenames can collide).
+func readJSONSession(path, key string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sess Session
+	if err := json.Unmarshal(data, &sess); err != nil {
+		return nil, err
+	}
+	if sess.Key != key {
+		return nil, nil
+	}
+	if sess.Messages == nil {
+		sess.Messages = []providers.Message{}
+	}
+	return &sess, nil
+}
+
+// jsonKeys returns the keys of the .json session files in dir that are not
+// already in seen.
+func jsonKeys(dir string, seen map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		var head struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(data, &head); err != nil || head.Key == "" || seen[head.Key] {
+			continue
+		}
+		keys = append(keys, head.Key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,281 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// JSONLStore keeps each session in a JSON Lines file: one record per
+// message, followed by a metadata record carrying the key, summary and
+// timestamps. Saving new messages appends to the file; only rewrites of
+// existing history replace it. Sessions written by JSONStore are still
+// read, and are migrated to JSON Lines on their next save.
+type JSONLStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewJSONLStore returns a JSONLStore writing to dir.
+func NewJSONLStore(dir string) *JSONLStore {
+	return &JSONLStore{dir: dir}
+}
+
+const (
+	recordMeta    = "meta"
+	recordMessage = "message"
+)
+
+// jsonlRecord is one line of a session file. Metadata records carry the
+// session's full metadata, so the last one wins.
+type jsonlRecord struct {
+	Type        string             `json:"type"`
+	Key         string             `json:"key,omitempty"`
+	Summary     string             `json:"summary,omitempty"`
+	Created     time.Time          `json:"created,omitzero"`
+	Updated     time.Time          `json:"updated,omitzero"`
+	Compactions []Compaction       `json:"compactions,omitempty"`
+	Overrides   Overrides          `json:"overrides,omitzero"`
+	Message     *providers.Message `json:"message,omitempty"`
+}
+
+func metaRecord(sess *Session) jsonlRecord {
+	return jsonlRecord{
+		Type:        recordMeta,
+		Key:         sess.Key,
+		Summary:     sess.Summary,
+		Created:     sess.Created,
+		Updated:     sess.Updated,
+		Compactions: sess.Compactions,
+		Overrides:   sess.Overrides,
+	}
+}
+
+func (s *JSONLStore) Load(key string) (*Session, error) {
+	filename, err := sessionFilename(key)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(filepath.Join(s.dir, filename+".jsonl"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return readJSONSession(filepath.Join(s.dir, filename+".json"), key)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sess, err := ReadJSONL(f)
+	if err != nil {
+		return nil, err
+	}
+	if sess.Key != key {
+		return nil, nil
+	}
+	return sess, nil
+}
+
+func (s *JSONLStore) Append(sess *Session, msgs []providers.Message) error {
+	filename, err := sessionFilename(sess.Key)
+	if err != nil {
+		return err
+	}
+	data, err := encodeRecords(sess, msgs)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(s.dir, filename+".jsonl"), os.O_RDWR|os.O_APPEND, 0o644)
+	if errors.Is(err, fs.ErrNotExist) {
+		// New session, or one still in the legacy JSON format: write it whole.
+		return s.replaceLocked(filename, sess)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Start on a fresh line if a previous append was torn.
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			data = append([]byte{'\n'}, data...)
+		}
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *JSONLStore) Replace(sess *Session) error {
+	filename, err := sessionFilename(sess.Key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replaceLocked(filename, sess)
+}
+
+func (s *JSONLStore) replaceLocked(filename string, sess *Session) error {
+	data, err := encodeRecords(sess, sess.Messages)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.dir, filename+".jsonl", data)
+}
+
+// WriteJSONL writes sess in the session file format: one record per
+// message followed by a metadata record.
+func WriteJSONL(w io.Writer, sess *Session) error {
+	data, err := encodeRecords(sess, sess.Messages)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadJSONL reads a session written in the session file format. Lines that
+// do not parse, such as a line torn by a crash mid-append, are skipped.
+func ReadJSONL(r io.Reader) (*Session, error) {
+	sess := &Session{Messages: []providers.Message{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var rec jsonlRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		switch rec.Type {
+		case recordMeta:
+			sess.Key = rec.Key
+			sess.Summary = rec.Summary
+			sess.Created = rec.Created
+			sess.Updated = rec.Updated
+			sess.Compactions = rec.Compactions
+			sess.Overrides = rec.Overrides
+		case recordMessage:
+			if rec.Message != nil {
+				sess.Messages = append(sess.Messages, *rec.Message)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+// encodeRecords renders msgs as message records followed by sess's metadata.
+func encodeRecords(sess *Session, msgs []providers.Message) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range msgs {
+		if err := enc.Encode(jsonlRecord{Type: recordMessage, Message: &msgs[i]}); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Encode(metaRecord(sess)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *JSONLStore) Delete(key string) error {
+	filename, err := sessionFilename(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, ext := range []string{".jsonl", ".json"} {
+		err := os.Remove(filepath.Join(s.dir, filename+ext))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *JSONLStore) Keys() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".jsonl" {
+			continue
+		}
+		key := jsonlKey(filepath.Join(s.dir, entry.Name()))
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	legacy, err := jsonKeys(s.dir, seen)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, legacy...), nil
+}
+
+// jsonlKey returns the key recorded in a session file's first metadata
+// record. Every write ends with one, so the first found is authoritative.
+func jsonlKey(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.Contains(line, []byte(`"type":"meta"`)) {
+			continue
+		}
+		var rec jsonlRecord
+		if err := json.Unmarshal(line, &rec); err == nil && rec.Type == recordMeta {
+			return rec.Key
+		}
+	}
+	return ""
+}
+
+func (s *JSONLStore) Close() error {
+	return nil
+}
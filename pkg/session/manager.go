@@ -1,13 +1,11 @@
 package session
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"strings"
+	"container/list"
 	"sync"
 	"time"
 
+	"github.com/sipeed/picoclaw/pkg/logger"
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
@@ -17,46 +15,137 @@ type Session struct {
 	Summary  string              `json:"summary,omitempty"`
 	Created  time.Time           `json:"created"`
 	Updated  time.Time           `json:"updated"`
+	// Compactions records the latest history compactions, oldest first.
+	Compactions []Compaction `json:"compactions,omitempty"`
+	// Overrides are the conversation's settings chosen with slash commands.
+	Overrides Overrides `json:"overrides,omitzero"`
 }
 
+// SessionManager holds the sessions in use in memory, loading each from its
+// store on first access. With a cache limit, the least recently used sessions
+// beyond it are dropped from memory once they have been saved.
 type SessionManager struct {
-	sessions map[string]*Session
-	mu       sync.RWMutex
-	storage  string
+	sessions  map[string]*entry
+	lru       *list.List // of *entry, most recently used first
+	maxCached int
+	mu        sync.Mutex
+	store     SessionStore
 }
 
-func NewSessionManager(storage string) *SessionManager {
-	sm := &SessionManager{
-		sessions: make(map[string]*Session),
-		storage:  storage,
-	}
+// entry is a cached session and its persistence state.
+type entry struct {
+	session *Session
+	elem    *list.Element
+
+	// persisted counts the leading messages already in the store.
+	persisted int
+	// rewrite is set when stored history was changed in place, so the next
+	// save must replace the stored session rather than append to it.
+	rewrite bool
+	// changes and saved count modifications and the modification count
+	// covered by the last successful save.
+	changes uint64
+	saved   uint64
+	// saving counts saves in flight; saveMu serializes them.
+	saving int
+	saveMu sync.Mutex
+}
+
+func (e *entry) dirty() bool {
+	return e.changes != e.saved
+}
 
+// NewSessionManager returns a manager keeping sessions as JSON files in
+// storage, without a cache limit. An empty storage keeps sessions in memory
+// only.
+func NewSessionManager(storage string) *SessionManager {
+	var store SessionStore
 	if storage != "" {
-		os.MkdirAll(storage, 0o755)
-		sm.loadSessions()
+		if s, err := OpenStore(StoreJSON, storage); err == nil {
+			store = s
+		}
 	}
+	return NewSessionManagerWithStore(store, 0)
+}
 
-	return sm
+// NewSessionManagerWithStore returns a manager backed by store that keeps at
+// most maxCached idle sessions in memory (0 means unlimited). A nil store
+// keeps sessions in memory only.
+func NewSessionManagerWithStore(store SessionStore, maxCached int) *SessionManager {
+	return &SessionManager{
+		sessions:  make(map[string]*entry),
+		lru:       list.New(),
+		maxCached: maxCached,
+		store:     store,
+	}
 }
 
-func (sm *SessionManager) GetOrCreate(key string) *Session {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+// lookupLocked returns the cached entry for key, loading it from the store
+// if needed. With create, a missing session is created. It returns nil when
+// the session does not exist and create is false.
+func (sm *SessionManager) lookupLocked(key string, create bool) *entry {
+	if e, ok := sm.sessions[key]; ok {
+		sm.lru.MoveToFront(e.elem)
+		return e
+	}
 
-	session, ok := sm.sessions[key]
-	if ok {
-		return session
+	var session *Session
+	if sm.store != nil {
+		loaded, err := sm.store.Load(key)
+		if err != nil {
+			logger.WarnCF("session", "Failed to load session", map[string]any{
+				"session_key": key,
+				"error":       err.Error(),
+			})
+		}
+		session = loaded
 	}
 
-	session = &Session{
-		Key:      key,
-		Messages: []providers.Message{},
-		Created:  time.Now(),
-		Updated:  time.Now(),
+	e := &entry{session: session}
+	if session != nil {
+		e.persisted = len(session.Messages)
+	} else {
+		if !create {
+			return nil
+		}
+		e.session = &Session{
+			Key:      key,
+			Messages: []providers.Message{},
+			Created:  time.Now(),
+			Updated:  time.Now(),
+		}
+		e.changes = 1
+	}
+	e.elem = sm.lru.PushFront(e)
+	sm.sessions[key] = e
+	sm.evictLocked()
+	return e
+}
+
+// evictLocked drops the least recently used sessions beyond the cache limit.
+// Sessions with unsaved changes or a save in flight stay until saved.
+func (sm *SessionManager) evictLocked() {
+	if sm.maxCached <= 0 || sm.store == nil {
+		return
 	}
-	sm.sessions[key] = session
+	excess := len(sm.sessions) - sm.maxCached
+	for elem := sm.lru.Back(); elem != nil && excess > 0; {
+		prev := elem.Prev()
+		e := elem.Value.(*entry)
+		if !e.dirty() && e.saving == 0 {
+			sm.lru.Remove(elem)
+			delete(sm.sessions, e.session.Key)
+			excess--
+		}
+		elem = prev
+	}
+}
+
+func (sm *SessionManager) GetOrCreate(key string) *Session {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	return session
+	return sm.lookupLocked(key, true).session
 }
 
 func (sm *SessionManager) AddMessage(sessionKey, role, content string) {
@@ -72,53 +161,46 @@ func (sm *SessionManager) AddFullMessage(sessionKey string, msg providers.Messag
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[sessionKey]
-	if !ok {
-		session = &Session{
-			Key:      sessionKey,
-			Messages: []providers.Message{},
-			Created:  time.Now(),
-		}
-		sm.sessions[sessionKey] = session
-	}
-
-	session.Messages = append(session.Messages, msg)
-	session.Updated = time.Now()
+	e := sm.lookupLocked(sessionKey, true)
+	e.session.Messages = append(e.session.Messages, msg)
+	e.session.Updated = time.Now()
+	e.changes++
 }
 
 func (sm *SessionManager) GetHistory(key string) []providers.Message {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
+	e := sm.lookupLocked(key, false)
+	if e == nil {
 		return []providers.Message{}
 	}
 
-	history := make([]providers.Message, len(session.Messages))
-	copy(history, session.Messages)
+	history := make([]providers.Message, len(e.session.Messages))
+	copy(history, e.session.Messages)
 	return history
 }
 
 func (sm *SessionManager) GetSummary(key string) string {
-	sm.mu.RLock()
-	defer sm.mu.RUnlock()
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
+	e := sm.lookupLocked(key, false)
+	if e == nil {
 		return ""
 	}
-	return session.Summary
+	return e.session.Summary
 }
 
 func (sm *SessionManager) SetSummary(key string, summary string) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if ok {
-		session.Summary = summary
-		session.Updated = time.Now()
+	e := sm.lookupLocked(key, false)
+	if e != nil {
+		e.session.Summary = summary
+		e.session.Updated = time.Now()
+		e.changes++
 	}
 }
 
@@ -126,157 +208,227 @@ func (sm *SessionManager) TruncateHistory(key string, keepLast int) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	session, ok := sm.sessions[key]
-	if !ok {
+	e := sm.lookupLocked(key, false)
+	if e == nil {
 		return
 	}
 
 	if keepLast <= 0 {
-		session.Messages = []providers.Message{}
-		session.Updated = time.Now()
+		e.session.Messages = []providers.Message{}
+		e.session.Updated = time.Now()
+		e.rewrite = true
+		e.changes++
 		return
 	}
 
-	if len(session.Messages) <= keepLast {
+	if len(e.session.Messages) <= keepLast {
 		return
 	}
 
-	session.Messages = session.Messages[len(session.Messages)-keepLast:]
-	session.Updated = time.Now()
-}
-
-// sanitizeFilename converts a session key into a cross-platform safe filename.
-// Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
-// volume separator on Windows, so filepath.Base would misinterpret the key.
-// We replace it with '_'. The original key is preserved inside the JSON file,
-// so loadSessions still maps back to the right in-memory key.
-func sanitizeFilename(key string) string {
-	return strings.ReplaceAll(key, ":", "_")
+	e.session.Messages = e.session.Messages[len(e.session.Messages)-keepLast:]
+	e.session.Updated = time.Now()
+	e.rewrite = true
+	e.changes++
 }
 
+// Save writes the session's unsaved changes to the store: new messages are
+// appended, and history rewritten since the last save replaces the stored
+// copy.
 func (sm *SessionManager) Save(key string) error {
-	if sm.storage == "" {
+	if sm.store == nil {
 		return nil
 	}
-
-	filename := sanitizeFilename(key)
-
-	// filepath.IsLocal rejects empty names, "..", absolute paths, and
-	// OS-reserved device names (NUL, COM1 … on Windows).
-	// The extra checks reject "." and any directory separators so that
-	// the session file is always written directly inside sm.storage.
-	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
-		return os.ErrInvalid
+	if _, err := sessionFilename(key); err != nil {
+		return err
 	}
 
-	// Snapshot under read lock, then perform slow file I/O after unlock.
-	sm.mu.RLock()
-	stored, ok := sm.sessions[key]
+	sm.mu.Lock()
+	e, ok := sm.sessions[key]
 	if !ok {
-		sm.mu.RUnlock()
+		sm.mu.Unlock()
 		return nil
 	}
+	e.saving++
+	sm.mu.Unlock()
+
+	e.saveMu.Lock()
+	err := sm.saveEntry(e)
+	e.saveMu.Unlock()
 
-	snapshot := Session{
-		Key:     stored.Key,
-		Summary: stored.Summary,
-		Created: stored.Created,
-		Updated: stored.Updated,
+	sm.mu.Lock()
+	e.saving--
+	sm.evictLocked()
+	sm.mu.Unlock()
+	return err
+}
+
+// saveEntry persists e. The caller holds e.saveMu.
+func (sm *SessionManager) saveEntry(e *entry) error {
+	// Snapshot under the lock, then perform slow I/O after unlock.
+	sm.mu.Lock()
+	if !e.dirty() {
+		sm.mu.Unlock()
+		return nil
+	}
+	stored := e.session
+	snapshot := &Session{
+		Key:         stored.Key,
+		Summary:     stored.Summary,
+		Created:     stored.Created,
+		Updated:     stored.Updated,
+		Compactions: append([]Compaction(nil), stored.Compactions...),
+		Overrides:   stored.Overrides,
+		Messages:    make([]providers.Message, len(stored.Messages)),
 	}
-	if len(stored.Messages) > 0 {
-		snapshot.Messages = make([]providers.Message, len(stored.Messages))
-		copy(snapshot.Messages, stored.Messages)
+	copy(snapshot.Messages, stored.Messages)
+	replace := e.rewrite || e.persisted > len(snapshot.Messages)
+	persisted := e.persisted
+	changes := e.changes
+	e.rewrite = false
+	sm.mu.Unlock()
+
+	var err error
+	if replace {
+		err = sm.store.Replace(snapshot)
 	} else {
-		snapshot.Messages = []providers.Message{}
+		err = sm.store.Append(snapshot, snapshot.Messages[persisted:])
 	}
-	sm.mu.RUnlock()
 
-	data, err := json.MarshalIndent(snapshot, "", "  ")
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 	if err != nil {
+		e.rewrite = e.rewrite || replace
 		return err
 	}
+	if !e.rewrite {
+		e.persisted = len(snapshot.Messages)
+	}
+	e.saved = changes
+	return nil
+}
 
-	sessionPath := filepath.Join(sm.storage, filename+".json")
-	tmpFile, err := os.CreateTemp(sm.storage, "session-*.tmp")
-	if err != nil {
-		return err
+// SetHistory updates the messages of a session.
+func (sm *SessionManager) SetHistory(key string, history []providers.Message) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	e := sm.lookupLocked(key, false)
+	if e != nil {
+		// Create a deep copy to strictly isolate internal state
+		// from the caller's slice.
+		msgs := make([]providers.Message, len(history))
+		copy(msgs, history)
+		e.session.Messages = msgs
+		e.session.Updated = time.Now()
+		e.rewrite = true
+		e.changes++
 	}
+}
 
-	tmpPath := tmpFile.Name()
-	cleanup := true
-	defer func() {
-		if cleanup {
-			_ = os.Remove(tmpPath)
-		}
-	}()
+// Keys lists the keys of all sessions, whether stored or only in memory.
+func (sm *SessionManager) Keys() ([]string, error) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
 
-	if _, err := tmpFile.Write(data); err != nil {
-		_ = tmpFile.Close()
-		return err
+	seen := make(map[string]bool)
+	var keys []string
+	if sm.store != nil {
+		stored, err := sm.store.Keys()
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range stored {
+			seen[key] = true
+			keys = append(keys, key)
+		}
 	}
-	if err := tmpFile.Chmod(0o644); err != nil {
-		_ = tmpFile.Close()
-		return err
+	for key := range sm.sessions {
+		if !seen[key] {
+			keys = append(keys, key)
+		}
 	}
-	if err := tmpFile.Sync(); err != nil {
-		_ = tmpFile.Close()
-		return err
+	return keys, nil
+}
+
+// Get returns a copy of the session for key, or nil if there is none.
+func (sm *SessionManager) Get(key string) *Session {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	e := sm.lookupLocked(key, false)
+	if e == nil {
+		return nil
 	}
-	if err := tmpFile.Close(); err != nil {
-		return err
+	s := *e.session
+	s.Messages = append([]providers.Message(nil), e.session.Messages...)
+	s.Compactions = append([]Compaction(nil), e.session.Compactions...)
+	return &s
+}
+
+// Delete removes the session for key from memory and from the store.
+func (sm *SessionManager) Delete(key string) error {
+	sm.mu.Lock()
+	if e, ok := sm.sessions[key]; ok {
+		sm.lru.Remove(e.elem)
+		delete(sm.sessions, key)
 	}
+	sm.mu.Unlock()
 
-	if err := os.Rename(tmpPath, sessionPath); err != nil {
-		return err
+	if sm.store == nil {
+		return nil
 	}
-	cleanup = false
-	return nil
+	return sm.store.Delete(key)
 }
 
-func (sm *SessionManager) loadSessions() error {
-	files, err := os.ReadDir(sm.storage)
-	if err != nil {
+// Restore replaces the session sess.Key with sess, for instance one read
+// back from an export, and saves it.
+func (sm *SessionManager) Restore(sess *Session) error {
+	if _, err := sessionFilename(sess.Key); err != nil {
 		return err
 	}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
-		}
-
-		if filepath.Ext(file.Name()) != ".json" {
-			continue
-		}
+	restored := *sess
+	restored.Messages = append([]providers.Message{}, sess.Messages...)
+	now := time.Now()
+	if restored.Created.IsZero() {
+		restored.Created = now
+	}
+	if restored.Updated.IsZero() {
+		restored.Updated = now
+	}
 
-		sessionPath := filepath.Join(sm.storage, file.Name())
-		data, err := os.ReadFile(sessionPath)
-		if err != nil {
-			continue
-		}
+	sm.mu.Lock()
+	e := sm.lookupLocked(sess.Key, true)
+	e.session = &restored
+	e.rewrite = true
+	e.changes++
+	sm.mu.Unlock()
 
-		var session Session
-		if err := json.Unmarshal(data, &session); err != nil {
-			continue
-		}
+	return sm.Save(sess.Key)
+}
 
-		sm.sessions[session.Key] = &session
+// Close saves sessions with unsaved changes and closes the store.
+func (sm *SessionManager) Close() error {
+	if sm.store == nil {
+		return nil
 	}
 
-	return nil
-}
-
-// SetHistory updates the messages of a session.
-func (sm *SessionManager) SetHistory(key string, history []providers.Message) {
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
-	session, ok := sm.sessions[key]
-	if ok {
-		// Create a deep copy to strictly isolate internal state
-		// from the caller's slice.
-		msgs := make([]providers.Message, len(history))
-		copy(msgs, history)
-		session.Messages = msgs
-		session.Updated = time.Now()
+	keys := make([]string, 0, len(sm.sessions))
+	for key, e := range sm.sessions {
+		if e.dirty() {
+			keys = append(keys, key)
+		}
+	}
+	sm.mu.Unlock()
+
+	for _, key := range keys {
+		if err := sm.Save(key); err != nil {
+			logger.WarnCF("session", "Failed to save session on close", map[string]any{
+				"session_key": key,
+				"error":       err.Error(),
+			})
+		}
 	}
+	return sm.store.Close()
 }
@@ -72,3 +72,74 @@ func TestSave_RejectsPathTraversal(t *testing.T) {
 		}
 	}
 }
+
+func TestSessionManager_LazyLoadAndEviction(t *testing.T) {
+	dir := t.TempDir()
+	store := NewJSONLStore(dir)
+	sm := NewSessionManagerWithStore(store, 2)
+
+	for _, key := range []string{"a", "b", "c"} {
+		sm.AddMessage(key, "user", "hello "+key)
+		if err := sm.Save(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(sm.sessions); n != 2 {
+		t.Errorf("cached sessions = %d, want the limit of 2", n)
+	}
+	if _, ok := sm.sessions["a"]; ok {
+		t.Error("least recently used session should have been evicted")
+	}
+
+	// Evicted sessions load again on demand.
+	if h := sm.GetHistory("a"); len(h) != 1 || h[0].Content != "hello a" {
+		t.Errorf("history of evicted session = %v", h)
+	}
+
+	// A fresh manager loads nothing until asked.
+	sm2 := NewSessionManagerWithStore(store, 2)
+	if len(sm2.sessions) != 0 {
+		t.Error("sessions should load lazily")
+	}
+	if h := sm2.GetHistory("b"); len(h) != 1 {
+		t.Errorf("history of b = %v", h)
+	}
+}
+
+func TestSessionManager_UnsavedSessionsAreNotEvicted(t *testing.T) {
+	sm := NewSessionManagerWithStore(NewJSONLStore(t.TempDir()), 1)
+	sm.AddMessage("a", "user", "unsaved")
+	sm.AddMessage("b", "user", "unsaved")
+
+	if h := sm.GetHistory("a"); len(h) != 1 {
+		t.Fatalf("unsaved session lost: %v", h)
+	}
+	if err := sm.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionManager_RewriteAfterTruncate(t *testing.T) {
+	dir := t.TempDir()
+	store := NewJSONLStore(dir)
+	sm := NewSessionManagerWithStore(store, 0)
+
+	for _, c := range []string{"1", "2", "3"} {
+		sm.AddMessage("k", "user", c)
+	}
+	sm.Save("k")
+	sm.TruncateHistory("k", 1)
+	sm.SetSummary("k", "earlier turns")
+	sm.AddMessage("k", "assistant", "4")
+	if err := sm.Save("k"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := store.Load("k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Messages) != 2 || loaded.Messages[0].Content != "3" || loaded.Summary != "earlier turns" {
+		t.Errorf("stored session = %+v", loaded)
+	}
+}
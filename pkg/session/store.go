@@ -0,0 +1,122 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// SessionStore persists sessions. The SessionManager loads sessions from it
+// on first use and writes them back on Save. Implementations must be safe
+// for concurrent use.
+type SessionStore interface {
+	// Load returns the stored session for key, or nil if there is none.
+	Load(key string) (*Session, error)
+	// Append records msgs, the messages added to s since it was last written,
+	// together with s's current summary and timestamps. Messages already
+	// stored are left untouched.
+	Append(s *Session, msgs []providers.Message) error
+	// Replace overwrites the stored session with s.
+	Replace(s *Session) error
+	// Delete removes the stored session for key. Deleting a missing session
+	// is not an error.
+	Delete(key string) error
+	// Keys lists the keys of all stored sessions.
+	Keys() ([]string, error)
+	// Close releases the store's resources.
+	Close() error
+}
+
+// Store kinds accepted by OpenStore.
+const (
+	// StoreJSON keeps one JSON file per session, rewritten on every save.
+	StoreJSON = "json"
+	// StoreJSONL keeps one append-only JSON Lines file per session.
+	StoreJSONL = "jsonl"
+	// StoreBolt keeps every session in a single embedded database file.
+	StoreBolt = "bolt"
+)
+
+// OpenStore opens a store of the given kind rooted at dir. An empty kind
+// selects StoreJSONL.
+func OpenStore(kind, dir string) (SessionStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case StoreJSON:
+		return NewJSONStore(dir), nil
+	case "", StoreJSONL:
+		return NewJSONLStore(dir), nil
+	case StoreBolt:
+		return OpenBoltStore(filepath.Join(dir, "sessions.db"))
+	default:
+		return nil, fmt.Errorf("unknown session store %q (want %s, %s or %s)", kind, StoreJSON, StoreJSONL, StoreBolt)
+	}
+}
+
+// sanitizeFilename converts a session key into a cross-platform safe filename.
+// Session keys use "channel:chatID" (e.g. "telegram:123456") but ':' is the
+// volume separator on Windows, so filepath.Base would misinterpret the key.
+// We replace it with '_'. The original key is preserved inside the file, so
+// loading still maps back to the right key.
+func sanitizeFilename(key string) string {
+	return strings.ReplaceAll(key, ":", "_")
+}
+
+// sessionFilename returns the base filename for key, rejecting keys that
+// would escape the storage directory.
+func sessionFilename(key string) (string, error) {
+	filename := sanitizeFilename(key)
+
+	// filepath.IsLocal rejects empty names, "..", absolute paths, and
+	// OS-reserved device names (NUL, COM1 … on Windows).
+	// The extra checks reject "." and any directory separators so that
+	// the session file is always written directly inside the storage dir.
+	if filename == "." || !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
+		return "", os.ErrInvalid
+	}
+	return filename, nil
+}
+
+// writeFileAtomic writes data to dir/name through a synced temp file and a
+// rename, so readers never observe a partially written file.
+func writeFileAtomic(dir, name string, data []byte) error {
+	tmpFile, err := os.CreateTemp(dir, "session-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	tmpPath := tmpFile.Name()
+	cleanup := true
+	defer func() {
+		if cleanup {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Chmod(0o644); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Sync(); err != nil {
+		_ = tmpFile.Close()
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(dir, name)); err != nil {
+		return err
+	}
+	cleanup = false
+	return nil
+}
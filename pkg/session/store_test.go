@@ -0,0 +1,185 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func openTestStores(t *testing.T) map[string]SessionStore {
+	t.Helper()
+	stores := map[string]SessionStore{}
+	for _, kind := range []string{StoreJSON, StoreJSONL, StoreBolt} {
+		store, err := OpenStore(kind, t.TempDir())
+		if err != nil {
+			t.Fatalf("OpenStore(%q): %v", kind, err)
+		}
+		t.Cleanup(func() { store.Close() })
+		stores[kind] = store
+	}
+	return stores
+}
+
+func messages(contents ...string) []providers.Message {
+	msgs := make([]providers.Message, len(contents))
+	for i, c := range contents {
+		msgs[i] = providers.Message{Role: "user", Content: c}
+	}
+	return msgs
+}
+
+func contents(msgs []providers.Message) []string {
+	out := make([]string, len(msgs))
+	for i, m := range msgs {
+		out[i] = m.Content
+	}
+	return out
+}
+
+func TestStores_AppendReplaceDelete(t *testing.T) {
+	for kind, store := range openTestStores(t) {
+		t.Run(kind, func(t *testing.T) {
+			key := "agent:main:telegram:direct:42"
+			if sess, err := store.Load(key); err != nil || sess != nil {
+				t.Fatalf("Load of missing session = %v, %v; want nil, nil", sess, err)
+			}
+
+			sess := &Session{Key: key, Messages: messages("a", "b")}
+			if err := store.Append(sess, sess.Messages); err != nil {
+				t.Fatal(err)
+			}
+			sess.Messages = append(sess.Messages, messages("c")...)
+			sess.Summary = "about letters"
+			if err := store.Append(sess, sess.Messages[2:]); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := store.Load(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := contents(loaded.Messages); !slices.Equal(got, []string{"a", "b", "c"}) {
+				t.Errorf("messages after appends = %v", got)
+			}
+			if loaded.Summary != "about letters" {
+				t.Errorf("summary = %q", loaded.Summary)
+			}
+
+			sess.Messages = messages("c")
+			if err := store.Replace(sess); err != nil {
+				t.Fatal(err)
+			}
+			loaded, _ = store.Load(key)
+			if got := contents(loaded.Messages); !slices.Equal(got, []string{"c"}) {
+				t.Errorf("messages after replace = %v", got)
+			}
+
+			keys, err := store.Keys()
+			if err != nil || !slices.Equal(keys, []string{key}) {
+				t.Errorf("Keys() = %v, %v", keys, err)
+			}
+
+			if err := store.Delete(key); err != nil {
+				t.Fatal(err)
+			}
+			if sess, _ := store.Load(key); sess != nil {
+				t.Error("session still loadable after Delete")
+			}
+		})
+	}
+}
+
+func TestJSONLStore_AppendsInsteadOfRewriting(t *testing.T) {
+	dir := t.TempDir()
+	store := NewJSONLStore(dir)
+	sess := &Session{Key: "cli:direct", Messages: messages("first")}
+	if err := store.Append(sess, sess.Messages); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "cli_direct.jsonl")
+	before, _ := os.ReadFile(path)
+
+	sess.Messages = append(sess.Messages, messages("second")...)
+	if err := store.Append(sess, sess.Messages[1:]); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(after), string(before)) {
+		t.Error("append rewrote earlier records")
+	}
+	if strings.Count(string(after), `"type":"message"`) != 2 {
+		t.Errorf("file should hold two message records:\n%s", after)
+	}
+}
+
+func TestJSONLStore_ReadsLegacyJSON(t *testing.T) {
+	dir := t.TempDir()
+	legacy := &Session{Key: "telegram:1", Messages: messages("old")}
+	if err := NewJSONStore(dir).Replace(legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	store := NewJSONLStore(dir)
+	loaded, err := store.Load("telegram:1")
+	if err != nil || loaded == nil || len(loaded.Messages) != 1 {
+		t.Fatalf("Load of legacy session = %+v, %v", loaded, err)
+	}
+
+	// The first append migrates the whole session to JSON Lines.
+	loaded.Messages = append(loaded.Messages, messages("new")...)
+	if err := store.Append(loaded, loaded.Messages[1:]); err != nil {
+		t.Fatal(err)
+	}
+	loaded, _ = store.Load("telegram:1")
+	if got := contents(loaded.Messages); !slices.Equal(got, []string{"old", "new"}) {
+		t.Errorf("messages after migration = %v", got)
+	}
+}
+
+func TestJSONLStore_SkipsTornLine(t *testing.T) {
+	dir := t.TempDir()
+	store := NewJSONLStore(dir)
+	sess := &Session{Key: "cli:direct", Messages: messages("kept")}
+	if err := store.Append(sess, sess.Messages); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := os.OpenFile(filepath.Join(dir, "cli_direct.jsonl"), os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"type":"message","message":{"role":"us`)
+	f.Close()
+
+	sess.Messages = append(sess.Messages, messages("after crash")...)
+	if err := store.Append(sess, sess.Messages[1:]); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := store.Load("cli:direct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(loaded.Messages); !slices.Equal(got, []string{"kept", "after crash"}) {
+		t.Errorf("messages = %v", got)
+	}
+}
+
+func TestBoltStore_SharesHandlePerPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	a, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := OpenBoltStore(path)
+	if err != nil {
+		t.Fatalf("second open of the same database: %v", err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Replace(&Session{Key: "k", Messages: messages("x")}); err != nil {
+		t.Errorf("write after closing the other handle: %v", err)
+	}
+	b.Close()
+}
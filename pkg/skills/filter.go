@@ -0,0 +1,68 @@
+package skills
+
+import (
+	"path"
+	"strings"
+)
+
+// Filter decides which skills an agent may see. Patterns are globs matched
+// against skill names; a leading "!" makes a pattern deny instead of allow.
+// Deny patterns win. When only deny patterns are given, every other skill is
+// allowed. A nil *Filter allows everything.
+type Filter struct {
+	allow []string
+	deny  []string
+}
+
+// NewFilter builds a filter from patterns such as "research-*" or
+// "!research-legacy". A nil slice yields a nil filter (no restriction); an
+// empty, non-nil slice allows no skills at all.
+func NewFilter(patterns []string) *Filter {
+	if patterns == nil {
+		return nil
+	}
+	f := &Filter{allow: []string{}}
+	onlyDeny := true
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if deny, ok := strings.CutPrefix(p, "!"); ok {
+			f.deny = append(f.deny, deny)
+			continue
+		}
+		onlyDeny = false
+		f.allow = append(f.allow, p)
+	}
+	if onlyDeny && len(f.deny) > 0 {
+		f.allow = []string{"*"}
+	}
+	return f
+}
+
+// Allows reports whether the skill called name passes the filter.
+func (f *Filter) Allows(name string) bool {
+	if f == nil {
+		return true
+	}
+	for _, p := range f.deny {
+		if globMatch(p, name) {
+			return false
+		}
+	}
+	for _, p := range f.allow {
+		if globMatch(p, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func globMatch(pattern, name string) bool {
+	if pattern == name {
+		return true
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
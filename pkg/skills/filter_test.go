@@ -0,0 +1,83 @@
+package skills
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterAllows(t *testing.T) {
+	testcases := []struct {
+		name     string
+		patterns []string
+		allowed  []string
+		denied   []string
+	}{
+		{
+			name:     "nil-allows-all",
+			patterns: nil,
+			allowed:  []string{"weather", "github"},
+		},
+		{
+			name:     "empty-allows-none",
+			patterns: []string{},
+			denied:   []string{"weather"},
+		},
+		{
+			name:     "exact-and-glob",
+			patterns: []string{"weather", "research-*"},
+			allowed:  []string{"weather", "research-arxiv"},
+			denied:   []string{"github", "home-lights"},
+		},
+		{
+			name:     "deny-wins",
+			patterns: []string{"research-*", "!research-legacy"},
+			allowed:  []string{"research-arxiv"},
+			denied:   []string{"research-legacy"},
+		},
+		{
+			name:     "only-deny",
+			patterns: []string{"!home-*"},
+			allowed:  []string{"weather"},
+			denied:   []string{"home-lights"},
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := NewFilter(tc.patterns)
+			for _, name := range tc.allowed {
+				assert.True(t, f.Allows(name), "expected %q to be allowed", name)
+			}
+			for _, name := range tc.denied {
+				assert.False(t, f.Allows(name), "expected %q to be denied", name)
+			}
+		})
+	}
+}
+
+func TestLoaderAppliesFilter(t *testing.T) {
+	tmp := t.TempDir()
+	ws := filepath.Join(tmp, "workspace")
+	global := filepath.Join(tmp, "global")
+
+	createSkillDir(t, filepath.Join(ws, "skills"), "research-arxiv", "research-arxiv", "search papers")
+	createSkillDir(t, global, "home-lights", "home-lights", "control lights")
+
+	sl := NewSkillsLoader(ws, global, "")
+	sl.SetFilter(NewFilter([]string{"research-*"}))
+
+	skills := sl.ListSkills()
+	assert.Len(t, skills, 1)
+	assert.Equal(t, "research-arxiv", skills[0].Name)
+	assert.Len(t, sl.ListAllSkills(), 2)
+
+	assert.Contains(t, sl.BuildSkillsSummary(), "research-arxiv")
+	assert.NotContains(t, sl.BuildSkillsSummary(), "home-lights")
+
+	_, ok := sl.LoadSkill("home-lights")
+	assert.False(t, ok)
+	assert.Contains(t, sl.LoadSkillsForContext([]string{"research-arxiv", "home-lights"}), "Skill: research-arxiv")
+	assert.NotContains(t, sl.LoadSkillsForContext([]string{"home-lights"}), "control lights")
+}
@@ -62,6 +62,7 @@ type SkillsLoader struct {
 	workspaceSkills string // workspace skills (project-level)
 	globalSkills    string // global skills (~/.picoclaw/skills)
 	builtinSkills   string // builtin skills
+	filter          *Filter
 }
 
 func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string) *SkillsLoader {
@@ -73,7 +74,34 @@ func NewSkillsLoader(workspace string, globalSkills string, builtinSkills string
 	}
 }
 
+// SetFilter restricts the skills the loader lists and loads. A nil filter
+// lifts the restriction.
+func (sl *SkillsLoader) SetFilter(filter *Filter) {
+	sl.filter = filter
+}
+
+// Filter returns the loader's skill filter, or nil when unrestricted.
+func (sl *SkillsLoader) Filter() *Filter {
+	return sl.filter
+}
+
+// ListSkills returns the skills that pass the loader's filter.
 func (sl *SkillsLoader) ListSkills() []SkillInfo {
+	all := sl.ListAllSkills()
+	if sl.filter == nil {
+		return all
+	}
+	skills := make([]SkillInfo, 0, len(all))
+	for _, s := range all {
+		if sl.filter.Allows(s.Name) {
+			skills = append(skills, s)
+		}
+	}
+	return skills
+}
+
+// ListAllSkills returns every discovered skill, ignoring the filter.
+func (sl *SkillsLoader) ListAllSkills() []SkillInfo {
 	skills := make([]SkillInfo, 0)
 	seen := make(map[string]bool)
 
@@ -124,6 +152,10 @@ func (sl *SkillsLoader) ListSkills() []SkillInfo {
 }
 
 func (sl *SkillsLoader) LoadSkill(name string) (string, bool) {
+	if !sl.filter.Allows(name) {
+		return "", false
+	}
+
 	// 1. load from workspace skills first (project-level)
 	if sl.workspaceSkills != "" {
 		skillFile := filepath.Join(sl.workspaceSkills, name, "SKILL.md")
@@ -0,0 +1,191 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkoukk/tiktoken-go"
+
+	"github.com/sipeed/picoclaw/pkg/fileutil"
+	"github.com/sipeed/picoclaw/pkg/logger"
+)
+
+// Encodings of OpenAI model families, matched by prefix in order.
+var encodingPrefixes = []struct {
+	prefix   string
+	encoding string
+}{
+	{"gpt-4o", tiktoken.MODEL_O200K_BASE},
+	{"chatgpt-4o", tiktoken.MODEL_O200K_BASE},
+	{"gpt-4.1", tiktoken.MODEL_O200K_BASE},
+	{"gpt-4.5", tiktoken.MODEL_O200K_BASE},
+	{"gpt-5", tiktoken.MODEL_O200K_BASE},
+	{"o1", tiktoken.MODEL_O200K_BASE},
+	{"o3", tiktoken.MODEL_O200K_BASE},
+	{"o4", tiktoken.MODEL_O200K_BASE},
+	{"gpt-4", tiktoken.MODEL_CL100K_BASE},
+	{"gpt-3.5", tiktoken.MODEL_CL100K_BASE},
+}
+
+func encodingForModel(id string) string {
+	for _, e := range encodingPrefixes {
+		if strings.HasPrefix(id, e.prefix) {
+			return e.encoding
+		}
+	}
+	return ""
+}
+
+// bpeCounter counts with a BPE encoding once it is loaded. Loading reads the
+// encoding's rank file from disk (downloading it once if missing and
+// downloads are enabled) in the background; until then, and for good if
+// loading fails, counts fall back to Heuristic so a request never waits on
+// the tokenizer.
+type bpeCounter struct {
+	name string
+	once sync.Once
+	enc  atomic.Pointer[tiktoken.Tiktoken]
+}
+
+var (
+	bpeMu       sync.Mutex
+	bpeCounters = map[string]*bpeCounter{}
+	setLoader   sync.Once
+	downloads   atomic.Bool
+)
+
+// errNotCached reports a rank file that is missing while downloads are off.
+var errNotCached = errors.New("encoding is not cached and downloads are disabled")
+
+// EnableDownloads lets counters download a missing rank file once. It is
+// off by default, so devices without internet access never try; their
+// counts are estimated unless the file was copied to the cache directory.
+func EnableDownloads(enabled bool) {
+	downloads.Store(enabled)
+}
+
+func bpeCounterFor(name string) *bpeCounter {
+	bpeMu.Lock()
+	defer bpeMu.Unlock()
+	c, ok := bpeCounters[name]
+	if !ok {
+		c = &bpeCounter{name: name}
+		bpeCounters[name] = c
+	}
+	return c
+}
+
+func (c *bpeCounter) Count(text string) int {
+	if enc := c.enc.Load(); enc != nil {
+		return len(enc.EncodeOrdinary(text))
+	}
+	c.once.Do(func() { go c.load() })
+	return Heuristic{}.Count(text)
+}
+
+func (c *bpeCounter) load() {
+	setLoader.Do(func() { tiktoken.SetBpeLoader(&cachedLoader{dir: cacheDir()}) })
+	enc, err := tiktoken.GetEncoding(c.name)
+	if errors.Is(err, errNotCached) {
+		logger.DebugCF("tokenizer", "BPE encoding not cached, estimating token counts", map[string]any{
+			"encoding": c.name,
+			"dir":      cacheDir(),
+		})
+		return
+	}
+	if err != nil {
+		logger.WarnCF("tokenizer", "BPE encoding unavailable, estimating token counts", map[string]any{
+			"encoding": c.name,
+			"error":    err.Error(),
+		})
+		return
+	}
+	c.enc.Store(enc)
+}
+
+// cacheDir is where encoding rank files are kept between runs.
+func cacheDir() string {
+	if dir := os.Getenv("TIKTOKEN_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "picoclaw-tokenizers")
+	}
+	return filepath.Join(home, ".picoclaw", "tokenizers")
+}
+
+// downloadTimeout bounds the one-time download of an encoding.
+const downloadTimeout = 30 * time.Second
+
+// cachedLoader reads tiktoken rank files from dir, downloading each once
+// when it is not there yet and downloads are enabled.
+type cachedLoader struct {
+	dir string
+}
+
+func (l *cachedLoader) LoadTiktokenBpe(url string) (map[string]int, error) {
+	file := filepath.Join(l.dir, path.Base(url))
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !downloads.Load() {
+			return nil, errNotCached
+		}
+		if data, err = download(url); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(l.dir, 0o755); err == nil {
+			fileutil.WriteFileAtomic(file, data, 0o644)
+		}
+	}
+	return parseRanks(data)
+}
+
+func download(url string) ([]byte, error) {
+	client := &http.Client{Timeout: downloadTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parseRanks parses a tiktoken rank file: one base64 token and its rank
+// per line.
+func parseRanks(data []byte) (map[string]int, error) {
+	ranks := make(map[string]int)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("malformed rank line %q", line)
+		}
+		b, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, err
+		}
+		r, err := strconv.Atoi(strings.TrimSpace(rank))
+		if err != nil {
+			return nil, err
+		}
+		ranks[string(b)] = r
+	}
+	return ranks, nil
+}
@@ -0,0 +1,106 @@
+// Package tokenizer counts the tokens a prompt takes for a given model, so
+// requests can be fitted to the model's context window before they are sent.
+package tokenizer
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// messageOverhead approximates the tokens each message costs beyond its
+// content (role markers and separators).
+const messageOverhead = 4
+
+// mediaPartTokens estimates what one image, audio or file part costs. Actual
+// costs vary by provider and media size; this keeps attachments visible to
+// the budget without counting their base64 payload as text.
+const mediaPartTokens = 1024
+
+// Counter counts the tokens in a piece of text.
+type Counter interface {
+	Count(text string) int
+}
+
+// Heuristic estimates 2.5 ASCII characters per token, which errs on the high
+// side for English and code, and one token per other character, since
+// tokenizers split CJK and most other scripts into a token or more per
+// character. It is used for models without a known tokenizer.
+type Heuristic struct{}
+
+func (Heuristic) Count(text string) int {
+	ascii, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return ascii*2/5 + other
+}
+
+// ForModel returns the counter for model, which may carry a protocol prefix
+// such as "openai/". OpenAI-style models get a BPE tokenizer; all others are
+// estimated with Heuristic.
+func ForModel(model string) Counter {
+	id := model
+	if i := strings.LastIndex(id, "/"); i >= 0 {
+		id = id[i+1:]
+	}
+	if name := encodingForModel(strings.ToLower(id)); name != "" {
+		return bpeCounterFor(name)
+	}
+	return Heuristic{}
+}
+
+// CountMessage returns the tokens msg takes in a request. A message with
+// content parts is sent as those parts, so they are counted instead of
+// Content.
+func CountMessage(c Counter, msg providers.Message) int {
+	n := messageOverhead
+	if len(msg.Parts) == 0 {
+		n += c.Count(msg.Content)
+	}
+	for _, p := range msg.Parts {
+		if p.Type == "text" {
+			n += c.Count(p.Text)
+		} else {
+			n += mediaPartTokens
+		}
+	}
+	if msg.ReasoningContent != "" {
+		n += c.Count(msg.ReasoningContent)
+	}
+	for _, tc := range msg.ToolCalls {
+		n += c.Count(tc.Name)
+		if tc.Function != nil {
+			n += c.Count(tc.Function.Name) + c.Count(tc.Function.Arguments)
+		}
+	}
+	return n
+}
+
+// CountMessages returns the tokens msgs take in a request.
+func CountMessages(c Counter, msgs []providers.Message) int {
+	n := 0
+	for _, m := range msgs {
+		n += CountMessage(c, m)
+	}
+	return n
+}
+
+// CountTools returns the tokens the tool schemas take in a request.
+func CountTools(c Counter, defs []providers.ToolDefinition) int {
+	n := 0
+	for _, d := range defs {
+		data, err := json.Marshal(d)
+		if err != nil {
+			continue
+		}
+		n += c.Count(string(data))
+	}
+	return n
+}
@@ -0,0 +1,146 @@
+package tokenizer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+func TestForModel(t *testing.T) {
+	tests := []struct {
+		model string
+		bpe   bool
+	}{
+		{"gpt-4o", true},
+		{"openai/gpt-4o-mini", true},
+		{"openrouter/openai/o3-mini", true},
+		{"gpt-3.5-turbo", true},
+		{"anthropic/claude-sonnet-4.6", false},
+		{"glm-4.7", false},
+		{"ollama/llama3", false},
+	}
+	for _, tt := range tests {
+		_, isBPE := ForModel(tt.model).(*bpeCounter)
+		if isBPE != tt.bpe {
+			t.Errorf("ForModel(%q) BPE = %v, want %v", tt.model, isBPE, tt.bpe)
+		}
+	}
+	if ForModel("gpt-4o") != ForModel("openai/gpt-4o-2024-08-06") {
+		t.Error("models sharing an encoding should share a counter")
+	}
+}
+
+func TestHeuristic(t *testing.T) {
+	if got := (Heuristic{}).Count("hello world"); got != 4 {
+		t.Errorf("Count = %d, want 4", got)
+	}
+	if got := (Heuristic{}).Count("你好世界你好"); got != 6 {
+		t.Errorf("Count = %d, want a token per CJK character", got)
+	}
+	if got := (Heuristic{}).Count("hello 世界"); got != 4 {
+		t.Errorf("Count of mixed text = %d, want 4", got)
+	}
+}
+
+func TestCountMessages(t *testing.T) {
+	msgs := []providers.Message{
+		{Role: "user", Content: strings.Repeat("a", 10)},
+		{Role: "assistant", ToolCalls: []providers.ToolCall{{
+			Name:     "read_file",
+			Function: &providers.FunctionCall{Name: "read_file", Arguments: `{"path":"a.txt"}`},
+		}}},
+	}
+	c := Heuristic{}
+	want := 2*messageOverhead + c.Count(msgs[0].Content) +
+		2*c.Count("read_file") + c.Count(`{"path":"a.txt"}`)
+	if got := CountMessages(c, msgs); got != want {
+		t.Errorf("CountMessages = %d, want %d", got, want)
+	}
+
+	withImage := providers.Message{Role: "user", Content: "look", Parts: []providers.ContentPart{
+		{Type: "text", Text: "look"},
+		{Type: "image", MIMEType: "image/png", Data: strings.Repeat("A", 100000)},
+	}}
+	if got, want := CountMessage(c, withImage), messageOverhead+c.Count("look")+mediaPartTokens; got != want {
+		t.Errorf("CountMessage with an image = %d, want %d", got, want)
+	}
+
+	defs := []providers.ToolDefinition{{Type: "function", Function: providers.ToolFunctionDefinition{Name: "read_file"}}}
+	if CountTools(c, defs) == 0 {
+		t.Error("CountTools should count the schema")
+	}
+}
+
+func TestBPECounter_LoadsCachedEncoding(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TIKTOKEN_CACHE_DIR", dir)
+
+	// A toy encoding: every single byte, plus one merge for "he".
+	var sb strings.Builder
+	for i := 0; i < 256; i++ {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), i)
+	}
+	fmt.Fprintf(&sb, "%s 256\n", base64.StdEncoding.EncodeToString([]byte("he")))
+	if err := os.WriteFile(filepath.Join(dir, "cl100k_base.tiktoken"), []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := ForModel("gpt-4")
+	if got := c.Count("hello"); got != (Heuristic{}).Count("hello") {
+		t.Errorf("before loading, Count = %d, want heuristic", got)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Count("hello") != 4 {
+		if time.Now().After(deadline) {
+			t.Fatalf("BPE encoding not loaded, Count = %d", c.Count("hello"))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCachedLoader_DownloadsOnlyWhenEnabled(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		fmt.Fprintln(w, "aGk= 7")
+	}))
+	defer server.Close()
+	l := &cachedLoader{dir: t.TempDir()}
+	defer EnableDownloads(false)
+
+	if _, err := l.LoadTiktokenBpe(server.URL + "/test.tiktoken"); !errors.Is(err, errNotCached) {
+		t.Fatalf("error = %v, want errNotCached", err)
+	}
+	if hits.Load() != 0 {
+		t.Fatal("downloaded with downloads disabled")
+	}
+
+	EnableDownloads(true)
+	ranks, err := l.LoadTiktokenBpe(server.URL + "/test.tiktoken")
+	if err != nil || ranks["hi"] != 7 || hits.Load() != 1 {
+		t.Fatalf("ranks = %v, err %v, hits %d", ranks, err, hits.Load())
+	}
+	if _, err := os.Stat(filepath.Join(l.dir, "test.tiktoken")); err != nil {
+		t.Errorf("rank file not cached: %v", err)
+	}
+}
+
+func TestParseRanks(t *testing.T) {
+	ranks, err := parseRanks([]byte("aGk= 7\n\n"))
+	if err != nil || ranks["hi"] != 7 {
+		t.Errorf("parseRanks = %v, %v", ranks, err)
+	}
+	if _, err := parseRanks([]byte("garbage")); err == nil {
+		t.Error("expected error for malformed line")
+	}
+}
@@ -11,7 +11,11 @@ type Tool interface {
 }
 
 // ContextualTool is an optional interface that tools can implement
-// to receive the current message context (channel, chatID)
+// to receive the current message context (channel, chatID).
+//
+// Deprecated: read the request from ctx with ToolCallContextFrom instead.
+// SetContext mutates a tool shared by concurrent sessions, so the registry
+// serializes calls to tools implementing it.
 type ContextualTool interface {
 	Tool
 	SetContext(channel, chatID string)
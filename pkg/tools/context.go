@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// ToolCallContext carries the request a tool call belongs to. The agent loop
+// attaches one per processed message, so tools shared across concurrent
+// sessions read their target from the context instead of mutable fields.
+type ToolCallContext struct {
+	Channel    string
+	ChatID     string
+	SenderID   string
+	SessionKey string
+	AgentID    string
+
+	messageSent atomic.Bool
+	reasoning   string
+	streamID    string
+}
+
+type toolCallContextKey struct{}
+
+// WithToolCallContext returns a copy of ctx carrying tc.
+func WithToolCallContext(ctx context.Context, tc *ToolCallContext) context.Context {
+	return context.WithValue(ctx, toolCallContextKey{}, tc)
+}
+
+// ToolCallContextFrom returns the ToolCallContext attached to ctx, or nil.
+func ToolCallContextFrom(ctx context.Context) *ToolCallContext {
+	tc, _ := ctx.Value(toolCallContextKey{}).(*ToolCallContext)
+	return tc
+}
+
+// ToolTarget returns the channel and chat ID of the current request, or
+// fallbackChannel/fallbackChatID when ctx carries none.
+func ToolTarget(ctx context.Context, fallbackChannel, fallbackChatID string) (string, string) {
+	if tc := ToolCallContextFrom(ctx); tc != nil && tc.Channel != "" && tc.ChatID != "" {
+		return tc.Channel, tc.ChatID
+	}
+	return fallbackChannel, fallbackChatID
+}
+
+// MarkMessageSent records that a tool delivered a message to the user during
+// this request.
+func (tc *ToolCallContext) MarkMessageSent() {
+	if tc != nil {
+		tc.messageSent.Store(true)
+	}
+}
+
+// MessageSent reports whether a tool delivered a message during this request.
+func (tc *ToolCallContext) MessageSent() bool {
+	return tc != nil && tc.messageSent.Load()
+}
+
+// SetReasoning records the model's reasoning behind the request's final
+// reply, for channels that show it.
+func (tc *ToolCallContext) SetReasoning(reasoning string) {
+	if tc != nil {
+		tc.reasoning = reasoning
+	}
+}
+
+// Reasoning returns the reasoning recorded with SetReasoning.
+func (tc *ToolCallContext) Reasoning() string {
+	if tc == nil {
+		return ""
+	}
+	return tc.reasoning
+}
+
+// SetStreamID records the stream the request's reply was streamed into, so
+// the final reply replaces it.
+func (tc *ToolCallContext) SetStreamID(id string) {
+	if tc != nil {
+		tc.streamID = id
+	}
+}
+
+// StreamID returns the stream recorded with SetStreamID.
+func (tc *ToolCallContext) StreamID() string {
+	if tc == nil {
+		return ""
+	}
+	return tc.streamID
+}
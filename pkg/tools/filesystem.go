@@ -149,6 +149,11 @@ func (t *WriteFileTool) Name() string {
 	return "write_file"
 }
 
+// ParallelSafe reports false so writes keep the order the model issued them in.
+func (t *WriteFileTool) ParallelSafe() bool {
+	return false
+}
+
 func (t *WriteFileTool) Description() string {
 	return "Write content to a file"
 }
@@ -0,0 +1,173 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+const (
+	defaultMemorySearchLimit = 5
+	maxMemorySearchLimit     = 20
+)
+
+// MemoryBackend is the agent memory searched and written by the memory tools.
+type MemoryBackend interface {
+	// Search ranks memory chunks against query. Archived transcripts of
+	// sessions other than sessionKey are not searched.
+	Search(query string, limit int, sessionKey string) ([]memory.Result, error)
+	Write(entry memory.Entry) (string, error)
+}
+
+// MemorySearchTool searches the agent's notes, daily logs and archived
+// conversations.
+type MemorySearchTool struct {
+	backend MemoryBackend
+}
+
+func NewMemorySearchTool(backend MemoryBackend) *MemorySearchTool {
+	return &MemorySearchTool{backend: backend}
+}
+
+func (t *MemorySearchTool) Name() string {
+	return "memory_search"
+}
+
+func (t *MemorySearchTool) Description() string {
+	return "Search long-term memory, daily notes and archived past conversations by keywords. " +
+		"Use this to recall facts, preferences or earlier discussions that are not in the current context."
+}
+
+func (t *MemorySearchTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "Keywords to search for",
+			},
+			"limit": map[string]any{
+				"type":        "integer",
+				"description": fmt.Sprintf("Maximum number of results (default %d, max %d)", defaultMemorySearchLimit, maxMemorySearchLimit),
+			},
+		},
+		"required": []string{"query"},
+	}
+}
+
+func (t *MemorySearchTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	query, ok := args["query"].(string)
+	if !ok || strings.TrimSpace(query) == "" {
+		return ErrorResult("query is required")
+	}
+
+	limit := defaultMemorySearchLimit
+	if l, ok := args["limit"].(float64); ok && int(l) > 0 {
+		limit = min(int(l), maxMemorySearchLimit)
+	}
+
+	var sessionKey string
+	if tc := ToolCallContextFrom(ctx); tc != nil {
+		sessionKey = tc.SessionKey
+	}
+	results, err := t.backend.Search(query, limit, sessionKey)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("memory search failed: %v", err)).WithError(err)
+	}
+	if len(results) == 0 {
+		return NewToolResult(fmt.Sprintf("No memories found for %q", query))
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Found %d memories for %q:\n", len(results), query)
+	for i, r := range results {
+		fmt.Fprintf(&sb, "\n%d. memory/%s:%d", i+1, r.Path, r.Line)
+		if r.Heading != "" {
+			fmt.Fprintf(&sb, " (%s)", r.Heading)
+		}
+		fmt.Fprintf(&sb, "\n%s\n", r.Text)
+	}
+	return NewToolResult(sb.String())
+}
+
+// MemoryWriteTool saves a structured note to the agent's memory.
+type MemoryWriteTool struct {
+	backend MemoryBackend
+}
+
+func NewMemoryWriteTool(backend MemoryBackend) *MemoryWriteTool {
+	return &MemoryWriteTool{backend: backend}
+}
+
+func (t *MemoryWriteTool) Name() string {
+	return "memory_write"
+}
+
+// ParallelSafe reports false so notes land in the order the model wrote them.
+func (t *MemoryWriteTool) ParallelSafe() bool {
+	return false
+}
+
+func (t *MemoryWriteTool) Description() string {
+	return "Save a note to memory. Use target \"long_term\" for lasting facts and preferences " +
+		"(kept in MEMORY.md) and \"daily\" (default) for events and progress of the day."
+}
+
+func (t *MemoryWriteTool) Parameters() map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"content": map[string]any{
+				"type":        "string",
+				"description": "The note to remember",
+			},
+			"title": map[string]any{
+				"type":        "string",
+				"description": "Short heading for the note",
+			},
+			"tags": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Tags that help find the note later",
+			},
+			"target": map[string]any{
+				"type":        "string",
+				"enum":        []string{"daily", "long_term"},
+				"description": "Where to file the note (default daily)",
+			},
+		},
+		"required": []string{"content"},
+	}
+}
+
+func (t *MemoryWriteTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
+	content, ok := args["content"].(string)
+	if !ok || strings.TrimSpace(content) == "" {
+		return ErrorResult("content is required")
+	}
+
+	entry := memory.Entry{Content: content}
+	entry.Title, _ = args["title"].(string)
+	if tags, ok := args["tags"].([]any); ok {
+		for _, tag := range tags {
+			if s, ok := tag.(string); ok {
+				entry.Tags = append(entry.Tags, s)
+			}
+		}
+	}
+	switch target, _ := args["target"].(string); target {
+	case "", "daily":
+	case "long_term":
+		entry.LongTerm = true
+	default:
+		return ErrorResult(fmt.Sprintf("unknown target %q (use daily or long_term)", target))
+	}
+
+	path, err := t.backend.Write(entry)
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("failed to save memory: %v", err)).WithError(err)
+	}
+	return SilentResult(fmt.Sprintf("Memory saved to %s", path))
+}
@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/memory"
+)
+
+type fakeMemory struct {
+	results []memory.Result
+	query   string
+	limit   int
+	session string
+	written []memory.Entry
+}
+
+func (f *fakeMemory) Search(query string, limit int, sessionKey string) ([]memory.Result, error) {
+	f.query, f.limit, f.session = query, limit, sessionKey
+	return f.results, nil
+}
+
+func (f *fakeMemory) Write(entry memory.Entry) (string, error) {
+	f.written = append(f.written, entry)
+	if entry.LongTerm {
+		return "memory/MEMORY.md", nil
+	}
+	return "memory/202601/20260105.md", nil
+}
+
+func TestMemorySearchTool(t *testing.T) {
+	backend := &fakeMemory{results: []memory.Result{
+		{Path: "MEMORY.md", Line: 3, Heading: "Preferences", Text: "Prefers green tea"},
+	}}
+	tool := NewMemorySearchTool(backend)
+
+	result := tool.Execute(context.Background(), map[string]any{"query": "tea", "limit": float64(50)})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if backend.limit != maxMemorySearchLimit {
+		t.Errorf("limit = %d, want clamp to %d", backend.limit, maxMemorySearchLimit)
+	}
+	for _, want := range []string{"memory/MEMORY.md:3", "(Preferences)", "Prefers green tea"} {
+		if !strings.Contains(result.ForLLM, want) {
+			t.Errorf("result missing %q:\n%s", want, result.ForLLM)
+		}
+	}
+
+	backend.results = nil
+	ctx := WithToolCallContext(context.Background(), &ToolCallContext{SessionKey: "agent:main:cli:direct"})
+	result = tool.Execute(ctx, map[string]any{"query": "coffee"})
+	if result.IsError || !strings.Contains(result.ForLLM, "No memories found") {
+		t.Errorf("empty search result = %+v", result)
+	}
+	if backend.limit != defaultMemorySearchLimit {
+		t.Errorf("default limit = %d", backend.limit)
+	}
+	if backend.session != "agent:main:cli:direct" {
+		t.Errorf("search not scoped to the calling session: %q", backend.session)
+	}
+
+	if result := tool.Execute(context.Background(), map[string]any{}); !result.IsError {
+		t.Error("expected error without query")
+	}
+}
+
+func TestMemoryWriteTool(t *testing.T) {
+	backend := &fakeMemory{}
+	tool := NewMemoryWriteTool(backend)
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"content": "Prefers green tea",
+		"title":   "Drinks",
+		"tags":    []any{"prefs", 1},
+		"target":  "long_term",
+	})
+	if result.IsError {
+		t.Fatalf("unexpected error: %s", result.ForLLM)
+	}
+	if !result.Silent || !strings.Contains(result.ForLLM, "memory/MEMORY.md") {
+		t.Errorf("result = %+v", result)
+	}
+	got := backend.written[0]
+	if got.Title != "Drinks" || !got.LongTerm || len(got.Tags) != 1 || got.Tags[0] != "prefs" {
+		t.Errorf("entry = %+v", got)
+	}
+
+	result = tool.Execute(context.Background(), map[string]any{"content": "Walked the dog"})
+	if result.IsError || backend.written[1].LongTerm {
+		t.Errorf("default target should be daily: %+v", backend.written[1])
+	}
+
+	if result := tool.Execute(context.Background(), map[string]any{"content": "x", "target": "weekly"}); !result.IsError {
+		t.Error("expected error for unknown target")
+	}
+	if result := tool.Execute(context.Background(), map[string]any{"content": "  "}); !result.IsError {
+		t.Error("expected error for empty content")
+	}
+}
@@ -8,10 +8,7 @@ import (
 type SendCallback func(channel, chatID, content string) error
 
 type MessageTool struct {
-	sendCallback   SendCallback
-	defaultChannel string
-	defaultChatID  string
-	sentInRound    bool // Tracks whether a message was sent in the current processing round
+	sendCallback SendCallback
 }
 
 func NewMessageTool() *MessageTool {
@@ -22,6 +19,12 @@ func (t *MessageTool) Name() string {
 	return "message"
 }
 
+// ParallelSafe reports false so messages reach the user in the order the
+// model sent them.
+func (t *MessageTool) ParallelSafe() bool {
+	return false
+}
+
 func (t *MessageTool) Description() string {
 	return "Send a message to user on a chat channel. Use this when you want to communicate something."
 }
@@ -47,17 +50,6 @@ func (t *MessageTool) Parameters() map[string]any {
 	}
 }
 
-func (t *MessageTool) SetContext(channel, chatID string) {
-	t.defaultChannel = channel
-	t.defaultChatID = chatID
-	t.sentInRound = false // Reset send tracking for new processing round
-}
-
-// HasSentInRound returns true if the message tool sent a message during the current round.
-func (t *MessageTool) HasSentInRound() bool {
-	return t.sentInRound
-}
-
 func (t *MessageTool) SetSendCallback(callback SendCallback) {
 	t.sendCallback = callback
 }
@@ -71,11 +63,14 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 	channel, _ := args["channel"].(string)
 	chatID, _ := args["chat_id"].(string)
 
-	if channel == "" {
-		channel = t.defaultChannel
-	}
-	if chatID == "" {
-		chatID = t.defaultChatID
+	tc := ToolCallContextFrom(ctx)
+	if tc != nil {
+		if channel == "" {
+			channel = tc.Channel
+		}
+		if chatID == "" {
+			chatID = tc.ChatID
+		}
 	}
 
 	if channel == "" || chatID == "" {
@@ -94,7 +89,7 @@ func (t *MessageTool) Execute(ctx context.Context, args map[string]any) *ToolRes
 		}
 	}
 
-	t.sentInRound = true
+	tc.MarkMessageSent()
 	// Silent: user already received the message directly
 	return &ToolResult{
 		ForLLM: fmt.Sprintf("Message sent to %s:%s", channel, chatID),
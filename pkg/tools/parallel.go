@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// DefaultMaxParallelTools bounds how many tool calls of one LLM response run
+// at once when no limit is configured.
+const DefaultMaxParallelTools = 4
+
+// ParallelSafeTool is an optional interface for tools to declare whether
+// their calls may overlap other tool calls of the same LLM response. Tools
+// that don't implement it are treated as parallel-safe.
+type ParallelSafeTool interface {
+	Tool
+	ParallelSafe() bool
+}
+
+// isParallelSafe reports whether a call to name may run concurrently.
+// Unknown tools are safe: executing them only produces an error result.
+func (r *ToolRegistry) isParallelSafe(name string) bool {
+	tool, ok := r.Get(name)
+	if !ok {
+		return true
+	}
+	if ps, ok := tool.(ParallelSafeTool); ok {
+		return ps.ParallelSafe()
+	}
+	return true
+}
+
+// RunToolCalls executes calls with run and returns their results in call
+// order. Consecutive parallel-safe calls run concurrently, at most limit at a
+// time (limit <= 0 means DefaultMaxParallelTools). A call to a tool that is
+// not parallel-safe waits for everything before it and runs alone, so it
+// still observes the effects of earlier calls.
+func (r *ToolRegistry) RunToolCalls(
+	ctx context.Context,
+	calls []providers.ToolCall,
+	limit int,
+	run func(ctx context.Context, call providers.ToolCall) *ToolResult,
+) []*ToolResult {
+	if limit <= 0 {
+		limit = DefaultMaxParallelTools
+	}
+	results := make([]*ToolResult, len(calls))
+	slots := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+
+	for i, call := range calls {
+		if limit == 1 || (r != nil && !r.isParallelSafe(call.Name)) {
+			wg.Wait()
+			results[i] = run(ctx, call)
+			continue
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			results[i] = run(ctx, call)
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+type sequentialMockTool struct {
+	mockRegistryTool
+}
+
+func (t *sequentialMockTool) ParallelSafe() bool { return false }
+
+func newParallelTestRegistry() *ToolRegistry {
+	r := NewToolRegistry()
+	r.Register(newMockTool("fetch", "I/O bound"))
+	r.Register(&sequentialMockTool{*newMockTool("edit", "mutates files")})
+	return r
+}
+
+func calls(names ...string) []providers.ToolCall {
+	out := make([]providers.ToolCall, len(names))
+	for i, n := range names {
+		out[i] = providers.ToolCall{ID: n + string(rune('0'+i)), Name: n}
+	}
+	return out
+}
+
+func TestRunToolCalls_RunsSafeCallsConcurrentlyInOrder(t *testing.T) {
+	r := newParallelTestRegistry()
+
+	var running, peak atomic.Int32
+	results := r.RunToolCalls(context.Background(), calls("fetch", "fetch", "fetch", "fetch", "fetch"), 3,
+		func(ctx context.Context, tc providers.ToolCall) *ToolResult {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+			return NewToolResult(tc.ID)
+		})
+
+	if peak.Load() != 3 {
+		t.Errorf("peak concurrency = %d, want the limit of 3", peak.Load())
+	}
+	for i, res := range results {
+		if want := "fetch" + string(rune('0'+i)); res.ForLLM != want {
+			t.Errorf("results[%d] = %q, want %q (call order)", i, res.ForLLM, want)
+		}
+	}
+}
+
+func TestRunToolCalls_SequentialToolRunsAlone(t *testing.T) {
+	r := newParallelTestRegistry()
+
+	var mu sync.Mutex
+	var events []string
+	var running atomic.Int32
+	r.RunToolCalls(context.Background(), calls("fetch", "fetch", "edit", "fetch"), 4,
+		func(ctx context.Context, tc providers.ToolCall) *ToolResult {
+			if n := running.Add(1); tc.Name == "edit" && n != 1 {
+				t.Errorf("edit overlapped %d other calls", n-1)
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+			mu.Lock()
+			events = append(events, tc.ID)
+			mu.Unlock()
+			return NewToolResult(tc.ID)
+		})
+
+	// Both fetches before the edit must have finished before it, and the
+	// fetch after it must start after it.
+	if len(events) != 4 || events[2] != "edit2" || events[3] != "fetch3" {
+		t.Errorf("completion order = %v, want edit2 third and fetch3 last", events)
+	}
+}
+
+func TestRunToolCalls_LimitOneIsSequential(t *testing.T) {
+	r := newParallelTestRegistry()
+
+	var running, peak atomic.Int32
+	r.RunToolCalls(context.Background(), calls("fetch", "fetch", "fetch"), 1,
+		func(ctx context.Context, tc providers.ToolCall) *ToolResult {
+			if n := running.Add(1); n > peak.Load() {
+				peak.Store(n)
+			}
+			time.Sleep(5 * time.Millisecond)
+			running.Add(-1)
+			return NewToolResult("")
+		})
+	if peak.Load() != 1 {
+		t.Errorf("peak concurrency = %d, want 1", peak.Load())
+	}
+}
+
+func TestRegistry_SideEffectToolsAreSequential(t *testing.T) {
+	r := NewToolRegistry()
+	r.Register(NewMessageTool())
+	r.Register(&CronTool{})
+	for _, name := range []string{"message", "cron"} {
+		if r.isParallelSafe(name) {
+			t.Errorf("%s is parallel-safe, want sequential", name)
+		}
+	}
+}
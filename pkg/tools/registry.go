@@ -14,6 +14,10 @@ import (
 type ToolRegistry struct {
 	tools map[string]Tool
 	mu    sync.RWMutex
+
+	// contextualMu serializes legacy ContextualTool calls so that a
+	// SetContext from one request cannot leak into another's Execute.
+	contextualMu sync.Mutex
 }
 
 func NewToolRegistry() *ToolRegistry {
@@ -64,8 +68,15 @@ func (r *ToolRegistry) ExecuteWithContext(
 		return ErrorResult(fmt.Sprintf("tool %q not found", name)).WithError(fmt.Errorf("tool not found"))
 	}
 
-	// If tool implements ContextualTool, set context
+	// Carry the request target in ctx for tools that read ToolCallContext.
+	if ToolCallContextFrom(ctx) == nil && channel != "" && chatID != "" {
+		ctx = WithToolCallContext(ctx, &ToolCallContext{Channel: channel, ChatID: chatID})
+	}
+
+	// Legacy tools receive the target through SetContext instead.
 	if contextualTool, ok := tool.(ContextualTool); ok && channel != "" && chatID != "" {
+		r.contextualMu.Lock()
+		defer r.contextualMu.Unlock()
 		contextualTool.SetContext(channel, chatID)
 	}
 
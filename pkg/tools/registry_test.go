@@ -167,6 +167,34 @@ func TestToolRegistry_ExecuteWithContext_SkipsEmptyContext(t *testing.T) {
 	}
 }
 
+type ctxCaptureTool struct {
+	mockRegistryTool
+	got *ToolCallContext
+}
+
+func (m *ctxCaptureTool) Execute(ctx context.Context, _ map[string]any) *ToolResult {
+	m.got = ToolCallContextFrom(ctx)
+	return m.result
+}
+
+func TestToolRegistry_ExecuteWithContext_AttachesToolCallContext(t *testing.T) {
+	r := NewToolRegistry()
+	ct := &ctxCaptureTool{mockRegistryTool: *newMockTool("capture", "reads ctx")}
+	r.Register(ct)
+
+	r.ExecuteWithContext(context.Background(), "capture", nil, "telegram", "chat-42", nil)
+	if ct.got == nil || ct.got.Channel != "telegram" || ct.got.ChatID != "chat-42" {
+		t.Fatalf("ToolCallContext = %+v, want telegram:chat-42", ct.got)
+	}
+
+	// An existing request context is passed through untouched.
+	tc := &ToolCallContext{Channel: "slack", ChatID: "C1", SessionKey: "agent:main:main", AgentID: "main"}
+	r.ExecuteWithContext(WithToolCallContext(context.Background(), tc), "capture", nil, "telegram", "chat-42", nil)
+	if ct.got != tc {
+		t.Fatalf("ToolCallContext = %+v, want the request's own", ct.got)
+	}
+}
+
 func TestToolRegistry_ExecuteWithContext_AsyncCallback(t *testing.T) {
 	r := NewToolRegistry()
 	at := &mockAsyncRegistryTool{
@@ -113,6 +113,11 @@ func (t *ExecTool) Name() string {
 	return "exec"
 }
 
+// ParallelSafe reports false: commands may depend on each other's side effects.
+func (t *ExecTool) ParallelSafe() bool {
+	return false
+}
+
 func (t *ExecTool) Description() string {
 	return "Execute a shell command and return its output. Use with caution."
 }
@@ -21,6 +21,7 @@ import (
 type InstallSkillTool struct {
 	registryMgr *skills.RegistryManager
 	workspace   string
+	filter      *skills.Filter
 	mu          sync.Mutex
 }
 
@@ -35,10 +36,20 @@ func NewInstallSkillTool(registryMgr *skills.RegistryManager, workspace string)
 	}
 }
 
+// SetFilter restricts installation to skills the agent's filter allows.
+func (t *InstallSkillTool) SetFilter(filter *skills.Filter) {
+	t.filter = filter
+}
+
 func (t *InstallSkillTool) Name() string {
 	return "install_skill"
 }
 
+// ParallelSafe reports false: installs write to the shared skills directory.
+func (t *InstallSkillTool) ParallelSafe() bool {
+	return false
+}
+
 func (t *InstallSkillTool) Description() string {
 	return "Install a skill from a registry by slug. Downloads and extracts the skill into the workspace. Use find_skills first to discover available skills."
 }
@@ -79,6 +90,9 @@ func (t *InstallSkillTool) Execute(ctx context.Context, args map[string]any) *To
 	if err := utils.ValidateSkillIdentifier(slug); err != nil {
 		return ErrorResult(fmt.Sprintf("invalid slug %q: error: %s", slug, err.Error()))
 	}
+	if !t.filter.Allows(slug) {
+		return ErrorResult(fmt.Sprintf("skill %q is not allowed for this agent", slug))
+	}
 
 	// Validate registry
 	registryName, _ := args["registry"].(string)
@@ -102,3 +102,15 @@ func TestInstallSkillToolMissingRegistry(t *testing.T) {
 	assert.True(t, result.IsError)
 	assert.Contains(t, result.ForLLM, "invalid registry")
 }
+
+func TestInstallSkillToolFilterRejectsSlug(t *testing.T) {
+	tool := NewInstallSkillTool(skills.NewRegistryManager(), t.TempDir())
+	tool.SetFilter(skills.NewFilter([]string{"research-*"}))
+
+	result := tool.Execute(context.Background(), map[string]any{
+		"slug":     "home-lights",
+		"registry": "clawhub",
+	})
+	assert.True(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "not allowed for this agent")
+}
@@ -12,6 +12,7 @@ import (
 type FindSkillsTool struct {
 	registryMgr *skills.RegistryManager
 	cache       *skills.SearchCache
+	filter      *skills.Filter
 }
 
 // NewFindSkillsTool creates a new FindSkillsTool.
@@ -24,6 +25,11 @@ func NewFindSkillsTool(registryMgr *skills.RegistryManager, cache *skills.Search
 	}
 }
 
+// SetFilter hides search results the agent's skill filter does not allow.
+func (t *FindSkillsTool) SetFilter(filter *skills.Filter) {
+	t.filter = filter
+}
+
 func (t *FindSkillsTool) Name() string {
 	return "find_skills"
 }
@@ -69,7 +75,7 @@ func (t *FindSkillsTool) Execute(ctx context.Context, args map[string]any) *Tool
 	// Check cache first.
 	if t.cache != nil {
 		if cached, hit := t.cache.Get(query); hit {
-			return SilentResult(formatSearchResults(query, cached, true))
+			return SilentResult(formatSearchResults(query, t.allowed(cached), true))
 		}
 	}
 
@@ -84,7 +90,22 @@ func (t *FindSkillsTool) Execute(ctx context.Context, args map[string]any) *Tool
 		t.cache.Put(query, results)
 	}
 
-	return SilentResult(formatSearchResults(query, results, false))
+	return SilentResult(formatSearchResults(query, t.allowed(results), false))
+}
+
+// allowed drops results the skill filter does not allow. Unfiltered results
+// stay in the cache, which may be shared with other agents.
+func (t *FindSkillsTool) allowed(results []skills.SearchResult) []skills.SearchResult {
+	if t.filter == nil {
+		return results
+	}
+	out := make([]skills.SearchResult, 0, len(results))
+	for _, r := range results {
+		if t.filter.Allows(r.Slug) {
+			out = append(out, r)
+		}
+	}
+	return out
 }
 
 func formatSearchResults(query string, results []skills.SearchResult, cached bool) string {
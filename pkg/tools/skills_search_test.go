@@ -88,3 +88,21 @@ func TestFormatSearchResultsWithData(t *testing.T) {
 	assert.Contains(t, output, "clawhub")
 	assert.Contains(t, output, "install_skill")
 }
+
+func TestFindSkillsToolFilterHidesResults(t *testing.T) {
+	cache := skills.NewSearchCache(10, 5*60*1000*1000*1000) // 5 min
+	cache.Put("smart home", []skills.SearchResult{
+		{Slug: "home-lights", Score: 0.9, RegistryName: "clawhub"},
+		{Slug: "research-arxiv", Score: 0.5, RegistryName: "clawhub"},
+	})
+
+	tool := NewFindSkillsTool(skills.NewRegistryManager(), cache)
+	tool.SetFilter(skills.NewFilter([]string{"home-*"}))
+	result := tool.Execute(context.Background(), map[string]any{
+		"query": "smart home",
+	})
+
+	assert.False(t, result.IsError)
+	assert.Contains(t, result.ForLLM, "home-lights")
+	assert.NotContains(t, result.ForLLM, "research-arxiv")
+}
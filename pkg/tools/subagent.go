@@ -10,6 +10,41 @@ import (
 	"github.com/sipeed/picoclaw/pkg/providers"
 )
 
+// MaxSubagentDepth bounds how deeply subagents may delegate to further
+// subagents. The agent handling a user message runs at depth 0.
+const MaxSubagentDepth = 2
+
+type subagentDepthKey struct{}
+
+// SubagentDepth reports how many levels of subagents ctx is running under.
+func SubagentDepth(ctx context.Context) int {
+	depth, _ := ctx.Value(subagentDepthKey{}).(int)
+	return depth
+}
+
+func withSubagentDepth(ctx context.Context, depth int) context.Context {
+	return context.WithValue(ctx, subagentDepthKey{}, depth)
+}
+
+// SubagentProfile describes the agent a subagent task runs as.
+type SubagentProfile struct {
+	Provider      providers.LLMProvider
+	Model         string
+	SystemPrompt  string
+	Tools         *ToolRegistry
+	MaxIterations int
+	MaxTokens     int
+	Temperature   float64
+}
+
+// SubagentUsageHook receives the LLM responses of tasks delegated to
+// agentID, which is empty for the agent that owns the manager.
+type SubagentUsageHook func(ctx context.Context, agentID, model string, resp *providers.LLMResponse)
+
+// SubagentResolver returns the profile for running a task as agentID. An
+// empty agentID means the agent that owns the manager.
+type SubagentResolver func(agentID string) (*SubagentProfile, error)
+
 type SubagentTask struct {
 	ID            string
 	Task          string
@@ -35,6 +70,8 @@ type SubagentManager struct {
 	temperature    float64
 	hasMaxTokens   bool
 	hasTemperature bool
+	resolver       SubagentResolver
+	usageHook      SubagentUsageHook
 	nextID         int
 }
 
@@ -80,11 +117,98 @@ func (sm *SubagentManager) RegisterTool(tool Tool) {
 	sm.tools.Register(tool)
 }
 
+// SetResolver makes tasks run as the agent they are delegated to, with that
+// agent's prompt, tools and model, instead of the manager's defaults.
+func (sm *SubagentManager) SetResolver(resolver SubagentResolver) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.resolver = resolver
+}
+
+// SetUsageHook makes the manager report the LLM responses of its tasks.
+func (sm *SubagentManager) SetUsageHook(hook SubagentUsageHook) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.usageHook = hook
+}
+
+// prepare returns the loop configuration and base system prompt for a task
+// delegated to agentID, and ctx one subagent level deeper.
+func (sm *SubagentManager) prepare(
+	ctx context.Context,
+	agentID string,
+) (context.Context, ToolLoopConfig, string, error) {
+	depth := SubagentDepth(ctx)
+	if depth >= MaxSubagentDepth {
+		return ctx, ToolLoopConfig{}, "", fmt.Errorf("subagent depth limit (%d) reached", MaxSubagentDepth)
+	}
+	ctx = withSubagentDepth(ctx, depth+1)
+
+	sm.mu.RLock()
+	resolver := sm.resolver
+	var onResponse func(context.Context, string, *providers.LLMResponse)
+	if hook := sm.usageHook; hook != nil {
+		onResponse = func(ctx context.Context, model string, resp *providers.LLMResponse) {
+			hook(ctx, agentID, model, resp)
+		}
+	}
+	cfg := ToolLoopConfig{
+		Provider:      sm.provider,
+		Model:         sm.defaultModel,
+		Tools:         sm.tools,
+		MaxIterations: sm.maxIterations,
+		OnResponse:    onResponse,
+	}
+	if sm.hasMaxTokens || sm.hasTemperature {
+		cfg.LLMOptions = map[string]any{}
+		if sm.hasMaxTokens {
+			cfg.LLMOptions["max_tokens"] = sm.maxTokens
+		}
+		if sm.hasTemperature {
+			cfg.LLMOptions["temperature"] = sm.temperature
+		}
+	}
+	sm.mu.RUnlock()
+
+	if resolver == nil {
+		return ctx, cfg, "", nil
+	}
+	profile, err := resolver(agentID)
+	if err != nil {
+		return ctx, ToolLoopConfig{}, "", err
+	}
+	cfg = ToolLoopConfig{
+		Provider:      profile.Provider,
+		Model:         profile.Model,
+		Tools:         profile.Tools,
+		MaxIterations: profile.MaxIterations,
+		LLMOptions: map[string]any{
+			"max_tokens":  profile.MaxTokens,
+			"temperature": profile.Temperature,
+		},
+		OnResponse: onResponse,
+	}
+	return ctx, cfg, profile.SystemPrompt, nil
+}
+
+// subagentPrompt appends the subagent instructions to an agent's own prompt.
+func subagentPrompt(base, instructions string) string {
+	if base == "" {
+		return instructions
+	}
+	return base + "\n\n---\n\n" + instructions
+}
+
 func (sm *SubagentManager) Spawn(
 	ctx context.Context,
 	task, label, agentID, originChannel, originChatID string,
 	callback AsyncCallback,
 ) (string, error) {
+	ctx, cfg, basePrompt, err := sm.prepare(ctx, agentID)
+	if err != nil {
+		return "", err
+	}
+
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
@@ -104,7 +228,7 @@ func (sm *SubagentManager) Spawn(
 	sm.tasks[taskID] = subagentTask
 
 	// Start task in background with context cancellation support
-	go sm.runTask(ctx, subagentTask, callback)
+	go sm.runTask(ctx, subagentTask, cfg, basePrompt, callback)
 
 	if label != "" {
 		return fmt.Sprintf("Spawned subagent '%s' for task: %s", label, task), nil
@@ -112,14 +236,20 @@ func (sm *SubagentManager) Spawn(
 	return fmt.Sprintf("Spawned subagent for task: %s", task), nil
 }
 
-func (sm *SubagentManager) runTask(ctx context.Context, task *SubagentTask, callback AsyncCallback) {
+func (sm *SubagentManager) runTask(
+	ctx context.Context,
+	task *SubagentTask,
+	cfg ToolLoopConfig,
+	basePrompt string,
+	callback AsyncCallback,
+) {
 	task.Status = "running"
 	task.Created = time.Now().UnixMilli()
 
 	// Build system prompt for subagent
-	systemPrompt := `You are a subagent. Complete the given task independently and report the result.
+	systemPrompt := subagentPrompt(basePrompt, `You are a subagent. Complete the given task independently and report the result.
 You have access to tools - use them as needed to complete your task.
-After completing the task, provide a clear summary of what was done.`
+After completing the task, provide a clear summary of what was done.`)
 
 	messages := []providers.Message{
 		{
@@ -144,33 +274,7 @@ After completing the task, provide a clear summary of what was done.`
 	}
 
 	// Run tool loop with access to tools
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
-	}
-
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, task.OriginChannel, task.OriginChatID)
+	loopResult, err := RunToolLoop(ctx, cfg, messages, task.OriginChannel, task.OriginChatID)
 
 	sm.mu.Lock()
 	var result *ToolResult
@@ -250,16 +354,12 @@ func (sm *SubagentManager) ListTasks() []*SubagentTask {
 // Unlike SpawnTool which runs tasks asynchronously, SubagentTool waits for completion
 // and returns the result directly in the ToolResult.
 type SubagentTool struct {
-	manager       *SubagentManager
-	originChannel string
-	originChatID  string
+	manager *SubagentManager
 }
 
 func NewSubagentTool(manager *SubagentManager) *SubagentTool {
 	return &SubagentTool{
-		manager:       manager,
-		originChannel: "cli",
-		originChatID:  "direct",
+		manager: manager,
 	}
 }
 
@@ -283,16 +383,15 @@ func (t *SubagentTool) Parameters() map[string]any {
 				"type":        "string",
 				"description": "Optional short label for the task (for display)",
 			},
+			"output_schema": map[string]any{
+				"type":        "object",
+				"description": "Optional JSON schema the result must match; the result is then returned as JSON",
+			},
 		},
 		"required": []string{"task"},
 	}
 }
 
-func (t *SubagentTool) SetContext(channel, chatID string) {
-	t.originChannel = channel
-	t.originChatID = chatID
-}
-
 func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolResult {
 	task, ok := args["task"].(string)
 	if !ok {
@@ -305,11 +404,17 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		return ErrorResult("Subagent manager not configured").WithError(fmt.Errorf("manager is nil"))
 	}
 
+	ctx, cfg, basePrompt, err := t.manager.prepare(ctx, "")
+	if err != nil {
+		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
+	}
+
 	// Build messages for subagent
 	messages := []providers.Message{
 		{
-			Role:    "system",
-			Content: "You are a subagent. Complete the given task independently and provide a clear, concise result.",
+			Role: "system",
+			Content: subagentPrompt(basePrompt,
+				"You are a subagent. Complete the given task independently and provide a clear, concise result."),
 		},
 		{
 			Role:    "user",
@@ -318,38 +423,20 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 	}
 
 	// Use RunToolLoop to execute with tools (same as async SpawnTool)
-	sm := t.manager
-	sm.mu.RLock()
-	tools := sm.tools
-	maxIter := sm.maxIterations
-	maxTokens := sm.maxTokens
-	temperature := sm.temperature
-	hasMaxTokens := sm.hasMaxTokens
-	hasTemperature := sm.hasTemperature
-	sm.mu.RUnlock()
-
-	var llmOptions map[string]any
-	if hasMaxTokens || hasTemperature {
-		llmOptions = map[string]any{}
-		if hasMaxTokens {
-			llmOptions["max_tokens"] = maxTokens
-		}
-		if hasTemperature {
-			llmOptions["temperature"] = temperature
-		}
-	}
+	originChannel, originChatID := ToolTarget(ctx, "cli", "direct")
 
-	loopResult, err := RunToolLoop(ctx, ToolLoopConfig{
-		Provider:      sm.provider,
-		Model:         sm.defaultModel,
-		Tools:         tools,
-		MaxIterations: maxIter,
-		LLMOptions:    llmOptions,
-	}, messages, t.originChannel, t.originChatID)
+	loopResult, err := RunToolLoop(ctx, cfg, messages, originChannel, originChatID)
 	if err != nil {
 		return ErrorResult(fmt.Sprintf("Subagent execution failed: %v", err)).WithError(err)
 	}
 
+	if schema, ok := args["output_schema"].(map[string]any); ok && len(schema) > 0 {
+		loopResult.Content, err = structuredResult(ctx, cfg, messages, loopResult.Content, schema)
+		if err != nil {
+			return ErrorResult(fmt.Sprintf("Subagent result does not match the output schema: %v", err)).WithError(err)
+		}
+	}
+
 	// ForUser: Brief summary for user (truncated if too long)
 	userContent := loopResult.Content
 	maxUserLen := 500
@@ -373,3 +460,27 @@ func (t *SubagentTool) Execute(ctx context.Context, args map[string]any) *ToolRe
 		Async:   false,
 	}
 }
+
+// structuredResult asks for the result of a finished task again, as JSON
+// matching schema.
+func structuredResult(
+	ctx context.Context,
+	cfg ToolLoopConfig,
+	messages []providers.Message,
+	result string,
+	schema map[string]any,
+) (string, error) {
+	messages = append(messages,
+		providers.Message{Role: "assistant", Content: result},
+		providers.Message{Role: "user", Content: "Give the result of the task as JSON matching the required schema."},
+	)
+	format := providers.ResponseFormat{Name: "task_result", Schema: schema}
+	resp, err := providers.ChatStructured(ctx, cfg.Provider, messages, cfg.Model, cfg.LLMOptions, format, nil)
+	if cfg.OnResponse != nil && resp != nil {
+		cfg.OnResponse(ctx, cfg.Model, resp)
+	}
+	if err != nil {
+		return "", err
+	}
+	return resp.Content, nil
+}
@@ -2,6 +2,7 @@ package tools
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -50,9 +51,8 @@ func TestSubagentManager_SetLLMOptions_AppliesToRunToolLoop(t *testing.T) {
 	manager := NewSubagentManager(provider, "test-model", "/tmp/test", nil)
 	manager.SetLLMOptions(2048, 0.6)
 	tool := NewSubagentTool(manager)
-	tool.SetContext("cli", "direct")
 
-	ctx := context.Background()
+	ctx := WithToolCallContext(context.Background(), &ToolCallContext{Channel: "cli", ChatID: "direct"})
 	args := map[string]any{"task": "Do something"}
 	result := tool.Execute(ctx, args)
 
@@ -147,28 +147,14 @@ func TestSubagentTool_Parameters(t *testing.T) {
 	}
 }
 
-// TestSubagentTool_SetContext verifies context setting
-func TestSubagentTool_SetContext(t *testing.T) {
-	provider := &MockLLMProvider{}
-	manager := NewSubagentManager(provider, "test-model", "/tmp/test", nil)
-	tool := NewSubagentTool(manager)
-
-	tool.SetContext("test-channel", "test-chat")
-
-	// Verify context is set (we can't directly access private fields,
-	// but we can verify it doesn't crash)
-	// The actual context usage is tested in Execute tests
-}
-
 // TestSubagentTool_Execute_Success tests successful execution
 func TestSubagentTool_Execute_Success(t *testing.T) {
 	provider := &MockLLMProvider{}
 	msgBus := bus.NewMessageBus()
 	manager := NewSubagentManager(provider, "test-model", "/tmp/test", msgBus)
 	tool := NewSubagentTool(manager)
-	tool.SetContext("telegram", "chat-123")
 
-	ctx := context.Background()
+	ctx := WithToolCallContext(context.Background(), &ToolCallContext{Channel: "telegram", ChatID: "chat-123"})
 	args := map[string]any{
 		"task":  "Write a haiku about coding",
 		"label": "haiku-task",
@@ -300,9 +286,8 @@ func TestSubagentTool_Execute_ContextPassing(t *testing.T) {
 	// Set context
 	channel := "test-channel"
 	chatID := "test-chat"
-	tool.SetContext(channel, chatID)
 
-	ctx := context.Background()
+	ctx := WithToolCallContext(context.Background(), &ToolCallContext{Channel: channel, ChatID: chatID})
 	args := map[string]any{
 		"task": "Test context passing",
 	}
@@ -348,3 +333,133 @@ func TestSubagentTool_ForUserTruncation(t *testing.T) {
 		t.Error("ForLLM should contain reference to original task")
 	}
 }
+
+func TestSubagentManager_ResolverSelectsAgentProfile(t *testing.T) {
+	provider := &MockLLMProvider{}
+	agentTools := NewToolRegistry()
+	agentTools.Register(newMockTool("read_file", "reads files"))
+
+	var resolved []string
+	manager := NewSubagentManager(&MockLLMProvider{}, "parent-model", "/tmp/test", nil)
+	manager.SetResolver(func(agentID string) (*SubagentProfile, error) {
+		resolved = append(resolved, agentID)
+		if agentID == "missing" {
+			return nil, fmt.Errorf("agent %q not found", agentID)
+		}
+		return &SubagentProfile{
+			Provider:      provider,
+			Model:         "subagent-model",
+			SystemPrompt:  "You are the coder agent.",
+			Tools:         agentTools,
+			MaxIterations: 3,
+			MaxTokens:     1024,
+			Temperature:   0.2,
+		}, nil
+	})
+
+	ctx, cfg, prompt, err := manager.prepare(context.Background(), "coder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Provider != provider || cfg.Model != "subagent-model" || cfg.Tools != agentTools {
+		t.Errorf("loop config = %+v, want the resolved agent's provider, model and tools", cfg)
+	}
+	if cfg.LLMOptions["max_tokens"] != 1024 || cfg.LLMOptions["temperature"] != 0.2 {
+		t.Errorf("LLM options = %v", cfg.LLMOptions)
+	}
+	if prompt != "You are the coder agent." {
+		t.Errorf("prompt = %q", prompt)
+	}
+	if SubagentDepth(ctx) != 1 {
+		t.Errorf("depth = %d, want 1", SubagentDepth(ctx))
+	}
+
+	if _, err := manager.Spawn(context.Background(), "task", "", "missing", "cli", "direct", nil); err == nil {
+		t.Error("expected Spawn to fail for an unresolvable agent")
+	}
+	if len(resolved) != 2 || resolved[0] != "coder" || resolved[1] != "missing" {
+		t.Errorf("resolved = %v", resolved)
+	}
+}
+
+func TestSubagentTool_DepthLimit(t *testing.T) {
+	manager := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	tool := NewSubagentTool(manager)
+
+	ctx := withSubagentDepth(context.Background(), MaxSubagentDepth)
+	result := tool.Execute(ctx, map[string]any{"task": "recurse"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "depth limit") {
+		t.Errorf("expected depth limit error, got: %+v", result)
+	}
+
+	result = NewSpawnTool(manager).Execute(ctx, map[string]any{"task": "recurse"})
+	if !result.IsError || !strings.Contains(result.ForLLM, "depth limit") {
+		t.Errorf("expected spawn to hit the depth limit, got: %+v", result)
+	}
+}
+
+func TestSubagentManager_UsageHookSeesResponses(t *testing.T) {
+	manager := NewSubagentManager(&MockLLMProvider{}, "test-model", "/tmp/test", nil)
+	var seen []string
+	manager.SetUsageHook(func(ctx context.Context, agentID, model string, resp *providers.LLMResponse) {
+		tc := ToolCallContextFrom(ctx)
+		seen = append(seen, fmt.Sprintf("%s/%s/%s", agentID, model, tc.SenderID))
+	})
+
+	ctx := WithToolCallContext(context.Background(), &ToolCallContext{Channel: "cli", ChatID: "direct", SenderID: "alice"})
+	if result := NewSubagentTool(manager).Execute(ctx, map[string]any{"task": "count"}); result.IsError {
+		t.Fatalf("subagent failed: %+v", result)
+	}
+	if len(seen) != 1 || seen[0] != "/test-model/alice" {
+		t.Errorf("usage hook saw %v", seen)
+	}
+}
+
+// structuredProvider answers requests with a response_format in JSON.
+type structuredProvider struct {
+	MockLLMProvider
+	formats int
+}
+
+func (m *structuredProvider) Chat(
+	ctx context.Context,
+	messages []providers.Message,
+	tools []providers.ToolDefinition,
+	model string,
+	options map[string]any,
+) (*providers.LLMResponse, error) {
+	if providers.ResponseFormatFrom(options) != nil {
+		m.formats++
+		return &providers.LLMResponse{Content: `{"count": 3}`}, nil
+	}
+	return m.MockLLMProvider.Chat(ctx, messages, tools, model, options)
+}
+
+func TestSubagentTool_Execute_OutputSchema(t *testing.T) {
+	provider := &structuredProvider{}
+	tool := NewSubagentTool(NewSubagentManager(provider, "test-model", "/tmp/test", nil))
+
+	ctx := WithToolCallContext(context.Background(), &ToolCallContext{Channel: "cli", ChatID: "direct"})
+	result := tool.Execute(ctx, map[string]any{
+		"task": "count the files",
+		"output_schema": map[string]any{
+			"type":       "object",
+			"properties": map[string]any{"count": map[string]any{"type": "integer"}},
+			"required":   []any{"count"},
+		},
+	})
+	if result.IsError {
+		t.Fatalf("subagent failed: %+v", result)
+	}
+	if provider.formats != 1 || !strings.Contains(result.ForLLM, `Result: {"count": 3}`) {
+		t.Errorf("formats %d, result %q", provider.formats, result.ForLLM)
+	}
+
+	result = tool.Execute(ctx, map[string]any{
+		"task":          "count the files",
+		"output_schema": map[string]any{"type": "object", "required": []any{"total"}},
+	})
+	if !result.IsError {
+		t.Errorf("expected a result not matching the schema to fail, got %+v", result)
+	}
+}
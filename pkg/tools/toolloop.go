@@ -23,6 +23,12 @@ type ToolLoopConfig struct {
 	Tools         *ToolRegistry
 	MaxIterations int
 	LLMOptions    map[string]any
+	// MaxParallelTools bounds concurrent tool calls per iteration
+	// (0 means DefaultMaxParallelTools).
+	MaxParallelTools int
+	// OnResponse, when set, is called with every LLM response, e.g. to
+	// record its token usage.
+	OnResponse func(ctx context.Context, model string, resp *providers.LLMResponse)
 }
 
 // ToolLoopResult contains the result of running the tool loop.
@@ -64,6 +70,9 @@ func RunToolLoop(
 		}
 		// 3. Call LLM
 		response, err := config.Provider.Chat(ctx, messages, providerToolDefs, config.Model, llmOpts)
+		if config.OnResponse != nil && response != nil {
+			config.OnResponse(ctx, config.Model, response)
+		}
 		if err != nil {
 			logger.ErrorCF("toolloop", "LLM call failed",
 				map[string]any{
@@ -121,23 +130,26 @@ func RunToolLoop(
 		}
 		messages = append(messages, assistantMsg)
 
-		// 7. Execute tool calls
-		for _, tc := range normalizedToolCalls {
-			argsJSON, _ := json.Marshal(tc.Arguments)
-			argsPreview := utils.Truncate(string(argsJSON), 200)
-			logger.InfoCF("toolloop", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
-				map[string]any{
-					"tool":      tc.Name,
-					"iteration": iteration,
-				})
+		// 7. Execute tool calls, appending results in call order
+		results := config.Tools.RunToolCalls(ctx, normalizedToolCalls, config.MaxParallelTools,
+			func(ctx context.Context, tc providers.ToolCall) *ToolResult {
+				argsJSON, _ := json.Marshal(tc.Arguments)
+				argsPreview := utils.Truncate(string(argsJSON), 200)
+				logger.InfoCF("toolloop", fmt.Sprintf("Tool call: %s(%s)", tc.Name, argsPreview),
+					map[string]any{
+						"tool":      tc.Name,
+						"iteration": iteration,
+					})
+
+				// Execute tool (no async callback for subagents - they run independently)
+				if config.Tools == nil {
+					return ErrorResult("No tools available")
+				}
+				return config.Tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, channel, chatID, nil)
+			})
 
-			// Execute tool (no async callback for subagents - they run independently)
-			var toolResult *ToolResult
-			if config.Tools != nil {
-				toolResult = config.Tools.ExecuteWithContext(ctx, tc.Name, tc.Arguments, channel, chatID, nil)
-			} else {
-				toolResult = ErrorResult("No tools available")
-			}
+		for i, tc := range normalizedToolCalls {
+			toolResult := results[i]
 
 			// Determine content for LLM
 			contentForLLM := toolResult.ForLLM
@@ -0,0 +1,118 @@
+package usage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+// Budget actions.
+const (
+	ActionBlock     = "block"
+	ActionDowngrade = "downgrade"
+)
+
+// Exceeded describes a budget that is used up.
+type Exceeded struct {
+	Budget config.BudgetConfig
+	Period string // "daily" or "monthly"
+	Spent  Totals
+}
+
+// Action is what to do with requests under the budget.
+func (e *Exceeded) Action() string {
+	if e.Budget.Action == ActionDowngrade && e.Budget.DowngradeModel != "" {
+		return ActionDowngrade
+	}
+	return ActionBlock
+}
+
+func (e *Exceeded) String() string {
+	scope := "global"
+	switch b := e.Budget; {
+	case b.Agent != "" && b.Sender != "":
+		scope = fmt.Sprintf("agent %s, sender %s", b.Agent, b.Sender)
+	case b.Agent != "":
+		scope = "agent " + b.Agent
+	case b.Sender != "":
+		scope = "sender " + b.Sender
+	}
+	return fmt.Sprintf("%s %s budget (spent $%.4f, %d tokens)", e.Period, scope, e.Spent.Cost, e.Spent.TotalTokens)
+}
+
+// Check returns the used-up budget that applies to a request by sender on
+// channel to agentID, or nil. Blocking budgets take precedence over
+// downgrading ones.
+func (t *Tracker) Check(budgets []config.BudgetConfig, agentID, channel, sender string) *Exceeded {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	var downgrade *Exceeded
+	for _, b := range budgets {
+		f, ok := budgetFilter(b, agentID, channel, sender)
+		if !ok {
+			continue
+		}
+		var e *Exceeded
+		if b.DailyCost > 0 || b.DailyTokens > 0 {
+			if spent := t.Spent(f, today); overLimit(spent, b.DailyCost, b.DailyTokens) {
+				e = &Exceeded{Budget: b, Period: "daily", Spent: spent}
+			}
+		}
+		if e == nil && (b.MonthlyCost > 0 || b.MonthlyTokens > 0) {
+			if spent := t.Spent(f, month); overLimit(spent, b.MonthlyCost, b.MonthlyTokens) {
+				e = &Exceeded{Budget: b, Period: "monthly", Spent: spent}
+			}
+		}
+		switch {
+		case e == nil:
+		case e.Action() == ActionBlock:
+			return e
+		case downgrade == nil:
+			downgrade = e
+		}
+	}
+	return downgrade
+}
+
+// budgetFilter returns the usage a budget counts for a request, or false if
+// the budget does not apply to it.
+func budgetFilter(b config.BudgetConfig, agentID, channel, sender string) (Filter, bool) {
+	f := Filter{Agent: b.Agent}
+	if b.Agent != "" && !strings.EqualFold(b.Agent, agentID) {
+		return f, false
+	}
+	switch {
+	case b.Sender == "":
+	case sender == "":
+		return f, false
+	case b.Sender == "*":
+		f.Channel, f.Sender = channel, sender
+	case strings.Contains(b.Sender, ":"):
+		ch, id, _ := strings.Cut(b.Sender, ":")
+		if ch != channel || !senderMatches(id, sender) {
+			return f, false
+		}
+		f.Channel, f.Sender = channel, sender
+	default:
+		if !senderMatches(b.Sender, sender) {
+			return f, false
+		}
+		f.Sender = sender
+	}
+	return f, true
+}
+
+// senderMatches reports whether a configured sender names sender, which may
+// be a compound "id|username".
+func senderMatches(want, sender string) bool {
+	id, _, _ := strings.Cut(sender, "|")
+	return want == sender || want == id
+}
+
+func overLimit(spent Totals, cost float64, tokens int) bool {
+	return (cost > 0 && spent.Cost >= cost) || (tokens > 0 && spent.TotalTokens >= tokens)
+}
@@ -0,0 +1,80 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestTracker_Check(t *testing.T) {
+	tracker := NewTracker(t.TempDir())
+	for _, r := range []Record{
+		{Agent: "main", Channel: "telegram", Sender: "42|alice", PromptTokens: 900, Cost: 0.9},
+		{Agent: "main", Channel: "discord", Sender: "7", PromptTokens: 50, Cost: 0.05},
+	} {
+		if err := tracker.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		budgets []config.BudgetConfig
+		channel string
+		sender  string
+		want    string // action, or "" when nothing is exceeded
+	}{
+		{
+			name:    "agent daily cost",
+			budgets: []config.BudgetConfig{{Agent: "main", DailyCost: 0.5}},
+			channel: "discord", sender: "7", want: ActionBlock,
+		},
+		{
+			name:    "other agent",
+			budgets: []config.BudgetConfig{{Agent: "helper", DailyCost: 0.5}},
+			channel: "discord", sender: "7",
+		},
+		{
+			name:    "per sender tokens",
+			budgets: []config.BudgetConfig{{Sender: "*", DailyTokens: 100}},
+			channel: "discord", sender: "7",
+		},
+		{
+			name:    "per sender tokens, heavy sender",
+			budgets: []config.BudgetConfig{{Sender: "*", MonthlyTokens: 100}},
+			channel: "telegram", sender: "42|alice", want: ActionBlock,
+		},
+		{
+			name: "named sender downgrades",
+			budgets: []config.BudgetConfig{
+				{Sender: "telegram:42", MonthlyCost: 0.5, Action: "downgrade", DowngradeModel: "cheap"},
+			},
+			channel: "telegram", sender: "42|alice", want: ActionDowngrade,
+		},
+		{
+			name: "block wins over downgrade",
+			budgets: []config.BudgetConfig{
+				{Sender: "42", DailyCost: 0.5, Action: "downgrade", DowngradeModel: "cheap"},
+				{DailyTokens: 500},
+			},
+			channel: "telegram", sender: "42|alice", want: ActionBlock,
+		},
+		{
+			name:    "downgrade without a model blocks",
+			budgets: []config.BudgetConfig{{DailyTokens: 500, Action: "downgrade"}},
+			channel: "discord", sender: "7", want: ActionBlock,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := tracker.Check(tt.budgets, "main", tt.channel, tt.sender)
+			got := ""
+			if e != nil {
+				got = e.Action()
+			}
+			if got != tt.want {
+				t.Errorf("Check = %q (%v), want %q", got, e, tt.want)
+			}
+		})
+	}
+}
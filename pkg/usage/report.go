@@ -0,0 +1,81 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Groupings a report can break usage down by.
+var Groupings = []string{"agent", "session", "sender", "channel", "model", "kind", "day"}
+
+// Row is the usage of one group of a report.
+type Row struct {
+	Key string
+	Totals
+}
+
+// Summarize groups records by one of Groupings, most expensive first (most
+// tokens first among equally priced groups).
+func Summarize(records []Record, by string) ([]Row, error) {
+	key, err := groupKey(by)
+	if err != nil {
+		return nil, err
+	}
+	groups := map[string]*Row{}
+	for _, r := range records {
+		k := key(r)
+		if k == "" {
+			k = "-"
+		}
+		row := groups[k]
+		if row == nil {
+			row = &Row{Key: k}
+			groups[k] = row
+		}
+		row.Add(r)
+	}
+
+	rows := make([]Row, 0, len(groups))
+	for _, row := range groups {
+		rows = append(rows, *row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if by == "day" {
+			return rows[i].Key < rows[j].Key
+		}
+		if rows[i].Cost != rows[j].Cost {
+			return rows[i].Cost > rows[j].Cost
+		}
+		if rows[i].TotalTokens != rows[j].TotalTokens {
+			return rows[i].TotalTokens > rows[j].TotalTokens
+		}
+		return rows[i].Key < rows[j].Key
+	})
+	return rows, nil
+}
+
+func groupKey(by string) (func(Record) string, error) {
+	switch by {
+	case "agent":
+		return func(r Record) string { return r.Agent }, nil
+	case "session":
+		return func(r Record) string { return r.Session }, nil
+	case "sender":
+		return func(r Record) string {
+			if r.Sender == "" {
+				return ""
+			}
+			return r.Channel + ":" + r.Sender
+		}, nil
+	case "channel":
+		return func(r Record) string { return r.Channel }, nil
+	case "model":
+		return func(r Record) string { return r.Model }, nil
+	case "kind":
+		return func(r Record) string { return r.Kind }, nil
+	case "day":
+		return func(r Record) string { return r.Time.Local().Format(time.DateOnly) }, nil
+	}
+	return nil, fmt.Errorf("unknown grouping %q (use one of %v)", by, Groupings)
+}
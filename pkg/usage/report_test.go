@@ -0,0 +1,30 @@
+package usage
+
+import (
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	records := []Record{
+		{Agent: "main", Channel: "telegram", Sender: "1", Model: "a", TotalTokens: 10, Cost: 0.1},
+		{Agent: "main", Channel: "telegram", Sender: "1", Model: "b", TotalTokens: 300},
+		{Agent: "helper", Model: "a", TotalTokens: 20, Cost: 0.2},
+	}
+
+	rows, err := Summarize(records, "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].Key != "a" || rows[0].Requests != 2 || rows[0].TotalTokens != 30 {
+		t.Errorf("by model = %+v", rows)
+	}
+
+	rows, _ = Summarize(records, "sender")
+	if len(rows) != 2 || rows[0].Key != "-" || rows[1].Key != "telegram:1" || rows[1].TotalTokens != 310 {
+		t.Errorf("by sender = %+v", rows)
+	}
+
+	if _, err := Summarize(records, "planet"); err == nil {
+		t.Error("unknown grouping accepted")
+	}
+}
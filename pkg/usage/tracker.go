@@ -0,0 +1,202 @@
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// monthLayout names the usage log of each month, e.g. 2026-10.jsonl.
+const monthLayout = "2006-01"
+
+// Filter selects the usage of an agent, a sender or both. Empty fields
+// match everything.
+type Filter struct {
+	Agent   string
+	Channel string
+	Sender  string
+}
+
+func (f Filter) matches(k spendKey) bool {
+	return (f.Agent == "" || strings.EqualFold(f.Agent, k.agent)) &&
+		(f.Channel == "" || f.Channel == k.channel) &&
+		(f.Sender == "" || f.Sender == k.sender)
+}
+
+type spendKey struct {
+	day, agent, channel, sender string
+}
+
+// Tracker appends usage records to one JSONL file per month in a directory
+// and keeps the current month's totals in memory for budget checks.
+type Tracker struct {
+	dir string
+
+	mu    sync.Mutex
+	month string // month the totals below are for
+	spent map[spendKey]*Totals
+}
+
+// NewTracker returns a tracker keeping its logs in dir.
+func NewTracker(dir string) *Tracker {
+	return &Tracker{dir: dir}
+}
+
+// Record logs r. Missing times and totals are filled in.
+func (t *Tracker) Record(r Record) error {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	if r.TotalTokens == 0 {
+		r.TotalTokens = r.PromptTokens + r.CompletionTokens
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Load the month's totals before r is in its log, so it is not counted
+	// twice below.
+	loaded := t.loadLocked(time.Now())
+	if err := os.MkdirAll(t.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create usage directory: %w", err)
+	}
+	f, err := os.OpenFile(monthFile(t.dir, r.Time), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open usage log: %w", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write usage log: %w", err)
+	}
+
+	if loaded && r.Time.Local().Format(monthLayout) == t.month {
+		t.addLocked(r)
+	}
+	return nil
+}
+
+// Spent totals the usage matching f from since, which must fall in the
+// current month, until now.
+func (t *Tracker) Spent(f Filter, since time.Time) Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var total Totals
+	if !t.loadLocked(time.Now()) {
+		return total
+	}
+	day := since.Format(time.DateOnly)
+	for k, s := range t.spent {
+		if k.day < day || !f.matches(k) {
+			continue
+		}
+		total.Requests += s.Requests
+		total.PromptTokens += s.PromptTokens
+		total.CompletionTokens += s.CompletionTokens
+		total.TotalTokens += s.TotalTokens
+		total.Cost += s.Cost
+	}
+	return total
+}
+
+// loadLocked makes the in-memory totals those of now's month, reading its
+// log when the month changed. It reports whether the totals are usable.
+func (t *Tracker) loadLocked(now time.Time) bool {
+	month := now.Format(monthLayout)
+	if t.spent != nil && t.month == month {
+		return true
+	}
+	records, err := readFile(monthFile(t.dir, now))
+	if err != nil && !os.IsNotExist(err) {
+		return false
+	}
+	t.month = month
+	t.spent = map[spendKey]*Totals{}
+	for _, r := range records {
+		t.addLocked(r)
+	}
+	return true
+}
+
+func (t *Tracker) addLocked(r Record) {
+	k := spendKey{
+		day:     r.Time.Local().Format(time.DateOnly),
+		agent:   r.Agent,
+		channel: r.Channel,
+		sender:  r.Sender,
+	}
+	s := t.spent[k]
+	if s == nil {
+		s = &Totals{}
+		t.spent[k] = s
+	}
+	s.Add(r)
+}
+
+func monthFile(dir string, t time.Time) string {
+	return filepath.Join(dir, t.Local().Format(monthLayout)+".jsonl")
+}
+
+// Load reads the records logged in dir between since and until; zero times
+// leave the range open.
+func Load(dir string, since, until time.Time) ([]Record, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var records []Record
+	for _, file := range files {
+		month, err := time.ParseInLocation(monthLayout, strings.TrimSuffix(filepath.Base(file), ".jsonl"), time.Local)
+		if err != nil {
+			continue
+		}
+		if (!since.IsZero() && month.AddDate(0, 1, 0).Before(since)) || (!until.IsZero() && month.After(until)) {
+			continue
+		}
+		recs, err := readFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range recs {
+			if (since.IsZero() || !r.Time.Before(since)) && (until.IsZero() || r.Time.Before(until)) {
+				records = append(records, r)
+			}
+		}
+	}
+	return records, nil
+}
+
+// readFile parses a usage log, skipping lines it cannot parse, such as one
+// cut short by a crash.
+func readFile(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r Record
+		if json.Unmarshal(scanner.Bytes(), &r) == nil {
+			records = append(records, r)
+		}
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,71 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTracker_RecordPersistsAndTotals(t *testing.T) {
+	dir := t.TempDir()
+	tracker := NewTracker(dir)
+	now := time.Now()
+
+	records := []Record{
+		{Agent: "main", Channel: "telegram", Sender: "1", Model: "m", PromptTokens: 100, CompletionTokens: 20, Cost: 0.5},
+		{Agent: "main", Channel: "telegram", Sender: "2", Model: "m", PromptTokens: 10, CompletionTokens: 5, Cost: 0.25},
+		{Agent: "helper", Channel: "telegram", Sender: "1", Model: "m", TotalTokens: 7},
+	}
+	for _, r := range records {
+		if err := tracker.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := tracker.Spent(Filter{Agent: "main"}, now); got.Requests != 2 || got.TotalTokens != 135 || got.Cost != 0.75 {
+		t.Errorf("main spent %+v", got)
+	}
+	if got := tracker.Spent(Filter{Channel: "telegram", Sender: "1"}, now); got.TotalTokens != 127 {
+		t.Errorf("sender 1 spent %+v", got)
+	}
+
+	// A fresh tracker picks the month's totals up from the log.
+	if got := NewTracker(dir).Spent(Filter{}, now); got.Requests != 3 || got.TotalTokens != 142 {
+		t.Errorf("reloaded totals %+v", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, now.Format("2006-01")+".jsonl")); err != nil {
+		t.Errorf("monthly log missing: %v", err)
+	}
+}
+
+func TestLoad_FiltersByTime(t *testing.T) {
+	dir := t.TempDir()
+	tracker := NewTracker(dir)
+	old := time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local)
+	for _, r := range []Record{
+		{Time: old, Agent: "main", TotalTokens: 1},
+		{Time: old.AddDate(0, 1, 0), Agent: "main", TotalTokens: 2},
+		{Agent: "main", TotalTokens: 3},
+	} {
+		if err := tracker.Record(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A torn line is skipped.
+	f, _ := os.OpenFile(filepath.Join(dir, "2025-03.jsonl"), os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString(`{"agent":"ma`)
+	f.Close()
+
+	all, err := Load(dir, time.Time{}, time.Time{})
+	if err != nil || len(all) != 3 {
+		t.Fatalf("Load all = %d records, %v", len(all), err)
+	}
+	april, err := Load(dir, old.AddDate(0, 0, 22), old.AddDate(0, 1, 22))
+	if err != nil || len(april) != 1 || april[0].TotalTokens != 2 {
+		t.Errorf("Load range = %+v, %v", april, err)
+	}
+	if got := tracker.Spent(Filter{}, old); got.TotalTokens != 3 {
+		t.Errorf("current month totals include other months: %+v", got)
+	}
+}
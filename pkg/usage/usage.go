@@ -0,0 +1,84 @@
+// Package usage records the tokens each LLM request uses, prices them and
+// enforces usage budgets.
+package usage
+
+import (
+	"math"
+	"time"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+	"github.com/sipeed/picoclaw/pkg/providers"
+)
+
+// Kinds of requests usage is recorded for.
+const (
+	KindChat     = "chat"     // a turn of a conversation
+	KindSummary  = "summary"  // history summarization
+	KindSubagent = "subagent" // a task delegated to a subagent
+)
+
+// Record is the usage of one LLM request.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Agent   string    `json:"agent"`
+	Session string    `json:"session,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+	Sender  string    `json:"sender,omitempty"`
+	Model   string    `json:"model"`
+	Kind    string    `json:"kind"`
+
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost,omitempty"` // USD
+}
+
+// Totals sums the usage of a number of requests.
+type Totals struct {
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Cost             float64
+}
+
+// Add counts r into t.
+func (t *Totals) Add(r Record) {
+	t.Requests++
+	t.PromptTokens += r.PromptTokens
+	t.CompletionTokens += r.CompletionTokens
+	t.TotalTokens += r.TotalTokens
+	t.Cost += r.Cost
+}
+
+// PriceTable holds the prices of the configured models, by model_name and
+// by the model ID sent to the provider.
+type PriceTable map[string]config.ModelPrice
+
+// NewPriceTable collects the prices set in the model list.
+func NewPriceTable(models []config.ModelConfig) PriceTable {
+	prices := PriceTable{}
+	for _, m := range models {
+		if m.Price == nil {
+			continue
+		}
+		_, id := providers.ExtractProtocol(m.Model)
+		for _, key := range []string{m.ModelName, m.Model, id} {
+			if _, ok := prices[key]; !ok && key != "" {
+				prices[key] = *m.Price
+			}
+		}
+	}
+	return prices
+}
+
+// Cost prices a request to model; models without a price cost nothing.
+func (p PriceTable) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := p[model]
+	if !ok {
+		return 0
+	}
+	cost := (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6
+	// Round to a millionth of a dollar to keep the log readable.
+	return math.Round(cost*1e6) / 1e6
+}
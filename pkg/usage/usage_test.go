@@ -0,0 +1,23 @@
+package usage
+
+import (
+	"testing"
+
+	"github.com/sipeed/picoclaw/pkg/config"
+)
+
+func TestPriceTable_Cost(t *testing.T) {
+	prices := NewPriceTable([]config.ModelConfig{
+		{ModelName: "smart", Model: "openai/gpt-4o", Price: &config.ModelPrice{Input: 2.5, Output: 10}},
+		{ModelName: "free", Model: "ollama/llama3"},
+	})
+
+	for _, model := range []string{"smart", "openai/gpt-4o", "gpt-4o"} {
+		if got := prices.Cost(model, 1000, 500); got != 0.0075 {
+			t.Errorf("Cost(%q) = %v, want 0.0075", model, got)
+		}
+	}
+	if got := prices.Cost("llama3", 1000, 500); got != 0 {
+		t.Errorf("unpriced model cost %v", got)
+	}
+}
@@ -141,3 +141,67 @@ func DownloadFileSimple(url, filename string) string {
 		LoggerPrefix: "media",
 	})
 }
+
+// inboundMediaDir is where media handed from channels to the agent loop is kept
+// until the agent has encoded it into the LLM request.
+func inboundMediaDir() string {
+	return filepath.Join(os.TempDir(), "picoclaw_media", "inbound")
+}
+
+// RetainMediaFile gives a downloaded file a second name in the inbound media
+// directory so it outlives the channel's own temp-file cleanup. Channels
+// delete their downloads as soon as the handler returns, while the agent loop
+// consumes the message asynchronously. Remote URLs and paths that cannot be
+// retained are returned unchanged.
+func RetainMediaFile(path string) string {
+	if path == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	if _, err := os.Stat(path); err != nil {
+		return path
+	}
+
+	dir := inboundMediaDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return path
+	}
+	retained := filepath.Join(dir, uuid.New().String()[:8]+"_"+SanitizeFilename(path))
+
+	// Hard links are free on the same filesystem; fall back to a copy otherwise.
+	if err := os.Link(path, retained); err == nil {
+		return retained
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return path
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(retained, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return path
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(retained)
+		return path
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(retained)
+		return path
+	}
+	return retained
+}
+
+// ReleaseMediaFile removes a file previously returned by RetainMediaFile.
+// Paths outside the inbound media directory are left untouched.
+func ReleaseMediaFile(path string) {
+	if filepath.Dir(path) != inboundMediaDir() {
+		return
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		logger.DebugCF("media", "Failed to release media file", map[string]any{
+			"path":  path,
+			"error": err.Error(),
+		})
+	}
+}